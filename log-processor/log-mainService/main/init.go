@@ -2,16 +2,61 @@ package main
 
 import (
 	"LOGProcessor/log-mainService/services"
+	"LOGProcessor/log-mainService/tasks"
 	"LOGProcessor/shared/db"
 	"LOGProcessor/shared/types"
+	"encoding/json"
+	"fmt"
+	"net"
 	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/google/martian/log"
 	"github.com/hibiken/asynq"
 	_ "github.com/lib/pq"
+	"github.com/redis/go-redis/v9"
 )
 
 const (
-	baseUrl = "api/"
+	baseUrl    = "api/"
+	ADMIN_ROLE = "admin"
+
+	ROLE_API    = "api"
+	ROLE_WORKER = "worker"
+	ROLE_ALL    = "all"
+
+	DEFAULT_WORKER_CONCURRENCY = 4
+	DEFAULT_WORKER_QUEUES      = "high:3,low:1"
+	DEFAULT_SHUTDOWN_GRACE_SEC = 30
+
+	DEFAULT_FAIR_USER_CONCURRENCY = 2
+	DEFAULT_FAIR_USER_WEIGHT      = 1
+	FAIR_POLL_INTERVAL            = time.Second
+	FAIR_INFLIGHT_TTL             = 24 * time.Hour
+
+	DEFAULT_INGEST_MAX_BYTES     = 2 << 30
+	DEFAULT_INGEST_MAX_REDIRECTS = 3
+	SOURCE_SYNC_INTERVAL         = time.Minute
+
+	DEFAULT_SYSLOG_MAX_MESSAGE_BYTES = 64 * 1024
+
+	DEFAULT_FORWARD_MAX_MESSAGE_BYTES = 16 << 20
+	MIN_FORWARD_MAX_MESSAGE_BYTES     = 64 * 1024
+
+	DEFAULT_LOG_MAX_LINE_BYTES  = 1 << 20
+	MIN_LOG_MAX_LINE_BYTES      = 4 * 1024
+	DEFAULT_LOG_SPILL_MAX_BYTES = 64 << 20
+
+	DEFAULT_REDACTION_DETECTORS = "email,jwt,bearer_token,aws_access_key,aws_secret_key,credit_card"
+
+	DEFAULT_GEOIP_RELOAD_INTERVAL_SEC  = 60
+	DEFAULT_THREAT_RELOAD_INTERVAL_SEC = 60
+
+	DEFAULT_ALERT_STREAM_WINDOW_SEC    = 60
+	DEFAULT_ALERT_DELIVERY_TIMEOUT_SEC = 10
 )
 
 var apiRoutes = types.ApiRoutes{
@@ -25,7 +70,7 @@ var apiRoutes = types.ApiRoutes{
 		Method:    "GET",
 		Pattern:   "/queue-status",
 		Handler:   services.HandleGetQueueCurrentStatus,
-		IsAuthReq: false,
+		IsAuthReq: true,
 	},
 	{
 		Method:    "GET",
@@ -39,12 +84,320 @@ var apiRoutes = types.ApiRoutes{
 		Handler:   services.HandleGetStatsByJobId,
 		IsAuthReq: true,
 	},
+	{
+		Method:    "GET",
+		Pattern:   "/stats/:jobId/retries",
+		Handler:   services.HandleGetJobRetryHistory,
+		IsAuthReq: true,
+	},
+	{
+		Method:    "GET",
+		Pattern:   "/stats/:jobId/latency",
+		Handler:   services.HandleGetJobLatencyStats,
+		IsAuthReq: true,
+	},
+	{
+		Method:    "GET",
+		Pattern:   "/stats/:jobId/rejected",
+		Handler:   services.HandleGetJobRejectedLines,
+		IsAuthReq: true,
+	},
+	{
+		Method:    "GET",
+		Pattern:   "/stats/:jobId/geo",
+		Handler:   services.HandleGetJobGeoStats,
+		IsAuthReq: true,
+	},
 	{
 		Method:    "GET",
 		Pattern:   "/live-stats",
 		Handler:   services.WebSocketHandler,
 		IsAuthReq: true,
 	},
+	{
+		Method:    "POST",
+		Pattern:   "/upload-url",
+		Handler:   services.HandleUploadFromUrl,
+		IsAuthReq: true,
+	},
+	{
+		Method:    "POST",
+		Pattern:   "/ingest",
+		Handler:   services.HandleStreamIngest,
+		IsAuthReq: true,
+	},
+	{
+		Method:    "POST",
+		Pattern:   "/v1/logs",
+		Handler:   services.HandleOtlpLogs,
+		IsAuthReq: true,
+	},
+	{
+		Method:    "POST",
+		Pattern:   "/patterns",
+		Handler:   services.HandleCreateGrokPattern,
+		IsAuthReq: true,
+	},
+	{
+		Method:    "GET",
+		Pattern:   "/patterns",
+		Handler:   services.HandleGetGrokPatterns,
+		IsAuthReq: true,
+	},
+	{
+		Method:    "DELETE",
+		Pattern:   "/patterns/:patternId",
+		Handler:   services.HandleDeleteGrokPattern,
+		IsAuthReq: true,
+	},
+	{
+		Method:    "POST",
+		Pattern:   "/patterns/test",
+		Handler:   services.HandleTestGrokPattern,
+		IsAuthReq: true,
+	},
+	{
+		Method:    "GET",
+		Pattern:   "/levels",
+		Handler:   services.HandleGetLogLevels,
+		IsAuthReq: true,
+	},
+	{
+		Method:    "PUT",
+		Pattern:   "/levels/mappings",
+		Handler:   services.HandlePutLevelMapping,
+		IsAuthReq: true,
+	},
+	{
+		Method:    "DELETE",
+		Pattern:   "/levels/mappings/:rawLevel",
+		Handler:   services.HandleDeleteLevelMapping,
+		IsAuthReq: true,
+	},
+	{
+		Method:    "GET",
+		Pattern:   "/redaction/rules",
+		Handler:   services.HandleGetRedactionRules,
+		IsAuthReq: true,
+	},
+	{
+		Method:    "PUT",
+		Pattern:   "/redaction/rules",
+		Handler:   services.HandlePutRedactionRule,
+		IsAuthReq: true,
+	},
+	{
+		Method:    "DELETE",
+		Pattern:   "/redaction/rules/:name",
+		Handler:   services.HandleDeleteRedactionRule,
+		IsAuthReq: true,
+	},
+	{
+		Method:    "POST",
+		Pattern:   "/redaction/test",
+		Handler:   services.HandleTestRedaction,
+		IsAuthReq: true,
+	},
+	{
+		Method:    "GET",
+		Pattern:   "/anomalies",
+		Handler:   services.HandleGetAnomalies,
+		IsAuthReq: true,
+	},
+	{
+		Method:    "POST",
+		Pattern:   "/alerts/channels",
+		Handler:   services.HandleCreateAlertChannel,
+		IsAuthReq: true,
+	},
+	{
+		Method:    "GET",
+		Pattern:   "/alerts/channels",
+		Handler:   services.HandleGetAlertChannels,
+		IsAuthReq: true,
+	},
+	{
+		Method:    "DELETE",
+		Pattern:   "/alerts/channels/:channelId",
+		Handler:   services.HandleDeleteAlertChannel,
+		IsAuthReq: true,
+	},
+	{
+		Method:    "POST",
+		Pattern:   "/alerts/rules",
+		Handler:   services.HandleCreateAlertRule,
+		IsAuthReq: true,
+	},
+	{
+		Method:    "GET",
+		Pattern:   "/alerts/rules",
+		Handler:   services.HandleGetAlertRules,
+		IsAuthReq: true,
+	},
+	{
+		Method:    "PUT",
+		Pattern:   "/alerts/rules/:ruleId",
+		Handler:   services.HandleUpdateAlertRule,
+		IsAuthReq: true,
+	},
+	{
+		Method:    "DELETE",
+		Pattern:   "/alerts/rules/:ruleId",
+		Handler:   services.HandleDeleteAlertRule,
+		IsAuthReq: true,
+	},
+	{
+		Method:    "GET",
+		Pattern:   "/alerts/history",
+		Handler:   services.HandleGetAlertHistory,
+		IsAuthReq: true,
+	},
+	{
+		Method:    "GET",
+		Pattern:   "/cidr-sets",
+		Handler:   services.HandleGetCidrSets,
+		IsAuthReq: true,
+	},
+	{
+		Method:    "PUT",
+		Pattern:   "/cidr-sets",
+		Handler:   services.HandlePutCidrSet,
+		IsAuthReq: true,
+	},
+	{
+		Method:    "DELETE",
+		Pattern:   "/cidr-sets/:name",
+		Handler:   services.HandleDeleteCidrSet,
+		IsAuthReq: true,
+	},
+	{
+		Method:    "POST",
+		Pattern:   "/sources",
+		Handler:   services.HandleCreateIngestSource,
+		IsAuthReq: true,
+	},
+	{
+		Method:    "GET",
+		Pattern:   "/sources",
+		Handler:   services.HandleGetIngestSources,
+		IsAuthReq: true,
+	},
+	{
+		Method:    "DELETE",
+		Pattern:   "/sources/:sourceId",
+		Handler:   services.HandleDeleteIngestSource,
+		IsAuthReq: true,
+	},
+	{
+		Method:    "POST",
+		Pattern:   "/sources/:sourceId/run",
+		Handler:   services.HandleRunIngestSource,
+		IsAuthReq: true,
+	},
+	{
+		Method:    "GET",
+		Pattern:   "/sources/:sourceId/objects",
+		Handler:   services.HandleGetIngestSourceObjects,
+		IsAuthReq: true,
+	},
+	{
+		Method:     "GET",
+		Pattern:    "/admin/queues",
+		Handler:    services.HandleListQueues,
+		IsAuthReq:  true,
+		IsAdminReq: true,
+	},
+	{
+		Method:     "POST",
+		Pattern:    "/admin/queues/:queue/pause",
+		Handler:    services.HandlePauseQueue,
+		IsAuthReq:  true,
+		IsAdminReq: true,
+	},
+	{
+		Method:     "POST",
+		Pattern:    "/admin/queues/:queue/resume",
+		Handler:    services.HandleResumeQueue,
+		IsAuthReq:  true,
+		IsAdminReq: true,
+	},
+	{
+		Method:     "GET",
+		Pattern:    "/admin/queues/:queue/tasks",
+		Handler:    services.HandleListQueueTasks,
+		IsAuthReq:  true,
+		IsAdminReq: true,
+	},
+	{
+		Method:     "DELETE",
+		Pattern:    "/admin/queues/:queue/tasks/:taskId",
+		Handler:    services.HandleDeleteQueueTask,
+		IsAuthReq:  true,
+		IsAdminReq: true,
+	},
+	{
+		Method:     "POST",
+		Pattern:    "/admin/queues/:queue/tasks/:taskId/run",
+		Handler:    services.HandleRunQueueTask,
+		IsAuthReq:  true,
+		IsAdminReq: true,
+	},
+	{
+		Method:     "GET",
+		Pattern:    "/admin/queues/:queue/history",
+		Handler:    services.HandleGetQueueHistory,
+		IsAuthReq:  true,
+		IsAdminReq: true,
+	},
+	{
+		Method:     "GET",
+		Pattern:    "/admin/blocklists",
+		Handler:    services.HandleGetBlocklists,
+		IsAuthReq:  true,
+		IsAdminReq: true,
+	},
+	{
+		Method:     "PUT",
+		Pattern:    "/admin/blocklists/:name",
+		Handler:    services.HandlePutBlocklist,
+		IsAuthReq:  true,
+		IsAdminReq: true,
+	},
+	{
+		Method:     "DELETE",
+		Pattern:    "/admin/blocklists/:name",
+		Handler:    services.HandleDeleteBlocklist,
+		IsAuthReq:  true,
+		IsAdminReq: true,
+	},
+	{
+		Method:     "GET",
+		Pattern:    "/admin/tenants",
+		Handler:    services.HandleListTenants,
+		IsAuthReq:  true,
+		IsAdminReq: true,
+	},
+	{
+		Method:     "GET",
+		Pattern:    "/admin/tenants/:userId/limits",
+		Handler:    services.HandleGetTenantLimits,
+		IsAuthReq:  true,
+		IsAdminReq: true,
+	},
+	{
+		Method:     "PUT",
+		Pattern:    "/admin/tenants/:userId/limits",
+		Handler:    services.HandleSetTenantLimits,
+		IsAuthReq:  true,
+		IsAdminReq: true,
+	},
+	{
+		Method:     "DELETE",
+		Pattern:    "/admin/tenants/:userId/limits",
+		Handler:    services.HandleDeleteTenantLimits,
+		IsAuthReq:  true,
+		IsAdminReq: true,
+	},
 }
 
 func init() {
@@ -52,12 +405,26 @@ func init() {
 		err error
 	)
 
+	types.ExitChan = make(chan error, 1)
 	loadEnvVariables()
+	initWorkerOptions()
+	initFairSchedulingOptions()
+	initTaskTiers()
+	validateWorkerQueues()
+	initIngestOptions()
+	initSyslogOptions()
+	initForwardOptions()
+	initProcessingOptions()
+	initRedactionOptions()
+	initGeoIPOptions()
+	initThreatIntelOptions()
+	initAlertOptions()
 	err = db.InitDbConnection()
 	if err != nil {
 		return
 	}
 	createAsynqRedisClient()
+	createRedisClient()
 }
 
 /******************************************************************************
@@ -81,6 +448,44 @@ func loadEnvVariables() {
 	types.CmnGlblCfg.DB_HOST = getEnv("DB_HOST", "")
 	types.CmnGlblCfg.REDIS_ADDR = getEnv("REDIS_ADDR", "")
 	types.CmnGlblCfg.KEYWORD_CONFIG = getEnv("KEYWORD_CONFIG", "")
+	types.CmnGlblCfg.WORKER_CONCURRENCY = getEnv("WORKER_CONCURRENCY", strconv.Itoa(DEFAULT_WORKER_CONCURRENCY))
+	types.CmnGlblCfg.WORKER_QUEUES = getEnv("WORKER_QUEUES", DEFAULT_WORKER_QUEUES)
+	types.CmnGlblCfg.WORKER_STRICT_PRIORITY = getEnv("WORKER_STRICT_PRIORITY", "false")
+	types.CmnGlblCfg.SHUTDOWN_GRACE_PERIOD_SEC = getEnv("SHUTDOWN_GRACE_PERIOD_SEC", strconv.Itoa(DEFAULT_SHUTDOWN_GRACE_SEC))
+	types.CmnGlblCfg.FAIR_DEFAULT_USER_CONCURRENCY = getEnv("FAIR_DEFAULT_USER_CONCURRENCY", strconv.Itoa(DEFAULT_FAIR_USER_CONCURRENCY))
+	types.CmnGlblCfg.FAIR_DEFAULT_USER_WEIGHT = getEnv("FAIR_DEFAULT_USER_WEIGHT", strconv.Itoa(DEFAULT_FAIR_USER_WEIGHT))
+	types.CmnGlblCfg.TASK_TIERS = getEnv("TASK_TIERS", "")
+	types.CmnGlblCfg.SOURCE_CREDENTIALS_KEY = getEnv("SOURCE_CREDENTIALS_KEY", "")
+	types.CmnGlblCfg.INGEST_MAX_BYTES = getEnv("INGEST_MAX_BYTES", strconv.Itoa(DEFAULT_INGEST_MAX_BYTES))
+	types.CmnGlblCfg.INGEST_MAX_REDIRECTS = getEnv("INGEST_MAX_REDIRECTS", strconv.Itoa(DEFAULT_INGEST_MAX_REDIRECTS))
+	types.CmnGlblCfg.INGEST_ALLOWED_CIDRS = getEnv("INGEST_ALLOWED_CIDRS", "")
+	types.CmnGlblCfg.SYSLOG_USER_ID = getEnv("SYSLOG_USER_ID", "")
+	types.CmnGlblCfg.SYSLOG_UDP_ADDR = getEnv("SYSLOG_UDP_ADDR", "")
+	types.CmnGlblCfg.SYSLOG_TCP_ADDR = getEnv("SYSLOG_TCP_ADDR", "")
+	types.CmnGlblCfg.SYSLOG_TLS_ADDR = getEnv("SYSLOG_TLS_ADDR", "")
+	types.CmnGlblCfg.SYSLOG_TLS_CERT_FILE = getEnv("SYSLOG_TLS_CERT_FILE", "")
+	types.CmnGlblCfg.SYSLOG_TLS_KEY_FILE = getEnv("SYSLOG_TLS_KEY_FILE", "")
+	types.CmnGlblCfg.SYSLOG_MAX_MESSAGE_BYTES = getEnv("SYSLOG_MAX_MESSAGE_BYTES", strconv.Itoa(DEFAULT_SYSLOG_MAX_MESSAGE_BYTES))
+	types.CmnGlblCfg.FORWARD_ADDR = getEnv("FORWARD_ADDR", "")
+	types.CmnGlblCfg.FORWARD_USER_ID = getEnv("FORWARD_USER_ID", "")
+	types.CmnGlblCfg.FORWARD_SHARED_KEY = getEnv("FORWARD_SHARED_KEY", "")
+	types.CmnGlblCfg.FORWARD_MAX_MESSAGE_BYTES = getEnv("FORWARD_MAX_MESSAGE_BYTES", strconv.Itoa(DEFAULT_FORWARD_MAX_MESSAGE_BYTES))
+	types.CmnGlblCfg.LOG_MAX_LINE_BYTES = getEnv("LOG_MAX_LINE_BYTES", strconv.Itoa(DEFAULT_LOG_MAX_LINE_BYTES))
+	types.CmnGlblCfg.LOG_OVERSIZE_MODE = getEnv("LOG_OVERSIZE_MODE", services.LINE_OVERSIZE_TRUNCATE)
+	types.CmnGlblCfg.LOG_SPILL_MAX_BYTES = getEnv("LOG_SPILL_MAX_BYTES", strconv.Itoa(DEFAULT_LOG_SPILL_MAX_BYTES))
+	types.CmnGlblCfg.REDACTION_DETECTORS = getEnv("REDACTION_DETECTORS", DEFAULT_REDACTION_DETECTORS)
+	types.CmnGlblCfg.REDACTION_ACTION = getEnv("REDACTION_ACTION", services.REDACT_ACTION_MASK)
+	types.CmnGlblCfg.REDACTION_HASH_KEY = getEnv("REDACTION_HASH_KEY", "")
+	types.CmnGlblCfg.GEOIP_CITY_DB = getEnv("GEOIP_CITY_DB", "")
+	types.CmnGlblCfg.GEOIP_ASN_DB = getEnv("GEOIP_ASN_DB", "")
+	types.CmnGlblCfg.GEOIP_RELOAD_INTERVAL_SEC = getEnv("GEOIP_RELOAD_INTERVAL_SEC", strconv.Itoa(DEFAULT_GEOIP_RELOAD_INTERVAL_SEC))
+	types.CmnGlblCfg.THREAT_RELOAD_INTERVAL_SEC = getEnv("THREAT_RELOAD_INTERVAL_SEC", strconv.Itoa(DEFAULT_THREAT_RELOAD_INTERVAL_SEC))
+	types.CmnGlblCfg.ALERT_STREAM_WINDOW_SEC = getEnv("ALERT_STREAM_WINDOW_SEC", strconv.Itoa(DEFAULT_ALERT_STREAM_WINDOW_SEC))
+	types.CmnGlblCfg.ALERT_DELIVERY_TIMEOUT_SEC = getEnv("ALERT_DELIVERY_TIMEOUT_SEC", strconv.Itoa(DEFAULT_ALERT_DELIVERY_TIMEOUT_SEC))
+	types.CmnGlblCfg.SMTP_ADDR = getEnv("SMTP_ADDR", "")
+	types.CmnGlblCfg.SMTP_USERNAME = getEnv("SMTP_USERNAME", "")
+	types.CmnGlblCfg.SMTP_PASSWORD = getEnv("SMTP_PASSWORD", "")
+	types.CmnGlblCfg.SMTP_FROM = getEnv("SMTP_FROM", "")
 }
 
 func getEnv(key, defaultValue string) string {
@@ -104,6 +509,17 @@ func createAsynqRedisClient() {
 	}
 }
 
+/******************************************************************************
+* FUNCTION:        createRedisClient
+* DESCRIPTION:     Function to create the plain redis client used by the fair
+*                  scheduler
+* INPUT:           None
+* RETURNS:         VOID
+******************************************************************************/
+func createRedisClient() {
+	types.Redis.RedisConn = redis.NewClient(&redis.Options{Addr: types.CmnGlblCfg.REDIS_ADDR})
+}
+
 /******************************************************************************
 * FUNCTION:        loadEnvVariables
 * DESCRIPTION:     Function to load env variables and assign to global variables
@@ -116,3 +532,411 @@ func InitInspector() {
 		os.Exit(0)
 	}
 }
+
+/******************************************************************************
+* FUNCTION:        initWorkerOptions
+* DESCRIPTION:     Function to build the asynq worker options from the env
+*                  variables. Invalid values fall back to the defaults
+* INPUT:           None
+* RETURNS:         VOID
+******************************************************************************/
+func initWorkerOptions() {
+	concurrency, err := strconv.Atoi(types.CmnGlblCfg.WORKER_CONCURRENCY)
+	if err != nil || concurrency <= 0 {
+		log.Errorf("invalid WORKER_CONCURRENCY %q; using %d", types.CmnGlblCfg.WORKER_CONCURRENCY, DEFAULT_WORKER_CONCURRENCY)
+		concurrency = DEFAULT_WORKER_CONCURRENCY
+	}
+
+	queues, err := parseQueuePriorities(types.CmnGlblCfg.WORKER_QUEUES)
+	if err != nil {
+		log.Errorf("invalid WORKER_QUEUES %q; err: %v; using %s", types.CmnGlblCfg.WORKER_QUEUES, err, DEFAULT_WORKER_QUEUES)
+		queues, _ = parseQueuePriorities(DEFAULT_WORKER_QUEUES)
+	}
+
+	strictPriority, err := strconv.ParseBool(types.CmnGlblCfg.WORKER_STRICT_PRIORITY)
+	if err != nil {
+		log.Errorf("invalid WORKER_STRICT_PRIORITY %q; using false", types.CmnGlblCfg.WORKER_STRICT_PRIORITY)
+		strictPriority = false
+	}
+
+	gracePeriodSec, err := strconv.Atoi(types.CmnGlblCfg.SHUTDOWN_GRACE_PERIOD_SEC)
+	if err != nil || gracePeriodSec < 0 {
+		log.Errorf("invalid SHUTDOWN_GRACE_PERIOD_SEC %q; using %d", types.CmnGlblCfg.SHUTDOWN_GRACE_PERIOD_SEC, DEFAULT_SHUTDOWN_GRACE_SEC)
+		gracePeriodSec = DEFAULT_SHUTDOWN_GRACE_SEC
+	}
+
+	types.WorkerCfg = types.WorkerConfig{
+		Concurrency:         concurrency,
+		Queues:              queues,
+		StrictPriority:      strictPriority,
+		ShutdownGracePeriod: time.Duration(gracePeriodSec) * time.Second,
+	}
+}
+
+/******************************************************************************
+* FUNCTION:        parseQueuePriorities
+* DESCRIPTION:     Function to parse queue priorities of the form
+*                  "high:3,low:1" into a map of queue name to priority
+* INPUT:           queue priority string
+* RETURNS:         map[string]int, error
+******************************************************************************/
+func parseQueuePriorities(input string) (map[string]int, error) {
+	queues := make(map[string]int)
+
+	for _, entry := range strings.Split(input, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		parts := strings.SplitN(entry, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("expected queue:priority, got %q", entry)
+		}
+
+		priority, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+		if err != nil || priority <= 0 {
+			return nil, fmt.Errorf("invalid priority for queue %q", parts[0])
+		}
+		queues[strings.TrimSpace(parts[0])] = priority
+	}
+
+	if len(queues) == 0 {
+		return nil, fmt.Errorf("no queues configured")
+	}
+
+	return queues, nil
+}
+
+/******************************************************************************
+* FUNCTION:        initFairSchedulingOptions
+* DESCRIPTION:     Function to build the default per-user scheduling limits
+*                  from the env variables. Invalid values fall back to the
+*                  defaults
+* INPUT:           None
+* RETURNS:         VOID
+******************************************************************************/
+func initFairSchedulingOptions() {
+	concurrency, err := strconv.Atoi(types.CmnGlblCfg.FAIR_DEFAULT_USER_CONCURRENCY)
+	if err != nil || concurrency <= 0 {
+		log.Errorf("invalid FAIR_DEFAULT_USER_CONCURRENCY %q; using %d", types.CmnGlblCfg.FAIR_DEFAULT_USER_CONCURRENCY, DEFAULT_FAIR_USER_CONCURRENCY)
+		concurrency = DEFAULT_FAIR_USER_CONCURRENCY
+	}
+
+	weight, err := strconv.Atoi(types.CmnGlblCfg.FAIR_DEFAULT_USER_WEIGHT)
+	if err != nil || weight <= 0 {
+		log.Errorf("invalid FAIR_DEFAULT_USER_WEIGHT %q; using %d", types.CmnGlblCfg.FAIR_DEFAULT_USER_WEIGHT, DEFAULT_FAIR_USER_WEIGHT)
+		weight = DEFAULT_FAIR_USER_WEIGHT
+	}
+
+	types.FairSchedCfg = types.FairSchedulingConfig{
+		DefaultMaxConcurrency: concurrency,
+		DefaultWeight:         weight,
+		PollInterval:          FAIR_POLL_INTERVAL,
+		InFlightTTL:           FAIR_INFLIGHT_TTL,
+	}
+}
+
+/******************************************************************************
+* FUNCTION:        initTaskTiers
+* DESCRIPTION:     Function to load the per file size task tiers from the
+*                  TASK_TIERS env variable (JSON array). Falls back to the
+*                  default tiers when unset or invalid
+* INPUT:           None
+* RETURNS:         VOID
+******************************************************************************/
+func initTaskTiers() {
+	types.TaskTiers = tasks.DefaultTaskTiers
+	if types.CmnGlblCfg.TASK_TIERS == "" {
+		return
+	}
+
+	var tiers []types.TaskTier
+	err := json.Unmarshal([]byte(types.CmnGlblCfg.TASK_TIERS), &tiers)
+	if err != nil || len(tiers) == 0 {
+		log.Errorf("invalid TASK_TIERS; err: %v; using default tiers", err)
+		return
+	}
+
+	for _, tier := range tiers {
+		if tier.Queue == "" || tier.MaxRetry < 0 || tier.TimeoutSec < 0 || tier.DeadlineSec < 0 {
+			log.Errorf("invalid task tier %q in TASK_TIERS; using default tiers", tier.Name)
+			return
+		}
+	}
+
+	types.TaskTiers = tiers
+}
+
+/******************************************************************************
+* FUNCTION:        validateWorkerQueues
+* DESCRIPTION:     Function to check WORKER_QUEUES against the queues tasks
+*                  are enqueued to. A name no task uses is most likely a
+*                  typo and falls back to the defaults, a queue in use that
+*                  is not served is reported as its tasks would never run
+* INPUT:           None
+* RETURNS:         VOID
+******************************************************************************/
+func validateWorkerQueues() {
+	inUse := tasks.QueuesInUse(types.TaskTiers)
+
+	for name := range types.WorkerCfg.Queues {
+		if _, ok := inUse[name]; !ok {
+			log.Errorf("invalid WORKER_QUEUES %q; err: unknown queue %q; using %s", types.CmnGlblCfg.WORKER_QUEUES, name, DEFAULT_WORKER_QUEUES)
+			types.WorkerCfg.Queues, _ = parseQueuePriorities(DEFAULT_WORKER_QUEUES)
+			break
+		}
+	}
+
+	names := make([]string, 0, len(inUse))
+	for name := range inUse {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, ok := types.WorkerCfg.Queues[name]; !ok {
+			log.Errorf("queue %q of %s is not in WORKER_QUEUES; its tasks are not processed by this worker", name, strings.Join(inUse[name], ", "))
+		}
+	}
+}
+
+/******************************************************************************
+* FUNCTION:        initIngestOptions
+* DESCRIPTION:     Function to build the remote ingestion options from the
+*                  env variables. Invalid values fall back to the defaults
+* INPUT:           None
+* RETURNS:         VOID
+******************************************************************************/
+func initIngestOptions() {
+	maxBytes, err := strconv.ParseInt(types.CmnGlblCfg.INGEST_MAX_BYTES, 10, 64)
+	if err != nil || maxBytes <= 0 {
+		log.Errorf("invalid INGEST_MAX_BYTES %q; using %d", types.CmnGlblCfg.INGEST_MAX_BYTES, DEFAULT_INGEST_MAX_BYTES)
+		maxBytes = DEFAULT_INGEST_MAX_BYTES
+	}
+
+	maxRedirects, err := strconv.Atoi(types.CmnGlblCfg.INGEST_MAX_REDIRECTS)
+	if err != nil || maxRedirects < 0 {
+		log.Errorf("invalid INGEST_MAX_REDIRECTS %q; using %d", types.CmnGlblCfg.INGEST_MAX_REDIRECTS, DEFAULT_INGEST_MAX_REDIRECTS)
+		maxRedirects = DEFAULT_INGEST_MAX_REDIRECTS
+	}
+
+	var allowedNets []*net.IPNet
+	for _, cidr := range strings.Split(types.CmnGlblCfg.INGEST_ALLOWED_CIDRS, ",") {
+		cidr = strings.TrimSpace(cidr)
+		if cidr == "" {
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			log.Errorf("invalid cidr %q in INGEST_ALLOWED_CIDRS; skipping", cidr)
+			continue
+		}
+		allowedNets = append(allowedNets, ipNet)
+	}
+
+	types.IngestCfg = types.IngestConfig{
+		MaxBytes:     maxBytes,
+		MaxRedirects: maxRedirects,
+		AllowedNets:  allowedNets,
+	}
+}
+
+/******************************************************************************
+* FUNCTION:        initSyslogOptions
+* DESCRIPTION:     Function to build the syslog listener options from the
+*                  env variables. The listeners only run when an address
+*                  is set
+* INPUT:           None
+* RETURNS:         VOID
+******************************************************************************/
+func initSyslogOptions() {
+	maxMessageBytes, err := strconv.Atoi(types.CmnGlblCfg.SYSLOG_MAX_MESSAGE_BYTES)
+	if err != nil || maxMessageBytes < 480 {
+		log.Errorf("invalid SYSLOG_MAX_MESSAGE_BYTES %q; using %d", types.CmnGlblCfg.SYSLOG_MAX_MESSAGE_BYTES, DEFAULT_SYSLOG_MAX_MESSAGE_BYTES)
+		maxMessageBytes = DEFAULT_SYSLOG_MAX_MESSAGE_BYTES
+	}
+
+	types.SyslogCfg = types.SyslogConfig{
+		UserId:          types.CmnGlblCfg.SYSLOG_USER_ID,
+		UDPAddr:         types.CmnGlblCfg.SYSLOG_UDP_ADDR,
+		TCPAddr:         types.CmnGlblCfg.SYSLOG_TCP_ADDR,
+		TLSAddr:         types.CmnGlblCfg.SYSLOG_TLS_ADDR,
+		TLSCertFile:     types.CmnGlblCfg.SYSLOG_TLS_CERT_FILE,
+		TLSKeyFile:      types.CmnGlblCfg.SYSLOG_TLS_KEY_FILE,
+		MaxMessageBytes: maxMessageBytes,
+	}
+}
+
+/******************************************************************************
+* FUNCTION:        initProcessingOptions
+* DESCRIPTION:     Function to build the line reading options of processed
+*                  files from the env variables
+* INPUT:           None
+* RETURNS:         VOID
+******************************************************************************/
+func initProcessingOptions() {
+	maxLineBytes, err := strconv.Atoi(types.CmnGlblCfg.LOG_MAX_LINE_BYTES)
+	if err != nil || maxLineBytes < MIN_LOG_MAX_LINE_BYTES {
+		log.Errorf("invalid LOG_MAX_LINE_BYTES %q; using %d", types.CmnGlblCfg.LOG_MAX_LINE_BYTES, DEFAULT_LOG_MAX_LINE_BYTES)
+		maxLineBytes = DEFAULT_LOG_MAX_LINE_BYTES
+	}
+
+	oversizeMode := strings.ToLower(types.CmnGlblCfg.LOG_OVERSIZE_MODE)
+	if oversizeMode != services.LINE_OVERSIZE_TRUNCATE && oversizeMode != services.LINE_OVERSIZE_SPILL {
+		log.Errorf("invalid LOG_OVERSIZE_MODE %q; using %s", types.CmnGlblCfg.LOG_OVERSIZE_MODE, services.LINE_OVERSIZE_TRUNCATE)
+		oversizeMode = services.LINE_OVERSIZE_TRUNCATE
+	}
+
+	spillMaxBytes, err := strconv.ParseInt(types.CmnGlblCfg.LOG_SPILL_MAX_BYTES, 10, 64)
+	if err != nil || spillMaxBytes < 0 {
+		log.Errorf("invalid LOG_SPILL_MAX_BYTES %q; using %d", types.CmnGlblCfg.LOG_SPILL_MAX_BYTES, DEFAULT_LOG_SPILL_MAX_BYTES)
+		spillMaxBytes = DEFAULT_LOG_SPILL_MAX_BYTES
+	}
+
+	types.ProcessingCfg = types.ProcessingConfig{
+		MaxLineBytes:  maxLineBytes,
+		OversizeMode:  oversizeMode,
+		SpillMaxBytes: spillMaxBytes,
+	}
+}
+
+/******************************************************************************
+* FUNCTION:        initRedactionOptions
+* DESCRIPTION:     Function to build the default redaction of every user
+*                  from the env variables. Unknown detectors are skipped, the
+*                  hash action needs REDACTION_HASH_KEY
+* INPUT:           None
+* RETURNS:         VOID
+******************************************************************************/
+func initRedactionOptions() {
+	var detectors []string
+	for _, name := range strings.Split(types.CmnGlblCfg.REDACTION_DETECTORS, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if !services.IsRedactionDetector(name) {
+			log.Errorf("unknown redaction detector %q; skipped", name)
+			continue
+		}
+		detectors = append(detectors, name)
+	}
+
+	action := strings.ToLower(types.CmnGlblCfg.REDACTION_ACTION)
+	switch action {
+	case services.REDACT_ACTION_MASK, services.REDACT_ACTION_DROP:
+	case services.REDACT_ACTION_HASH:
+		if types.CmnGlblCfg.REDACTION_HASH_KEY == "" {
+			log.Errorf("REDACTION_ACTION hash needs REDACTION_HASH_KEY; using %s", services.REDACT_ACTION_MASK)
+			action = services.REDACT_ACTION_MASK
+		}
+	default:
+		log.Errorf("invalid REDACTION_ACTION %q; using %s", types.CmnGlblCfg.REDACTION_ACTION, services.REDACT_ACTION_MASK)
+		action = services.REDACT_ACTION_MASK
+	}
+
+	types.RedactionCfg = types.RedactionConfig{
+		Detectors: detectors,
+		Action:    action,
+		HashKey:   types.CmnGlblCfg.REDACTION_HASH_KEY,
+	}
+}
+
+/******************************************************************************
+* FUNCTION:        initGeoIPOptions
+* DESCRIPTION:     Function to build the GeoIP enrichment options from the
+*                  env variables. Enrichment is off without a database path
+* INPUT:           None
+* RETURNS:         VOID
+******************************************************************************/
+func initGeoIPOptions() {
+	reloadSec, err := strconv.Atoi(types.CmnGlblCfg.GEOIP_RELOAD_INTERVAL_SEC)
+	if err != nil || reloadSec <= 0 {
+		log.Errorf("invalid GEOIP_RELOAD_INTERVAL_SEC %q; using %d", types.CmnGlblCfg.GEOIP_RELOAD_INTERVAL_SEC, DEFAULT_GEOIP_RELOAD_INTERVAL_SEC)
+		reloadSec = DEFAULT_GEOIP_RELOAD_INTERVAL_SEC
+	}
+
+	types.GeoIPCfg = types.GeoIPConfig{
+		CityDBPath:     types.CmnGlblCfg.GEOIP_CITY_DB,
+		ASNDBPath:      types.CmnGlblCfg.GEOIP_ASN_DB,
+		ReloadInterval: time.Duration(reloadSec) * time.Second,
+	}
+}
+
+/******************************************************************************
+* FUNCTION:        initForwardOptions
+* DESCRIPTION:     Function to build the fluent forward listener options
+*                  from the env variables. The listener only runs when an
+*                  address is set
+* INPUT:           None
+* RETURNS:         VOID
+******************************************************************************/
+func initForwardOptions() {
+	maxMessageBytes, err := strconv.Atoi(types.CmnGlblCfg.FORWARD_MAX_MESSAGE_BYTES)
+	if err != nil || maxMessageBytes < MIN_FORWARD_MAX_MESSAGE_BYTES {
+		log.Errorf("invalid FORWARD_MAX_MESSAGE_BYTES %q; using %d", types.CmnGlblCfg.FORWARD_MAX_MESSAGE_BYTES, DEFAULT_FORWARD_MAX_MESSAGE_BYTES)
+		maxMessageBytes = DEFAULT_FORWARD_MAX_MESSAGE_BYTES
+	}
+
+	types.ForwardCfg = types.ForwardConfig{
+		UserId:          types.CmnGlblCfg.FORWARD_USER_ID,
+		Addr:            types.CmnGlblCfg.FORWARD_ADDR,
+		SharedKey:       types.CmnGlblCfg.FORWARD_SHARED_KEY,
+		MaxMessageBytes: maxMessageBytes,
+	}
+}
+
+/******************************************************************************
+* FUNCTION:        initThreatIntelOptions
+* DESCRIPTION:     Function to build the threat intel options from the env
+*                  variables
+* INPUT:           None
+* RETURNS:         VOID
+******************************************************************************/
+func initThreatIntelOptions() {
+	reloadSec, err := strconv.Atoi(types.CmnGlblCfg.THREAT_RELOAD_INTERVAL_SEC)
+	if err != nil || reloadSec <= 0 {
+		log.Errorf("invalid THREAT_RELOAD_INTERVAL_SEC %q; using %d", types.CmnGlblCfg.THREAT_RELOAD_INTERVAL_SEC, DEFAULT_THREAT_RELOAD_INTERVAL_SEC)
+		reloadSec = DEFAULT_THREAT_RELOAD_INTERVAL_SEC
+	}
+
+	types.ThreatIntelCfg = types.ThreatIntelConfig{
+		ReloadInterval: time.Duration(reloadSec) * time.Second,
+	}
+}
+
+/******************************************************************************
+* FUNCTION:        initAlertOptions
+* DESCRIPTION:     Function to build the alert options from the env
+*                  variables. Email channels need SMTP_ADDR and SMTP_FROM
+* INPUT:           None
+* RETURNS:         VOID
+******************************************************************************/
+func initAlertOptions() {
+	windowSec, err := strconv.Atoi(types.CmnGlblCfg.ALERT_STREAM_WINDOW_SEC)
+	if err != nil || windowSec <= 0 {
+		log.Errorf("invalid ALERT_STREAM_WINDOW_SEC %q; using %d", types.CmnGlblCfg.ALERT_STREAM_WINDOW_SEC, DEFAULT_ALERT_STREAM_WINDOW_SEC)
+		windowSec = DEFAULT_ALERT_STREAM_WINDOW_SEC
+	}
+
+	timeoutSec, err := strconv.Atoi(types.CmnGlblCfg.ALERT_DELIVERY_TIMEOUT_SEC)
+	if err != nil || timeoutSec <= 0 {
+		log.Errorf("invalid ALERT_DELIVERY_TIMEOUT_SEC %q; using %d", types.CmnGlblCfg.ALERT_DELIVERY_TIMEOUT_SEC, DEFAULT_ALERT_DELIVERY_TIMEOUT_SEC)
+		timeoutSec = DEFAULT_ALERT_DELIVERY_TIMEOUT_SEC
+	}
+
+	smtpAddr := types.CmnGlblCfg.SMTP_ADDR
+	if smtpAddr != "" && types.CmnGlblCfg.SMTP_FROM == "" {
+		log.Errorf("SMTP_ADDR is set without SMTP_FROM; email alerts are disabled")
+		smtpAddr = ""
+	}
+
+	types.AlertCfg = types.AlertConfig{
+		StreamWindow:    time.Duration(windowSec) * time.Second,
+		DeliveryTimeout: time.Duration(timeoutSec) * time.Second,
+		SMTPAddr:        smtpAddr,
+		SMTPUsername:    types.CmnGlblCfg.SMTP_USERNAME,
+		SMTPPassword:    types.CmnGlblCfg.SMTP_PASSWORD,
+		SMTPFrom:        types.CmnGlblCfg.SMTP_FROM,
+	}
+}
@@ -4,11 +4,14 @@ import (
 	"LOGProcessor/log-mainService/services"
 	"LOGProcessor/log-mainService/tasks"
 	"LOGProcessor/shared/types"
+	"context"
+	"flag"
 	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -20,23 +23,69 @@ import (
 
 /******************************************************************************
 * FUNCTION:        main
-* DESCRIPTION:     Entry point for the application. Starts the Gin router and
+* DESCRIPTION:     Entry point for the application. Depending on the role it
+*                  starts the Gin router, the asynq worker or both, and
 *                  listens for system signals.
 * INPUT:           None
 * RETURNS:         VOID
 ******************************************************************************/
 func main() {
+	role := flag.String("role", getEnv("SERVICE_ROLE", ROLE_ALL), "service role: api, worker or all")
+	flag.Parse()
+
+	if *role != ROLE_API && *role != ROLE_WORKER && *role != ROLE_ALL {
+		log.Errorf("invalid role %q; expected one of %s, %s, %s", *role, ROLE_API, ROLE_WORKER, ROLE_ALL)
+		os.Exit(1)
+	}
+
 	sigChan := make(chan os.Signal, 1)
-	router := createNewRouter()
-	go router.Run(":" + types.CmnGlblCfg.RUNNING_PORT)
-	go runMuxAsynqServer()
+	// both roles store entries, the api ones of pushed streams
+	services.StartGeoIP()
+	services.StartThreatIntel()
+	if *role == ROLE_API || *role == ROLE_ALL {
+		router := createNewRouter()
+		services.StartStreamBatcher()
+		runHttpServer(router)
+		if err := services.StartSyslogServer(); err != nil {
+			requestExit(err)
+		} else if err := services.StartForwardServer(); err != nil {
+			requestExit(err)
+		}
+	}
+	if *role == ROLE_WORKER || *role == ROLE_ALL {
+		runMuxAsynqServer()
+		runFairDispatcher()
+		runSourceScheduler()
+	}
 	initRateLimitOptions()
 	InitInspector()
 
 	go signalHandler(sigChan)
 	err := <-types.ExitChan
 
-	log.Errorf("error", err.Error())
+	log.Infof("shutting down; reason: %v", err)
+	gracefulShutdown()
+}
+
+/******************************************************************************
+* FUNCTION:        runHttpServer
+*
+* DESCRIPTION:     Starts the http server in the background. Kept as an
+*                  http.Server so that it can be drained on shutdown
+* INPUT:           router
+* RETURNS:         VOID
+******************************************************************************/
+func runHttpServer(router *gin.Engine) {
+	httpServer = &http.Server{
+		Addr:    ":" + types.CmnGlblCfg.RUNNING_PORT,
+		Handler: router,
+	}
+
+	go func() {
+		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			requestExit(fmt.Errorf("http server stopped: %v", err))
+		}
+	}()
 }
 
 /******************************************************************************
@@ -61,20 +110,25 @@ func createNewRouter() *gin.Engine {
 
 	for _, route := range apiRoutes {
 
+		handlers := []gin.HandlerFunc{}
 		if route.IsAuthReq {
-			r.Use(AuthMiddleware)
+			handlers = append(handlers, AuthMiddleware)
 		}
+		if route.IsAdminReq {
+			handlers = append(handlers, AdminMiddleware)
+		}
+		handlers = append(handlers, route.Handler)
 
 		endpoint := baseUrl + route.Pattern
 		switch route.Method {
 		case "GET":
-			r.GET(endpoint, route.Handler)
+			r.GET(endpoint, handlers...)
 		case "POST":
-			r.POST(endpoint, route.Handler)
+			r.POST(endpoint, handlers...)
 		case "PUT":
-			r.PUT(endpoint, route.Handler)
+			r.PUT(endpoint, handlers...)
 		case "DELETE":
-			r.DELETE(endpoint, route.Handler)
+			r.DELETE(endpoint, handlers...)
 		default:
 			panic("Unsupported HTTP method: " + route.Method)
 		}
@@ -119,6 +173,11 @@ func AuthMiddleware(c *gin.Context) {
 			return
 		}
 		c.Set("user_id", claims["sub"])
+		if appMetadata, ok := claims["app_metadata"].(map[string]interface{}); ok {
+			if role, ok := appMetadata["role"].(string); ok {
+				c.Set("user_role", role)
+			}
+		}
 	} else {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid claims"})
 		c.Abort()
@@ -129,43 +188,125 @@ func AuthMiddleware(c *gin.Context) {
 	c.Next()
 }
 
+/******************************************************************************
+* FUNCTION:        AdminMiddleware
+*
+* DESCRIPTION:     Middleware function that restricts the route to users
+*									 having the admin role. Must run after AuthMiddleware
+* INPUT:           None
+* RETURNS:         VOID
+******************************************************************************/
+func AdminMiddleware(c *gin.Context) {
+	role, _ := c.Get("user_role")
+	if role != ADMIN_ROLE {
+		c.JSON(http.StatusForbidden, gin.H{"error": "admin role required"})
+		c.Abort()
+		return
+	}
+
+	c.Next()
+}
+
 /******************************************************************************
 * FUNCTION:        signalHandler
 *
-* DESCRIPTION:     Listens for OS interrupt signals and gracefully shuts down
-*                 the application.
+* DESCRIPTION:     Listens for OS interrupt/terminate signals and notifies
+*                  main to gracefully shut down the application.
 * INPUT:           None
 * RETURNS:         VOID
 ******************************************************************************/
 func signalHandler(sigChan chan os.Signal) {
-	signal.Notify(sigChan, os.Interrupt)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
 	sig := <-sigChan
-	types.ExitChan <- fmt.Errorf("%+v signal", sig)
+	requestExit(fmt.Errorf("%+v signal", sig))
+}
+
+/******************************************************************************
+* FUNCTION:        requestExit
+*
+* DESCRIPTION:     Hands the reason of a shutdown to main without blocking.
+*                  ExitChan holds one reason, main reads it only once
+*                  everything is started, and any later reason is dropped
+*                  as the shutdown is already under way
+* INPUT:           err
+* RETURNS:         VOID
+******************************************************************************/
+func requestExit(err error) {
+	select {
+	case types.ExitChan <- err:
+	default:
+		log.Errorf("shutdown already requested; dropping: %v", err)
+	}
 }
 
 /******************************************************************************
 * FUNCTION:        runMuxAsynqServer
 *
-* DESCRIPTION:     runs mux server for handling asynq queue
+* DESCRIPTION:     starts mux server for handling asynq queue. Signals are
+*                  handled by signalHandler, hence Start is used over Run
 * INPUT:           None
 * RETURNS:         VOID
 ******************************************************************************/
 func runMuxAsynqServer() {
-	srv := asynq.NewServer(asynq.RedisClientOpt{Addr: types.CmnGlblCfg.REDIS_ADDR},
+	asynqServer = asynq.NewServer(asynq.RedisClientOpt{Addr: types.CmnGlblCfg.REDIS_ADDR},
 		asynq.Config{
-			Concurrency: 4,
-			Queues: map[string]int{
-				"high": 3,
-				"low":  1,
-			},
+			Concurrency:     types.WorkerCfg.Concurrency,
+			Queues:          types.WorkerCfg.Queues,
+			StrictPriority:  types.WorkerCfg.StrictPriority,
+			ShutdownTimeout: types.WorkerCfg.ShutdownGracePeriod,
+			RetryDelayFunc:  tasks.RetryDelay,
 		})
 	mux := asynq.NewServeMux()
+	mux.Use(services.TrackTaskHandlers)
 	mux.HandleFunc(tasks.TypeLogProcess, services.HandleAsyncTaskMethod)
+	mux.HandleFunc(tasks.TypeSourceIngest, services.HandleSourceIngestTask)
+	mux.HandleFunc(tasks.TypeAlertNotify, services.HandleAlertNotifyTask)
+	if err := asynqServer.Start(mux); err != nil {
+		requestExit(fmt.Errorf("asynq server failed to start: %v", err))
+		return
+	}
 	fmt.Println("muxx servr started")
-	if err := srv.Run(mux); err != nil {
-		fmt.Println("ERR", err)
+}
+
+/******************************************************************************
+* FUNCTION:        runFairDispatcher
+*
+* DESCRIPTION:     starts the dispatcher that moves parked tasks from the
+*                  per-user lists to the asynq queues
+* INPUT:           None
+* RETURNS:         VOID
+******************************************************************************/
+func runFairDispatcher() {
+	var ctx context.Context
+	ctx, stopFairDispatcher = context.WithCancel(context.Background())
+	go tasks.RunFairDispatcher(ctx)
+}
+
+/******************************************************************************
+* FUNCTION:        runSourceScheduler
+*
+* DESCRIPTION:     starts the periodic task manager enqueuing the ingest
+*                  source runs on their cron schedules. Sources are re-read
+*                  every SOURCE_SYNC_INTERVAL
+* INPUT:           None
+* RETURNS:         VOID
+******************************************************************************/
+func runSourceScheduler() {
+	var err error
+	sourceScheduler, err = asynq.NewPeriodicTaskManager(asynq.PeriodicTaskManagerOpts{
+		RedisConnOpt:               asynq.RedisClientOpt{Addr: types.CmnGlblCfg.REDIS_ADDR},
+		PeriodicTaskConfigProvider: &services.SourceScheduleProvider{},
+		SyncInterval:               SOURCE_SYNC_INTERVAL,
+	})
+	if err != nil {
+		requestExit(fmt.Errorf("source scheduler failed to init: %v", err))
+		return
+	}
+
+	if err = sourceScheduler.Start(); err != nil {
+		requestExit(fmt.Errorf("source scheduler failed to start: %v", err))
+		return
 	}
-	srv.Shutdown()
 }
 
 /******************************************************************************
@@ -0,0 +1,118 @@
+/**************************************************************************
+ * File       	   : shutdown.go
+ * DESCRIPTION     : This file contains functions that gracefully shut
+ *                   down the http server, the asynq worker and the
+ *                   redis/db handles
+ * DATE            : 19-October-2026
+ **************************************************************************/
+
+package main
+
+import (
+	"LOGProcessor/log-mainService/services"
+	"LOGProcessor/shared/types"
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/google/martian/log"
+	"github.com/hibiken/asynq"
+)
+
+var (
+	httpServer         *http.Server
+	asynqServer        *asynq.Server
+	stopFairDispatcher context.CancelFunc
+	sourceScheduler    *asynq.PeriodicTaskManager
+)
+
+/******************************************************************************
+* FUNCTION:        gracefulShutdown
+*
+* DESCRIPTION:     Shuts down the components in order: stop pulling new
+*                  tasks, stop accepting http requests, close websocket
+*                  clients, stop scheduling and dispatching, wait for
+*                  in-flight tasks and finally close the redis and db
+*                  handles. Tasks and http requests drain side by side
+*                  within the one grace period
+* INPUT:           None
+* RETURNS:         VOID
+******************************************************************************/
+func gracefulShutdown() {
+	deadline := time.Now().Add(types.WorkerCfg.ShutdownGracePeriod)
+
+	// the asynq server is shut down first and alongside the other stages:
+	// its ShutdownTimeout, fixed when it started, is counted from here and
+	// so ends with the grace period. Tasks still active then are put back
+	// in the queue and their handlers cancelled
+	var asynqDone chan struct{}
+	if asynqServer != nil {
+		services.StopTaskHandlers()
+		asynqDone = make(chan struct{})
+		go func() {
+			asynqServer.Shutdown()
+			close(asynqDone)
+		}()
+	}
+
+	if httpServer != nil {
+		ctx, cancel := context.WithDeadline(context.Background(), deadline)
+		if err := httpServer.Shutdown(ctx); err != nil {
+			log.Errorf("http server shutdown; err: %v", err)
+		}
+		cancel()
+		log.Infof("http server stopped")
+	}
+
+	// after the http server and the syslog/forward listeners so that
+	// every accepted push is flushed
+	services.StopSyslogServer()
+	services.StopForwardServer()
+	services.StopStreamBatcher()
+	services.StopGeoIP()
+	services.StopThreatIntel()
+
+	services.CloseAllWebSockets()
+
+	if stopFairDispatcher != nil {
+		stopFairDispatcher()
+	}
+
+	if sourceScheduler != nil {
+		sourceScheduler.Shutdown()
+	}
+
+	if asynqDone != nil {
+		<-asynqDone
+		// handlers asynq gave up on return once they see their context
+		// cancelled, the db must outlive them
+		services.WaitTaskHandlers()
+		log.Infof("asynq server stopped")
+	}
+
+	if types.AsynqClient.AsynqClient != nil {
+		if err := types.AsynqClient.AsynqClient.Close(); err != nil {
+			log.Errorf("failed to close asynq client; err: %v", err)
+		}
+	}
+
+	if types.AsynqClient.AsynqInspector != nil {
+		if err := types.AsynqClient.AsynqInspector.Close(); err != nil {
+			log.Errorf("failed to close asynq inspector; err: %v", err)
+		}
+	}
+
+	if types.Redis.RedisConn != nil {
+		if err := types.Redis.RedisConn.Close(); err != nil {
+			log.Errorf("failed to close redis client; err: %v", err)
+		}
+	}
+
+	if types.Db.DbConn != nil {
+		if err := types.Db.DbConn.Close(); err != nil {
+			log.Errorf("failed to close db connection; err: %v", err)
+		}
+	}
+
+	log.Infof("shutdown complete")
+}
@@ -0,0 +1,169 @@
+/**************************************************************************
+ * File       	   : apiHandleAlertNotifyTask.go
+ * DESCRIPTION     : This file contains the asynq handler that sends one
+ *                   notification of a fired alert and records the outcome
+ *                   of every attempt on the delivery
+ * DATE            : 19-October-2026
+ **************************************************************************/
+
+package services
+
+import (
+	"LOGProcessor/log-mainService/tasks"
+	"LOGProcessor/shared/db"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/martian/log"
+	"github.com/hibiken/asynq"
+)
+
+var ErrAlertDeliveryNotFound = errors.New("alert delivery not found")
+
+/******************************************************************************
+* FUNCTION:        HandleAlertNotifyTask
+*
+* DESCRIPTION:     This function sends a delivery through its channel. A
+*                  failed send is retried with backoff unless the channel
+*                  rejected it, the delivery is "retrying" in between and
+*                  "failed" after the last attempt
+* INPUT:					 ctx, asynq task
+* RETURNS:         error
+******************************************************************************/
+func HandleAlertNotifyTask(ctx context.Context, t *asynq.Task) (err error) {
+	defer PanicRecovery("HandleAlertNotifyTask")
+
+	var pay tasks.AlertNotifyPayload
+	if err = json.Unmarshal(t.Payload(), &pay); err != nil {
+		return fmt.Errorf("%w: invalid payload: %v", asynq.SkipRetry, err)
+	}
+
+	delivery, err := getAlertDelivery(pay.DeliveryId)
+	if err != nil {
+		if errors.Is(err, ErrAlertDeliveryNotFound) {
+			log.Infof("alert delivery %d no longer exists", pay.DeliveryId)
+			return nil
+		}
+		if errors.Is(err, ErrAlertDeliveryRejected) {
+			markAlertDelivery(pay.DeliveryId, "failed", err)
+			return fmt.Errorf("%w: %v", asynq.SkipRetry, err)
+		}
+		return err
+	}
+	if delivery.Status == "sent" {
+		return nil
+	}
+	if delivery.ChannelType == "" {
+		markAlertDelivery(pay.DeliveryId, "failed", errors.New("the channel was deleted"))
+		return nil
+	}
+
+	err = sendAlertDelivery(ctx, delivery)
+	if err != nil {
+		if cancelledByShutdown(ctx) {
+			// the task is back in the queue, the delivery stays as it was
+			return err
+		}
+		if errors.Is(err, ErrAlertDeliveryRejected) {
+			err = fmt.Errorf("%w: %w", asynq.SkipRetry, err)
+		}
+		status := "retrying"
+		if isFinalAttempt(ctx, err) {
+			status = "failed"
+		}
+		log.Errorf("failed to send alert delivery %d; err: %v", pay.DeliveryId, err)
+		markAlertDelivery(pay.DeliveryId, status, err)
+		return err
+	}
+
+	markAlertDelivery(pay.DeliveryId, "sent", nil)
+	return nil
+}
+
+/******************************************************************************
+* FUNCTION:        getAlertDelivery
+*
+* DESCRIPTION:     Loads a delivery with its alert and its channel, the
+*                  channel credentials decrypted. ChannelType is empty when
+*                  the channel was deleted, a channel that cannot be read
+*                  is rejected
+* INPUT:           deliveryId
+* RETURNS:         *alertDelivery, error
+******************************************************************************/
+func getAlertDelivery(deliveryId int64) (*alertDelivery, error) {
+	query := `
+	SELECT d.delivery_id, d.status, h.alert_id, h.rule_name, h.message, h.payload,
+		c.channel_type, c.config, c.credentials
+	FROM alert_deliveries d
+	JOIN alert_history h ON d.alert_id = h.alert_id
+	LEFT JOIN alert_channels c ON d.channel_id = c.channel_id
+	WHERE d.delivery_id = $1`
+
+	result, err := db.GetDataFromDB(query, []interface{}{deliveryId})
+	if err != nil {
+		return nil, err
+	}
+	if len(result) == 0 {
+		return nil, ErrAlertDeliveryNotFound
+	}
+
+	row := result[0]
+	delivery := &alertDelivery{}
+	delivery.DeliveryId, _ = row["delivery_id"].(int64)
+	delivery.Status, _ = row["status"].(string)
+	delivery.AlertId, _ = row["alert_id"].(int64)
+	delivery.RuleName, _ = row["rule_name"].(string)
+	delivery.Message, _ = row["message"].(string)
+	delivery.ChannelType, _ = row["channel_type"].(string)
+
+	// numbers are kept as written, so ids are not reformatted as floats
+	if payload, ok := row["payload"].(string); ok && payload != "" {
+		decoder := json.NewDecoder(strings.NewReader(payload))
+		decoder.UseNumber()
+		if err := decoder.Decode(&delivery.Payload); err != nil {
+			return nil, fmt.Errorf("%w: invalid payload of alert %d: %v", ErrAlertDeliveryRejected, delivery.AlertId, err)
+		}
+	}
+
+	if config, ok := row["config"].(string); ok && config != "" {
+		if err := json.Unmarshal([]byte(config), &delivery.Config); err != nil {
+			return nil, fmt.Errorf("%w: invalid config of alert delivery %d: %v", ErrAlertDeliveryRejected, deliveryId, err)
+		}
+	}
+
+	if encrypted, ok := row["credentials"].(string); ok && encrypted != "" {
+		plain, err := decryptCredentials(encrypted)
+		if err != nil {
+			return nil, fmt.Errorf("%w: failed to decrypt credentials of alert delivery %d: %v", ErrAlertDeliveryRejected, deliveryId, err)
+		}
+		if err := json.Unmarshal(plain, &delivery.Credentials); err != nil {
+			return nil, fmt.Errorf("%w: invalid credentials of alert delivery %d: %v", ErrAlertDeliveryRejected, deliveryId, err)
+		}
+	}
+
+	return delivery, nil
+}
+
+/******************************************************************************
+* FUNCTION:        markAlertDelivery
+*
+* DESCRIPTION:     Records the outcome of an attempt on a delivery
+* INPUT:           deliveryId, status, sendErr (nil once sent)
+* RETURNS:         void
+******************************************************************************/
+func markAlertDelivery(deliveryId int64, status string, sendErr error) {
+	query := "UPDATE alert_deliveries SET status = $2, attempts = attempts + 1, last_error = $3 WHERE delivery_id = $1"
+	args := []interface{}{deliveryId, status, nil}
+	if sendErr != nil {
+		args[2] = sendErr.Error()
+	} else {
+		query = "UPDATE alert_deliveries SET status = $2, attempts = attempts + 1, last_error = $3, sent_at = now() WHERE delivery_id = $1"
+	}
+
+	if _, err := db.UpdateDataInDB(nil, query, args); err != nil {
+		log.Errorf("failed to update alert delivery %d; err: %v", deliveryId, err)
+	}
+}
@@ -0,0 +1,555 @@
+/**************************************************************************
+ * File       	   : apiHandleAlerts.go
+ * DESCRIPTION     : This file contains functions that manage the alert
+ *                   channels and rules of a user and list the alerts that
+ *                   fired with the state of their notifications
+ * DATE            : 19-October-2026
+ **************************************************************************/
+
+package services
+
+import (
+	"LOGProcessor/shared/db"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/martian/log"
+)
+
+type AlertChannelReq struct {
+	Name        string `json:"name" binding:"required"`
+	ChannelType string `json:"type" binding:"required"`
+	// URL of a webhook or slack channel
+	URL string `json:"url"`
+	// Secret signs webhook bodies, generated when empty
+	Secret string   `json:"secret"`
+	To     []string `json:"to"`
+}
+
+type AlertRuleReq struct {
+	Name        string  `json:"name" binding:"required"`
+	Condition   string  `json:"condition" binding:"required"`
+	ChannelIds  []int64 `json:"channelIds"`
+	CooldownSec *int    `json:"cooldownSec"`
+	Enabled     *bool   `json:"enabled"`
+}
+
+/******************************************************************************
+* FUNCTION:        HandleCreateAlertChannel
+*
+* DESCRIPTION:     This function adds a notification channel. The signing
+*                  secret of a webhook and the url of a slack channel are
+*                  stored encrypted, a generated secret is returned once
+* INPUT:					 gin context
+* RETURNS:         void
+******************************************************************************/
+func HandleCreateAlertChannel(ctx *gin.Context) {
+	defer PanicRecovery("HandleCreateAlertChannel")
+
+	var req AlertChannelReq
+
+	userId, err := extractToken(ctx, "user_id")
+	if err != nil {
+		log.Errorf("failed to get user_id from context; err: ", err)
+		SendResponse(ctx, http.StatusUnauthorized, "internal server error", "", 0)
+		return
+	}
+
+	if err = ctx.ShouldBindJSON(&req); err != nil {
+		SendResponse(ctx, http.StatusBadRequest, "invalid request body", nil, 0)
+		return
+	}
+
+	channelType := strings.ToLower(strings.TrimSpace(req.ChannelType))
+	config := AlertChannelConfig{}
+	creds := AlertChannelCredentials{}
+	generated := false
+
+	switch channelType {
+	case ALERT_CHANNEL_WEBHOOK:
+		config.URL = strings.TrimSpace(req.URL)
+		creds.Secret = req.Secret
+		if creds.Secret == "" {
+			secret := make([]byte, 32)
+			if _, err = rand.Read(secret); err != nil {
+				log.Errorf("failed to generate webhook secret; err: %v", err)
+				SendResponse(ctx, http.StatusInternalServerError, "internal server error", nil, 0)
+				return
+			}
+			creds.Secret = hex.EncodeToString(secret)
+			generated = true
+		}
+	case ALERT_CHANNEL_SLACK:
+		creds.SlackURL = strings.TrimSpace(req.URL)
+	case ALERT_CHANNEL_EMAIL:
+		config.To = req.To
+	}
+	if err = validateAlertChannel(channelType, &config, &creds); err != nil {
+		SendResponse(ctx, http.StatusBadRequest, err.Error(), nil, 0)
+		return
+	}
+
+	count, err := db.GetDataFromDB("SELECT COUNT(*) AS count FROM alert_channels WHERE user_id = $1", []interface{}{userId})
+	if err != nil {
+		log.Errorf("failed to count alert channels; err: %v", err)
+		SendResponse(ctx, http.StatusInternalServerError, "internal server error", nil, 0)
+		return
+	}
+	if channelCount, _ := count[0]["count"].(int64); channelCount >= ALERT_MAX_CHANNELS {
+		SendResponse(ctx, http.StatusBadRequest, "alert channel limit reached", nil, 0)
+		return
+	}
+
+	configJSON, _ := json.Marshal(config)
+	data := map[string]interface{}{
+		"user_id":      userId,
+		"name":         strings.TrimSpace(req.Name),
+		"channel_type": channelType,
+		"config":       string(configJSON),
+		"created_at":   time.Now(),
+	}
+
+	if creds != (AlertChannelCredentials{}) {
+		credsJSON, _ := json.Marshal(creds)
+		data["credentials"], err = encryptCredentials(credsJSON)
+		if err != nil {
+			if errors.Is(err, ErrCredentialsKeyMissing) {
+				SendResponse(ctx, http.StatusBadRequest, "storing credentials is not enabled on this server", nil, 0)
+				return
+			}
+			log.Errorf("failed to encrypt credentials; err: %v", err)
+			SendResponse(ctx, http.StatusInternalServerError, "internal server error", nil, 0)
+			return
+		}
+	}
+
+	channelId, err := db.InsertAndReturnColumn(nil, "alert_channels", "channel_id", data)
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key") {
+			SendResponse(ctx, http.StatusConflict, "a channel with this name already exists", nil, 0)
+			return
+		}
+		log.Errorf("failed to insert into alert_channels; err: %v", err)
+		SendResponse(ctx, http.StatusInternalServerError, "internal server error", nil, 0)
+		return
+	}
+
+	delete(data, "credentials")
+	data["channel_id"] = channelId
+	data["config"] = config
+	if generated {
+		data["secret"] = creds.Secret
+	}
+	SendResponse(ctx, http.StatusOK, "alert channel created successfully", data, 1)
+}
+
+/******************************************************************************
+* FUNCTION:        HandleGetAlertChannels
+*
+* DESCRIPTION:     This function lists the notification channels of the
+*                  user, without their secrets
+* INPUT:					 gin context
+* RETURNS:         void
+******************************************************************************/
+func HandleGetAlertChannels(ctx *gin.Context) {
+	defer PanicRecovery("HandleGetAlertChannels")
+
+	userId, err := extractToken(ctx, "user_id")
+	if err != nil {
+		log.Errorf("failed to get user_id from context; err: ", err)
+		SendResponse(ctx, http.StatusUnauthorized, "internal server error", "", 0)
+		return
+	}
+
+	query := `
+	SELECT channel_id, name, channel_type, config, created_at FROM alert_channels
+	WHERE user_id = $1
+	ORDER BY channel_id ASC`
+
+	result, err := db.GetDataFromDB(query, []interface{}{userId})
+	if err != nil {
+		log.Errorf("failed to get data from db; err: ", err)
+		SendResponse(ctx, http.StatusBadRequest, "internal server error", nil, 0)
+		return
+	}
+
+	SendResponse(ctx, http.StatusOK, "alert channels retrieved succesfully", result, int64(len(result)))
+}
+
+/******************************************************************************
+* FUNCTION:        HandleDeleteAlertChannel
+*
+* DESCRIPTION:     This function deletes a channel of the user. Rules
+*                  naming it notify their other channels, pending
+*                  notifications to it fail
+* INPUT:					 gin context
+* RETURNS:         void
+******************************************************************************/
+func HandleDeleteAlertChannel(ctx *gin.Context) {
+	defer PanicRecovery("HandleDeleteAlertChannel")
+
+	userId, err := extractToken(ctx, "user_id")
+	if err != nil {
+		log.Errorf("failed to get user_id from context; err: ", err)
+		SendResponse(ctx, http.StatusUnauthorized, "internal server error", "", 0)
+		return
+	}
+
+	channelId, err := strconv.ParseInt(ctx.Param("channelId"), 10, 64)
+	if err != nil {
+		SendResponse(ctx, http.StatusBadRequest, "invalid channelId", nil, 0)
+		return
+	}
+
+	rows, err := db.UpdateDataInDB(nil, "DELETE FROM alert_channels WHERE channel_id = $1 AND user_id = $2", []interface{}{channelId, userId})
+	if err != nil {
+		log.Errorf("failed to delete alert channel %d; err: %v", channelId, err)
+		SendResponse(ctx, http.StatusInternalServerError, "internal server error", nil, 0)
+		return
+	}
+	if rows == 0 {
+		SendResponse(ctx, http.StatusNotFound, "alert channel not found", nil, 0)
+		return
+	}
+
+	SendResponse(ctx, http.StatusOK, "alert channel deleted successfully", channelId, 1)
+}
+
+/******************************************************************************
+* FUNCTION:        HandleCreateAlertRule
+*
+* DESCRIPTION:     This function adds an alert rule, checked at the end of
+*                  every job and on stream ingestion
+* INPUT:					 gin context
+* RETURNS:         void
+******************************************************************************/
+func HandleCreateAlertRule(ctx *gin.Context) {
+	defer PanicRecovery("HandleCreateAlertRule")
+
+	userId, data, ok := bindAlertRule(ctx)
+	if !ok {
+		return
+	}
+
+	count, err := db.GetDataFromDB("SELECT COUNT(*) AS count FROM alert_rules WHERE user_id = $1", []interface{}{userId})
+	if err != nil {
+		log.Errorf("failed to count alert rules; err: %v", err)
+		SendResponse(ctx, http.StatusInternalServerError, "internal server error", nil, 0)
+		return
+	}
+	if ruleCount, _ := count[0]["count"].(int64); ruleCount >= ALERT_MAX_RULES {
+		SendResponse(ctx, http.StatusBadRequest, "alert rule limit reached", nil, 0)
+		return
+	}
+
+	data["user_id"] = userId
+	data["created_at"] = time.Now()
+	ruleId, err := db.InsertAndReturnColumn(nil, "alert_rules", "rule_id", data)
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key") {
+			SendResponse(ctx, http.StatusConflict, "a rule with this name already exists", nil, 0)
+			return
+		}
+		log.Errorf("failed to insert into alert_rules; err: %v", err)
+		SendResponse(ctx, http.StatusInternalServerError, "internal server error", nil, 0)
+		return
+	}
+	invalidateAlertRules(userId)
+
+	data["rule_id"] = ruleId
+	data["channel_ids"] = json.RawMessage(data["channel_ids"].(string))
+	SendResponse(ctx, http.StatusOK, "alert rule created successfully", data, 1)
+}
+
+/******************************************************************************
+* FUNCTION:        HandleUpdateAlertRule
+*
+* DESCRIPTION:     This function replaces an alert rule of the user, e.g.
+*                  to disable it. Its cooldown carries on
+* INPUT:					 gin context
+* RETURNS:         void
+******************************************************************************/
+func HandleUpdateAlertRule(ctx *gin.Context) {
+	defer PanicRecovery("HandleUpdateAlertRule")
+
+	ruleId, err := strconv.ParseInt(ctx.Param("ruleId"), 10, 64)
+	if err != nil {
+		SendResponse(ctx, http.StatusBadRequest, "invalid ruleId", nil, 0)
+		return
+	}
+
+	userId, data, ok := bindAlertRule(ctx)
+	if !ok {
+		return
+	}
+
+	query := `
+	UPDATE alert_rules SET name = $3, condition = $4, channel_ids = $5::jsonb, cooldown_sec = $6, enabled = $7
+	WHERE rule_id = $1 AND user_id = $2`
+	args := []interface{}{ruleId, userId, data["name"], data["condition"], data["channel_ids"], data["cooldown_sec"], data["enabled"]}
+
+	rows, err := db.UpdateDataInDB(nil, query, args)
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key") {
+			SendResponse(ctx, http.StatusConflict, "a rule with this name already exists", nil, 0)
+			return
+		}
+		log.Errorf("failed to update alert rule %d; err: %v", ruleId, err)
+		SendResponse(ctx, http.StatusInternalServerError, "internal server error", nil, 0)
+		return
+	}
+	if rows == 0 {
+		SendResponse(ctx, http.StatusNotFound, "alert rule not found", nil, 0)
+		return
+	}
+	invalidateAlertRules(userId)
+
+	data["rule_id"] = ruleId
+	data["channel_ids"] = json.RawMessage(data["channel_ids"].(string))
+	SendResponse(ctx, http.StatusOK, "alert rule updated successfully", data, 1)
+}
+
+/******************************************************************************
+* FUNCTION:        HandleGetAlertRules
+*
+* DESCRIPTION:     This function lists the alert rules of the user with
+*                  the time each last fired
+* INPUT:					 gin context
+* RETURNS:         void
+******************************************************************************/
+func HandleGetAlertRules(ctx *gin.Context) {
+	defer PanicRecovery("HandleGetAlertRules")
+
+	userId, err := extractToken(ctx, "user_id")
+	if err != nil {
+		log.Errorf("failed to get user_id from context; err: ", err)
+		SendResponse(ctx, http.StatusUnauthorized, "internal server error", "", 0)
+		return
+	}
+
+	query := `
+	SELECT rule_id, name, condition, channel_ids, cooldown_sec, enabled, last_fired_at, created_at
+	FROM alert_rules WHERE user_id = $1
+	ORDER BY rule_id ASC`
+
+	result, err := db.GetDataFromDB(query, []interface{}{userId})
+	if err != nil {
+		log.Errorf("failed to get data from db; err: ", err)
+		SendResponse(ctx, http.StatusBadRequest, "internal server error", nil, 0)
+		return
+	}
+	for _, row := range result {
+		if channelIds, ok := row["channel_ids"].(string); ok {
+			row["channel_ids"] = json.RawMessage(channelIds)
+		}
+	}
+
+	SendResponse(ctx, http.StatusOK, "alert rules retrieved succesfully", result, int64(len(result)))
+}
+
+/******************************************************************************
+* FUNCTION:        HandleDeleteAlertRule
+*
+* DESCRIPTION:     This function deletes an alert rule of the user, the
+*                  alerts it fired stay in the history
+* INPUT:					 gin context
+* RETURNS:         void
+******************************************************************************/
+func HandleDeleteAlertRule(ctx *gin.Context) {
+	defer PanicRecovery("HandleDeleteAlertRule")
+
+	userId, err := extractToken(ctx, "user_id")
+	if err != nil {
+		log.Errorf("failed to get user_id from context; err: ", err)
+		SendResponse(ctx, http.StatusUnauthorized, "internal server error", "", 0)
+		return
+	}
+
+	ruleId, err := strconv.ParseInt(ctx.Param("ruleId"), 10, 64)
+	if err != nil {
+		SendResponse(ctx, http.StatusBadRequest, "invalid ruleId", nil, 0)
+		return
+	}
+
+	rows, err := db.UpdateDataInDB(nil, "DELETE FROM alert_rules WHERE rule_id = $1 AND user_id = $2", []interface{}{ruleId, userId})
+	if err != nil {
+		log.Errorf("failed to delete alert rule %d; err: %v", ruleId, err)
+		SendResponse(ctx, http.StatusInternalServerError, "internal server error", nil, 0)
+		return
+	}
+	if rows == 0 {
+		SendResponse(ctx, http.StatusNotFound, "alert rule not found", nil, 0)
+		return
+	}
+	invalidateAlertRules(userId)
+
+	SendResponse(ctx, http.StatusOK, "alert rule deleted successfully", ruleId, 1)
+}
+
+/******************************************************************************
+* FUNCTION:        HandleGetAlertHistory
+*
+* DESCRIPTION:     This function lists the alerts that fired for the user
+*                  with the state of each notification, paginated by the
+*                  last id. The optional ruleId query param narrows them
+*                  down
+* INPUT:					 gin context
+* RETURNS:         void
+******************************************************************************/
+func HandleGetAlertHistory(ctx *gin.Context) {
+	defer PanicRecovery("HandleGetAlertHistory")
+
+	userId, err := extractToken(ctx, "user_id")
+	if err != nil {
+		log.Errorf("failed to get user_id from context; err: ", err)
+		SendResponse(ctx, http.StatusUnauthorized, "internal server error", "", 0)
+		return
+	}
+
+	pageSize, err := strconv.Atoi(ctx.DefaultQuery("pageSize", "10"))
+	if err != nil || pageSize <= 0 {
+		SendResponse(ctx, http.StatusBadRequest, "invalid pageSize", nil, 0)
+		return
+	}
+
+	lastId, err := strconv.ParseInt(ctx.DefaultQuery("lastId", "0"), 10, 64)
+	if err != nil {
+		SendResponse(ctx, http.StatusBadRequest, "invalid lastId", nil, 0)
+		return
+	}
+
+	query := `
+	SELECT h.alert_id, h.rule_id, h.rule_name, h.condition, h.file_id, h.origin,
+		h.observed, h.message, h.created_at,
+		COALESCE((
+			SELECT json_agg(json_build_object(
+				'delivery_id', d.delivery_id, 'channel_id', d.channel_id, 'channel', d.channel_name,
+				'status', d.status, 'attempts', d.attempts, 'last_error', d.last_error, 'sent_at', d.sent_at
+			) ORDER BY d.delivery_id)
+			FROM alert_deliveries d WHERE d.alert_id = h.alert_id
+		), '[]') AS deliveries
+	FROM alert_history h
+	WHERE h.user_id = $1 AND h.alert_id > $2`
+	whereEleList := []interface{}{userId, lastId}
+
+	if ruleId := ctx.Query("ruleId"); ruleId != "" {
+		whereEleList = append(whereEleList, ruleId)
+		query += fmt.Sprintf(" AND h.rule_id = $%d", len(whereEleList))
+	}
+
+	whereEleList = append(whereEleList, pageSize)
+	query += fmt.Sprintf(`
+	ORDER BY h.alert_id ASC
+	LIMIT $%d`, len(whereEleList))
+
+	result, err := db.GetDataFromDB(query, whereEleList)
+	if err != nil {
+		log.Errorf("failed to get data from db; err: ", err)
+		SendResponse(ctx, http.StatusBadRequest, "internal server error", nil, 0)
+		return
+	}
+
+	var nextLastId int64
+	for _, row := range result {
+		if deliveries, ok := row["deliveries"].(string); ok {
+			row["deliveries"] = json.RawMessage(deliveries)
+		}
+		nextLastId, _ = row["alert_id"].(int64)
+	}
+
+	responseData := map[string]interface{}{
+		"data":       result,
+		"nextLastId": nextLastId,
+		"pageSize":   pageSize,
+	}
+
+	SendResponse(ctx, http.StatusOK, "alert history retrieved succesfully", responseData, int64(len(result)))
+}
+
+/******************************************************************************
+* FUNCTION:        bindAlertRule
+*
+* DESCRIPTION:     Helper function reading and checking the rule in the
+*                  request body. The channels must belong to the user.
+*                  Sends the error response itself
+* INPUT:           gin context
+* RETURNS:         userId, alert_rules columns, ok
+******************************************************************************/
+func bindAlertRule(ctx *gin.Context) (string, map[string]interface{}, bool) {
+	var req AlertRuleReq
+
+	userId, err := extractToken(ctx, "user_id")
+	if err != nil {
+		log.Errorf("failed to get user_id from context; err: ", err)
+		SendResponse(ctx, http.StatusUnauthorized, "internal server error", "", 0)
+		return "", nil, false
+	}
+
+	if err = ctx.ShouldBindJSON(&req); err != nil {
+		SendResponse(ctx, http.StatusBadRequest, "invalid request body", nil, 0)
+		return "", nil, false
+	}
+
+	condition := strings.TrimSpace(req.Condition)
+	if _, err = parseAlertCondition(condition); err != nil {
+		SendResponse(ctx, http.StatusBadRequest, err.Error(), nil, 0)
+		return "", nil, false
+	}
+
+	cooldownSec := ALERT_DEFAULT_COOLDOWN_SEC
+	if req.CooldownSec != nil {
+		cooldownSec = *req.CooldownSec
+	}
+	if cooldownSec < 0 || cooldownSec > ALERT_MAX_COOLDOWN_SEC {
+		SendResponse(ctx, http.StatusBadRequest, fmt.Sprintf("cooldownSec must be 0-%d", ALERT_MAX_COOLDOWN_SEC), nil, 0)
+		return "", nil, false
+	}
+
+	channelIds := make([]int64, 0, len(req.ChannelIds))
+	seen := make(map[int64]bool)
+	for _, channelId := range req.ChannelIds {
+		if !seen[channelId] {
+			seen[channelId] = true
+			channelIds = append(channelIds, channelId)
+		}
+	}
+	if len(channelIds) > ALERT_MAX_CHANNELS {
+		SendResponse(ctx, http.StatusBadRequest, fmt.Sprintf("a rule notifies at most %d channels", ALERT_MAX_CHANNELS), nil, 0)
+		return "", nil, false
+	}
+	channelIdsJSON, _ := json.Marshal(channelIds)
+
+	if len(channelIds) > 0 {
+		query := `
+		SELECT COUNT(*) AS count FROM alert_channels
+		WHERE user_id = $1 AND channel_id IN (SELECT jsonb_array_elements_text($2::jsonb)::bigint)`
+		count, err := db.GetDataFromDB(query, []interface{}{userId, string(channelIdsJSON)})
+		if err != nil {
+			log.Errorf("failed to count alert channels; err: %v", err)
+			SendResponse(ctx, http.StatusInternalServerError, "internal server error", nil, 0)
+			return "", nil, false
+		}
+		if owned, _ := count[0]["count"].(int64); owned != int64(len(channelIds)) {
+			SendResponse(ctx, http.StatusBadRequest, "unknown channel in channelIds", nil, 0)
+			return "", nil, false
+		}
+	}
+
+	data := map[string]interface{}{
+		"name":         strings.TrimSpace(req.Name),
+		"condition":    condition,
+		"channel_ids":  string(channelIdsJSON),
+		"cooldown_sec": cooldownSec,
+		"enabled":      req.Enabled == nil || *req.Enabled,
+	}
+
+	return userId, data, true
+}
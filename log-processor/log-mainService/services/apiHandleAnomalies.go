@@ -0,0 +1,93 @@
+/**************************************************************************
+ * File       	   : apiHandleAnomalies.go
+ * DESCRIPTION     : This file contains functions that list the anomalies
+ *                   detected in the jobs of a user
+ * DATE            : 19-October-2026
+ **************************************************************************/
+
+package services
+
+import (
+	"LOGProcessor/shared/db"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/martian/log"
+)
+
+/******************************************************************************
+* FUNCTION:        HandleGetAnomalies
+*
+* DESCRIPTION:     This function lists the anomalies of the user's jobs in
+*                  the order they were found, paginated by the last id like
+*                  the log stats. Optional kind and jobId query params
+*                  narrow them down
+* INPUT:					 gin context
+* RETURNS:         void
+******************************************************************************/
+func HandleGetAnomalies(ctx *gin.Context) {
+	defer PanicRecovery("HandleGetAnomalies")
+
+	userId, err := extractToken(ctx, "user_id")
+	if err != nil {
+		log.Errorf("failed to get user_id from context; err: ", err)
+		SendResponse(ctx, http.StatusUnauthorized, "internal server error", "", 0)
+		return
+	}
+
+	pageSize, err := strconv.Atoi(ctx.DefaultQuery("pageSize", "10"))
+	if err != nil || pageSize <= 0 {
+		SendResponse(ctx, http.StatusBadRequest, "invalid pageSize", nil, 0)
+		return
+	}
+
+	lastId, err := strconv.ParseInt(ctx.DefaultQuery("lastId", "0"), 10, 64)
+	if err != nil {
+		SendResponse(ctx, http.StatusBadRequest, "invalid lastId", nil, 0)
+		return
+	}
+
+	query := `
+	SELECT a.anomaly_id, a.file_id, f.job_id, f.file_name, a.scope, a.kind, a.subject,
+		a.score, a.observed, a.expected, a.created_at
+	FROM anomalies a
+	JOIN file_stats f ON a.file_id = f.file_id
+	WHERE a.user_id = $1 AND a.anomaly_id > $2`
+	whereEleList := []interface{}{userId, lastId}
+
+	if kind := ctx.Query("kind"); kind != "" {
+		whereEleList = append(whereEleList, kind)
+		query += fmt.Sprintf(" AND a.kind = $%d", len(whereEleList))
+	}
+	if jobId := ctx.Query("jobId"); jobId != "" {
+		whereEleList = append(whereEleList, jobId)
+		query += fmt.Sprintf(" AND f.job_id = $%d", len(whereEleList))
+	}
+
+	whereEleList = append(whereEleList, pageSize)
+	query += fmt.Sprintf(`
+	ORDER BY a.anomaly_id ASC
+	LIMIT $%d`, len(whereEleList))
+
+	result, err := db.GetDataFromDB(query, whereEleList)
+	if err != nil {
+		log.Errorf("failed to get data from db; err: ", err)
+		SendResponse(ctx, http.StatusBadRequest, "internal server error", nil, 0)
+		return
+	}
+
+	var nextLastId int64
+	if len(result) > 0 {
+		nextLastId, _ = result[len(result)-1]["anomaly_id"].(int64)
+	}
+
+	responseData := map[string]interface{}{
+		"data":       result,
+		"nextLastId": nextLastId,
+		"pageSize":   pageSize,
+	}
+
+	SendResponse(ctx, http.StatusOK, "anomalies retrieved succesfully", responseData, int64(len(result)))
+}
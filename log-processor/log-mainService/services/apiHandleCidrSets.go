@@ -0,0 +1,180 @@
+/**************************************************************************
+ * File       	   : apiHandleCidrSets.go
+ * DESCRIPTION     : This file contains functions that manage the named
+ *                   CIDR sets of a user
+ * DATE            : 19-October-2026
+ **************************************************************************/
+
+package services
+
+import (
+	"LOGProcessor/shared/db"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/martian/log"
+)
+
+const (
+	CIDR_MAX_SETS        = 50
+	CIDR_MAX_SET_ENTRIES = 1000
+)
+
+var cidrSetNameRegex = regexp.MustCompile(`^[a-z0-9_]{1,64}$`)
+
+type CidrSetReq struct {
+	Name  string   `json:"name" binding:"required"`
+	Cidrs []string `json:"cidrs" binding:"required"`
+}
+
+/******************************************************************************
+* FUNCTION:        HandleGetCidrSets
+*
+* DESCRIPTION:     This function lists the CIDR sets of the user
+* INPUT:					 gin context
+* RETURNS:         void
+******************************************************************************/
+func HandleGetCidrSets(ctx *gin.Context) {
+	defer PanicRecovery("HandleGetCidrSets")
+
+	userId, err := extractToken(ctx, "user_id")
+	if err != nil {
+		log.Errorf("failed to get user_id from context; err: ", err)
+		SendResponse(ctx, http.StatusUnauthorized, "internal server error", "", 0)
+		return
+	}
+
+	query := `
+	SELECT name, array_to_string(cidrs, ',') AS cidrs, created_at FROM cidr_sets
+	WHERE user_id = $1
+	ORDER BY name ASC`
+
+	sets, err := db.GetDataFromDB(query, []interface{}{userId})
+	if err != nil {
+		log.Errorf("failed to get data from db; err: ", err)
+		SendResponse(ctx, http.StatusBadRequest, "internal server error", nil, 0)
+		return
+	}
+
+	for _, set := range sets {
+		cidrs, _ := set["cidrs"].(string)
+		set["cidrs"] = strings.Split(cidrs, ",")
+	}
+
+	SendResponse(ctx, http.StatusOK, "cidr sets retrieved succesfully", sets, int64(len(sets)))
+}
+
+/******************************************************************************
+* FUNCTION:        HandlePutCidrSet
+*
+* DESCRIPTION:     This function stores a CIDR set of the user, replacing
+*                  the set of the same name. Entries processed afterwards
+*                  whose ip is in the set are tagged with its name
+* INPUT:					 gin context
+* RETURNS:         void
+******************************************************************************/
+func HandlePutCidrSet(ctx *gin.Context) {
+	defer PanicRecovery("HandlePutCidrSet")
+
+	var req CidrSetReq
+
+	userId, err := extractToken(ctx, "user_id")
+	if err != nil {
+		log.Errorf("failed to get user_id from context; err: ", err)
+		SendResponse(ctx, http.StatusUnauthorized, "internal server error", "", 0)
+		return
+	}
+
+	if err = ctx.ShouldBindJSON(&req); err != nil {
+		SendResponse(ctx, http.StatusBadRequest, "invalid request body", nil, 0)
+		return
+	}
+
+	name := strings.ToLower(strings.TrimSpace(req.Name))
+	if !cidrSetNameRegex.MatchString(name) {
+		SendResponse(ctx, http.StatusBadRequest, "name must be 1-64 lowercase letters, digits or '_'", nil, 0)
+		return
+	}
+	if len(req.Cidrs) == 0 || len(req.Cidrs) > CIDR_MAX_SET_ENTRIES {
+		SendResponse(ctx, http.StatusBadRequest, "a set needs 1 to 1000 cidrs", nil, 0)
+		return
+	}
+
+	cidrs := make([]string, 0, len(req.Cidrs))
+	for _, value := range req.Cidrs {
+		prefix, err := parseCidr(value)
+		if err != nil {
+			SendResponse(ctx, http.StatusBadRequest, err.Error(), nil, 0)
+			return
+		}
+		cidrs = append(cidrs, prefix.String())
+	}
+
+	count, err := db.GetDataFromDB("SELECT COUNT(*) AS count FROM cidr_sets WHERE user_id = $1 AND name <> $2", []interface{}{userId, name})
+	if err != nil {
+		log.Errorf("failed to count cidr sets; err: %v", err)
+		SendResponse(ctx, http.StatusInternalServerError, "internal server error", nil, 0)
+		return
+	}
+	if setCount, _ := count[0]["count"].(int64); setCount >= CIDR_MAX_SETS {
+		SendResponse(ctx, http.StatusBadRequest, "cidr set limit reached", nil, 0)
+		return
+	}
+
+	query := `
+	INSERT INTO cidr_sets (user_id, name, cidrs, created_at)
+	VALUES ($1, $2, $3::cidr[], $4)
+	ON CONFLICT (user_id, name) DO UPDATE SET cidrs = EXCLUDED.cidrs, created_at = EXCLUDED.created_at`
+
+	if _, err = db.UpdateDataInDB(nil, query, []interface{}{userId, name, textArrayLiteral(cidrs), time.Now()}); err != nil {
+		log.Errorf("failed to store cidr set; err: %v", err)
+		SendResponse(ctx, http.StatusInternalServerError, "internal server error", nil, 0)
+		return
+	}
+	invalidateCidrSets(userId)
+
+	responseData := map[string]interface{}{
+		"name":  name,
+		"cidrs": cidrs,
+	}
+
+	SendResponse(ctx, http.StatusOK, "cidr set stored successfully", responseData, 1)
+}
+
+/******************************************************************************
+* FUNCTION:        HandleDeleteCidrSet
+*
+* DESCRIPTION:     This function deletes a CIDR set of the user, entries
+*                  already tagged keep the tag
+* INPUT:					 gin context
+* RETURNS:         void
+******************************************************************************/
+func HandleDeleteCidrSet(ctx *gin.Context) {
+	defer PanicRecovery("HandleDeleteCidrSet")
+
+	userId, err := extractToken(ctx, "user_id")
+	if err != nil {
+		log.Errorf("failed to get user_id from context; err: ", err)
+		SendResponse(ctx, http.StatusUnauthorized, "internal server error", "", 0)
+		return
+	}
+
+	name := strings.ToLower(strings.TrimSpace(ctx.Param("name")))
+
+	rows, err := db.UpdateDataInDB(nil, "DELETE FROM cidr_sets WHERE user_id = $1 AND name = $2", []interface{}{userId, name})
+	if err != nil {
+		log.Errorf("failed to delete cidr set %s; err: %v", name, err)
+		SendResponse(ctx, http.StatusInternalServerError, "internal server error", nil, 0)
+		return
+	}
+	if rows == 0 {
+		SendResponse(ctx, http.StatusNotFound, "cidr set not found", nil, 0)
+		return
+	}
+	invalidateCidrSets(userId)
+
+	SendResponse(ctx, http.StatusOK, "cidr set deleted successfully", name, 1)
+}
@@ -74,11 +74,25 @@ func HandleGetQueueCurrentStatus(ctx *gin.Context) {
 		Failed:    highQueueStats.Failed + lowQueueStats.Failed,
 	}
 
+	// only the caller's own counts, every user is listed on /admin/tenants
+	var tenant *tasks.TenantStatus
+	if userId, err := extractToken(ctx, "user_id"); err == nil {
+		status := tasks.GetTenantStatus(timeoutCtx, userId)
+		tenant = &status
+	}
+
 	activeTasks, err := getActiveTasks(inspector)
 	if err == nil && len(activeTasks) > 0 {
 		responseData := map[string]interface{}{
 			"queue_status": totalStats,
 			"active_tasks": activeTasks,
+			"tenant":       tenant,
+		}
+		SendResponse(ctx, http.StatusOK, "Queue status fetched successfully", responseData, 1)
+	} else if tenant != nil {
+		responseData := map[string]interface{}{
+			"queue_status": totalStats,
+			"tenant":       tenant,
 		}
 		SendResponse(ctx, http.StatusOK, "Queue status fetched successfully", responseData, 1)
 	} else {
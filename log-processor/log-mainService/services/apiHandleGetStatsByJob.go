@@ -9,13 +9,23 @@ package services
 
 import (
 	"LOGProcessor/shared/db"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/martian/log"
 )
 
+const (
+	GEO_STATS_DEFAULT_LIMIT = 50
+	GEO_STATS_MAX_LIMIT     = 1000
+)
+
+// log_stats columns of container logs that can be filtered on
+var containerFilterColumns = []string{"stream", "namespace", "pod", "container"}
+
 /******************************************************************************
 * FUNCTION:        HandleGetStatsByJobId
 *
@@ -24,7 +34,13 @@ import (
 *									 the reason being paginating with offset can be very expensive and time consuming
 *									 for very large files, which is expected from a logging service.
 *									 Hence to optimize querying I take an approach by filtering with
-*									 the last id, thereby resulting in a faster output
+*									 the last id, thereby resulting in a faster output.
+*									 Container logs can be filtered by the stream,
+*									 namespace, pod and container query params,
+*									 any log by minLevel (severity >= the level),
+*									 ip (an address or a network, e.g. 10.0.0.0/8)
+*									 and ipTag (the name of a cidr set), threat=true
+*									 keeps the lines touching a blocklist
 *
 * INPUT:					 gin context
 * RETURNS:         void
@@ -68,11 +84,49 @@ func HandleGetStatsByJobId(ctx *gin.Context) {
 	query = `
 	SELECT l.* FROM log_stats l
 	JOIN file_stats f ON l.file_id = f.file_id
-	WHERE f.job_id = $1 AND f.user_id = $2 AND l.file_id > $3
+	WHERE f.job_id = $1 AND f.user_id = $2 AND l.file_id > $3`
+	whereEleList = append(whereEleList, jobId, userId, lastId)
+
+	for _, column := range containerFilterColumns {
+		if value := ctx.Query(column); value != "" {
+			whereEleList = append(whereEleList, value)
+			query += fmt.Sprintf(" AND l.%s = $%d", column, len(whereEleList))
+		}
+	}
+
+	if minLevel := strings.ToUpper(ctx.Query("minLevel")); minLevel != "" {
+		if !isCanonicalLevel(minLevel) {
+			SendResponse(ctx, http.StatusBadRequest, "invalid minLevel", nil, 0)
+			return
+		}
+		whereEleList = append(whereEleList, levelSeverities[minLevel])
+		query += fmt.Sprintf(" AND l.severity >= $%d", len(whereEleList))
+	}
+
+	if ipFilter := ctx.Query("ip"); ipFilter != "" {
+		prefix, err := parseCidr(ipFilter)
+		if err != nil {
+			SendResponse(ctx, http.StatusBadRequest, "invalid ip: "+err.Error(), nil, 0)
+			return
+		}
+		whereEleList = append(whereEleList, prefix.String())
+		query += fmt.Sprintf(" AND l.ip <<= $%d::cidr", len(whereEleList))
+	}
+
+	if ipTag := strings.ToLower(ctx.Query("ipTag")); ipTag != "" {
+		whereEleList = append(whereEleList, ipTag)
+		query += fmt.Sprintf(" AND l.ip_tags @> ARRAY[$%d]::text[]", len(whereEleList))
+	}
+
+	if ctx.Query("threat") == "true" {
+		query += " AND l.threat_indicator IS NOT NULL"
+	}
+
+	whereEleList = append(whereEleList, pageSize)
+	query += fmt.Sprintf(`
 	ORDER BY l.file_id ASC
-	LIMIT $4`
+	LIMIT $%d`, len(whereEleList))
 
-	whereEleList = append(whereEleList, jobId, userId, lastId, pageSize)
 	result, err = db.GetDataFromDB(query, whereEleList)
 	if err != nil {
 		log.Errorf("failed to get data from db; err: ", err)
@@ -93,3 +147,256 @@ func HandleGetStatsByJobId(ctx *gin.Context) {
 
 	SendResponse(ctx, http.StatusOK, "log stats retrieved succesfully", responseData, int64(len(result)))
 }
+
+/******************************************************************************
+* FUNCTION:        HandleGetJobRetryHistory
+*
+* DESCRIPTION:     This function gets the failed attempts of a job along with
+*                  their reason and whether the job was retried afterwards
+* INPUT:					 gin context
+* RETURNS:         void
+******************************************************************************/
+func HandleGetJobRetryHistory(ctx *gin.Context) {
+	defer PanicRecovery("HandleGetJobRetryHistory")
+
+	var (
+		err          error
+		query        string
+		userId       string
+		whereEleList []interface{}
+		result       []map[string]interface{}
+	)
+
+	jobId := ctx.Param("jobId")
+
+	userId, err = extractToken(ctx, "user_id")
+	if err != nil {
+		log.Errorf("failed to get user_id from context; err: ", err)
+		SendResponse(ctx, http.StatusUnauthorized, "internal server error", "", 0)
+		return
+	}
+
+	query = `
+	SELECT r.attempt, r.reason, r.will_retry, r.created_at FROM job_retry_history r
+	JOIN file_stats f ON r.file_id = f.file_id
+	WHERE r.job_id = $1 AND f.user_id = $2
+	ORDER BY r.attempt ASC`
+
+	whereEleList = append(whereEleList, jobId, userId)
+	result, err = db.GetDataFromDB(query, whereEleList)
+	if err != nil {
+		log.Errorf("failed to get data from db; err: ", err)
+		SendResponse(ctx, http.StatusBadRequest, "internal server error", nil, 0)
+		return
+	}
+
+	SendResponse(ctx, http.StatusOK, "retry history retrieved succesfully", result, int64(len(result)))
+}
+
+/******************************************************************************
+* FUNCTION:        HandleGetJobLatencyStats
+*
+* DESCRIPTION:     This function aggregates the duration fields of a job's
+*                  log lines (e.g. logfmt dur=1.3s), one row per field with
+*                  count, min, avg, max and percentiles in milliseconds
+* INPUT:					 gin context
+* RETURNS:         void
+******************************************************************************/
+func HandleGetJobLatencyStats(ctx *gin.Context) {
+	defer PanicRecovery("HandleGetJobLatencyStats")
+
+	var (
+		err          error
+		query        string
+		userId       string
+		whereEleList []interface{}
+		result       []map[string]interface{}
+	)
+
+	jobId := ctx.Param("jobId")
+
+	userId, err = extractToken(ctx, "user_id")
+	if err != nil {
+		log.Errorf("failed to get user_id from context; err: ", err)
+		SendResponse(ctx, http.StatusUnauthorized, "internal server error", "", 0)
+		return
+	}
+
+	query = `
+	SELECT d.key AS field,
+		COUNT(*) AS count,
+		MIN(d.value::float8) AS min_ms,
+		AVG(d.value::float8) AS avg_ms,
+		MAX(d.value::float8) AS max_ms,
+		PERCENTILE_CONT(0.5) WITHIN GROUP (ORDER BY d.value::float8) AS p50_ms,
+		PERCENTILE_CONT(0.95) WITHIN GROUP (ORDER BY d.value::float8) AS p95_ms,
+		PERCENTILE_CONT(0.99) WITHIN GROUP (ORDER BY d.value::float8) AS p99_ms
+	FROM log_stats l
+	JOIN file_stats f ON l.file_id = f.file_id
+	CROSS JOIN LATERAL jsonb_each_text(l.durations) d
+	WHERE f.job_id = $1 AND f.user_id = $2 AND l.durations IS NOT NULL
+	GROUP BY d.key
+	ORDER BY d.key`
+
+	whereEleList = append(whereEleList, jobId, userId)
+	result, err = db.GetDataFromDB(query, whereEleList)
+	if err != nil {
+		log.Errorf("failed to get data from db; err: ", err)
+		SendResponse(ctx, http.StatusBadRequest, "internal server error", nil, 0)
+		return
+	}
+
+	SendResponse(ctx, http.StatusOK, "latency stats retrieved succesfully", result, int64(len(result)))
+}
+
+/******************************************************************************
+* FUNCTION:        HandleGetJobGeoStats
+*
+* DESCRIPTION:     This function breaks the lines of a job with an ip down
+*                  by country and by ASN, the largest groups first
+* INPUT:					 gin context
+* RETURNS:         void
+******************************************************************************/
+func HandleGetJobGeoStats(ctx *gin.Context) {
+	defer PanicRecovery("HandleGetJobGeoStats")
+
+	var (
+		err          error
+		query        string
+		userId       string
+		limit        int
+		whereEleList []interface{}
+		countries    []map[string]interface{}
+		asns         []map[string]interface{}
+	)
+
+	jobId := ctx.Param("jobId")
+
+	userId, err = extractToken(ctx, "user_id")
+	if err != nil {
+		log.Errorf("failed to get user_id from context; err: ", err)
+		SendResponse(ctx, http.StatusUnauthorized, "internal server error", "", 0)
+		return
+	}
+
+	limit, err = strconv.Atoi(ctx.DefaultQuery("limit", strconv.Itoa(GEO_STATS_DEFAULT_LIMIT)))
+	if err != nil || limit <= 0 || limit > GEO_STATS_MAX_LIMIT {
+		SendResponse(ctx, http.StatusBadRequest, "limit must be between 1 and 1000", nil, 0)
+		return
+	}
+
+	whereEleList = append(whereEleList, jobId, userId, limit)
+
+	query = `
+	SELECT l.geo_country AS country, COUNT(*) AS count, COUNT(DISTINCT l.ip) AS distinct_ips
+	FROM log_stats l
+	JOIN file_stats f ON l.file_id = f.file_id
+	WHERE f.job_id = $1 AND f.user_id = $2 AND l.ip IS NOT NULL
+	GROUP BY l.geo_country
+	ORDER BY count DESC
+	LIMIT $3`
+
+	countries, err = db.GetDataFromDB(query, whereEleList)
+	if err != nil {
+		log.Errorf("failed to get data from db; err: ", err)
+		SendResponse(ctx, http.StatusBadRequest, "internal server error", nil, 0)
+		return
+	}
+
+	query = `
+	SELECT l.asn, MAX(l.as_org) AS as_org, COUNT(*) AS count, COUNT(DISTINCT l.ip) AS distinct_ips
+	FROM log_stats l
+	JOIN file_stats f ON l.file_id = f.file_id
+	WHERE f.job_id = $1 AND f.user_id = $2 AND l.ip IS NOT NULL
+	GROUP BY l.asn
+	ORDER BY count DESC
+	LIMIT $3`
+
+	asns, err = db.GetDataFromDB(query, whereEleList)
+	if err != nil {
+		log.Errorf("failed to get data from db; err: ", err)
+		SendResponse(ctx, http.StatusBadRequest, "internal server error", nil, 0)
+		return
+	}
+
+	responseData := map[string]interface{}{
+		"countries": countries,
+		"asns":      asns,
+	}
+
+	SendResponse(ctx, http.StatusOK, "geo stats retrieved succesfully", responseData, int64(len(countries)))
+}
+
+/******************************************************************************
+* FUNCTION:        HandleGetJobRejectedLines
+*
+* DESCRIPTION:     This function gets the line counts of a job, the number
+*                  of rejected lines per reason and the sampled rejected
+*                  lines, optionally of a single reason. A file rejected
+*                  for the most part was likely given the wrong parser
+* INPUT:					 gin context
+* RETURNS:         void
+******************************************************************************/
+func HandleGetJobRejectedLines(ctx *gin.Context) {
+	defer PanicRecovery("HandleGetJobRejectedLines")
+
+	var (
+		err          error
+		query        string
+		userId       string
+		whereEleList []interface{}
+		counts       []map[string]interface{}
+		samples      []map[string]interface{}
+	)
+
+	jobId := ctx.Param("jobId")
+
+	userId, err = extractToken(ctx, "user_id")
+	if err != nil {
+		log.Errorf("failed to get user_id from context; err: ", err)
+		SendResponse(ctx, http.StatusUnauthorized, "internal server error", "", 0)
+		return
+	}
+
+	query = `
+	SELECT file_id, line_count, parsed_lines, rejected_lines, rejected_reasons
+	FROM file_stats WHERE job_id = $1 AND user_id = $2`
+
+	whereEleList = append(whereEleList, jobId, userId)
+	counts, err = db.GetDataFromDB(query, whereEleList)
+	if err != nil {
+		log.Errorf("failed to get data from db; err: ", err)
+		SendResponse(ctx, http.StatusBadRequest, "internal server error", nil, 0)
+		return
+	}
+	if len(counts) == 0 {
+		SendResponse(ctx, http.StatusNotFound, "job not found", nil, 0)
+		return
+	}
+
+	query = `
+	SELECT s.reason, s.line_number, s.line FROM rejected_line_samples s
+	JOIN file_stats f ON s.file_id = f.file_id
+	WHERE f.job_id = $1 AND f.user_id = $2`
+
+	if reason := ctx.Query("reason"); reason != "" {
+		whereEleList = append(whereEleList, reason)
+		query += fmt.Sprintf(" AND s.reason = $%d", len(whereEleList))
+	}
+	query += `
+	ORDER BY s.reason ASC, s.line_number ASC`
+
+	samples, err = db.GetDataFromDB(query, whereEleList)
+	if err != nil {
+		log.Errorf("failed to get data from db; err: ", err)
+		SendResponse(ctx, http.StatusBadRequest, "internal server error", nil, 0)
+		return
+	}
+
+	responseData := map[string]interface{}{
+		"lines":   counts[0],
+		"samples": samples,
+	}
+
+	SendResponse(ctx, http.StatusOK, "rejected lines retrieved succesfully", responseData, int64(len(samples)))
+}
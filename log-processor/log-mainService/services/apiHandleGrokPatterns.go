@@ -0,0 +1,325 @@
+/**************************************************************************
+ * File       	   : apiHandleGrokPatterns.go
+ * DESCRIPTION     : This file contains functions that create, list,
+ *                   delete and dry-run the grok patterns of a user
+ * DATE            : 19-October-2026
+ **************************************************************************/
+
+package services
+
+import (
+	"LOGProcessor/log-mainService/tasks"
+	"LOGProcessor/shared/db"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/martian/log"
+)
+
+const (
+	GROK_MAX_TEST_LINES = 100
+)
+
+type GrokPatternReq struct {
+	Name        string            `json:"name" binding:"required"`
+	Pattern     string            `json:"pattern" binding:"required"`
+	Definitions map[string]string `json:"definitions"`
+}
+
+type GrokTestReq struct {
+	PatternId   int64             `json:"patternId"`
+	Pattern     string            `json:"pattern"`
+	Definitions map[string]string `json:"definitions"`
+	Lines       []string          `json:"lines" binding:"required"`
+	Timezone    string            `json:"timezone"`
+}
+
+type GrokTestResult struct {
+	Line    string                 `json:"line"`
+	Matched bool                   `json:"matched"`
+	Fields  map[string]interface{} `json:"fields,omitempty"`
+	Entry   *GrokTestEntry         `json:"entry,omitempty"`
+	Error   string                 `json:"error,omitempty"`
+}
+
+type GrokTestEntry struct {
+	Timestamp    time.Time              `json:"timestamp"`
+	RawTimestamp string                 `json:"rawTimestamp"`
+	Level        string                 `json:"level"`
+	Message      string                 `json:"message"`
+	IP           string                 `json:"ip,omitempty"`
+	Attributes   map[string]interface{} `json:"attributes,omitempty"`
+}
+
+/******************************************************************************
+* FUNCTION:        HandleCreateGrokPattern
+*
+* DESCRIPTION:     This function validates and stores a grok pattern of the
+*                  user. Custom sub-patterns can be given as definitions
+* INPUT:					 gin context
+* RETURNS:         void
+******************************************************************************/
+func HandleCreateGrokPattern(ctx *gin.Context) {
+	defer PanicRecovery("HandleCreateGrokPattern")
+
+	var (
+		err       error
+		req       GrokPatternReq
+		userId    string
+		patternId int64
+	)
+
+	userId, err = extractToken(ctx, "user_id")
+	if err != nil {
+		log.Errorf("failed to get user_id from context; err: ", err)
+		SendResponse(ctx, http.StatusUnauthorized, "internal server error", "", 0)
+		return
+	}
+
+	if err = ctx.ShouldBindJSON(&req); err != nil {
+		SendResponse(ctx, http.StatusBadRequest, "invalid request body", nil, 0)
+		return
+	}
+
+	compiled, err := compileGrokPattern(req.Pattern, req.Definitions)
+	if err != nil {
+		SendResponse(ctx, http.StatusBadRequest, err.Error(), nil, 0)
+		return
+	}
+	if !compiled.hasTimestampField() {
+		SendResponse(ctx, http.StatusBadRequest, "pattern must capture a timestamp, ts or time field", nil, 0)
+		return
+	}
+
+	definitionsJSON, _ := json.Marshal(req.Definitions)
+	data := map[string]interface{}{
+		"user_id":     userId,
+		"name":        req.Name,
+		"pattern":     req.Pattern,
+		"definitions": string(definitionsJSON),
+		"created_at":  time.Now(),
+	}
+
+	patternId, err = db.InsertAndReturnColumn(nil, "grok_patterns", "pattern_id", data)
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key") {
+			SendResponse(ctx, http.StatusConflict, "a pattern with this name already exists", nil, 0)
+			return
+		}
+		log.Errorf("failed to insert into grok_patterns; err: %v", err)
+		SendResponse(ctx, http.StatusInternalServerError, "internal server error", nil, 0)
+		return
+	}
+
+	data["pattern_id"] = patternId
+	data["definitions"] = req.Definitions
+	SendResponse(ctx, http.StatusOK, "grok pattern created successfully", data, 1)
+}
+
+/******************************************************************************
+* FUNCTION:        HandleGetGrokPatterns
+*
+* DESCRIPTION:     This function lists the grok patterns of the user
+* INPUT:					 gin context
+* RETURNS:         void
+******************************************************************************/
+func HandleGetGrokPatterns(ctx *gin.Context) {
+	defer PanicRecovery("HandleGetGrokPatterns")
+
+	userId, err := extractToken(ctx, "user_id")
+	if err != nil {
+		log.Errorf("failed to get user_id from context; err: ", err)
+		SendResponse(ctx, http.StatusUnauthorized, "internal server error", "", 0)
+		return
+	}
+
+	query := `
+	SELECT pattern_id, name, pattern, definitions, created_at
+	FROM grok_patterns WHERE user_id = $1
+	ORDER BY pattern_id ASC`
+
+	result, err := db.GetDataFromDB(query, []interface{}{userId})
+	if err != nil {
+		log.Errorf("failed to get data from db; err: ", err)
+		SendResponse(ctx, http.StatusBadRequest, "internal server error", nil, 0)
+		return
+	}
+
+	SendResponse(ctx, http.StatusOK, "grok patterns retrieved succesfully", result, int64(len(result)))
+}
+
+/******************************************************************************
+* FUNCTION:        HandleDeleteGrokPattern
+*
+* DESCRIPTION:     This function deletes a grok pattern of the user. Files
+*                  still queued with the pattern fail without retry
+* INPUT:					 gin context
+* RETURNS:         void
+******************************************************************************/
+func HandleDeleteGrokPattern(ctx *gin.Context) {
+	defer PanicRecovery("HandleDeleteGrokPattern")
+
+	userId, err := extractToken(ctx, "user_id")
+	if err != nil {
+		log.Errorf("failed to get user_id from context; err: ", err)
+		SendResponse(ctx, http.StatusUnauthorized, "internal server error", "", 0)
+		return
+	}
+
+	patternId, err := strconv.ParseInt(ctx.Param("patternId"), 10, 64)
+	if err != nil {
+		SendResponse(ctx, http.StatusBadRequest, "invalid patternId", nil, 0)
+		return
+	}
+
+	rows, err := db.UpdateDataInDB(nil, "DELETE FROM grok_patterns WHERE pattern_id = $1 AND user_id = $2", []interface{}{patternId, userId})
+	if err != nil {
+		log.Errorf("failed to delete grok pattern %d; err: %v", patternId, err)
+		SendResponse(ctx, http.StatusInternalServerError, "internal server error", nil, 0)
+		return
+	}
+	if rows == 0 {
+		SendResponse(ctx, http.StatusNotFound, ErrGrokPatternNotFound.Error(), nil, 0)
+		return
+	}
+
+	SendResponse(ctx, http.StatusOK, "grok pattern deleted successfully", patternId, 1)
+}
+
+/******************************************************************************
+* FUNCTION:        HandleTestGrokPattern
+*
+* DESCRIPTION:     This function dry-runs a pattern against sample lines
+*                  without storing anything. Either a stored patternId or
+*                  an inline pattern with definitions is tested; each line
+*                  reports its raw fields and the resulting log entry
+* INPUT:					 gin context
+* RETURNS:         void
+******************************************************************************/
+func HandleTestGrokPattern(ctx *gin.Context) {
+	defer PanicRecovery("HandleTestGrokPattern")
+
+	var (
+		err      error
+		req      GrokTestReq
+		userId   string
+		pattern  *GrokPattern
+		location *time.Location
+	)
+
+	userId, err = extractToken(ctx, "user_id")
+	if err != nil {
+		log.Errorf("failed to get user_id from context; err: ", err)
+		SendResponse(ctx, http.StatusUnauthorized, "internal server error", "", 0)
+		return
+	}
+
+	if err = ctx.ShouldBindJSON(&req); err != nil {
+		SendResponse(ctx, http.StatusBadRequest, "invalid request body", nil, 0)
+		return
+	}
+	if len(req.Lines) > GROK_MAX_TEST_LINES {
+		SendResponse(ctx, http.StatusBadRequest, "at most 100 lines can be tested", nil, 0)
+		return
+	}
+
+	if location, err = loadSourceTimezone(req.Timezone); err != nil {
+		SendResponse(ctx, http.StatusBadRequest, "invalid timezone", nil, 0)
+		return
+	}
+
+	if req.PatternId > 0 {
+		pattern, err = getGrokPattern(req.PatternId, userId)
+		if err != nil {
+			if errors.Is(err, ErrGrokPatternNotFound) {
+				SendResponse(ctx, http.StatusNotFound, err.Error(), nil, 0)
+				return
+			}
+			log.Errorf("failed to get grok pattern %d; err: %v", req.PatternId, err)
+			SendResponse(ctx, http.StatusInternalServerError, "internal server error", nil, 0)
+			return
+		}
+	} else {
+		pattern, err = compileGrokPattern(req.Pattern, req.Definitions)
+		if err != nil {
+			SendResponse(ctx, http.StatusBadRequest, err.Error(), nil, 0)
+			return
+		}
+	}
+
+	timestamps := newTimestampRecognizer(location, time.Now())
+	results := make([]GrokTestResult, 0, len(req.Lines))
+	matched := 0
+	for _, line := range req.Lines {
+		result := GrokTestResult{Line: line}
+		result.Fields, result.Matched = pattern.match(line)
+		if result.Matched {
+			matched++
+			if entry, err := pattern.parseLine(line, 0, timestamps); err == nil {
+				result.Entry = &GrokTestEntry{
+					Timestamp:    entry.Timestamp,
+					RawTimestamp: entry.RawTimestamp,
+					Level:        entry.LogLevel,
+					Message:      entry.Message,
+					IP:           entry.IP,
+					Attributes:   entry.Attributes,
+				}
+			} else {
+				result.Error = "matched but the timestamp field is missing or not a supported format"
+			}
+		}
+		results = append(results, result)
+	}
+
+	SendResponse(ctx, http.StatusOK, "grok pattern tested successfully", results, int64(matched))
+}
+
+/******************************************************************************
+* FUNCTION:        resolveParseOptions
+*
+* DESCRIPTION:     Builds the parser options of an upload from the optional
+*                  patternId (0 when not given), which must be a pattern of
+*                  the user, the optional IANA timezone and the optional
+*                  character encoding of the source. Sends the error
+*                  response and returns false otherwise
+* INPUT:           gin context, userId, patternId, timezone, encoding
+* RETURNS:         tasks.LogParseOptions, ok
+******************************************************************************/
+func resolveParseOptions(ctx *gin.Context, userId string, patternId int64, timezone, encoding string) (tasks.LogParseOptions, bool) {
+	var options tasks.LogParseOptions
+
+	if _, err := loadSourceTimezone(timezone); err != nil {
+		SendResponse(ctx, http.StatusBadRequest, "invalid timezone", nil, 0)
+		return options, false
+	}
+	options.Timezone = timezone
+
+	encodingName, err := lookupEncoding(encoding)
+	if err != nil {
+		SendResponse(ctx, http.StatusBadRequest, "invalid encoding", nil, 0)
+		return options, false
+	}
+	options.Encoding = encodingName
+
+	if patternId == 0 {
+		return options, true
+	}
+
+	if _, err := getGrokPattern(patternId, userId); err != nil {
+		if errors.Is(err, ErrGrokPatternNotFound) {
+			SendResponse(ctx, http.StatusBadRequest, err.Error(), nil, 0)
+			return options, false
+		}
+		log.Errorf("failed to get grok pattern %d; err: %v", patternId, err)
+		SendResponse(ctx, http.StatusInternalServerError, "internal server error", nil, 0)
+		return options, false
+	}
+
+	options.PatternId = patternId
+	return options, true
+}
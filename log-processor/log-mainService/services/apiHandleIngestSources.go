@@ -0,0 +1,312 @@
+/**************************************************************************
+ * File       	   : apiHandleIngestSources.go
+ * DESCRIPTION     : This file contains functions that register, list,
+ *                   delete and trigger the remote ingest sources of a
+ *                   user
+ * DATE            : 19-October-2026
+ **************************************************************************/
+
+package services
+
+import (
+	"LOGProcessor/log-mainService/tasks"
+	"LOGProcessor/shared/db"
+	"LOGProcessor/shared/types"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/martian/log"
+	"github.com/hibiken/asynq"
+	"github.com/robfig/cron/v3"
+)
+
+const (
+	MAX_SOURCE_OBJECTS_PAGE_SIZE = 100
+)
+
+type IngestSourceReq struct {
+	Name        string                  `json:"name" binding:"required"`
+	SourceType  string                  `json:"type" binding:"required"`
+	CronSpec    string                  `json:"cronSpec" binding:"required"`
+	Enabled     *bool                   `json:"enabled"`
+	Config      IngestSourceConfig      `json:"config"`
+	Credentials IngestSourceCredentials `json:"credentials"`
+}
+
+/******************************************************************************
+* FUNCTION:        HandleCreateIngestSource
+*
+* DESCRIPTION:     This function registers a new remote source that is
+*                  fetched on its cron schedule. Credentials are stored
+*                  encrypted and never returned
+* INPUT:					 gin context
+* RETURNS:         void
+******************************************************************************/
+func HandleCreateIngestSource(ctx *gin.Context) {
+	defer PanicRecovery("HandleCreateIngestSource")
+
+	var (
+		err      error
+		req      IngestSourceReq
+		userId   string
+		sourceId int64
+	)
+
+	userId, err = extractToken(ctx, "user_id")
+	if err != nil {
+		log.Errorf("failed to get user_id from context; err: ", err)
+		SendResponse(ctx, http.StatusUnauthorized, "internal server error", "", 0)
+		return
+	}
+
+	if err = ctx.ShouldBindJSON(&req); err != nil {
+		SendResponse(ctx, http.StatusBadRequest, "invalid request body", nil, 0)
+		return
+	}
+
+	if _, err = cron.ParseStandard(req.CronSpec); err != nil {
+		SendResponse(ctx, http.StatusBadRequest, "invalid cronSpec: "+err.Error(), nil, 0)
+		return
+	}
+
+	source := &IngestSource{
+		UserId:      userId,
+		Name:        req.Name,
+		SourceType:  req.SourceType,
+		CronSpec:    req.CronSpec,
+		Config:      req.Config,
+		Credentials: req.Credentials,
+	}
+	if err = validateIngestSource(source); err != nil {
+		SendResponse(ctx, http.StatusBadRequest, err.Error(), nil, 0)
+		return
+	}
+
+	configJSON, _ := json.Marshal(req.Config)
+	data := map[string]interface{}{
+		"user_id":     userId,
+		"name":        req.Name,
+		"source_type": req.SourceType,
+		"cron_spec":   req.CronSpec,
+		"enabled":     req.Enabled == nil || *req.Enabled,
+		"config":      string(configJSON),
+		"created_at":  time.Now(),
+	}
+
+	if req.Credentials != (IngestSourceCredentials{}) {
+		credsJSON, _ := json.Marshal(req.Credentials)
+		data["credentials"], err = encryptCredentials(credsJSON)
+		if err != nil {
+			if errors.Is(err, ErrCredentialsKeyMissing) {
+				SendResponse(ctx, http.StatusBadRequest, "storing credentials is not enabled on this server", nil, 0)
+				return
+			}
+			log.Errorf("failed to encrypt credentials; err: %v", err)
+			SendResponse(ctx, http.StatusInternalServerError, "internal server error", nil, 0)
+			return
+		}
+	}
+
+	sourceId, err = db.InsertAndReturnColumn(nil, "ingest_sources", "source_id", data)
+	if err != nil {
+		log.Errorf("failed to insert into ingest_sources; err: %v", err)
+		SendResponse(ctx, http.StatusInternalServerError, "internal server error", nil, 0)
+		return
+	}
+
+	delete(data, "credentials")
+	data["source_id"] = sourceId
+	data["config"] = req.Config
+	SendResponse(ctx, http.StatusOK, "ingest source created successfully", data, 1)
+}
+
+/******************************************************************************
+* FUNCTION:        HandleGetIngestSources
+*
+* DESCRIPTION:     This function lists the sources of the user along with
+*                  the outcome of their last run
+* INPUT:					 gin context
+* RETURNS:         void
+******************************************************************************/
+func HandleGetIngestSources(ctx *gin.Context) {
+	defer PanicRecovery("HandleGetIngestSources")
+
+	userId, err := extractToken(ctx, "user_id")
+	if err != nil {
+		log.Errorf("failed to get user_id from context; err: ", err)
+		SendResponse(ctx, http.StatusUnauthorized, "internal server error", "", 0)
+		return
+	}
+
+	query := `
+	SELECT source_id, name, source_type, cron_spec, enabled, config, last_run_at,
+	last_run_status, last_run_ingested, last_error, created_at
+	FROM ingest_sources WHERE user_id = $1
+	ORDER BY source_id ASC`
+
+	result, err := db.GetDataFromDB(query, []interface{}{userId})
+	if err != nil {
+		log.Errorf("failed to get data from db; err: ", err)
+		SendResponse(ctx, http.StatusBadRequest, "internal server error", nil, 0)
+		return
+	}
+
+	SendResponse(ctx, http.StatusOK, "ingest sources retrieved succesfully", result, int64(len(result)))
+}
+
+/******************************************************************************
+* FUNCTION:        HandleDeleteIngestSource
+*
+* DESCRIPTION:     This function deletes a source of the user. The periodic
+*                  task manager drops its schedule on the next sync
+* INPUT:					 gin context
+* RETURNS:         void
+******************************************************************************/
+func HandleDeleteIngestSource(ctx *gin.Context) {
+	defer PanicRecovery("HandleDeleteIngestSource")
+
+	source, ok := getOwnedIngestSource(ctx)
+	if !ok {
+		return
+	}
+
+	_, err := db.UpdateDataInDB(nil, "DELETE FROM ingest_sources WHERE source_id = $1", []interface{}{source.SourceId})
+	if err != nil {
+		log.Errorf("failed to delete source %d; err: %v", source.SourceId, err)
+		SendResponse(ctx, http.StatusInternalServerError, "internal server error", nil, 0)
+		return
+	}
+
+	SendResponse(ctx, http.StatusOK, "ingest source deleted successfully", source.SourceId, 1)
+}
+
+/******************************************************************************
+* FUNCTION:        HandleRunIngestSource
+*
+* DESCRIPTION:     This function triggers a run of the source right away
+*                  without waiting for its schedule
+* INPUT:					 gin context
+* RETURNS:         void
+******************************************************************************/
+func HandleRunIngestSource(ctx *gin.Context) {
+	defer PanicRecovery("HandleRunIngestSource")
+
+	source, ok := getOwnedIngestSource(ctx)
+	if !ok {
+		return
+	}
+
+	task, opts, err := tasks.NewSourceIngestTask(source.SourceId)
+	if err != nil {
+		log.Errorf("failed to create ingest task; err: %v", err)
+		SendResponse(ctx, http.StatusInternalServerError, "internal server error", nil, 0)
+		return
+	}
+
+	taskInfo, err := types.AsynqClient.AsynqClient.EnqueueContext(ctx, task, opts...)
+	if err != nil {
+		if errors.Is(err, asynq.ErrDuplicateTask) {
+			SendResponse(ctx, http.StatusConflict, "a run of this source is already queued", nil, 0)
+			return
+		}
+		log.Errorf("failed to enqueue ingest task; err: %v", err)
+		SendResponse(ctx, http.StatusInternalServerError, "internal server error", nil, 0)
+		return
+	}
+
+	SendResponse(ctx, http.StatusOK, "ingest source run queued", taskInfo.ID, 1)
+}
+
+/******************************************************************************
+* FUNCTION:        HandleGetIngestSourceObjects
+*
+* DESCRIPTION:     This function lists the objects already ingested from a
+*                  source, paginated by the last id like the log stats
+* INPUT:					 gin context
+* RETURNS:         void
+******************************************************************************/
+func HandleGetIngestSourceObjects(ctx *gin.Context) {
+	defer PanicRecovery("HandleGetIngestSourceObjects")
+
+	source, ok := getOwnedIngestSource(ctx)
+	if !ok {
+		return
+	}
+
+	pageSize, err := strconv.Atoi(ctx.DefaultQuery("pageSize", "10"))
+	if err != nil || pageSize <= 0 || pageSize > MAX_SOURCE_OBJECTS_PAGE_SIZE {
+		SendResponse(ctx, http.StatusBadRequest, "invalid pageSize, at most 100", nil, 0)
+		return
+	}
+
+	lastId, err := strconv.ParseInt(ctx.DefaultQuery("lastId", "0"), 10, 64)
+	if err != nil {
+		SendResponse(ctx, http.StatusBadRequest, "invalid lastId", nil, 0)
+		return
+	}
+
+	query := `
+	SELECT id, object_key, object_version, file_id, ingested_at FROM ingest_source_objects
+	WHERE source_id = $1 AND id > $2
+	ORDER BY id ASC
+	LIMIT $3`
+
+	result, err := db.GetDataFromDB(query, []interface{}{source.SourceId, lastId, pageSize})
+	if err != nil {
+		log.Errorf("failed to get data from db; err: ", err)
+		SendResponse(ctx, http.StatusBadRequest, "internal server error", nil, 0)
+		return
+	}
+
+	var nextLastId int64
+	if len(result) > 0 {
+		nextLastId, _ = result[len(result)-1]["id"].(int64)
+	}
+
+	responseData := map[string]interface{}{
+		"data":       result,
+		"nextLastId": nextLastId,
+		"pageSize":   pageSize,
+	}
+
+	SendResponse(ctx, http.StatusOK, "ingested objects retrieved succesfully", responseData, int64(len(result)))
+}
+
+/******************************************************************************
+* FUNCTION:        getOwnedIngestSource
+*
+* DESCRIPTION:     Helper function to load the source of the sourceId param
+*                  and check it belongs to the user. Sends the error
+*                  response itself
+* INPUT:           gin context
+* RETURNS:         *IngestSource, ok
+******************************************************************************/
+func getOwnedIngestSource(ctx *gin.Context) (*IngestSource, bool) {
+	userId, err := extractToken(ctx, "user_id")
+	if err != nil {
+		log.Errorf("failed to get user_id from context; err: ", err)
+		SendResponse(ctx, http.StatusUnauthorized, "internal server error", "", 0)
+		return nil, false
+	}
+
+	sourceId, err := strconv.ParseInt(ctx.Param("sourceId"), 10, 64)
+	if err != nil {
+		SendResponse(ctx, http.StatusBadRequest, "invalid sourceId", nil, 0)
+		return nil, false
+	}
+
+	source, err := getIngestSource(sourceId, false)
+	if err != nil || source.UserId != userId {
+		if err != nil && !errors.Is(err, ErrSourceNotFound) {
+			log.Errorf("failed to get source %d; err: %v", sourceId, err)
+		}
+		SendResponse(ctx, http.StatusNotFound, "ingest source not found", nil, 0)
+		return nil, false
+	}
+
+	return source, true
+}
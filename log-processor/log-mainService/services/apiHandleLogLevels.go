@@ -0,0 +1,164 @@
+/**************************************************************************
+ * File       	   : apiHandleLogLevels.go
+ * DESCRIPTION     : This file contains functions that list the canonical
+ *                   log levels and manage the level mappings of a user
+ * DATE            : 19-October-2026
+ **************************************************************************/
+
+package services
+
+import (
+	"LOGProcessor/shared/db"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/martian/log"
+)
+
+const (
+	LEVEL_MAX_RAW_LEN = 64
+)
+
+type LevelMappingReq struct {
+	RawLevel string `json:"rawLevel" binding:"required"`
+	Level    string `json:"level" binding:"required"`
+}
+
+/******************************************************************************
+* FUNCTION:        HandleGetLogLevels
+*
+* DESCRIPTION:     This function lists the canonical levels with their
+*                  severity and the level mappings of the user
+* INPUT:					 gin context
+* RETURNS:         void
+******************************************************************************/
+func HandleGetLogLevels(ctx *gin.Context) {
+	defer PanicRecovery("HandleGetLogLevels")
+
+	userId, err := extractToken(ctx, "user_id")
+	if err != nil {
+		log.Errorf("failed to get user_id from context; err: ", err)
+		SendResponse(ctx, http.StatusUnauthorized, "internal server error", "", 0)
+		return
+	}
+
+	query := `
+	SELECT raw_level, level, created_at FROM level_mappings
+	WHERE user_id = $1
+	ORDER BY raw_level ASC`
+
+	mappings, err := db.GetDataFromDB(query, []interface{}{userId})
+	if err != nil {
+		log.Errorf("failed to get data from db; err: ", err)
+		SendResponse(ctx, http.StatusBadRequest, "internal server error", nil, 0)
+		return
+	}
+
+	levels := make([]map[string]interface{}, 0, len(canonicalLevels))
+	for severity, level := range canonicalLevels {
+		levels = append(levels, map[string]interface{}{"level": level, "severity": severity})
+	}
+
+	responseData := map[string]interface{}{
+		"levels":   levels,
+		"mappings": mappings,
+	}
+
+	SendResponse(ctx, http.StatusOK, "log levels retrieved succesfully", responseData, int64(len(mappings)))
+}
+
+/******************************************************************************
+* FUNCTION:        HandlePutLevelMapping
+*
+* DESCRIPTION:     This function maps a raw level of the user's logs to a
+*                  canonical level, replacing an existing mapping. The
+*                  mapping takes precedence over the built-in aliases for
+*                  lines processed afterwards
+* INPUT:					 gin context
+* RETURNS:         void
+******************************************************************************/
+func HandlePutLevelMapping(ctx *gin.Context) {
+	defer PanicRecovery("HandlePutLevelMapping")
+
+	var req LevelMappingReq
+
+	userId, err := extractToken(ctx, "user_id")
+	if err != nil {
+		log.Errorf("failed to get user_id from context; err: ", err)
+		SendResponse(ctx, http.StatusUnauthorized, "internal server error", "", 0)
+		return
+	}
+
+	if err = ctx.ShouldBindJSON(&req); err != nil {
+		SendResponse(ctx, http.StatusBadRequest, "invalid request body", nil, 0)
+		return
+	}
+
+	rawLevel := strings.ToUpper(strings.TrimSpace(req.RawLevel))
+	level := strings.ToUpper(strings.TrimSpace(req.Level))
+	if rawLevel == "" || len(rawLevel) > LEVEL_MAX_RAW_LEN {
+		SendResponse(ctx, http.StatusBadRequest, "invalid rawLevel", nil, 0)
+		return
+	}
+	if !isCanonicalLevel(level) {
+		SendResponse(ctx, http.StatusBadRequest, "level must be one of TRACE, DEBUG, INFO, WARN, ERROR, FATAL", nil, 0)
+		return
+	}
+
+	query := `
+	INSERT INTO level_mappings (user_id, raw_level, level, created_at)
+	VALUES ($1, $2, $3, $4)
+	ON CONFLICT (user_id, raw_level) DO UPDATE SET level = EXCLUDED.level, created_at = EXCLUDED.created_at`
+
+	if _, err = db.UpdateDataInDB(nil, query, []interface{}{userId, rawLevel, level, time.Now()}); err != nil {
+		log.Errorf("failed to store level mapping; err: %v", err)
+		SendResponse(ctx, http.StatusInternalServerError, "internal server error", nil, 0)
+		return
+	}
+	invalidateLevelMappings(userId)
+
+	responseData := map[string]interface{}{
+		"rawLevel": rawLevel,
+		"level":    level,
+		"severity": levelSeverities[level],
+	}
+
+	SendResponse(ctx, http.StatusOK, "level mapping stored successfully", responseData, 1)
+}
+
+/******************************************************************************
+* FUNCTION:        HandleDeleteLevelMapping
+*
+* DESCRIPTION:     This function deletes a level mapping of the user, the
+*                  raw level falls back to the built-in aliases
+* INPUT:					 gin context
+* RETURNS:         void
+******************************************************************************/
+func HandleDeleteLevelMapping(ctx *gin.Context) {
+	defer PanicRecovery("HandleDeleteLevelMapping")
+
+	userId, err := extractToken(ctx, "user_id")
+	if err != nil {
+		log.Errorf("failed to get user_id from context; err: ", err)
+		SendResponse(ctx, http.StatusUnauthorized, "internal server error", "", 0)
+		return
+	}
+
+	rawLevel := strings.ToUpper(strings.TrimSpace(ctx.Param("rawLevel")))
+
+	rows, err := db.UpdateDataInDB(nil, "DELETE FROM level_mappings WHERE user_id = $1 AND raw_level = $2", []interface{}{userId, rawLevel})
+	if err != nil {
+		log.Errorf("failed to delete level mapping %s; err: %v", rawLevel, err)
+		SendResponse(ctx, http.StatusInternalServerError, "internal server error", nil, 0)
+		return
+	}
+	if rows == 0 {
+		SendResponse(ctx, http.StatusNotFound, "level mapping not found", nil, 0)
+		return
+	}
+	invalidateLevelMappings(userId)
+
+	SendResponse(ctx, http.StatusOK, "level mapping deleted successfully", rawLevel, 1)
+}
@@ -8,6 +8,7 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -21,14 +22,37 @@ import (
 )
 
 const (
-	MAX_CHUNKS = 1
+	MAX_CHUNKS        = 1
+	UNKNOWN_LOG_LEVEL = "UNKNOWN"
+	// files above this size are read in chunks
+	LARGE_FILE_THRESHOLD_BYTES = 1 << 30
+	// number of lines after which processing checks whether the task
+	// context was cancelled, e.g. on worker shutdown
+	CTX_CHECK_INTERVAL = 1000
 )
 
 var (
+	// ErrUnparseableFile is returned when not a single line of the file
+	// matches the log format, retrying would give the same result
+	ErrUnparseableFile = errors.New("no line of the log file could be parsed")
+
 	logLineRegex = regexp.MustCompile(`\[(.*?)\]\s+(\w+)\s+(.*)`)
-	ipRegex      = regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b`)
+	// level after a leading timestamp: " ERROR msg", " [warn] msg", " INFO: msg"
+	logLevelPrefixRegex = regexp.MustCompile(`^\s+[\[(]?([A-Za-z]+)[\])]?:?\s+(.*)$`)
+
+	defaultLineParser = &logLineParser{timestamps: defaultTimestamps}
 )
 
+// logLineParser parses the built-in line formats, timestamps are read by
+// the recognizer of the file
+type logLineParser struct {
+	timestamps *timestampRecognizer
+}
+
+// lineParseFunc parses one line of a file into a LogEntry, lines that do
+// not parse return ErrLineNoMatch or ErrLineBadTimestamp
+type lineParseFunc func(line string, fileID int64) (LogEntry, error)
+
 type FileChunk struct {
 	StartOffset int64
 	EndOffset   int64
@@ -36,12 +60,44 @@ type FileChunk struct {
 }
 
 type LogEntry struct {
-	Timestamp       time.Time
+	Timestamp time.Time
+	// RawTimestamp is the timestamp as written in the line
+	RawTimestamp string
+	// LogLevel is the canonical level, RawLevel the level as written
 	LogLevel        string
+	RawLevel        string
+	Severity        int
 	Message         string
 	KeywordDetected string
 	IP              string
 	FileID          int64
+	// Attributes holds structured fields of the record, stored as json
+	Attributes map[string]interface{}
+	TraceID    string
+	SpanID     string
+	// Stream (stdout/stderr) and kubernetes metadata of container logs
+	Stream    string
+	Namespace string
+	Pod       string
+	Container string
+	// Durations holds duration fields in milliseconds, for latency stats
+	Durations map[string]float64
+	// Truncated flags the entry of a line cut at the maximum line length,
+	// LineBytes and LineNumber locate the whole line
+	Truncated  bool
+	LineBytes  int64
+	LineNumber int64
+	// GeoIP and ASN enrichment of IP
+	GeoCountry string
+	GeoCity    string
+	ASN        int64
+	ASOrg      string
+	// IPTags are the names of the user's CIDR sets containing IP
+	IPTags []string
+	// ThreatIndicator is the blocklisted ip, network or domain the entry
+	// touched, ThreatList the blocklist it is from
+	ThreatIndicator string
+	ThreatList      string
 }
 
 type KeywordStats map[string]int
@@ -55,6 +111,11 @@ type LogStats struct {
 	JobID         string
 	KeywordCounts KeywordStats
 	ErrorCount    int
+	Lines         *LineAccounting
+	// Spilled holds the full content of cut oversize lines
+	Spilled []spilledLine
+	// Encoding is the encoding the file was read with
+	Encoding string
 }
 
 /******************************************************************************
@@ -68,11 +129,8 @@ func HandleAsyncTaskMethod(ctx context.Context, t *asynq.Task) error {
 	defer PanicRecovery("HandleAsyncTaskMethod")
 
 	var (
-		err           error
-		logStats      *LogStats
-		fileSizeBytes int64
-		queueName     string
-		inspector     = types.AsynqClient.AsynqInspector
+		err      error
+		logStats *LogStats
 	)
 
 	payload := t.Payload()
@@ -80,24 +138,44 @@ func HandleAsyncTaskMethod(ctx context.Context, t *asynq.Task) error {
 	json.Unmarshal(payload, &pay)
 	startTime := time.Now()
 	taskID := t.ResultWriter().TaskID()
+	retried, _ := asynq.GetRetryCount(ctx)
 
-	if fileSizeBytes > 1073741824 {
-		queueName = "low"
-	} else {
-		queueName = "high"
-	}
+	// the user's fair scheduling slot is held across retries and only
+	// released once the task will not run again
+	defer func() {
+		if cancelledByShutdown(ctx) {
+			return
+		}
+		if err == nil || isFinalAttempt(ctx, err) {
+			tasks.ReleaseTenantSlot(pay.UserId, taskID)
+		}
+	}()
 
-	taskInfo, inspErr := inspector.GetTaskInfo(queueName, taskID)
-	if inspErr == nil && taskInfo.Retried < 1 {
+	if retried < 1 {
 		BroadcastMessage(fmt.Sprintf("Job %s active", taskID), "job-update", pay.UserId)
 	}
 
 	defer func() {
+		if err != nil && cancelledByShutdown(ctx) {
+			log.Infof("job %s interrupted by the shutdown, it is back in the queue", taskID)
+			return
+		}
 		if err != nil {
-			if inspErr == nil && taskInfo.Retried == (taskInfo.MaxRetry-1) {
+			final := isFinalAttempt(ctx, err)
+			recordJobAttemptFailure(pay.FileId, taskID, retried+1, err, !final)
+			if final {
+				failureReason := fmt.Sprintf("task permanently failed after max retries: %v", err)
+				if errors.Is(err, asynq.SkipRetry) {
+					failureReason = fmt.Sprintf("task failed without retry: %v", err)
+				}
 				BroadcastMessage(fmt.Sprintf("Job %s failed", taskID), "job-update", pay.UserId)
-				data, _ := updateFileStats(nil, pay.FileId, "Failed", startTime, 0,
-					fmt.Sprintf("task permanently failed after max retries: %v", err))
+				data, _ := updateFileStats(nil, pay.FileId, "Failed", startTime, 0, failureReason)
+				// the rejected lines tell why a file did not parse
+				if logStats != nil && logStats.Lines != nil {
+					if accErr := storeLineAccounting(nil, pay.FileId, logStats.Lines); accErr != nil {
+						log.Errorf("failed to store line accounting of file %d; err: %v", pay.FileId, accErr)
+					}
+				}
 				data["file_id"] = pay.FileId
 				BroadcastMessage(data, "log-table-update", pay.UserId)
 				//! delete file from supabase
@@ -120,13 +198,40 @@ func HandleAsyncTaskMethod(ctx context.Context, t *asynq.Task) error {
 		}
 	}()
 
-	if fileSizeBytes > 1073741824 {
-		logStats, err = processLargeLogFile(ctx, pay.FilePath, pay.FileId, pay.FileSizeBytes)
+	parse, err := lineParserFor(pay)
+	if err != nil {
+		return err
+	}
+
+	redactor, err := getRedactor(pay.UserId)
+	if err != nil {
+		return fmt.Errorf("failed to load redaction rules: %v", err)
+	}
+	cidrSets, err := getCidrSets(pay.UserId)
+	if err != nil {
+		return fmt.Errorf("failed to load cidr sets: %v", err)
+	}
+	var (
+		redactions RedactionCounts
+		threatHits int64
+	)
+
+	if pay.FileSizeBytes > LARGE_FILE_THRESHOLD_BYTES {
+		logStats, err = processLargeLogFile(ctx, pay.FilePath, pay.FileId, parse, pay.ParseOptions.Encoding)
 	} else {
-		logStats, err = processLogFile(ctx, pay.FilePath, pay.FileId)
-		if err != nil {
-			return fmt.Errorf("error processing log file: %v", err)
-		}
+		logStats, err = processLogFile(ctx, pay.FilePath, pay.FileId, parse, pay.ParseOptions.Encoding)
+	}
+	// the rejected lines of a failed file are stored as well. Enrichment,
+	// cidr tagging and threat matching go first, they need the ip and the
+	// host names before any redaction
+	if logStats != nil {
+		enrichLogEntries(logStats.LogEntries)
+		tagLogEntries(logStats.LogEntries, cidrSets)
+		threatHits = matchThreatEntries(logStats.LogEntries)
+		redactions = redactLogStats(logStats, redactor)
+	}
+	if err != nil {
+		return fmt.Errorf("error processing log file: %w", err)
 	}
 
 	err = insertLogEntries(tx, ctx, logStats.LogEntries)
@@ -134,6 +239,31 @@ func HandleAsyncTaskMethod(ctx context.Context, t *asynq.Task) error {
 		return fmt.Errorf("error inserting log entries: %v", err)
 	}
 
+	err = insertSpilledLines(tx, pay.FileId, logStats.Spilled)
+	if err != nil {
+		return fmt.Errorf("error inserting oversize lines: %v", err)
+	}
+
+	err = storeLineAccounting(tx, pay.FileId, logStats.Lines)
+	if err != nil {
+		return err
+	}
+
+	err = storeFileEncoding(tx, pay.FileId, logStats.Encoding)
+	if err != nil {
+		return fmt.Errorf("failed to store file encoding: %v", err)
+	}
+
+	err = storeRedactionCounts(tx, pay.FileId, redactions)
+	if err != nil {
+		return fmt.Errorf("failed to store redaction counts: %v", err)
+	}
+
+	err = storeThreatHits(tx, pay.FileId, threatHits)
+	if err != nil {
+		return fmt.Errorf("failed to store threat hits: %v", err)
+	}
+
 	keywordJSON, _ := json.Marshal(logStats.KeywordCounts)
 	data, _ := updateFileStats(tx, pay.FileId, "Completed", startTime, logStats.ErrorCount, "", string(keywordJSON))
 
@@ -143,8 +273,22 @@ func HandleAsyncTaskMethod(ctx context.Context, t *asynq.Task) error {
 	}
 	data["file_id"] = pay.FileId
 	BroadcastMessage(data, "log-table-update", pay.UserId)
+	broadcastThreatHits(pay.UserId, pay.FileId, logStats.LogEntries)
 	BroadcastMessage(fmt.Sprintf("Job %s completed", taskID), "job-update", pay.UserId)
 
+	// the job is stored already, a failure here only loses its anomalies
+	anomalies, anomalyErr := detectJobAnomalies(pay.UserId, pay.FileId, logStats.LogEntries)
+	if anomalyErr != nil {
+		log.Errorf("failed to detect anomalies of file %d; err: %v", pay.FileId, anomalyErr)
+	} else if len(anomalies) > 0 {
+		BroadcastMessage(map[string]interface{}{"file_id": pay.FileId, "anomalies": anomalies}, "anomaly-detected", pay.UserId)
+	}
+
+	// the alert rules see the anomalies of the job as well
+	if alertErr := checkJobAlerts(pay.UserId, pay.FileId, logStats, threatHits, len(anomalies)); alertErr != nil {
+		log.Errorf("failed to check alert rules of file %d; err: %v", pay.FileId, alertErr)
+	}
+
 	return nil
 }
 
@@ -155,12 +299,12 @@ func HandleAsyncTaskMethod(ctx context.Context, t *asynq.Task) error {
 * INPUT:					 gin context
 * RETURNS:         void
 ******************************************************************************/
-func processLogFile(ctx context.Context, filePath string, fileID int64) (*LogStats, error) {
+func processLogFile(ctx context.Context, filePath string, fileID int64, parse lineParseFunc, requestedEncoding string) (*LogStats, error) {
 	defer PanicRecovery("processLogFile")
 
-	fileContent, err := downloadFileFromSupeBaseStorage(filePath)
+	fileContent, err := downloadFileFromSupeBaseStorage(ctx, filePath)
 	if err != nil {
-		return nil, fmt.Errorf("error downloading file: %v", err)
+		return nil, fmt.Errorf("error downloading file: %w", err)
 	}
 
 	tempFile, err := os.CreateTemp("", "log-processing-*")
@@ -175,20 +319,72 @@ func processLogFile(ctx context.Context, filePath string, fileID int64) (*LogSta
 		return nil, fmt.Errorf("error writing to temp file: %v", err)
 	}
 
-	_, err = tempFile.Seek(0, 0)
+	sourceEnc, err := sniffFileEncoding(tempFile, requestedEncoding)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tempFile.Seek(int64(sourceEnc.BOMLen), 0)
 	if err != nil {
 		return nil, fmt.Errorf("error resetting file pointer: %v", err)
 	}
 
-	scanner := bufio.NewScanner(tempFile)
+	logStats, err := readLogLines(ctx, sourceEnc.decoder(tempFile), newContainerLogParser(filePath, fileID, parse))
+	if err != nil {
+		return nil, err
+	}
+	logStats.Encoding = sourceEnc.Name
+
+	// the stats are returned with the error so the rejected lines can be
+	// stored
+	if logStats.Lines.Total > 0 && len(logStats.LogEntries) == 0 {
+		return logStats, fmt.Errorf("%w: %w", asynq.SkipRetry, ErrUnparseableFile)
+	}
+
+	return logStats, nil
+}
+
+/******************************************************************************
+* FUNCTION:        readLogLines
+*
+* DESCRIPTION:     Parses the lines of a file or chunk. Oversize lines are
+*                  parsed cut and their entry flagged, when the cut line
+*                  does not parse it is rejected as oversize
+* INPUT:           ctx, reader, parser of the file
+* RETURNS:         LogStats, error
+******************************************************************************/
+func readLogLines(ctx context.Context, reader io.Reader, parser *containerLogParser) (*LogStats, error) {
+	lineReader := newLineReader(reader)
 	logEntries := []LogEntry{}
 	keywordCounts := make(KeywordStats)
 	errorCount := 0
+	lines := newLineAccounting()
+	var spilled []spilledLine
+
+	var lineCount int64
+	for {
+		line, err := lineReader.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading log file: %v", err)
+		}
+
+		lineCount++
+		if lineCount%CTX_CHECK_INTERVAL == 0 && ctx.Err() != nil {
+			return nil, fmt.Errorf("processing interrupted: %v", ctx.Err())
+		}
 
-	for scanner.Scan() {
-		line := scanner.Text()
-		entry, ok := parseLogLine(line, fileID)
+		entry, ok, err := parser.parseLine(line.Text)
+		if err != nil && line.Oversize {
+			err = fmt.Errorf("%w: %w", ErrLineOversize, err)
+		}
+		lines.count(lineCount, line.Text, err)
 		if ok {
+			if line.Oversize {
+				spilled = markOversize(&entry, line, lineCount, spilled)
+			}
 			logEntries = append(logEntries, entry)
 		}
 		if entry.KeywordDetected != "" {
@@ -197,44 +393,144 @@ func processLogFile(ctx context.Context, filePath string, fileID int64) (*LogSta
 		}
 	}
 
-	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("error scanning log file: %v", err)
+	for _, entry := range parser.flush() {
+		logEntries = append(logEntries, entry)
+		if entry.KeywordDetected != "" {
+			keywordCounts[entry.KeywordDetected]++
+			errorCount++
+		}
 	}
 
-	logStats := &LogStats{
+	return &LogStats{
 		LogEntries:    logEntries,
 		KeywordCounts: keywordCounts,
 		ErrorCount:    errorCount,
+		Lines:         lines,
+		Spilled:       spilled,
+	}, nil
+}
+
+/******************************************************************************
+* FUNCTION:        lineParserFor
+*
+* DESCRIPTION:     Returns the line parser selected for the upload, the
+*                  user's grok pattern or the built-in formats, reading
+*                  timestamps in the source timezone and applying the
+*                  user's level mappings. A pattern deleted in the meantime
+*                  fails the task without retry
+* INPUT:           payload
+* RETURNS:         lineParseFunc, error
+******************************************************************************/
+func lineParserFor(pay tasks.LogProcessPayload) (lineParseFunc, error) {
+	location, err := loadSourceTimezone(pay.ParseOptions.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", asynq.SkipRetry, err)
 	}
+	timestamps := newTimestampRecognizer(location, time.Now())
 
-	return logStats, nil
+	mappings, err := getLevelMappings(pay.UserId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load level mappings: %v", err)
+	}
+
+	if pay.ParseOptions.PatternId == 0 {
+		parser := &logLineParser{timestamps: timestamps}
+		return withLevelMappings(parser.parseLine, mappings), nil
+	}
+
+	pattern, err := getGrokPattern(pay.ParseOptions.PatternId, pay.UserId)
+	if err != nil {
+		if errors.Is(err, ErrGrokPatternNotFound) {
+			return nil, fmt.Errorf("%w: %w", asynq.SkipRetry, err)
+		}
+		return nil, fmt.Errorf("failed to load grok pattern: %v", err)
+	}
+
+	return withLevelMappings(func(line string, fileID int64) (LogEntry, error) {
+		return pattern.parseLine(line, fileID, timestamps)
+	}, mappings), nil
 }
 
 /******************************************************************************
 * FUNCTION:        parseLogLine
 *
-* DESCRIPTION:     This function is used to upload file to SupeBase & enqueu
-* INPUT:					 gin context
-* RETURNS:         void
+* DESCRIPTION:     Parses a line of the built-in formats, zone-less times
+*                  are UTC
+* INPUT:           line, fileID
+* RETURNS:         LogEntry, ok
 ******************************************************************************/
 func parseLogLine(line string, fileID int64) (LogEntry, bool) {
+	entry, err := defaultLineParser.parseLine(line, fileID)
+	return entry, err == nil
+}
+
+/******************************************************************************
+* FUNCTION:        parseLine
+*
+* DESCRIPTION:     Parses a "[timestamp] LEVEL message" line or a line
+*                  starting with a timestamp, optionally followed by a
+*                  level. Syslog style lines keep their tag in the message.
+*                  Lines in another format are tried as logfmt
+* INPUT:           line, fileID
+* RETURNS:         LogEntry, error
+******************************************************************************/
+func (p *logLineParser) parseLine(line string, fileID int64) (LogEntry, error) {
 	defer PanicRecovery("parseLogLine")
 
-	matches := logLineRegex.FindStringSubmatch(line)
-	if len(matches) < 4 {
-		return LogEntry{}, false
+	if matches := logLineRegex.FindStringSubmatch(line); len(matches) >= 4 {
+		if timestamp, ok := p.timestamps.parse(matches[1]); ok {
+			entry := buildLogEntry(timestamp, matches[2], matches[3], fileID)
+			entry.RawTimestamp = matches[1]
+			return entry, nil
+		}
 	}
 
-	timestamp, err := time.Parse(time.RFC3339, matches[1])
-	if err != nil {
-		timestamp, err = time.Parse("2006-01-02 15:04:05", matches[1])
-		if err != nil {
-			return LogEntry{}, false
+	timestamp, raw, rest, layout, ok := p.timestamps.parsePrefix(line)
+	if !ok {
+		entry, err := parseLogfmtLine(line, fileID, p.timestamps)
+		// a line opening with a bracket or a date is in one of the formats
+		// above but its timestamp could not be read
+		if errors.Is(err, ErrLineNoMatch) && (strings.HasPrefix(line, "[") || p.timestamps.looksLikeTimestamp(line)) {
+			err = ErrLineBadTimestamp
+		}
+		return entry, err
+	}
+
+	level := UNKNOWN_LOG_LEVEL
+	message := strings.TrimSpace(rest)
+	if matches := logLevelPrefixRegex.FindStringSubmatch(rest); matches != nil && isLogLevelWord(matches[1]) {
+		level = matches[1]
+		message = matches[2]
+	} else if layout.YearLess {
+		msg := &SyslogMessage{}
+		parseRfc3164Header(msg, message)
+		if msg.AppName != "" {
+			message = msg.AppName + ": " + msg.Message
 		}
 	}
 
-	logLevel := strings.ToUpper(matches[2])
-	message := matches[3]
+	entry := buildLogEntry(timestamp, level, message, fileID)
+	entry.RawTimestamp = raw
+	return entry, nil
+}
+
+/******************************************************************************
+* FUNCTION:        buildLogEntry
+*
+* DESCRIPTION:     Builds a LogEntry out of the parsed parts of a line. An
+*                  embedded json payload is stripped from the message, the
+*                  ip is taken from it or from the message and the first
+*                  configured keyword found is recorded. The level is kept
+*                  as written and normalized to its canonical level
+* INPUT:           timestamp, level, message, fileID
+* RETURNS:         LogEntry
+******************************************************************************/
+func buildLogEntry(timestamp time.Time, level, message string, fileID int64) LogEntry {
+	logLevel := normalizeLogLevel(level)
+	rawLevel := strings.TrimSpace(level)
+	if strings.EqualFold(rawLevel, UNKNOWN_LOG_LEVEL) {
+		rawLevel = ""
+	}
 
 	var jsonPayload map[string]interface{}
 	ip := ""
@@ -246,16 +542,13 @@ func parseLogLine(line string, fileID int64) (LogEntry, bool) {
 		if err == nil {
 			message = strings.TrimSpace(message[:jsonStart])
 			if ipValue, ok := jsonPayload["ip"]; ok {
-				ip = fmt.Sprintf("%v", ipValue)
+				ip = normalizeIP(fmt.Sprintf("%v", ipValue))
 			}
 		}
 	}
 
 	if ip == "" {
-		ipMatches := ipRegex.FindStringSubmatch(message)
-		if len(ipMatches) > 0 {
-			ip = ipMatches[0]
-		}
+		ip = extractIP(message)
 	}
 
 	keywordDetected := ""
@@ -269,13 +562,15 @@ func parseLogLine(line string, fileID int64) (LogEntry, bool) {
 	}
 
 	return LogEntry{
-		Timestamp:       timestamp,
+		Timestamp:       timestamp.UTC(),
 		LogLevel:        logLevel,
+		RawLevel:        rawLevel,
+		Severity:        levelSeverities[logLevel],
 		Message:         message,
 		KeywordDetected: keywordDetected,
 		IP:              ip,
 		FileID:          fileID,
-	}, true
+	}
 }
 
 /******************************************************************************
@@ -298,10 +593,31 @@ func insertLogEntries(tx *sql.Tx, ctx context.Context, entries []LogEntry) error
 		logData = append(logData, map[string]interface{}{
 			"file_id":          entry.FileID,
 			"err_timestamp":    entry.Timestamp,
+			"raw_timestamp":    nullIfEmpty(entry.RawTimestamp),
 			"log_level":        entry.LogLevel,
+			"raw_level":        nullIfEmpty(entry.RawLevel),
+			"severity":         entry.Severity,
 			"err_mssg":         entry.Message,
 			"keyword_detected": entry.KeywordDetected,
-			"ip":               entry.IP,
+			"ip":               nullIfEmpty(entry.IP),
+			"ip_tags":          textArrayLiteral(entry.IPTags),
+			"attributes":       attributesToJSON(entry.Attributes),
+			"trace_id":         nullIfEmpty(entry.TraceID),
+			"span_id":          nullIfEmpty(entry.SpanID),
+			"stream":           nullIfEmpty(entry.Stream),
+			"namespace":        nullIfEmpty(entry.Namespace),
+			"pod":              nullIfEmpty(entry.Pod),
+			"container":        nullIfEmpty(entry.Container),
+			"durations":        durationsToJSON(entry.Durations),
+			"truncated":        entry.Truncated,
+			"line_bytes":       nullIfZero(entry.LineBytes),
+			"line_number":      nullIfZero(entry.LineNumber),
+			"geo_country":      nullIfEmpty(entry.GeoCountry),
+			"geo_city":         nullIfEmpty(entry.GeoCity),
+			"asn":              nullIfZero(entry.ASN),
+			"as_org":           nullIfEmpty(entry.ASOrg),
+			"threat_indicator": nullIfEmpty(entry.ThreatIndicator),
+			"threat_list":      nullIfEmpty(entry.ThreatList),
 			"created_at":       time.Now(),
 		})
 	}
@@ -316,26 +632,95 @@ func insertLogEntries(tx *sql.Tx, ctx context.Context, entries []LogEntry) error
 		batch := logData[i:end]
 		err := db.AddMultipleRecordInDB(tx, "log_stats", batch)
 		if err != nil {
-			return fmt.Errorf("error inserting log batch: %v", err)
+			return fmt.Errorf("error inserting log batch: %w", err)
 		}
 	}
 
 	return nil
 }
 
+/******************************************************************************
+* FUNCTION:        attributesToJSON
+*
+* DESCRIPTION:     Helper function to encode the attributes column, nil
+*                  when the entry has none
+* INPUT:           attributes
+* RETURNS:         interface{}
+******************************************************************************/
+func attributesToJSON(attributes map[string]interface{}) interface{} {
+	if len(attributes) == 0 {
+		return nil
+	}
+
+	encoded, err := json.Marshal(attributes)
+	if err != nil {
+		return nil
+	}
+	return string(encoded)
+}
+
+/******************************************************************************
+* FUNCTION:        durationsToJSON
+*
+* DESCRIPTION:     Helper function to encode the durations column, nil
+*                  when the entry has none
+* INPUT:           durations
+* RETURNS:         interface{}
+******************************************************************************/
+func durationsToJSON(durations map[string]float64) interface{} {
+	if len(durations) == 0 {
+		return nil
+	}
+
+	encoded, err := json.Marshal(durations)
+	if err != nil {
+		return nil
+	}
+	return string(encoded)
+}
+
+/******************************************************************************
+* FUNCTION:        nullIfEmpty
+*
+* DESCRIPTION:     Helper function to store empty optional strings as NULL
+* INPUT:           value
+* RETURNS:         interface{}
+******************************************************************************/
+func nullIfEmpty(value string) interface{} {
+	if value == "" {
+		return nil
+	}
+	return value
+}
+
+/******************************************************************************
+* FUNCTION:        nullIfZero
+*
+* DESCRIPTION:     Helper function to store unset optional numbers as NULL
+* INPUT:           value
+* RETURNS:         interface{}
+******************************************************************************/
+func nullIfZero(value int64) interface{} {
+	if value == 0 {
+		return nil
+	}
+	return value
+}
+
 /******************************************************************************
 * FUNCTION:        processLargeLogFile
 *
-* DESCRIPTION:     Process large log files by breaking into chunks
-* INPUT:           Context, file path, ID, size, user ID, job ID
+* DESCRIPTION:     Process large log files by breaking into chunks. The
+*                  chunks follow the size of the downloaded file
+* INPUT:           Context, file path, ID, line parser, requested encoding
 * RETURNS:         LogStats, error
 ******************************************************************************/
-func processLargeLogFile(ctx context.Context, filePath string, fileID int64, fileSize int64) (*LogStats, error) {
+func processLargeLogFile(ctx context.Context, filePath string, fileID int64, parse lineParseFunc, requestedEncoding string) (*LogStats, error) {
 	defer PanicRecovery("processLargeLogFile")
 
-	fileContent, err := downloadFileFromSupeBaseStorage(filePath)
+	fileContent, err := downloadFileFromSupeBaseStorage(ctx, filePath)
 	if err != nil {
-		return nil, fmt.Errorf("error downloading file: %v", err)
+		return nil, fmt.Errorf("error downloading file: %w", err)
 	}
 	defer fileContent.Close()
 
@@ -346,25 +731,37 @@ func processLargeLogFile(ctx context.Context, filePath string, fileID int64, fil
 	defer os.Remove(tempFile.Name())
 	defer tempFile.Close()
 
-	_, err = io.Copy(tempFile, fileContent)
+	fileSize, err := io.Copy(tempFile, fileContent)
 	if err != nil {
 		return nil, fmt.Errorf("error writing to temp file: %v", err)
 	}
 
+	sourceEnc, err := sniffFileEncoding(tempFile, requestedEncoding)
+	if err != nil {
+		return nil, err
+	}
+
 	chunkSize := fileSize / MAX_CHUNKS
 	var chunks []FileChunk
 
-	for i := 0; i < MAX_CHUNKS; i++ {
-		startOffset := int64(i) * chunkSize
-		endOffset := startOffset + chunkSize
-		if i == MAX_CHUNKS-1 {
-			endOffset = fileSize
+	if sourceEnc.lineSplittable() {
+		for i := 0; i < MAX_CHUNKS; i++ {
+			startOffset := int64(i) * chunkSize
+			endOffset := startOffset + chunkSize
+			if i == MAX_CHUNKS-1 {
+				endOffset = fileSize
+			}
+			chunks = append(chunks, FileChunk{
+				StartOffset: startOffset,
+				EndOffset:   endOffset,
+				ChunkIndex:  i,
+			})
 		}
-		chunks = append(chunks, FileChunk{
-			StartOffset: startOffset,
-			EndOffset:   endOffset,
-			ChunkIndex:  i,
-		})
+		chunks[0].StartOffset = int64(sourceEnc.BOMLen)
+	} else {
+		// a "\n" byte does not end a line in utf-16, the file is read as
+		// one chunk
+		chunks = append(chunks, FileChunk{StartOffset: int64(sourceEnc.BOMLen), EndOffset: fileSize})
 	}
 
 	var wg sync.WaitGroup
@@ -373,28 +770,32 @@ func processLargeLogFile(ctx context.Context, filePath string, fileID int64, fil
 	chunkErrors := make([]error, MAX_CHUNKS)
 	finalKeywordStats := make(KeywordStats)
 	finalErrCount := 0
+	finalLines := newLineAccounting()
+	var finalSpilled []spilledLine
 
 	for i, chunk := range chunks {
 		wg.Add(1)
 		go func(c FileChunk, index int) {
 			defer wg.Done()
 
-			chunkEntries, keywordStats, errCount, err := processFileChunk(tempFile, c, fileID)
+			chunkStats, err := processFileChunk(ctx, tempFile, c, filePath, fileID, parse, sourceEnc)
 			if err != nil {
 				chunkErrors[index] = err
 				return
 			}
 
 			mu.Lock()
-			for k, _ := range keywordStats {
+			for k, _ := range chunkStats.KeywordCounts {
 				if _, ok := finalKeywordStats[k]; !ok {
-					finalKeywordStats[k] = keywordStats[k]
+					finalKeywordStats[k] = chunkStats.KeywordCounts[k]
 				} else {
-					finalKeywordStats[k] += keywordStats[k]
+					finalKeywordStats[k] += chunkStats.KeywordCounts[k]
 				}
 			}
-			finalErrCount += errCount
-			allLogEntries = append(allLogEntries, chunkEntries...)
+			finalErrCount += chunkStats.ErrorCount
+			finalLines.merge(chunkStats.Lines)
+			finalSpilled = append(finalSpilled, chunkStats.Spilled...)
+			allLogEntries = append(allLogEntries, chunkStats.LogEntries...)
 			mu.Unlock()
 		}(chunk, i)
 	}
@@ -411,64 +812,48 @@ func processLargeLogFile(ctx context.Context, filePath string, fileID int64, fil
 		LogEntries:    allLogEntries,
 		KeywordCounts: finalKeywordStats,
 		ErrorCount:    finalErrCount,
+		Lines:         finalLines,
+		Spilled:       finalSpilled,
+		Encoding:      sourceEnc.Name,
 	}, nil
 }
 
 /******************************************************************************
 * FUNCTION:        processFileChunk
 *
-* DESCRIPTION:     Process a single chunk of a log file
-* INPUT:           File, chunk info, file ID
-* RETURNS:         Log entries, error
+* DESCRIPTION:     Process a single chunk of a log file, line numbers of
+*                  rejected lines count from the start of the chunk
+* INPUT:           Context, file, chunk info, file path, file ID, line
+*                  parser, encoding of the file
+* RETURNS:         LogStats of the chunk, error
 ******************************************************************************/
-func processFileChunk(file *os.File, chunk FileChunk, fileID int64) ([]LogEntry, KeywordStats, int, error) {
+func processFileChunk(ctx context.Context, file *os.File, chunk FileChunk, filePath string, fileID int64, parse lineParseFunc, sourceEnc sourceEncoding) (*LogStats, error) {
 	defer PanicRecovery("processFileChunk")
 
 	_, err := file.Seek(chunk.StartOffset, 0)
 	if err != nil {
-		return nil, nil, 0, fmt.Errorf("error seeking to chunk start: %v", err)
+		return nil, fmt.Errorf("error seeking to chunk start: %v", err)
 	}
 
-	if chunk.StartOffset > 0 {
+	if chunk.ChunkIndex > 0 {
 		reader := bufio.NewReader(file)
 		_, err := reader.ReadString('\n')
 		if err != nil && err != io.EOF {
-			return nil, nil, 0, fmt.Errorf("error finding line boundary: %v", err)
+			return nil, fmt.Errorf("error finding line boundary: %v", err)
 		}
 	}
 
 	currentPos, err := file.Seek(0, io.SeekCurrent)
 	if err != nil {
-		return nil, nil, 0, fmt.Errorf("error getting current position: %v", err)
+		return nil, fmt.Errorf("error getting current position: %v", err)
 	}
 
 	chunkSize := chunk.EndOffset - currentPos
 	if chunkSize <= 0 {
-		return []LogEntry{}, nil, 0, nil
-	}
-
-	logEntries := []LogEntry{}
-	scanner := bufio.NewScanner(io.LimitReader(file, chunkSize))
-	errorCount := 0
-	keywordCounts := make(KeywordStats)
-
-	for scanner.Scan() {
-		line := scanner.Text()
-		entry, ok := parseLogLine(line, fileID)
-		if ok {
-			logEntries = append(logEntries, entry)
-		}
-		if entry.KeywordDetected != "" {
-			keywordCounts[entry.KeywordDetected]++
-			errorCount++
-		}
-	}
-
-	if err := scanner.Err(); err != nil {
-		return nil, nil, 0, fmt.Errorf("error scanning log file: %v", err)
+		return &LogStats{KeywordCounts: make(KeywordStats), Lines: newLineAccounting()}, nil
 	}
 
-	return logEntries, keywordCounts, errorCount, nil
+	return readLogLines(ctx, sourceEnc.decoder(io.LimitReader(file, chunkSize)), newContainerLogParser(filePath, fileID, parse))
 }
 
 /******************************************************************************
@@ -508,3 +893,47 @@ func updateFileStats(tx *sql.Tx, fileID int64, status string, startTime time.Tim
 
 	return data, nil
 }
+
+/******************************************************************************
+* FUNCTION:        isFinalAttempt
+*
+* DESCRIPTION:     Tells whether a failed attempt is the last one, either
+*                  because retries are exhausted or the error skips retry
+* INPUT:           ctx, err
+* RETURNS:         bool
+******************************************************************************/
+func isFinalAttempt(ctx context.Context, err error) bool {
+	if errors.Is(err, asynq.SkipRetry) {
+		return true
+	}
+
+	retried, _ := asynq.GetRetryCount(ctx)
+	maxRetry, _ := asynq.GetMaxRetry(ctx)
+
+	return retried >= maxRetry
+}
+
+/******************************************************************************
+* FUNCTION:        recordJobAttemptFailure
+*
+* DESCRIPTION:     Stores the reason of a failed attempt in job_retry_history
+*                  so the full retry history of a job can be inspected
+* INPUT:           fileID, jobID, attempt, err, willRetry
+* RETURNS:         void
+******************************************************************************/
+func recordJobAttemptFailure(fileID int64, jobID string, attempt int, err error, willRetry bool) {
+	defer PanicRecovery("recordJobAttemptFailure")
+
+	data := []map[string]interface{}{{
+		"file_id":    fileID,
+		"job_id":     jobID,
+		"attempt":    attempt,
+		"reason":     err.Error(),
+		"will_retry": willRetry,
+		"created_at": time.Now(),
+	}}
+
+	if dbErr := db.AddMultipleRecordInDB(nil, "job_retry_history", data); dbErr != nil {
+		log.Errorf("failed to record retry history for job %s; err: %v", jobID, dbErr)
+	}
+}
@@ -0,0 +1,430 @@
+/**************************************************************************
+ * File       	   : apiHandleOtlpLogs.go
+ * DESCRIPTION     : This file contains the OpenTelemetry OTLP/HTTP logs
+ *                   receiver. Protobuf and JSON encoded export requests
+ *                   are mapped into LogEntry values and written through
+ *                   the stream batcher, one stream per service.name
+ * DATE            : 19-October-2026
+ **************************************************************************/
+
+package services
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/martian/log"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	statuspb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	OTLP_CONTENT_TYPE_PROTOBUF = "application/x-protobuf"
+	OTLP_CONTENT_TYPE_JSON     = "application/json"
+	OTLP_STREAM_PREFIX         = "otlp-"
+	OTLP_UNKNOWN_SERVICE       = "unknown_service"
+	OTLP_MAX_BODY_BYTES        = 10 << 20
+)
+
+/******************************************************************************
+* FUNCTION:        HandleOtlpLogs
+*
+* DESCRIPTION:     This function receives an OTLP/HTTP ExportLogsServiceRequest
+*                  and answers with an ExportLogsServiceResponse in the
+*                  encoding of the request. Records that cannot be stored
+*                  are reported through partial_success
+* INPUT:					 gin context
+* RETURNS:         void
+******************************************************************************/
+func HandleOtlpLogs(ctx *gin.Context) {
+	defer PanicRecovery("HandleOtlpLogs")
+
+	isJSON := ctx.ContentType() == OTLP_CONTENT_TYPE_JSON
+	if !isJSON && ctx.ContentType() != OTLP_CONTENT_TYPE_PROTOBUF {
+		sendOtlpStatus(ctx, http.StatusUnsupportedMediaType, false, "unsupported content type "+ctx.ContentType())
+		return
+	}
+
+	userId, err := extractToken(ctx, "user_id")
+	if err != nil {
+		log.Errorf("failed to get user_id from context; err: ", err)
+		sendOtlpStatus(ctx, http.StatusUnauthorized, isJSON, "unauthorized")
+		return
+	}
+
+	body, err := readOtlpBody(ctx)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			sendOtlpStatus(ctx, http.StatusRequestEntityTooLarge, isJSON, "request body too large")
+			return
+		}
+		sendOtlpStatus(ctx, http.StatusBadRequest, isJSON, "failed to read body: "+err.Error())
+		return
+	}
+
+	req := &collogspb.ExportLogsServiceRequest{}
+	if isJSON {
+		err = unmarshalOtlpJSON(body, req)
+	} else {
+		err = proto.Unmarshal(body, req)
+	}
+	if err != nil {
+		sendOtlpStatus(ctx, http.StatusBadRequest, isJSON, "invalid export request: "+err.Error())
+		return
+	}
+
+	streams, rejected := mapOtlpLogs(req)
+	counts := make(map[string]int, len(streams))
+	for source, entries := range streams {
+		counts[source] = len(entries)
+	}
+	if err = checkStreamCapacity(userId, counts); err != nil {
+		// retryable for OTLP exporters, nothing was buffered yet
+		ctx.Header("Retry-After", "1")
+		sendOtlpStatus(ctx, http.StatusTooManyRequests, isJSON, err.Error())
+		return
+	}
+
+	// once a stream is buffered a retry would store it twice, streams
+	// that fail after that are reported through partial_success
+	var failed int64
+	var failedErr error
+	buffered := false
+	for source, entries := range streams {
+		err = AppendStreamEntries(userId, source, entries)
+		if err == nil {
+			buffered = true
+			continue
+		}
+		log.Errorf("failed to buffer otlp logs of %s; err: %v", source, err)
+		if !buffered {
+			if errors.Is(err, ErrStreamBackpressure) {
+				ctx.Header("Retry-After", "1")
+				sendOtlpStatus(ctx, http.StatusTooManyRequests, isJSON, err.Error())
+				return
+			}
+			sendOtlpStatus(ctx, http.StatusServiceUnavailable, isJSON, "failed to store logs")
+			return
+		}
+		failed += int64(len(entries))
+		failedErr = err
+	}
+
+	resp := &collogspb.ExportLogsServiceResponse{}
+	if rejected > 0 || failed > 0 {
+		messages := []string{}
+		if rejected > 0 {
+			messages = append(messages, fmt.Sprintf("%d log records without a body were rejected", rejected))
+		}
+		if failed > 0 {
+			messages = append(messages, fmt.Sprintf("%d log records could not be stored: %v", failed, failedErr))
+		}
+		resp.PartialSuccess = &collogspb.ExportLogsPartialSuccess{
+			RejectedLogRecords: rejected + failed,
+			ErrorMessage:       strings.Join(messages, "; "),
+		}
+	}
+	sendOtlpMessage(ctx, http.StatusOK, isJSON, resp)
+}
+
+/******************************************************************************
+* FUNCTION:        mapOtlpLogs
+*
+* DESCRIPTION:     Maps the log records of an export request to LogEntry
+*                  values grouped by stream. Records are kept with their
+*                  attributes, resource attributes and trace context
+* INPUT:           export request
+* RETURNS:         entries per stream, rejected record count
+******************************************************************************/
+func mapOtlpLogs(req *collogspb.ExportLogsServiceRequest) (map[string][]LogEntry, int64) {
+	streams := make(map[string][]LogEntry)
+	var rejected int64
+
+	for _, resourceLogs := range req.GetResourceLogs() {
+		resourceAttrs := otlpAttributesToMap(resourceLogs.GetResource().GetAttributes())
+
+		serviceName, _ := resourceAttrs["service.name"].(string)
+		if serviceName == "" {
+			serviceName = OTLP_UNKNOWN_SERVICE
+		}
+		source := otlpStreamName(serviceName)
+
+		for _, scopeLogs := range resourceLogs.GetScopeLogs() {
+			for _, record := range scopeLogs.GetLogRecords() {
+				entry, ok := otlpRecordToLogEntry(record, resourceAttrs)
+				if !ok {
+					rejected++
+					continue
+				}
+				streams[source] = append(streams[source], entry)
+			}
+		}
+	}
+
+	return streams, rejected
+}
+
+/******************************************************************************
+* FUNCTION:        otlpRecordToLogEntry
+*
+* DESCRIPTION:     Maps a single LogRecord. The time falls back to the
+*                  observed time, then to now; the level to the severity
+*                  number range when the record has no severity text
+* INPUT:           record, resource attributes
+* RETURNS:         LogEntry, ok
+******************************************************************************/
+func otlpRecordToLogEntry(record *logspb.LogRecord, resourceAttrs map[string]interface{}) (LogEntry, bool) {
+	body := otlpValueToInterface(record.GetBody())
+	if body == nil || body == "" {
+		return LogEntry{}, false
+	}
+
+	message, ok := body.(string)
+	if !ok {
+		encoded, _ := json.Marshal(body)
+		message = string(encoded)
+	}
+
+	timestamp := time.Now()
+	if record.GetTimeUnixNano() > 0 {
+		timestamp = time.Unix(0, int64(record.GetTimeUnixNano()))
+	} else if record.GetObservedTimeUnixNano() > 0 {
+		timestamp = time.Unix(0, int64(record.GetObservedTimeUnixNano()))
+	}
+
+	level := record.GetSeverityText()
+	if level == "" {
+		level = otlpSeverityLevel(record.GetSeverityNumber())
+	}
+
+	entry := buildLogEntry(timestamp, level, message, 0)
+
+	attributes := map[string]interface{}{}
+	if recordAttrs := otlpAttributesToMap(record.GetAttributes()); len(recordAttrs) > 0 {
+		attributes["attributes"] = recordAttrs
+		if entry.IP == "" {
+			address, _ := recordAttrs["client.address"].(string)
+			entry.IP = normalizeIP(address)
+		}
+	}
+	if len(resourceAttrs) > 0 {
+		attributes["resource"] = resourceAttrs
+	}
+	if len(attributes) > 0 {
+		entry.Attributes = attributes
+	}
+
+	if len(record.GetTraceId()) > 0 {
+		entry.TraceID = hex.EncodeToString(record.GetTraceId())
+	}
+	if len(record.GetSpanId()) > 0 {
+		entry.SpanID = hex.EncodeToString(record.GetSpanId())
+	}
+
+	return entry, true
+}
+
+/******************************************************************************
+* FUNCTION:        otlpSeverityLevel
+*
+* DESCRIPTION:     Maps an OTLP severity number to a log level
+* INPUT:           severity number
+* RETURNS:         string
+******************************************************************************/
+func otlpSeverityLevel(severity logspb.SeverityNumber) string {
+	switch {
+	case severity >= logspb.SeverityNumber_SEVERITY_NUMBER_FATAL:
+		return "FATAL"
+	case severity >= logspb.SeverityNumber_SEVERITY_NUMBER_ERROR:
+		return "ERROR"
+	case severity >= logspb.SeverityNumber_SEVERITY_NUMBER_WARN:
+		return "WARN"
+	case severity >= logspb.SeverityNumber_SEVERITY_NUMBER_INFO:
+		return "INFO"
+	case severity >= logspb.SeverityNumber_SEVERITY_NUMBER_DEBUG:
+		return "DEBUG"
+	case severity >= logspb.SeverityNumber_SEVERITY_NUMBER_TRACE:
+		return "TRACE"
+	default:
+		return "UNSPECIFIED"
+	}
+}
+
+/******************************************************************************
+* FUNCTION:        otlpAttributesToMap
+*
+* DESCRIPTION:     Converts OTLP key/values into a plain map
+* INPUT:           attributes
+* RETURNS:         map[string]interface{}
+******************************************************************************/
+func otlpAttributesToMap(attrs []*commonpb.KeyValue) map[string]interface{} {
+	result := make(map[string]interface{}, len(attrs))
+	for _, kv := range attrs {
+		result[kv.GetKey()] = otlpValueToInterface(kv.GetValue())
+	}
+	return result
+}
+
+/******************************************************************************
+* FUNCTION:        otlpValueToInterface
+*
+* DESCRIPTION:     Converts an OTLP AnyValue into its plain go value
+* INPUT:           value
+* RETURNS:         interface{}
+******************************************************************************/
+func otlpValueToInterface(value *commonpb.AnyValue) interface{} {
+	switch v := value.GetValue().(type) {
+	case *commonpb.AnyValue_StringValue:
+		return v.StringValue
+	case *commonpb.AnyValue_BoolValue:
+		return v.BoolValue
+	case *commonpb.AnyValue_IntValue:
+		return v.IntValue
+	case *commonpb.AnyValue_DoubleValue:
+		return v.DoubleValue
+	case *commonpb.AnyValue_BytesValue:
+		return base64.StdEncoding.EncodeToString(v.BytesValue)
+	case *commonpb.AnyValue_ArrayValue:
+		values := make([]interface{}, 0, len(v.ArrayValue.GetValues()))
+		for _, item := range v.ArrayValue.GetValues() {
+			values = append(values, otlpValueToInterface(item))
+		}
+		return values
+	case *commonpb.AnyValue_KvlistValue:
+		return otlpAttributesToMap(v.KvlistValue.GetValues())
+	default:
+		return nil
+	}
+}
+
+/******************************************************************************
+* FUNCTION:        unmarshalOtlpJSON
+*
+* DESCRIPTION:     Decodes the OTLP JSON encoding. It is the protobuf JSON
+*                  mapping except that trace and span ids are hex instead
+*                  of base64, so those are converted before decoding
+* INPUT:           body, request
+* RETURNS:         error
+******************************************************************************/
+func unmarshalOtlpJSON(body []byte, req *collogspb.ExportLogsServiceRequest) error {
+	var raw map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&raw); err != nil {
+		return err
+	}
+
+	resourceLogs, _ := raw["resourceLogs"].([]interface{})
+	for _, rl := range resourceLogs {
+		rlMap, _ := rl.(map[string]interface{})
+		scopeLogs, _ := rlMap["scopeLogs"].([]interface{})
+		for _, sl := range scopeLogs {
+			slMap, _ := sl.(map[string]interface{})
+			records, _ := slMap["logRecords"].([]interface{})
+			for _, record := range records {
+				recordMap, _ := record.(map[string]interface{})
+				for _, key := range []string{"traceId", "spanId"} {
+					id, _ := recordMap[key].(string)
+					if id == "" {
+						continue
+					}
+					decoded, err := hex.DecodeString(id)
+					if err != nil {
+						return fmt.Errorf("invalid %s %q", key, id)
+					}
+					recordMap[key] = base64.StdEncoding.EncodeToString(decoded)
+				}
+			}
+		}
+	}
+
+	converted, err := json.Marshal(raw)
+	if err != nil {
+		return err
+	}
+
+	return protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(converted, req)
+}
+
+/******************************************************************************
+* FUNCTION:        readOtlpBody
+*
+* DESCRIPTION:     Reads the request body, gunzipping it when encoded
+* INPUT:           gin context
+* RETURNS:         body, error
+******************************************************************************/
+func readOtlpBody(ctx *gin.Context) ([]byte, error) {
+	var body io.Reader = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, OTLP_MAX_BODY_BYTES)
+
+	if strings.EqualFold(ctx.GetHeader("Content-Encoding"), "gzip") {
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		body = http.MaxBytesReader(ctx.Writer, gz, OTLP_MAX_BODY_BYTES*10)
+	}
+
+	return io.ReadAll(body)
+}
+
+/******************************************************************************
+* FUNCTION:        otlpStreamName
+*
+* DESCRIPTION:     Builds a valid stream name out of the service name
+* INPUT:           service name
+* RETURNS:         string
+******************************************************************************/
+func otlpStreamName(serviceName string) string {
+	name := OTLP_STREAM_PREFIX + unsafeStreamNameRegex.ReplaceAllString(serviceName, "_")
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	return name
+}
+
+/******************************************************************************
+* FUNCTION:        sendOtlpStatus
+*
+* DESCRIPTION:     Helper function to send an error as a google.rpc.Status
+*                  as required by OTLP/HTTP
+* INPUT:           gin context, http status, isJSON, message
+* RETURNS:         void
+******************************************************************************/
+func sendOtlpStatus(ctx *gin.Context, status int, isJSON bool, message string) {
+	sendOtlpMessage(ctx, status, isJSON, &statuspb.Status{Message: message})
+}
+
+/******************************************************************************
+* FUNCTION:        sendOtlpMessage
+*
+* DESCRIPTION:     Helper function to send a protobuf message in the
+*                  encoding of the request
+* INPUT:           gin context, http status, isJSON, message
+* RETURNS:         void
+******************************************************************************/
+func sendOtlpMessage(ctx *gin.Context, status int, isJSON bool, message proto.Message) {
+	if isJSON {
+		body, _ := protojson.Marshal(message)
+		ctx.Data(status, OTLP_CONTENT_TYPE_JSON, body)
+		return
+	}
+
+	body, _ := proto.Marshal(message)
+	ctx.Data(status, OTLP_CONTENT_TYPE_PROTOBUF, body)
+}
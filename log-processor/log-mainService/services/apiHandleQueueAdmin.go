@@ -0,0 +1,396 @@
+/**************************************************************************
+ * File       	   : apiHandleQueueAdmin.go
+ * DESCRIPTION     : This file contains admin functions to inspect and
+ *                   manage the asynq queues and their tasks
+ * DATE            : 19-October-2026
+ **************************************************************************/
+
+package services
+
+import (
+	"LOGProcessor/log-mainService/tasks"
+	"LOGProcessor/shared/db"
+	"LOGProcessor/shared/types"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/martian/log"
+	"github.com/hibiken/asynq"
+)
+
+const (
+	TASK_STATE_PENDING   = "pending"
+	TASK_STATE_SCHEDULED = "scheduled"
+	TASK_STATE_RETRY     = "retry"
+	TASK_STATE_ARCHIVED  = "archived"
+	TASK_STATE_COMPLETED = "completed"
+	TASK_STATE_ACTIVE    = "active"
+
+	MAX_HISTORY_DAYS = 90
+)
+
+type QueueAdminInfo struct {
+	Queue     string    `json:"queue"`
+	Paused    bool      `json:"paused"`
+	Size      int       `json:"size"`
+	Pending   int       `json:"pending"`
+	Active    int       `json:"active"`
+	Scheduled int       `json:"scheduled"`
+	Retry     int       `json:"retry"`
+	Archived  int       `json:"archived"`
+	Completed int       `json:"completed"`
+	Processed int       `json:"processed"`
+	Failed    int       `json:"failed"`
+	Latency   float64   `json:"latencySec"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+type QueueDailyStats struct {
+	Date      string `json:"date"`
+	Processed int    `json:"processed"`
+	Failed    int    `json:"failed"`
+}
+
+/******************************************************************************
+* FUNCTION:        HandleListQueues
+*
+* DESCRIPTION:     This function lists every queue known to asynq along with
+*                  its current counters
+* INPUT:					 gin context
+* RETURNS:         void
+******************************************************************************/
+func HandleListQueues(ctx *gin.Context) {
+	defer PanicRecovery("HandleListQueues")
+
+	inspector := types.AsynqClient.AsynqInspector
+
+	queues, err := inspector.Queues()
+	if err != nil {
+		log.Errorf("failed to list queues; err: %v", err)
+		SendResponse(ctx, http.StatusInternalServerError, "internal server error", nil, 0)
+		return
+	}
+
+	result := make([]QueueAdminInfo, 0, len(queues))
+	for _, qName := range queues {
+		qInfo, err := inspector.GetQueueInfo(qName)
+		if err != nil {
+			log.Errorf("failed to get queue info for %s; err: %v", qName, err)
+			continue
+		}
+		result = append(result, QueueAdminInfo{
+			Queue:     qInfo.Queue,
+			Paused:    qInfo.Paused,
+			Size:      qInfo.Size,
+			Pending:   qInfo.Pending,
+			Active:    qInfo.Active,
+			Scheduled: qInfo.Scheduled,
+			Retry:     qInfo.Retry,
+			Archived:  qInfo.Archived,
+			Completed: qInfo.Completed,
+			Processed: qInfo.Processed,
+			Failed:    qInfo.Failed,
+			Latency:   qInfo.Latency.Seconds(),
+			Timestamp: qInfo.Timestamp,
+		})
+	}
+
+	SendResponse(ctx, http.StatusOK, "queues fetched successfully", result, int64(len(result)))
+}
+
+/******************************************************************************
+* FUNCTION:        HandlePauseQueue
+*
+* DESCRIPTION:     This function pauses a queue so that workers stop pulling
+*                  tasks from it
+* INPUT:					 gin context
+* RETURNS:         void
+******************************************************************************/
+func HandlePauseQueue(ctx *gin.Context) {
+	defer PanicRecovery("HandlePauseQueue")
+
+	qName := ctx.Param("queue")
+	err := types.AsynqClient.AsynqInspector.PauseQueue(qName)
+	if err != nil {
+		log.Errorf("failed to pause queue %s; err: %v", qName, err)
+		SendResponse(ctx, http.StatusBadRequest, "failed to pause queue", nil, 0)
+		return
+	}
+
+	SendResponse(ctx, http.StatusOK, "queue paused successfully", qName, 1)
+}
+
+/******************************************************************************
+* FUNCTION:        HandleResumeQueue
+*
+* DESCRIPTION:     This function resumes a previously paused queue
+* INPUT:					 gin context
+* RETURNS:         void
+******************************************************************************/
+func HandleResumeQueue(ctx *gin.Context) {
+	defer PanicRecovery("HandleResumeQueue")
+
+	qName := ctx.Param("queue")
+	err := types.AsynqClient.AsynqInspector.UnpauseQueue(qName)
+	if err != nil {
+		log.Errorf("failed to resume queue %s; err: %v", qName, err)
+		SendResponse(ctx, http.StatusBadRequest, "failed to resume queue", nil, 0)
+		return
+	}
+
+	SendResponse(ctx, http.StatusOK, "queue resumed successfully", qName, 1)
+}
+
+/******************************************************************************
+* FUNCTION:        HandleListQueueTasks
+*
+* DESCRIPTION:     This function lists the tasks of a queue in the requested
+*                  state. Unlike log_stats, asynq only supports page based
+*                  listing so page & pageSize are used here
+* INPUT:					 gin context
+* RETURNS:         void
+******************************************************************************/
+func HandleListQueueTasks(ctx *gin.Context) {
+	defer PanicRecovery("HandleListQueueTasks")
+
+	var (
+		err       error
+		taskInfos []*asynq.TaskInfo
+	)
+
+	qName := ctx.Param("queue")
+	state := ctx.DefaultQuery("state", TASK_STATE_PENDING)
+
+	page, err := strconv.Atoi(ctx.DefaultQuery("page", "1"))
+	if err != nil || page <= 0 {
+		SendResponse(ctx, http.StatusBadRequest, "invalid page", nil, 0)
+		return
+	}
+
+	pageSize, err := strconv.Atoi(ctx.DefaultQuery("pageSize", "20"))
+	if err != nil || pageSize <= 0 {
+		SendResponse(ctx, http.StatusBadRequest, "invalid pageSize", nil, 0)
+		return
+	}
+
+	inspector := types.AsynqClient.AsynqInspector
+	listOpts := []asynq.ListOption{asynq.Page(page), asynq.PageSize(pageSize)}
+
+	switch state {
+	case TASK_STATE_PENDING:
+		taskInfos, err = inspector.ListPendingTasks(qName, listOpts...)
+	case TASK_STATE_ACTIVE:
+		taskInfos, err = inspector.ListActiveTasks(qName, listOpts...)
+	case TASK_STATE_SCHEDULED:
+		taskInfos, err = inspector.ListScheduledTasks(qName, listOpts...)
+	case TASK_STATE_RETRY:
+		taskInfos, err = inspector.ListRetryTasks(qName, listOpts...)
+	case TASK_STATE_ARCHIVED:
+		taskInfos, err = inspector.ListArchivedTasks(qName, listOpts...)
+	case TASK_STATE_COMPLETED:
+		taskInfos, err = inspector.ListCompletedTasks(qName, listOpts...)
+	default:
+		SendResponse(ctx, http.StatusBadRequest, "invalid state", nil, 0)
+		return
+	}
+
+	if err != nil {
+		log.Errorf("failed to list %s tasks for queue %s; err: %v", state, qName, err)
+		SendResponse(ctx, http.StatusBadRequest, "failed to list tasks", nil, 0)
+		return
+	}
+
+	result := make([]map[string]interface{}, 0, len(taskInfos))
+	for _, task := range taskInfos {
+		result = append(result, taskInfoToMap(task))
+	}
+
+	responseData := map[string]interface{}{
+		"tasks":    result,
+		"state":    state,
+		"page":     page,
+		"pageSize": pageSize,
+	}
+
+	SendResponse(ctx, http.StatusOK, "tasks fetched successfully", responseData, int64(len(result)))
+}
+
+/******************************************************************************
+* FUNCTION:        HandleDeleteQueueTask
+*
+* DESCRIPTION:     This function deletes a task from a queue. Active tasks
+*                  cannot be deleted. A log:process task that was still to
+*                  run frees the user's fair scheduling slot and its file
+*                  is marked as failed
+* INPUT:					 gin context
+* RETURNS:         void
+******************************************************************************/
+func HandleDeleteQueueTask(ctx *gin.Context) {
+	defer PanicRecovery("HandleDeleteQueueTask")
+
+	qName := ctx.Param("queue")
+	taskId := ctx.Param("taskId")
+
+	task, err := types.AsynqClient.AsynqInspector.GetTaskInfo(qName, taskId)
+	if err != nil {
+		log.Errorf("failed to get task %s from queue %s; err: %v", taskId, qName, err)
+		SendResponse(ctx, http.StatusBadRequest, "failed to delete task", nil, 0)
+		return
+	}
+
+	err = types.AsynqClient.AsynqInspector.DeleteTask(qName, taskId)
+	if err != nil {
+		log.Errorf("failed to delete task %s from queue %s; err: %v", taskId, qName, err)
+		SendResponse(ctx, http.StatusBadRequest, "failed to delete task", nil, 0)
+		return
+	}
+
+	if task.Type == tasks.TypeLogProcess {
+		cancelDeletedLogTask(task)
+	}
+
+	SendResponse(ctx, http.StatusOK, "task deleted successfully", taskId, 1)
+}
+
+/******************************************************************************
+* FUNCTION:        cancelDeletedLogTask
+*
+* DESCRIPTION:     Helper function cleaning up after a deleted log:process
+*                  task. Pending, scheduled and retry tasks hold a slot of
+*                  the user and their file is still waiting; archived and
+*                  completed ones were already cleaned up when they ended
+* INPUT:           task
+* RETURNS:         void
+******************************************************************************/
+func cancelDeletedLogTask(task *asynq.TaskInfo) {
+	switch task.State {
+	case asynq.TaskStatePending, asynq.TaskStateScheduled, asynq.TaskStateRetry:
+	default:
+		return
+	}
+
+	var pay tasks.LogProcessPayload
+	if err := json.Unmarshal(task.Payload, &pay); err != nil {
+		log.Errorf("failed to decode payload of deleted task %s; err: %v", task.ID, err)
+		return
+	}
+
+	tasks.ReleaseTenantSlot(pay.UserId, task.ID)
+
+	data := map[string]interface{}{
+		"status":         "Failed",
+		"failure_reason": "task deleted from the queue by an admin",
+		"completed_at":   time.Now(),
+	}
+	if err := db.UpdateSingleRecord(nil, "file_stats", "file_id", pay.FileId, data); err != nil {
+		log.Errorf("failed to mark file %d of deleted task %s as failed; err: %v", pay.FileId, task.ID, err)
+		return
+	}
+
+	BroadcastMessage(fmt.Sprintf("Job %s failed", task.ID), "job-update", pay.UserId)
+	data["file_id"] = pay.FileId
+	BroadcastMessage(data, "log-table-update", pay.UserId)
+}
+
+/******************************************************************************
+* FUNCTION:        HandleRunQueueTask
+*
+* DESCRIPTION:     This function moves a scheduled, retry or archived task to
+*                  pending so that it gets processed right away
+* INPUT:					 gin context
+* RETURNS:         void
+******************************************************************************/
+func HandleRunQueueTask(ctx *gin.Context) {
+	defer PanicRecovery("HandleRunQueueTask")
+
+	qName := ctx.Param("queue")
+	taskId := ctx.Param("taskId")
+
+	err := types.AsynqClient.AsynqInspector.RunTask(qName, taskId)
+	if err != nil {
+		log.Errorf("failed to run task %s from queue %s; err: %v", taskId, qName, err)
+		SendResponse(ctx, http.StatusBadRequest, "failed to run task", nil, 0)
+		return
+	}
+
+	SendResponse(ctx, http.StatusOK, "task scheduled to run", taskId, 1)
+}
+
+/******************************************************************************
+* FUNCTION:        HandleGetQueueHistory
+*
+* DESCRIPTION:     This function returns the daily processed/failed counts of
+*                  a queue for the last n days
+* INPUT:					 gin context
+* RETURNS:         void
+******************************************************************************/
+func HandleGetQueueHistory(ctx *gin.Context) {
+	defer PanicRecovery("HandleGetQueueHistory")
+
+	qName := ctx.Param("queue")
+	days, err := strconv.Atoi(ctx.DefaultQuery("days", "7"))
+	if err != nil || days <= 0 || days > MAX_HISTORY_DAYS {
+		SendResponse(ctx, http.StatusBadRequest, "invalid days", nil, 0)
+		return
+	}
+
+	history, err := types.AsynqClient.AsynqInspector.History(qName, days)
+	if err != nil {
+		log.Errorf("failed to get history for queue %s; err: %v", qName, err)
+		SendResponse(ctx, http.StatusBadRequest, "failed to get queue history", nil, 0)
+		return
+	}
+
+	result := make([]QueueDailyStats, 0, len(history))
+	for _, dayStats := range history {
+		result = append(result, QueueDailyStats{
+			Date:      dayStats.Date.Format("2006-01-02"),
+			Processed: dayStats.Processed,
+			Failed:    dayStats.Failed,
+		})
+	}
+
+	SendResponse(ctx, http.StatusOK, "queue history fetched successfully", result, int64(len(result)))
+}
+
+/******************************************************************************
+* FUNCTION:        taskInfoToMap
+*
+* DESCRIPTION:     Helper function to convert asynq task info to a response map
+* INPUT:           task
+* RETURNS:         map[string]interface{}
+******************************************************************************/
+func taskInfoToMap(task *asynq.TaskInfo) map[string]interface{} {
+	taskMap := map[string]interface{}{
+		"id":        task.ID,
+		"type":      task.Type,
+		"queue":     task.Queue,
+		"state":     task.State.String(),
+		"max_retry": task.MaxRetry,
+		"retried":   task.Retried,
+		"last_err":  task.LastErr,
+	}
+
+	if !task.LastFailedAt.IsZero() {
+		taskMap["last_failed_at"] = task.LastFailedAt
+	}
+	if !task.NextProcessAt.IsZero() {
+		taskMap["next_process_at"] = task.NextProcessAt
+	}
+	if !task.CompletedAt.IsZero() {
+		taskMap["completed_at"] = task.CompletedAt
+	}
+
+	if task.Type == tasks.TypeLogProcess {
+		var payload tasks.LogProcessPayload
+		if err := json.Unmarshal(task.Payload, &payload); err == nil {
+			taskMap["payload"] = payload
+		}
+	}
+
+	return taskMap
+}
@@ -0,0 +1,283 @@
+/**************************************************************************
+ * File       	   : apiHandleRedaction.go
+ * DESCRIPTION     : This file contains functions that manage the redaction
+ *                   rules of a user and dry-run them on sample lines
+ * DATE            : 19-October-2026
+ **************************************************************************/
+
+package services
+
+import (
+	"LOGProcessor/shared/db"
+	"LOGProcessor/shared/types"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/martian/log"
+)
+
+const (
+	REDACT_MAX_RULES       = 50
+	REDACT_MAX_PATTERN_LEN = 1024
+	REDACT_MAX_TEST_LINES  = 100
+)
+
+var redactRuleNameRegex = regexp.MustCompile(`^[a-z0-9_]{1,64}$`)
+
+type RedactionRuleReq struct {
+	Name    string `json:"name" binding:"required"`
+	Pattern string `json:"pattern"`
+	Action  string `json:"action" binding:"required"`
+}
+
+type RedactionTestReq struct {
+	Lines []string `json:"lines" binding:"required"`
+}
+
+/******************************************************************************
+* FUNCTION:        HandleGetRedactionRules
+*
+* DESCRIPTION:     This function lists the built-in detectors with their
+*                  default action and the redaction rules of the user
+* INPUT:					 gin context
+* RETURNS:         void
+******************************************************************************/
+func HandleGetRedactionRules(ctx *gin.Context) {
+	defer PanicRecovery("HandleGetRedactionRules")
+
+	userId, err := extractToken(ctx, "user_id")
+	if err != nil {
+		log.Errorf("failed to get user_id from context; err: ", err)
+		SendResponse(ctx, http.StatusUnauthorized, "internal server error", "", 0)
+		return
+	}
+
+	query := `
+	SELECT name, pattern, action, created_at FROM redaction_rules
+	WHERE user_id = $1
+	ORDER BY rule_id ASC`
+
+	rules, err := db.GetDataFromDB(query, []interface{}{userId})
+	if err != nil {
+		log.Errorf("failed to get data from db; err: ", err)
+		SendResponse(ctx, http.StatusBadRequest, "internal server error", nil, 0)
+		return
+	}
+
+	defaults := make(map[string]bool, len(types.RedactionCfg.Detectors))
+	for _, name := range types.RedactionCfg.Detectors {
+		defaults[name] = true
+	}
+	detectors := make([]map[string]interface{}, 0, len(builtinDetectors))
+	for _, detector := range builtinDetectors {
+		action := REDACT_ACTION_OFF
+		if defaults[detector.Name] {
+			action = types.RedactionCfg.Action
+		}
+		detectors = append(detectors, map[string]interface{}{"name": detector.Name, "defaultAction": action})
+	}
+
+	responseData := map[string]interface{}{
+		"detectors": detectors,
+		"rules":     rules,
+	}
+
+	SendResponse(ctx, http.StatusOK, "redaction rules retrieved succesfully", responseData, int64(len(rules)))
+}
+
+/******************************************************************************
+* FUNCTION:        HandlePutRedactionRule
+*
+* DESCRIPTION:     This function stores a redaction rule of the user,
+*                  replacing the rule of the same name. A rule named after a
+*                  built-in detector sets its action ("off" disables it),
+*                  any other rule needs a pattern
+* INPUT:					 gin context
+* RETURNS:         void
+******************************************************************************/
+func HandlePutRedactionRule(ctx *gin.Context) {
+	defer PanicRecovery("HandlePutRedactionRule")
+
+	var req RedactionRuleReq
+
+	userId, err := extractToken(ctx, "user_id")
+	if err != nil {
+		log.Errorf("failed to get user_id from context; err: ", err)
+		SendResponse(ctx, http.StatusUnauthorized, "internal server error", "", 0)
+		return
+	}
+
+	if err = ctx.ShouldBindJSON(&req); err != nil {
+		SendResponse(ctx, http.StatusBadRequest, "invalid request body", nil, 0)
+		return
+	}
+
+	name := strings.ToLower(strings.TrimSpace(req.Name))
+	action := strings.ToLower(strings.TrimSpace(req.Action))
+	if !redactRuleNameRegex.MatchString(name) {
+		SendResponse(ctx, http.StatusBadRequest, "name must be 1-64 lowercase letters, digits or '_'", nil, 0)
+		return
+	}
+
+	var pattern interface{}
+	if IsRedactionDetector(name) {
+		if req.Pattern != "" {
+			SendResponse(ctx, http.StatusBadRequest, "a built-in detector takes no pattern", nil, 0)
+			return
+		}
+		if action != REDACT_ACTION_OFF && !isRedactAction(action) {
+			SendResponse(ctx, http.StatusBadRequest, invalidRedactActionMessage(action), nil, 0)
+			return
+		}
+	} else {
+		if req.Pattern == "" || len(req.Pattern) > REDACT_MAX_PATTERN_LEN {
+			SendResponse(ctx, http.StatusBadRequest, "pattern is required and at most 1024 characters", nil, 0)
+			return
+		}
+		regex, err := regexp.Compile(req.Pattern)
+		if err != nil {
+			SendResponse(ctx, http.StatusBadRequest, "invalid pattern: "+err.Error(), nil, 0)
+			return
+		}
+		// such a pattern matches between every two characters
+		if regex.MatchString("") {
+			SendResponse(ctx, http.StatusBadRequest, "invalid pattern: it matches the empty string", nil, 0)
+			return
+		}
+		if !isRedactAction(action) {
+			SendResponse(ctx, http.StatusBadRequest, invalidRedactActionMessage(action), nil, 0)
+			return
+		}
+		pattern = req.Pattern
+	}
+
+	count, err := db.GetDataFromDB("SELECT COUNT(*) AS count FROM redaction_rules WHERE user_id = $1 AND name <> $2", []interface{}{userId, name})
+	if err != nil {
+		log.Errorf("failed to count redaction rules; err: %v", err)
+		SendResponse(ctx, http.StatusInternalServerError, "internal server error", nil, 0)
+		return
+	}
+	if ruleCount, _ := count[0]["count"].(int64); ruleCount >= REDACT_MAX_RULES {
+		SendResponse(ctx, http.StatusBadRequest, "redaction rule limit reached", nil, 0)
+		return
+	}
+
+	query := `
+	INSERT INTO redaction_rules (user_id, name, pattern, action, created_at)
+	VALUES ($1, $2, $3, $4, $5)
+	ON CONFLICT (user_id, name) DO UPDATE SET pattern = EXCLUDED.pattern, action = EXCLUDED.action, created_at = EXCLUDED.created_at`
+
+	if _, err = db.UpdateDataInDB(nil, query, []interface{}{userId, name, pattern, action, time.Now()}); err != nil {
+		log.Errorf("failed to store redaction rule; err: %v", err)
+		SendResponse(ctx, http.StatusInternalServerError, "internal server error", nil, 0)
+		return
+	}
+	invalidateRedactor(userId)
+
+	responseData := map[string]interface{}{
+		"name":    name,
+		"pattern": pattern,
+		"action":  action,
+	}
+
+	SendResponse(ctx, http.StatusOK, "redaction rule stored successfully", responseData, 1)
+}
+
+/******************************************************************************
+* FUNCTION:        HandleDeleteRedactionRule
+*
+* DESCRIPTION:     This function deletes a redaction rule of the user, a
+*                  built-in detector falls back to its default action
+* INPUT:					 gin context
+* RETURNS:         void
+******************************************************************************/
+func HandleDeleteRedactionRule(ctx *gin.Context) {
+	defer PanicRecovery("HandleDeleteRedactionRule")
+
+	userId, err := extractToken(ctx, "user_id")
+	if err != nil {
+		log.Errorf("failed to get user_id from context; err: ", err)
+		SendResponse(ctx, http.StatusUnauthorized, "internal server error", "", 0)
+		return
+	}
+
+	name := strings.ToLower(strings.TrimSpace(ctx.Param("name")))
+
+	rows, err := db.UpdateDataInDB(nil, "DELETE FROM redaction_rules WHERE user_id = $1 AND name = $2", []interface{}{userId, name})
+	if err != nil {
+		log.Errorf("failed to delete redaction rule %s; err: %v", name, err)
+		SendResponse(ctx, http.StatusInternalServerError, "internal server error", nil, 0)
+		return
+	}
+	if rows == 0 {
+		SendResponse(ctx, http.StatusNotFound, "redaction rule not found", nil, 0)
+		return
+	}
+	invalidateRedactor(userId)
+
+	SendResponse(ctx, http.StatusOK, "redaction rule deleted successfully", name, 1)
+}
+
+/******************************************************************************
+* FUNCTION:        HandleTestRedaction
+*
+* DESCRIPTION:     This function applies the user's current redaction rules
+*                  to sample lines without storing anything
+* INPUT:					 gin context
+* RETURNS:         void
+******************************************************************************/
+func HandleTestRedaction(ctx *gin.Context) {
+	defer PanicRecovery("HandleTestRedaction")
+
+	var req RedactionTestReq
+
+	userId, err := extractToken(ctx, "user_id")
+	if err != nil {
+		log.Errorf("failed to get user_id from context; err: ", err)
+		SendResponse(ctx, http.StatusUnauthorized, "internal server error", "", 0)
+		return
+	}
+
+	if err = ctx.ShouldBindJSON(&req); err != nil || len(req.Lines) > REDACT_MAX_TEST_LINES {
+		SendResponse(ctx, http.StatusBadRequest, "invalid request body, at most 100 lines", nil, 0)
+		return
+	}
+
+	invalidateRedactor(userId)
+	r, err := getRedactor(userId)
+	if err != nil {
+		log.Errorf("failed to load redaction rules; err: %v", err)
+		SendResponse(ctx, http.StatusInternalServerError, "internal server error", nil, 0)
+		return
+	}
+
+	counts := make(RedactionCounts)
+	lines := make([]string, 0, len(req.Lines))
+	for _, line := range req.Lines {
+		lines = append(lines, r.redact(line, counts))
+	}
+
+	responseData := map[string]interface{}{
+		"lines":  lines,
+		"counts": counts,
+	}
+
+	SendResponse(ctx, http.StatusOK, "redaction tested successfully", responseData, int64(len(lines)))
+}
+
+/******************************************************************************
+* FUNCTION:        invalidRedactActionMessage
+*
+* DESCRIPTION:     Helper function explaining why an action was refused
+* INPUT:           action
+* RETURNS:         string
+******************************************************************************/
+func invalidRedactActionMessage(action string) string {
+	if action == REDACT_ACTION_HASH {
+		return "the hash action needs REDACTION_HASH_KEY to be configured"
+	}
+	return "action must be one of mask, hash, drop"
+}
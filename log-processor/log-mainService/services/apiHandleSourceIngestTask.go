@@ -0,0 +1,359 @@
+/**************************************************************************
+ * File       	   : apiHandleSourceIngestTask.go
+ * DESCRIPTION     : This file contains the asynq handler that fetches new
+ *                   objects of a registered ingest source and the config
+ *                   provider feeding the sources' cron schedules to the
+ *                   asynq periodic task manager
+ * DATE            : 19-October-2026
+ **************************************************************************/
+
+package services
+
+import (
+	"LOGProcessor/log-mainService/tasks"
+	"LOGProcessor/shared/db"
+	"LOGProcessor/shared/types"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/martian/log"
+	"github.com/hibiken/asynq"
+)
+
+const (
+	MAX_OBJECTS_PER_RUN = 100
+)
+
+var (
+	ErrSourceNotFound = errors.New("ingest source not found")
+	// errObjectUnchanged is returned for an object without a version whose
+	// content was already ingested
+	errObjectUnchanged = errors.New("object content unchanged")
+
+	unsafeObjectNameRegex = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+)
+
+type SourceScheduleProvider struct{}
+
+/******************************************************************************
+* FUNCTION:        GetConfigs
+*
+* DESCRIPTION:     asynq.PeriodicTaskConfigProvider implementation returning
+*                  one periodic task per enabled ingest source. The periodic
+*                  task manager calls it on every sync so that sources added
+*                  or removed through the api are picked up
+* INPUT:					 void
+* RETURNS:         []*asynq.PeriodicTaskConfig, error
+******************************************************************************/
+func (p *SourceScheduleProvider) GetConfigs() ([]*asynq.PeriodicTaskConfig, error) {
+	result, err := db.GetDataFromDB("SELECT source_id, cron_spec FROM ingest_sources WHERE enabled = true", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	configs := make([]*asynq.PeriodicTaskConfig, 0, len(result))
+	for _, row := range result {
+		sourceId, _ := row["source_id"].(int64)
+		cronSpec, _ := row["cron_spec"].(string)
+
+		task, opts, err := tasks.NewSourceIngestTask(sourceId)
+		if err != nil {
+			log.Errorf("failed to create ingest task for source %d; err: %v", sourceId, err)
+			continue
+		}
+		configs = append(configs, &asynq.PeriodicTaskConfig{
+			Cronspec: cronSpec,
+			Task:     task,
+			Opts:     opts,
+		})
+	}
+
+	return configs, nil
+}
+
+/******************************************************************************
+* FUNCTION:        HandleSourceIngestTask
+*
+* DESCRIPTION:     This function lists the objects of a source, skips the
+*                  ones already ingested and stores/enqueues the new ones
+*                  through the same path as an upload. Objects that fail are
+*                  left untracked so that the retry picks them up again
+* INPUT:					 ctx, task
+* RETURNS:         error
+******************************************************************************/
+func HandleSourceIngestTask(ctx context.Context, t *asynq.Task) (err error) {
+	defer PanicRecovery("HandleSourceIngestTask")
+
+	var (
+		pay      tasks.SourceIngestPayload
+		source   *IngestSource
+		fetcher  sourceFetcher
+		objects  []RemoteObject
+		ingested int
+	)
+
+	if err = json.Unmarshal(t.Payload(), &pay); err != nil {
+		return fmt.Errorf("%w: invalid payload: %v", asynq.SkipRetry, err)
+	}
+
+	source, err = getIngestSource(pay.SourceId, true)
+	if err != nil {
+		if errors.Is(err, ErrSourceNotFound) {
+			return fmt.Errorf("%w: %w", asynq.SkipRetry, err)
+		}
+		return err
+	}
+	if !source.Enabled {
+		return nil
+	}
+
+	defer func() {
+		updateSourceRunStatus(source, ingested, err)
+	}()
+
+	fetcher, err = newSourceFetcher(ctx, source)
+	if err != nil {
+		return err
+	}
+	defer fetcher.Close()
+
+	objects, err = fetcher.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, obj := range objects {
+		if ingested+failed >= MAX_OBJECTS_PER_RUN {
+			log.Infof("source %d reached %d objects for this run", source.SourceId, MAX_OBJECTS_PER_RUN)
+			break
+		}
+
+		done, checkErr := isObjectIngested(source.SourceId, obj)
+		if checkErr != nil {
+			return checkErr
+		}
+		if done {
+			continue
+		}
+
+		if objErr := ingestRemoteObject(ctx, source, fetcher, obj); objErr != nil {
+			if errors.Is(objErr, errObjectUnchanged) {
+				continue
+			}
+			log.Errorf("failed to ingest %s from source %d; err: %v", obj.Key, source.SourceId, objErr)
+			failed++
+			continue
+		}
+		ingested++
+	}
+
+	if failed > 0 {
+		err = fmt.Errorf("%d of %d new objects failed to ingest", failed, ingested+failed)
+		return err
+	}
+
+	return nil
+}
+
+/******************************************************************************
+* FUNCTION:        ingestRemoteObject
+*
+* DESCRIPTION:     Streams a single remote object into supabase, enqueues it
+*                  for processing and records it as ingested. An object
+*                  without a version, e.g. a http object served without
+*                  ETag and Last-Modified, is versioned by the SHA-256 of
+*                  its content, so it is spooled to a temp file first
+* INPUT:           ctx, source, fetcher, obj
+* RETURNS:         error, errObjectUnchanged when the content is known
+******************************************************************************/
+func ingestRemoteObject(ctx context.Context, source *IngestSource, fetcher sourceFetcher, obj RemoteObject) error {
+	if obj.Size > types.IngestCfg.MaxBytes {
+		return ErrIngestTooLarge
+	}
+
+	reader, err := fetcher.Open(ctx, obj)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	body := newMaxBytesReader(reader)
+	if obj.Version == "" {
+		tempFile, err := os.CreateTemp("", "source-object-*")
+		if err != nil {
+			return fmt.Errorf("error creating temp file: %v", err)
+		}
+		defer os.Remove(tempFile.Name())
+		defer tempFile.Close()
+
+		hash := sha256.New()
+		if _, err = io.Copy(io.MultiWriter(tempFile, hash), body); err != nil {
+			return err
+		}
+		if _, err = tempFile.Seek(0, io.SeekStart); err != nil {
+			return fmt.Errorf("error seeking temp file: %v", err)
+		}
+
+		obj.Version = "sha256:" + hex.EncodeToString(hash.Sum(nil))
+		done, err := isObjectIngested(source.SourceId, obj)
+		if err != nil {
+			return err
+		}
+		if done {
+			return errObjectUnchanged
+		}
+		body = tempFile
+	}
+
+	token, err := JwtTokenCreatorForUser(source.UserId)
+	if err != nil {
+		return err
+	}
+
+	fileName := fmt.Sprintf("sources/%d/%s-%s", source.SourceId, time.Now().UTC().Format("20060102T150405"), containerObjectName(obj.Key))
+	data, err := storeAndEnqueueLogFile(ctx, token, source.UserId, fileName, body, tasks.LogParseOptions{})
+	if err != nil {
+		return err
+	}
+
+	err = db.AddMultipleRecordInDB(nil, "ingest_source_objects", []map[string]interface{}{{
+		"source_id":      source.SourceId,
+		"object_key":     obj.Key,
+		"object_version": obj.Version,
+		"file_id":        data["file_id"],
+		"ingested_at":    time.Now(),
+	}})
+	if err != nil {
+		return fmt.Errorf("failed to record ingested object: %v", err)
+	}
+
+	BroadcastMessage(data, "log-table-update", source.UserId)
+	return nil
+}
+
+/******************************************************************************
+* FUNCTION:        isObjectIngested
+*
+* DESCRIPTION:     Tells whether this version of the object was ingested
+* INPUT:           sourceId, obj
+* RETURNS:         bool, error
+******************************************************************************/
+func isObjectIngested(sourceId int64, obj RemoteObject) (bool, error) {
+	query := `
+	SELECT 1 FROM ingest_source_objects
+	WHERE source_id = $1 AND object_key = $2 AND object_version = $3
+	LIMIT 1`
+
+	result, err := db.GetDataFromDB(query, []interface{}{sourceId, obj.Key, obj.Version})
+	if err != nil {
+		return false, err
+	}
+
+	return len(result) > 0, nil
+}
+
+/******************************************************************************
+* FUNCTION:        getIngestSource
+*
+* DESCRIPTION:     Loads a source from the db, decrypting its credentials
+*                  when asked to
+* INPUT:           sourceId, withCredentials
+* RETURNS:         *IngestSource, error
+******************************************************************************/
+func getIngestSource(sourceId int64, withCredentials bool) (*IngestSource, error) {
+	query := `
+	SELECT source_id, user_id, name, source_type, cron_spec, enabled, config, credentials
+	FROM ingest_sources WHERE source_id = $1`
+
+	result, err := db.GetDataFromDB(query, []interface{}{sourceId})
+	if err != nil {
+		return nil, err
+	}
+	if len(result) == 0 {
+		return nil, ErrSourceNotFound
+	}
+
+	row := result[0]
+	source := &IngestSource{}
+	source.SourceId, _ = row["source_id"].(int64)
+	source.UserId, _ = row["user_id"].(string)
+	source.Name, _ = row["name"].(string)
+	source.SourceType, _ = row["source_type"].(string)
+	source.CronSpec, _ = row["cron_spec"].(string)
+	source.Enabled, _ = row["enabled"].(bool)
+
+	if config, ok := row["config"].(string); ok && config != "" {
+		if err := json.Unmarshal([]byte(config), &source.Config); err != nil {
+			return nil, fmt.Errorf("invalid config of source %d: %v", sourceId, err)
+		}
+	}
+
+	if encrypted, ok := row["credentials"].(string); withCredentials && ok && encrypted != "" {
+		plain, err := decryptCredentials(encrypted)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt credentials of source %d: %v", sourceId, err)
+		}
+		if err := json.Unmarshal(plain, &source.Credentials); err != nil {
+			return nil, fmt.Errorf("invalid credentials of source %d: %v", sourceId, err)
+		}
+	}
+
+	return source, nil
+}
+
+/******************************************************************************
+* FUNCTION:        updateSourceRunStatus
+*
+* DESCRIPTION:     Stores the outcome of the last run on the source and lets
+*                  the user know through the websocket
+* INPUT:           source, ingested count, err
+* RETURNS:         void
+******************************************************************************/
+func updateSourceRunStatus(source *IngestSource, ingested int, runErr error) {
+	data := map[string]interface{}{
+		"last_run_at":       time.Now(),
+		"last_run_status":   "success",
+		"last_run_ingested": ingested,
+		"last_error":        "",
+	}
+	if runErr != nil {
+		data["last_run_status"] = "failed"
+		data["last_error"] = runErr.Error()
+	}
+
+	if err := db.UpdateSingleRecord(nil, "ingest_sources", "source_id", source.SourceId, data); err != nil {
+		log.Errorf("failed to update run status of source %d; err: %v", source.SourceId, err)
+	}
+
+	data["source_id"] = source.SourceId
+	BroadcastMessage(data, "source-ingest-update", source.UserId)
+}
+
+/******************************************************************************
+* FUNCTION:        sanitizeObjectName
+*
+* DESCRIPTION:     Builds a storage safe file name out of a remote object key
+* INPUT:           key
+* RETURNS:         string
+******************************************************************************/
+func sanitizeObjectName(key string) string {
+	key = strings.SplitN(key, "?", 2)[0]
+	name := unsafeObjectNameRegex.ReplaceAllString(path.Base(key), "_")
+	name = strings.Trim(name, "._")
+	if name == "" {
+		return "object"
+	}
+
+	return name
+}
@@ -0,0 +1,182 @@
+/**************************************************************************
+ * File       	   : apiHandleStreamIngest.go
+ * DESCRIPTION     : This file contains the push endpoint through which
+ *                   services send log lines continuously instead of
+ *                   uploading files
+ * DATE            : 19-October-2026
+ **************************************************************************/
+
+package services
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/martian/log"
+)
+
+const (
+	STREAM_DEFAULT_SOURCE = "default"
+	STREAM_MAX_BODY_BYTES = 10 << 20
+	STREAM_MAX_LINE_BYTES = 1 << 20
+)
+
+type StreamLogRecord struct {
+	Line      string        `json:"line"`
+	Timestamp string        `json:"timestamp"`
+	Level     LogLevelValue `json:"level"`
+	Message   string        `json:"message"`
+	Msg       string        `json:"msg"`
+}
+
+/******************************************************************************
+* FUNCTION:        HandleStreamIngest
+*
+* DESCRIPTION:     This function accepts a batch of log lines for the
+*                  stream named by the source query param. The body is
+*                  NDJSON (application/x-ndjson) or plain text, optionally
+*                  gzip encoded. Lines are parsed like file lines and
+*                  buffered; they reach log_stats within a second
+* INPUT:					 gin context
+* RETURNS:         void
+******************************************************************************/
+func HandleStreamIngest(ctx *gin.Context) {
+	defer PanicRecovery("HandleStreamIngest")
+
+	var (
+		err     error
+		userId  string
+		body    io.Reader
+		entries []LogEntry
+	)
+
+	userId, err = extractToken(ctx, "user_id")
+	if err != nil {
+		log.Errorf("failed to get user_id from context; err: ", err)
+		SendResponse(ctx, http.StatusUnauthorized, "internal server error", "", 0)
+		return
+	}
+
+	source := ctx.DefaultQuery("source", STREAM_DEFAULT_SOURCE)
+	if !streamNameRegex.MatchString(source) {
+		SendResponse(ctx, http.StatusBadRequest, ErrInvalidStreamName.Error(), nil, 0)
+		return
+	}
+
+	body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, STREAM_MAX_BODY_BYTES)
+	if strings.EqualFold(ctx.GetHeader("Content-Encoding"), "gzip") {
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			SendResponse(ctx, http.StatusBadRequest, "invalid gzip body", nil, 0)
+			return
+		}
+		defer gz.Close()
+		// bound the decompressed size as well
+		body = http.MaxBytesReader(ctx.Writer, gz, STREAM_MAX_BODY_BYTES*10)
+	}
+
+	isNDJSON := strings.Contains(ctx.ContentType(), "json")
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), STREAM_MAX_LINE_BYTES)
+
+	lineCount, rejected := 0, 0
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		lineCount++
+
+		var (
+			entry LogEntry
+			ok    bool
+		)
+		if isNDJSON {
+			entry, ok = parseStreamRecord(line)
+		} else {
+			entry, ok = parseLogLine(line, 0)
+		}
+		if !ok {
+			rejected++
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	if err = scanner.Err(); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			SendResponse(ctx, http.StatusRequestEntityTooLarge, "request body too large", nil, 0)
+			return
+		}
+		SendResponse(ctx, http.StatusBadRequest, "failed to read body: "+err.Error(), nil, 0)
+		return
+	}
+
+	err = AppendStreamEntries(userId, source, entries)
+	if err != nil {
+		if errors.Is(err, ErrStreamBackpressure) {
+			ctx.Header("Retry-After", "1")
+			SendResponse(ctx, http.StatusTooManyRequests, err.Error(), nil, 0)
+			return
+		}
+		log.Errorf("failed to buffer stream entries; err: %v", err)
+		SendResponse(ctx, http.StatusInternalServerError, "internal server error", nil, 0)
+		return
+	}
+
+	responseData := map[string]interface{}{
+		"source":   source,
+		"accepted": len(entries),
+		"rejected": rejected,
+	}
+
+	SendResponse(ctx, http.StatusAccepted, "log lines accepted", responseData, int64(lineCount))
+}
+
+/******************************************************************************
+* FUNCTION:        parseStreamRecord
+*
+* DESCRIPTION:     Parses one NDJSON record. A "line" field is parsed with
+*                  parseLogLine, otherwise the timestamp/level/message
+*                  fields are used, the timestamp defaulting to now
+* INPUT:           record
+* RETURNS:         LogEntry, ok
+******************************************************************************/
+func parseStreamRecord(record string) (LogEntry, bool) {
+	var rec StreamLogRecord
+	if err := json.Unmarshal([]byte(record), &rec); err != nil {
+		return LogEntry{}, false
+	}
+
+	if rec.Line != "" {
+		return parseLogLine(rec.Line, 0)
+	}
+
+	message := rec.Message
+	if message == "" {
+		message = rec.Msg
+	}
+	if message == "" || rec.Level == "" {
+		return LogEntry{}, false
+	}
+
+	timestamp := time.Now()
+	if rec.Timestamp != "" {
+		parsed, ok := parseLogTimestamp(rec.Timestamp)
+		if !ok {
+			return LogEntry{}, false
+		}
+		timestamp = parsed
+	}
+
+	return buildLogEntry(timestamp, string(rec.Level), message, 0), true
+}
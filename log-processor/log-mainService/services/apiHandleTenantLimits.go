@@ -0,0 +1,103 @@
+/**************************************************************************
+ * File       	   : apiHandleTenantLimits.go
+ * DESCRIPTION     : This file contains admin functions to read and change
+ *                   the per-user fair scheduling limits
+ * DATE            : 19-October-2026
+ **************************************************************************/
+
+package services
+
+import (
+	"LOGProcessor/log-mainService/tasks"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/martian/log"
+)
+
+/******************************************************************************
+* FUNCTION:        HandleListTenants
+*
+* DESCRIPTION:     This function returns pending/in-flight counts and limits
+*                  of every user that has parked or running tasks
+* INPUT:					 gin context
+* RETURNS:         void
+******************************************************************************/
+func HandleListTenants(ctx *gin.Context) {
+	defer PanicRecovery("HandleListTenants")
+
+	tenants, err := tasks.GetTenantStatuses(ctx)
+	if err != nil {
+		log.Errorf("failed to get tenant statuses; err: %v", err)
+		SendResponse(ctx, http.StatusInternalServerError, "failed to get tenant statuses", nil, 0)
+		return
+	}
+
+	SendResponse(ctx, http.StatusOK, "tenants fetched successfully", tenants, int64(len(tenants)))
+}
+
+/******************************************************************************
+* FUNCTION:        HandleGetTenantLimits
+*
+* DESCRIPTION:     This function returns the scheduling limits of a user
+* INPUT:					 gin context
+* RETURNS:         void
+******************************************************************************/
+func HandleGetTenantLimits(ctx *gin.Context) {
+	defer PanicRecovery("HandleGetTenantLimits")
+
+	userId := ctx.Param("userId")
+	limits := tasks.GetTenantLimits(ctx, userId)
+
+	SendResponse(ctx, http.StatusOK, "tenant limits fetched successfully", limits, 1)
+}
+
+/******************************************************************************
+* FUNCTION:        HandleSetTenantLimits
+*
+* DESCRIPTION:     This function overrides the scheduling limits of a user
+* INPUT:					 gin context
+* RETURNS:         void
+******************************************************************************/
+func HandleSetTenantLimits(ctx *gin.Context) {
+	defer PanicRecovery("HandleSetTenantLimits")
+
+	var limits tasks.TenantLimits
+
+	userId := ctx.Param("userId")
+	if err := ctx.ShouldBindJSON(&limits); err != nil {
+		SendResponse(ctx, http.StatusBadRequest, "invalid request body", nil, 0)
+		return
+	}
+
+	err := tasks.SetTenantLimits(ctx, userId, limits)
+	if err != nil {
+		log.Errorf("failed to set limits for user %s; err: %v", userId, err)
+		SendResponse(ctx, http.StatusBadRequest, err.Error(), nil, 0)
+		return
+	}
+
+	SendResponse(ctx, http.StatusOK, "tenant limits updated successfully", limits, 1)
+}
+
+/******************************************************************************
+* FUNCTION:        HandleDeleteTenantLimits
+*
+* DESCRIPTION:     This function resets the scheduling limits of a user to
+*                  the defaults
+* INPUT:					 gin context
+* RETURNS:         void
+******************************************************************************/
+func HandleDeleteTenantLimits(ctx *gin.Context) {
+	defer PanicRecovery("HandleDeleteTenantLimits")
+
+	userId := ctx.Param("userId")
+	err := tasks.DeleteTenantLimits(ctx, userId)
+	if err != nil {
+		log.Errorf("failed to reset limits for user %s; err: %v", userId, err)
+		SendResponse(ctx, http.StatusInternalServerError, "internal server error", nil, 0)
+		return
+	}
+
+	SendResponse(ctx, http.StatusOK, "tenant limits reset successfully", tasks.GetTenantLimits(ctx, userId), 1)
+}
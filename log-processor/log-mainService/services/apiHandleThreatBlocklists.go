@@ -0,0 +1,168 @@
+/**************************************************************************
+ * File       	   : apiHandleThreatBlocklists.go
+ * DESCRIPTION     : This file contains admin functions that upload, list
+ *                   and delete the threat intel blocklists
+ * DATE            : 19-October-2026
+ **************************************************************************/
+
+package services
+
+import (
+	"LOGProcessor/shared/db"
+	"errors"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/martian/log"
+)
+
+var blocklistNameRegex = regexp.MustCompile(`^[a-z0-9_-]{1,64}$`)
+
+/******************************************************************************
+* FUNCTION:        HandleGetBlocklists
+*
+* DESCRIPTION:     This function lists the blocklists with their indicator
+*                  counts and the networks and domains loaded by this
+*                  instance
+* INPUT:					 gin context
+* RETURNS:         void
+******************************************************************************/
+func HandleGetBlocklists(ctx *gin.Context) {
+	defer PanicRecovery("HandleGetBlocklists")
+
+	query := `
+	SELECT name, format, indicator_count, skipped_count, updated_at FROM threat_blocklists
+	ORDER BY name ASC`
+
+	lists, err := db.GetDataFromDB(query, nil)
+	if err != nil {
+		log.Errorf("failed to get data from db; err: ", err)
+		SendResponse(ctx, http.StatusBadRequest, "internal server error", nil, 0)
+		return
+	}
+
+	loaded := map[string]interface{}{"networks": 0, "domains": 0}
+	if matcher := threatMatcherPtr.Load(); matcher != nil {
+		loaded["networks"] = matcher.cidrs
+		loaded["domains"] = len(matcher.domains)
+	}
+
+	responseData := map[string]interface{}{
+		"blocklists": lists,
+		"loaded":     loaded,
+	}
+
+	SendResponse(ctx, http.StatusOK, "blocklists retrieved succesfully", responseData, int64(len(lists)))
+}
+
+/******************************************************************************
+* FUNCTION:        HandlePutBlocklist
+*
+* DESCRIPTION:     This function uploads a blocklist, replacing the list of
+*                  the same name. The "file" form field holds the list, the
+*                  optional "format" field is text, csv or stix, otherwise
+*                  taken from the file extension
+* INPUT:					 gin context
+* RETURNS:         void
+******************************************************************************/
+func HandlePutBlocklist(ctx *gin.Context) {
+	defer PanicRecovery("HandlePutBlocklist")
+
+	name := strings.ToLower(strings.TrimSpace(ctx.Param("name")))
+	if !blocklistNameRegex.MatchString(name) {
+		SendResponse(ctx, http.StatusBadRequest, "name must be 1-64 lowercase letters, digits, '_' or '-'", nil, 0)
+		return
+	}
+
+	fileHeader, err := ctx.FormFile("file")
+	if err != nil {
+		SendResponse(ctx, http.StatusBadRequest, "failed to read file", nil, 0)
+		return
+	}
+	if fileHeader.Size > BLOCKLIST_MAX_BYTES {
+		SendResponse(ctx, http.StatusRequestEntityTooLarge, ErrBlocklistTooLarge.Error(), nil, 0)
+		return
+	}
+
+	format := strings.ToLower(strings.TrimSpace(ctx.PostForm("format")))
+	if format == "" {
+		format = blocklistFormatOf(fileHeader.Filename)
+	}
+	if !isBlocklistFormat(format) {
+		SendResponse(ctx, http.StatusBadRequest, "format must be one of text, csv, stix", nil, 0)
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		log.Errorf("failed to read file; err: ", err)
+		SendResponse(ctx, http.StatusBadRequest, "failed to read file", nil, 0)
+		return
+	}
+	defer file.Close()
+
+	parsed, err := parseBlocklist(file, format)
+	if err != nil {
+		if errors.Is(err, ErrBlocklistTooLarge) {
+			SendResponse(ctx, http.StatusRequestEntityTooLarge, err.Error(), nil, 0)
+			return
+		}
+		SendResponse(ctx, http.StatusBadRequest, err.Error(), nil, 0)
+		return
+	}
+	if len(parsed.Indicators) == 0 {
+		SendResponse(ctx, http.StatusBadRequest, "the file holds no ip, network or domain", nil, 0)
+		return
+	}
+
+	if _, err = storeBlocklist(name, format, parsed); err != nil {
+		log.Errorf("failed to store blocklist %s; err: %v", name, err)
+		SendResponse(ctx, http.StatusInternalServerError, "internal server error", nil, 0)
+		return
+	}
+	// the other instances pick the list up on their next reload
+	if err = reloadThreatMatcher(); err != nil {
+		log.Errorf("failed to reload threat blocklists; err: %v", err)
+	}
+
+	responseData := map[string]interface{}{
+		"name":       name,
+		"format":     format,
+		"indicators": len(parsed.Indicators),
+		"skipped":    parsed.Skipped,
+	}
+
+	SendResponse(ctx, http.StatusOK, "blocklist stored successfully", responseData, int64(len(parsed.Indicators)))
+}
+
+/******************************************************************************
+* FUNCTION:        HandleDeleteBlocklist
+*
+* DESCRIPTION:     This function deletes a blocklist, entries flagged
+*                  already keep the flag
+* INPUT:					 gin context
+* RETURNS:         void
+******************************************************************************/
+func HandleDeleteBlocklist(ctx *gin.Context) {
+	defer PanicRecovery("HandleDeleteBlocklist")
+
+	name := strings.ToLower(strings.TrimSpace(ctx.Param("name")))
+
+	rows, err := db.UpdateDataInDB(nil, "DELETE FROM threat_blocklists WHERE name = $1", []interface{}{name})
+	if err != nil {
+		log.Errorf("failed to delete blocklist %s; err: %v", name, err)
+		SendResponse(ctx, http.StatusInternalServerError, "internal server error", nil, 0)
+		return
+	}
+	if rows == 0 {
+		SendResponse(ctx, http.StatusNotFound, "blocklist not found", nil, 0)
+		return
+	}
+	if err = reloadThreatMatcher(); err != nil {
+		log.Errorf("failed to reload threat blocklists; err: %v", err)
+	}
+
+	SendResponse(ctx, http.StatusOK, "blocklist deleted successfully", name, 1)
+}
@@ -0,0 +1,152 @@
+/**************************************************************************
+ * File       	   : apiHandleUploadFromUrl.go
+ * DESCRIPTION     : This file contains functions that fetch a log file
+ *                   from a url, stream it to supabase storage and enqueue
+ *                   it exactly like a multipart upload
+ * DATE            : 19-October-2026
+ **************************************************************************/
+
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/martian/log"
+)
+
+const (
+	URL_FETCH_TIMEOUT = 10 * time.Minute
+)
+
+type UploadFromUrlReq struct {
+	URL         string                  `json:"url" binding:"required"`
+	FileName    string                  `json:"fileName"`
+	Headers     map[string]string       `json:"headers"`
+	Credentials IngestSourceCredentials `json:"credentials"`
+	PatternId   int64                   `json:"patternId"`
+	Timezone    string                  `json:"timezone"`
+	Encoding    string                  `json:"encoding"`
+}
+
+/******************************************************************************
+* FUNCTION:        HandleUploadFromUrl
+*
+* DESCRIPTION:     This function fetches the file behind the url with the
+*                  optional headers/credentials, streams it into SupeBase
+*                  and enqueues it. Internal addresses are refused unless
+*                  allow-listed through INGEST_ALLOWED_CIDRS
+* INPUT:					 gin context
+* RETURNS:         void
+******************************************************************************/
+func HandleUploadFromUrl(ctx *gin.Context) {
+	defer PanicRecovery("HandleUploadFromUrl")
+
+	var (
+		err    error
+		req    UploadFromUrlReq
+		token  string
+		userId string
+		data   map[string]interface{}
+	)
+
+	if err = ctx.ShouldBindJSON(&req); err != nil {
+		SendResponse(ctx, http.StatusBadRequest, "invalid request body", "", 0)
+		return
+	}
+
+	parsedUrl, err := url.Parse(req.URL)
+	if err != nil || (parsedUrl.Scheme != "http" && parsedUrl.Scheme != "https") || parsedUrl.Host == "" {
+		SendResponse(ctx, http.StatusBadRequest, "url must be an http(s) url", "", 0)
+		return
+	}
+
+	token, err = extractToken(ctx, "token")
+	if err != nil {
+		log.Errorf("failed to get token from context; err: ", err)
+		SendResponse(ctx, http.StatusUnauthorized, "internal server error", "", 0)
+		return
+	}
+	userId, err = extractToken(ctx, "user_id")
+	if err != nil {
+		log.Errorf("failed to get user_id from context; err: ", err)
+		SendResponse(ctx, http.StatusUnauthorized, "internal server error", "", 0)
+		return
+	}
+
+	parseOptions, ok := resolveParseOptions(ctx, userId, req.PatternId, req.Timezone, req.Encoding)
+	if !ok {
+		return
+	}
+
+	fetchCtx, cancel := context.WithTimeout(ctx.Request.Context(), URL_FETCH_TIMEOUT)
+	defer cancel()
+
+	fetcher := &httpSourceFetcher{
+		source: &IngestSource{
+			UserId:      userId,
+			SourceType:  SOURCE_TYPE_HTTP,
+			Config:      IngestSourceConfig{URL: req.URL, Headers: req.Headers},
+			Credentials: req.Credentials,
+		},
+		client: newIngestHttpClient(),
+	}
+
+	reader, err := fetcher.Open(fetchCtx, RemoteObject{Key: req.URL})
+	if err != nil {
+		sendUrlFetchError(ctx, err, http.StatusBadGateway, "failed to fetch url")
+		return
+	}
+	defer reader.Close()
+
+	fileName := containerObjectName(parsedUrl.Path)
+	if req.FileName != "" {
+		fileName = sanitizeObjectName(req.FileName)
+	}
+	fileName = fmt.Sprintf("url/%s-%s", time.Now().UTC().Format("20060102T150405"), fileName)
+
+	data, err = storeAndEnqueueLogFile(fetchCtx, token, userId, fileName, newMaxBytesReader(reader), parseOptions)
+	if err != nil {
+		if strings.Contains(err.Error(), "The resource already exists") {
+			SendResponse(ctx, http.StatusBadRequest, "file name already exists", "", 0)
+			return
+		}
+		sendUrlFetchError(ctx, err, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	BroadcastMessage(data, "log-table-update", userId)
+	SendResponse(ctx, http.StatusOK, "File uploaded successfully", data["file_path"], 1)
+}
+
+/******************************************************************************
+* FUNCTION:        sendUrlFetchError
+*
+* DESCRIPTION:     Helper function to map a fetch/store error to a response.
+*                  Unknown errors are answered with the given status
+* INPUT:           gin context, err, status, message
+* RETURNS:         void
+******************************************************************************/
+func sendUrlFetchError(ctx *gin.Context, err error, status int, message string) {
+	switch {
+	case errors.Is(err, ErrDisallowedDestination):
+		SendResponse(ctx, http.StatusBadRequest, "url points to a disallowed address", "", 0)
+	case errors.Is(err, ErrTooManyRedirects):
+		SendResponse(ctx, http.StatusBadRequest, "url has too many redirects", "", 0)
+	case errors.Is(err, ErrIngestTooLarge):
+		SendResponse(ctx, http.StatusRequestEntityTooLarge, ErrIngestTooLarge.Error(), "", 0)
+	case errors.Is(err, ErrRemoteStatus):
+		SendResponse(ctx, http.StatusBadGateway, err.Error(), "", 0)
+	case errors.Is(err, context.DeadlineExceeded):
+		SendResponse(ctx, http.StatusGatewayTimeout, "timed out fetching url", "", 0)
+	default:
+		log.Errorf("failed to ingest url; err: %v", err)
+		SendResponse(ctx, status, message, "", 0)
+	}
+}
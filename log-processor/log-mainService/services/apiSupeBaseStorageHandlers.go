@@ -9,15 +9,25 @@ package services
 
 import (
 	"LOGProcessor/shared/types"
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
-	"mime/multipart"
 	"net/http"
+	"strings"
 	"time"
 
+	"github.com/hibiken/asynq"
 	storage "github.com/supabase-community/storage-go"
 )
 
+var (
+	// ErrMissingObject is returned when the log file is no longer in the
+	// bucket. Retrying cannot fix it, hence it is wrapped with SkipRetry
+	ErrMissingObject = errors.New("log file not found in storage")
+)
+
 /******************************************************************************
 * FUNCTION:        uploadFileToSupeBaseStorage
 *
@@ -25,7 +35,7 @@ import (
 * INPUT:
 * RETURNS:         string, err
 ******************************************************************************/
-func uploadFileToSupeBaseStorage(authToken, fileName string, file multipart.File) (storage.FileUploadResponse, error) {
+func uploadFileToSupeBaseStorage(authToken, fileName string, file io.Reader) (storage.FileUploadResponse, error) {
 	client := ConfigSupeBaseStorageClient(authToken)
 	resp, err := client.UploadFile(types.CmnGlblCfg.SUPEBASE_BUCKET, fileName, file)
 
@@ -39,14 +49,17 @@ func uploadFileToSupeBaseStorage(authToken, fileName string, file multipart.File
 /******************************************************************************
 * FUNCTION:        downloadFileFromSupeBaseStorage
 *
-* DESCRIPTION:     This function is used to download file from SupeBase
-* INPUT:
+* DESCRIPTION:     This function is used to download file from SupeBase. The
+*                  request is bound to ctx so that a stuck download is
+*                  aborted once the task times out
+* INPUT:           ctx, filePath
 * RETURNS:         io.ReadCloser, err
 ******************************************************************************/
-func downloadFileFromSupeBaseStorage(filePath string) (io.ReadCloser, error) {
+func downloadFileFromSupeBaseStorage(ctx context.Context, filePath string) (io.ReadCloser, error) {
 	var (
 		err  error
 		resp *http.Response
+		req  *http.Request
 	)
 	token, err := JwtTokenCreatorForSupebaseStorage(filePath)
 	if err != nil {
@@ -57,13 +70,27 @@ func downloadFileFromSupeBaseStorage(filePath string) (io.ReadCloser, error) {
 		types.CmnGlblCfg.SUPEBASE_API, types.CmnGlblCfg.SUPEBASE_STORAGE_BASE, filePath, token)
 
 	for i := 0; i < 3; i++ {
-		resp, err = http.Get(url)
+		req, err = http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err = http.DefaultClient.Do(req)
 		if err == nil && resp.StatusCode == http.StatusOK {
 			return resp.Body, nil
 		}
+		if err == nil && isObjectNotFound(resp) {
+			return nil, fmt.Errorf("%w: %w: %s", asynq.SkipRetry, ErrMissingObject, filePath)
+		}
+		if err == nil && i < 2 {
+			resp.Body.Close()
+		}
 		time.Sleep(time.Second)
 	}
 
+	if err != nil {
+		return nil, err
+	}
+
 	if resp.StatusCode != http.StatusOK {
 		resp.Body.Close()
 		return nil, fmt.Errorf("failed to download file, status: %d", resp.StatusCode)
@@ -71,3 +98,34 @@ func downloadFileFromSupeBaseStorage(filePath string) (io.ReadCloser, error) {
 
 	return resp.Body, nil
 }
+
+/******************************************************************************
+* FUNCTION:        isObjectNotFound
+*
+* DESCRIPTION:     Helper function to tell whether supabase answered that the
+*                  object does not exist. Supabase reports a missing object
+*                  either as 404 or as 400 with a not_found error body. The
+*                  response body is closed when the object is missing
+* INPUT:           resp
+* RETURNS:         bool
+******************************************************************************/
+func isObjectNotFound(resp *http.Response) bool {
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return true
+	}
+	if resp.StatusCode != http.StatusBadRequest {
+		return false
+	}
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	bodyLower := strings.ToLower(string(body))
+	if strings.Contains(bodyLower, "not_found") || strings.Contains(bodyLower, "not found") {
+		return true
+	}
+
+	return false
+}
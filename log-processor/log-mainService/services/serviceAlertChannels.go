@@ -0,0 +1,357 @@
+/**************************************************************************
+ * File       	   : serviceAlertChannels.go
+ * DESCRIPTION     : This file contains the notification channels of the
+ *                   alerts: generic webhooks signed with HMAC-SHA256,
+ *                   Slack-compatible incoming webhooks and SMTP email
+ * DATE            : 19-October-2026
+ **************************************************************************/
+
+package services
+
+import (
+	"LOGProcessor/shared/types"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	ALERT_CHANNEL_WEBHOOK = "webhook"
+	ALERT_CHANNEL_SLACK   = "slack"
+	ALERT_CHANNEL_EMAIL   = "email"
+
+	ALERT_MAX_CHANNELS   = 20
+	ALERT_MAX_RECIPIENTS = 20
+	// bytes of a webhook response read before the connection is dropped
+	ALERT_MAX_RESPONSE_BYTES = 64 * 1024
+
+	ALERT_SIGNATURE_HEADER = "X-LogProcessor-Signature"
+	ALERT_TIMESTAMP_HEADER = "X-LogProcessor-Timestamp"
+	ALERT_DELIVERY_HEADER  = "X-LogProcessor-Delivery"
+)
+
+var (
+	// ErrAlertDeliveryRejected is returned when sending again would give
+	// the same result, e.g. a 4xx from the webhook
+	ErrAlertDeliveryRejected = errors.New("notification rejected")
+	ErrEmailNotConfigured    = errors.New("email alerts are not enabled on this server")
+)
+
+// AlertChannelConfig holds what is shown of a channel
+type AlertChannelConfig struct {
+	// webhook
+	URL string `json:"url,omitempty"`
+	// email
+	To []string `json:"to,omitempty"`
+}
+
+// AlertChannelCredentials are stored encrypted and never returned
+type AlertChannelCredentials struct {
+	// Secret signs the body posted to a webhook
+	Secret string `json:"secret,omitempty"`
+	// SlackURL is the incoming webhook url, which is a credential itself
+	SlackURL string `json:"slackUrl,omitempty"`
+}
+
+// alertDelivery is one notification of an alert to a channel
+type alertDelivery struct {
+	DeliveryId  int64
+	AlertId     int64
+	Status      string
+	ChannelType string
+	Config      AlertChannelConfig
+	Credentials AlertChannelCredentials
+	RuleName    string
+	Message     string
+	Payload     map[string]interface{}
+}
+
+/******************************************************************************
+* FUNCTION:        validateAlertChannel
+*
+* DESCRIPTION:     Checks the settings of a channel by its type. Webhook
+*                  urls must be http(s), recipients valid addresses
+* INPUT:           channelType, config, credentials
+* RETURNS:         error
+******************************************************************************/
+func validateAlertChannel(channelType string, config *AlertChannelConfig, creds *AlertChannelCredentials) error {
+	switch channelType {
+	case ALERT_CHANNEL_WEBHOOK:
+		if err := validateWebhookUrl(config.URL); err != nil {
+			return err
+		}
+		if creds.Secret == "" {
+			return errors.New("secret is required to sign the webhook")
+		}
+
+	case ALERT_CHANNEL_SLACK:
+		if err := validateWebhookUrl(creds.SlackURL); err != nil {
+			return err
+		}
+
+	case ALERT_CHANNEL_EMAIL:
+		if types.AlertCfg.SMTPAddr == "" {
+			return ErrEmailNotConfigured
+		}
+		if len(config.To) == 0 || len(config.To) > ALERT_MAX_RECIPIENTS {
+			return fmt.Errorf("to must hold 1-%d addresses", ALERT_MAX_RECIPIENTS)
+		}
+		for i, to := range config.To {
+			address, err := mail.ParseAddress(to)
+			if err != nil {
+				return fmt.Errorf("invalid address %q", to)
+			}
+			config.To[i] = address.Address
+		}
+
+	default:
+		return fmt.Errorf("type must be one of %s, %s, %s", ALERT_CHANNEL_WEBHOOK, ALERT_CHANNEL_SLACK, ALERT_CHANNEL_EMAIL)
+	}
+
+	return nil
+}
+
+/******************************************************************************
+* FUNCTION:        validateWebhookUrl
+*
+* DESCRIPTION:     Helper function checking a webhook url is an absolute
+*                  http(s) url. The destination itself is checked when the
+*                  notification is sent
+* INPUT:           rawUrl
+* RETURNS:         error
+******************************************************************************/
+func validateWebhookUrl(rawUrl string) error {
+	parsed, err := url.Parse(rawUrl)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return errors.New("url must be an http or https url")
+	}
+	return nil
+}
+
+/******************************************************************************
+* FUNCTION:        sendAlertDelivery
+*
+* DESCRIPTION:     Sends a delivery through its channel
+* INPUT:           ctx, delivery
+* RETURNS:         error, wrapping ErrAlertDeliveryRejected when retrying
+*                  is pointless
+******************************************************************************/
+func sendAlertDelivery(ctx context.Context, delivery *alertDelivery) error {
+	switch delivery.ChannelType {
+	case ALERT_CHANNEL_WEBHOOK:
+		body := make(map[string]interface{}, len(delivery.Payload)+1)
+		for key, value := range delivery.Payload {
+			body[key] = value
+		}
+		body["alert_id"] = delivery.AlertId
+		bodyJSON, _ := json.Marshal(body)
+
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		headers := map[string]string{
+			ALERT_TIMESTAMP_HEADER: timestamp,
+			ALERT_SIGNATURE_HEADER: signWebhookBody(delivery.Credentials.Secret, timestamp, bodyJSON),
+			ALERT_DELIVERY_HEADER:  strconv.FormatInt(delivery.DeliveryId, 10),
+		}
+		return postAlertWebhook(ctx, delivery.Config.URL, headers, bodyJSON)
+
+	case ALERT_CHANNEL_SLACK:
+		bodyJSON, _ := json.Marshal(map[string]string{"text": delivery.Message})
+		return postAlertWebhook(ctx, delivery.Credentials.SlackURL, nil, bodyJSON)
+
+	case ALERT_CHANNEL_EMAIL:
+		subject := "[LOGProcessor] Alert: " + delivery.RuleName
+		body := delivery.Message + "\n\n"
+		for _, key := range []string{"rule", "condition", "observed", "previous", "origin", "scope", "file_id", "fired_at"} {
+			if value, ok := delivery.Payload[key]; ok {
+				body += fmt.Sprintf("%s: %v\n", key, value)
+			}
+		}
+		body += fmt.Sprintf("alert_id: %d\n", delivery.AlertId)
+		return sendAlertEmail(ctx, delivery.Config.To, subject, body)
+
+	default:
+		return fmt.Errorf("%w: unknown channel type %q", ErrAlertDeliveryRejected, delivery.ChannelType)
+	}
+}
+
+/******************************************************************************
+* FUNCTION:        signWebhookBody
+*
+* DESCRIPTION:     Returns the signature header value of a webhook body,
+*                  the hex HMAC-SHA256 of "<timestamp>.<body>" keyed by the
+*                  channel secret. Receivers recompute it and should refuse
+*                  old timestamps to stop replays
+* INPUT:           secret, timestamp, body
+* RETURNS:         "sha256=<hex>"
+******************************************************************************/
+func signWebhookBody(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+/******************************************************************************
+* FUNCTION:        postAlertWebhook
+*
+* DESCRIPTION:     Posts a json body to a webhook through the client used
+*                  for remote ingestion, so internal addresses are refused
+*                  unless allow-listed. 429 and 5xx are retried, other
+*                  non-2xx statuses are rejected
+* INPUT:           ctx, target url, headers, body
+* RETURNS:         error
+******************************************************************************/
+func postAlertWebhook(ctx context.Context, target string, headers map[string]string, body []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, types.AlertCfg.DeliveryTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrAlertDeliveryRejected, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "LOGProcessor-Alerts")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := newIngestHttpClient().Do(req)
+	if err != nil {
+		if errors.Is(err, ErrDisallowedDestination) || errors.Is(err, ErrTooManyRedirects) {
+			return fmt.Errorf("%w: %v", ErrAlertDeliveryRejected, err)
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, ALERT_MAX_RESPONSE_BYTES))
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return fmt.Errorf("%w: %s", ErrRemoteStatus, resp.Status)
+	default:
+		return fmt.Errorf("%w: %s", ErrAlertDeliveryRejected, resp.Status)
+	}
+}
+
+/******************************************************************************
+* FUNCTION:        sendAlertEmail
+*
+* DESCRIPTION:     Sends a plain text email through SMTP_ADDR, upgrading
+*                  to TLS when the server offers STARTTLS. 5xx replies of
+*                  the server are rejected, others retried
+* INPUT:           ctx, recipients, subject, body
+* RETURNS:         error
+******************************************************************************/
+func sendAlertEmail(ctx context.Context, to []string, subject, body string) (err error) {
+	cfg := types.AlertCfg
+	if cfg.SMTPAddr == "" {
+		return fmt.Errorf("%w: %v", ErrAlertDeliveryRejected, ErrEmailNotConfigured)
+	}
+	defer func() {
+		var replyErr *textproto.Error
+		if errors.As(err, &replyErr) && replyErr.Code >= 500 {
+			err = fmt.Errorf("%w: %v", ErrAlertDeliveryRejected, err)
+		}
+	}()
+
+	host, _, err := net.SplitHostPort(cfg.SMTPAddr)
+	if err != nil {
+		return fmt.Errorf("%w: invalid SMTP_ADDR: %v", ErrAlertDeliveryRejected, err)
+	}
+
+	dialer := &net.Dialer{Timeout: cfg.DeliveryTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", cfg.SMTPAddr)
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(cfg.DeliveryTimeout))
+
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err = client.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	// smtp.PlainAuth refuses to send the password without TLS, unless the
+	// server is on localhost
+	if cfg.SMTPUsername != "" {
+		if err = client.Auth(smtp.PlainAuth("", cfg.SMTPUsername, cfg.SMTPPassword, host)); err != nil {
+			return err
+		}
+	}
+
+	if err = client.Mail(cfg.SMTPFrom); err != nil {
+		return err
+	}
+	for _, recipient := range to {
+		if err = client.Rcpt(recipient); err != nil {
+			return err
+		}
+	}
+
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err = writer.Write(buildAlertEmail(cfg.SMTPFrom, to, subject, body)); err != nil {
+		writer.Close()
+		return err
+	}
+	if err = writer.Close(); err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
+
+/******************************************************************************
+* FUNCTION:        buildAlertEmail
+*
+* DESCRIPTION:     Helper function building the message of an alert email.
+*                  Line breaks are removed from the subject so a rule name
+*                  cannot add headers
+* INPUT:           from, to, subject, body
+* RETURNS:         message
+******************************************************************************/
+func buildAlertEmail(from string, to []string, subject, body string) []byte {
+	subject = strings.Join(strings.Fields(subject), " ")
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	msg.WriteString("\r\n")
+	msg.WriteString(strings.ReplaceAll(strings.ReplaceAll(body, "\r\n", "\n"), "\n", "\r\n"))
+	msg.WriteString("\r\n")
+
+	return msg.Bytes()
+}
@@ -0,0 +1,627 @@
+/**************************************************************************
+ * File       	   : serviceAlertRules.go
+ * DESCRIPTION     : This file contains the alert rules of a user, their
+ *                   conditions and the checks run at job completion and on
+ *                   stream ingestion. A rule that holds is recorded in
+ *                   alert_history and one alert:notify task is enqueued
+ *                   per channel of the rule
+ * DATE            : 19-October-2026
+ **************************************************************************/
+
+package services
+
+import (
+	"LOGProcessor/log-mainService/tasks"
+	"LOGProcessor/shared/db"
+	"LOGProcessor/shared/types"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/martian/log"
+)
+
+const (
+	ALERT_KIND_THRESHOLD = "threshold"
+	ALERT_KIND_KEYWORD   = "keyword"
+	ALERT_KIND_CHANGE    = "change"
+
+	ALERT_ORIGIN_JOB    = "job"
+	ALERT_ORIGIN_STREAM = "stream"
+
+	ALERT_MAX_RULES            = 50
+	ALERT_DEFAULT_COOLDOWN_SEC = 300
+	ALERT_MAX_COOLDOWN_SEC     = 7 * 24 * 60 * 60
+	// rules are reloaded after this long, so changes made through the api
+	// reach the task service as well
+	ALERT_RULES_CACHE_TTL = time.Minute
+	// streams whose alert window is kept in memory
+	ALERT_MAX_STREAM_WINDOWS = 10000
+	// completed jobs looked at for the last run of the same scope
+	ALERT_LAST_RUN_LOOKBACK = 200
+)
+
+var (
+	ErrInvalidAlertCondition = errors.New(`condition must look like "error_count > 100", "keyword 'panic' seen" or "error_rate up 3x vs last run"`)
+
+	// error_rate is the percentage of the lines counted as errors
+	alertMetrics = map[string]bool{
+		"error_count":    true,
+		"error_rate":     true,
+		"line_count":     true,
+		"rejected_lines": true,
+		"threat_hits":    true,
+		"anomalies":      true,
+	}
+
+	alertThresholdRegex = regexp.MustCompile(`^([a-z]+(?:[ _][a-z]+)?)\s*(>=|<=|==|!=|>|<)\s*(\d+(?:\.\d+)?)$`)
+	alertKeywordRegex   = regexp.MustCompile(`^keyword\s+(?:'([^']+)'|"([^"]+)")\s+seen$`)
+	alertChangeRegex    = regexp.MustCompile(`^([a-z]+(?:[ _][a-z]+)?)\s+(up|down)\s+(\d+(?:\.\d+)?)x\s+vs\s+last\s+run$`)
+	whitespaceRegex     = regexp.MustCompile(`\s+`)
+
+	alertRulesCache = newBoundedCache[string, []alertRule](ALERT_RULES_CACHE_TTL, CACHE_MAX_USERS)
+
+	// windows of streams gone quiet are evicted first, such a window
+	// would only be compared against a stale last run
+	streamAlertWindows = newBoundedCache[streamKey, *streamAlertWindow](0, ALERT_MAX_STREAM_WINDOWS)
+	streamAlertMu      sync.Mutex
+)
+
+// alertCondition is the parsed condition of a rule
+type alertCondition struct {
+	Kind    string
+	Metric  string
+	Op      string
+	Value   float64
+	Keyword string
+}
+
+type alertRule struct {
+	RuleId      int64
+	Name        string
+	Condition   string
+	Parsed      alertCondition
+	ChannelIds  string
+	CooldownSec int64
+}
+
+// alertRun is what the rules are checked against: a completed job or a
+// window of a stream. Previous holds the metrics of the last run, nil
+// when there is none
+type alertRun struct {
+	Origin   string
+	FileId   int64
+	Scope    string
+	Metrics  map[string]float64
+	Previous map[string]float64
+	Entries  []LogEntry
+}
+
+// streamAlertWindow sums the batches of a stream until the window closes
+type streamAlertWindow struct {
+	Start    time.Time
+	Metrics  map[string]float64
+	Previous map[string]float64
+}
+
+/******************************************************************************
+* FUNCTION:        parseAlertCondition
+*
+* DESCRIPTION:     Parses the condition of a rule, one of
+*                  "<metric> <op> <number>", "keyword '<text>' seen" or
+*                  "<metric> up|down <n>x vs last run". Case and spacing do
+*                  not matter and "error rate" reads as error_rate
+* INPUT:           condition
+* RETURNS:         alertCondition, error
+******************************************************************************/
+func parseAlertCondition(condition string) (alertCondition, error) {
+	text := whitespaceRegex.ReplaceAllString(strings.ToLower(strings.TrimSpace(condition)), " ")
+
+	if match := alertKeywordRegex.FindStringSubmatch(text); match != nil {
+		keyword := strings.TrimSpace(match[1] + match[2])
+		if keyword == "" {
+			return alertCondition{}, ErrInvalidAlertCondition
+		}
+		return alertCondition{Kind: ALERT_KIND_KEYWORD, Keyword: keyword}, nil
+	}
+
+	if match := alertChangeRegex.FindStringSubmatch(text); match != nil {
+		metric, err := alertMetricOf(match[1])
+		if err != nil {
+			return alertCondition{}, err
+		}
+		factor, _ := strconv.ParseFloat(match[3], 64)
+		if factor <= 1 {
+			return alertCondition{}, fmt.Errorf("the factor of %q must be above 1", condition)
+		}
+		return alertCondition{Kind: ALERT_KIND_CHANGE, Metric: metric, Op: match[2], Value: factor}, nil
+	}
+
+	if match := alertThresholdRegex.FindStringSubmatch(text); match != nil {
+		metric, err := alertMetricOf(match[1])
+		if err != nil {
+			return alertCondition{}, err
+		}
+		value, _ := strconv.ParseFloat(match[3], 64)
+		return alertCondition{Kind: ALERT_KIND_THRESHOLD, Metric: metric, Op: match[2], Value: value}, nil
+	}
+
+	return alertCondition{}, ErrInvalidAlertCondition
+}
+
+/******************************************************************************
+* FUNCTION:        alertMetricOf
+*
+* DESCRIPTION:     Helper function mapping a metric as written in a
+*                  condition to its name
+* INPUT:           name
+* RETURNS:         metric, error
+******************************************************************************/
+func alertMetricOf(name string) (string, error) {
+	metric := strings.ReplaceAll(name, " ", "_")
+	if !alertMetrics[metric] {
+		return "", fmt.Errorf("unknown metric %q, use one of error_count, error_rate, line_count, rejected_lines, threat_hits, anomalies", name)
+	}
+	return metric, nil
+}
+
+/******************************************************************************
+* FUNCTION:        evaluate
+*
+* DESCRIPTION:     Checks the condition against a run. A change rule holds
+*                  only when the last run had a value above 0 to compare to
+* INPUT:           run
+* RETURNS:         holds, observed value, last run value (0 when unused)
+******************************************************************************/
+func (c alertCondition) evaluate(run *alertRun) (bool, float64, float64) {
+	switch c.Kind {
+	case ALERT_KIND_KEYWORD:
+		var seen float64
+		for i := range run.Entries {
+			if strings.Contains(strings.ToLower(run.Entries[i].Message), c.Keyword) {
+				seen++
+			}
+		}
+		return seen > 0, seen, 0
+
+	case ALERT_KIND_CHANGE:
+		observed := run.Metrics[c.Metric]
+		previous, ok := run.Previous[c.Metric]
+		if !ok || previous <= 0 {
+			return false, observed, previous
+		}
+		if c.Op == "up" {
+			return observed >= previous*c.Value, observed, previous
+		}
+		return observed <= previous/c.Value, observed, previous
+
+	default:
+		observed := run.Metrics[c.Metric]
+		switch c.Op {
+		case ">":
+			return observed > c.Value, observed, 0
+		case ">=":
+			return observed >= c.Value, observed, 0
+		case "<":
+			return observed < c.Value, observed, 0
+		case "<=":
+			return observed <= c.Value, observed, 0
+		case "==":
+			return observed == c.Value, observed, 0
+		default:
+			return observed != c.Value, observed, 0
+		}
+	}
+}
+
+/******************************************************************************
+* FUNCTION:        withErrorRate
+*
+* DESCRIPTION:     Helper function setting the error_rate of metrics from
+*                  their error and line counts
+* INPUT:           metrics
+* RETURNS:         metrics
+******************************************************************************/
+func withErrorRate(metrics map[string]float64) map[string]float64 {
+	metrics["error_rate"] = 0
+	if metrics["line_count"] > 0 {
+		metrics["error_rate"] = 100 * metrics["error_count"] / metrics["line_count"]
+	}
+	return metrics
+}
+
+/******************************************************************************
+* FUNCTION:        getAlertRules
+*
+* DESCRIPTION:     Returns the enabled rules of a user, cached for
+*                  ALERT_RULES_CACHE_TTL. Rules whose condition no longer
+*                  parses are skipped
+* INPUT:           userId
+* RETURNS:         rules, error
+******************************************************************************/
+func getAlertRules(userId string) ([]alertRule, error) {
+	if cached, ok := alertRulesCache.get(userId); ok {
+		return cached, nil
+	}
+
+	query := `
+	SELECT rule_id, name, condition, channel_ids, cooldown_sec FROM alert_rules
+	WHERE user_id = $1 AND enabled = true
+	ORDER BY rule_id ASC`
+
+	rows, err := db.GetDataFromDB(query, []interface{}{userId})
+	if err != nil {
+		return nil, err
+	}
+
+	rules := make([]alertRule, 0, len(rows))
+	for _, row := range rows {
+		rule := alertRule{}
+		rule.RuleId, _ = row["rule_id"].(int64)
+		rule.Name, _ = row["name"].(string)
+		rule.Condition, _ = row["condition"].(string)
+		rule.ChannelIds, _ = row["channel_ids"].(string)
+		rule.CooldownSec, _ = row["cooldown_sec"].(int64)
+
+		rule.Parsed, err = parseAlertCondition(rule.Condition)
+		if err != nil {
+			log.Errorf("skipping alert rule %d of user %s; err: %v", rule.RuleId, userId, err)
+			continue
+		}
+		rules = append(rules, rule)
+	}
+
+	alertRulesCache.set(userId, rules)
+
+	return rules, nil
+}
+
+/******************************************************************************
+* FUNCTION:        invalidateAlertRules
+*
+* DESCRIPTION:     Drops the cached rules of a user after a change
+* INPUT:           userId
+* RETURNS:         void
+******************************************************************************/
+func invalidateAlertRules(userId string) {
+	alertRulesCache.remove(userId)
+}
+
+/******************************************************************************
+* FUNCTION:        checkJobAlerts
+*
+* DESCRIPTION:     Checks the rules of a user against a completed job. The
+*                  last run is the previous completed job of the same
+*                  source or file name pattern, as for anomaly detection
+* INPUT:           userId, fileId, logStats, threatHits, anomalies count
+* RETURNS:         error
+******************************************************************************/
+func checkJobAlerts(userId string, fileId int64, logStats *LogStats, threatHits int64, anomalies int) error {
+	rules, err := getAlertRules(userId)
+	if err != nil || len(rules) == 0 {
+		return err
+	}
+
+	metrics := map[string]float64{
+		"error_count": float64(logStats.ErrorCount),
+		"line_count":  float64(len(logStats.LogEntries)),
+		"threat_hits": float64(threatHits),
+		"anomalies":   float64(anomalies),
+	}
+	if logStats.Lines != nil {
+		metrics["line_count"] = float64(logStats.Lines.Total)
+		metrics["rejected_lines"] = float64(logStats.Lines.Rejected)
+	}
+
+	file, err := db.GetDataFromDB("SELECT file_name FROM file_stats WHERE file_id = $1", []interface{}{fileId})
+	if err != nil {
+		return err
+	}
+	var fileName string
+	if len(file) > 0 {
+		fileName, _ = file[0]["file_name"].(string)
+	}
+
+	run := &alertRun{
+		Origin:  ALERT_ORIGIN_JOB,
+		FileId:  fileId,
+		Scope:   fileName,
+		Metrics: withErrorRate(metrics),
+		Entries: logStats.LogEntries,
+	}
+	for _, rule := range rules {
+		if rule.Parsed.Kind == ALERT_KIND_CHANGE {
+			if run.Previous, err = lastRunMetrics(userId, fileId, fileName); err != nil {
+				return err
+			}
+			break
+		}
+	}
+
+	checkAlertRules(userId, rules, run, ALERT_KIND_THRESHOLD, ALERT_KIND_KEYWORD, ALERT_KIND_CHANGE)
+	return nil
+}
+
+/******************************************************************************
+* FUNCTION:        lastRunMetrics
+*
+* DESCRIPTION:     Returns the metrics of the last completed job before
+*                  fileId in the same scope as fileName
+* INPUT:           userId, fileId, fileName
+* RETURNS:         metrics, nil when there is no last run, error
+******************************************************************************/
+func lastRunMetrics(userId string, fileId int64, fileName string) (map[string]float64, error) {
+	query := `
+	SELECT f.file_name, f.error_count, f.line_count, f.rejected_lines, f.threat_hits,
+		(SELECT COUNT(*) FROM anomalies a WHERE a.file_id = f.file_id) AS anomalies
+	FROM file_stats f
+	WHERE f.user_id = $1 AND f.file_id < $2 AND f.status = 'Completed' AND f.stream_source IS NULL
+	ORDER BY f.file_id DESC
+	LIMIT $3`
+
+	rows, err := db.GetDataFromDB(query, []interface{}{userId, fileId, ALERT_LAST_RUN_LOOKBACK})
+	if err != nil {
+		return nil, err
+	}
+
+	scope := anomalyScopeOf(fileName)
+	for _, row := range rows {
+		name, _ := row["file_name"].(string)
+		if anomalyScopeOf(name) != scope {
+			continue
+		}
+
+		metrics := make(map[string]float64)
+		for _, metric := range []string{"error_count", "line_count", "rejected_lines", "threat_hits", "anomalies"} {
+			value, _ := row[metric].(int64)
+			metrics[metric] = float64(value)
+		}
+		return withErrorRate(metrics), nil
+	}
+
+	return nil, nil
+}
+
+/******************************************************************************
+* FUNCTION:        checkStreamAlerts
+*
+* DESCRIPTION:     Checks the rules of a user against a flushed stream
+*                  batch. Keyword rules are checked on every batch, the
+*                  other rules once per ALERT_STREAM_WINDOW_SEC window, the
+*                  window before being the last run. A window closes with
+*                  the first batch after its end
+* INPUT:           key, fileId, entries
+* RETURNS:         error
+******************************************************************************/
+func checkStreamAlerts(key streamKey, fileId int64, entries []LogEntry) error {
+	batch := map[string]float64{"line_count": float64(len(entries))}
+	for i := range entries {
+		if entries[i].KeywordDetected != "" {
+			batch["error_count"]++
+		}
+		if entries[i].ThreatIndicator != "" {
+			batch["threat_hits"]++
+		}
+	}
+
+	var closed *alertRun
+	streamAlertMu.Lock()
+	window, ok := streamAlertWindows.get(key)
+	if !ok {
+		window = &streamAlertWindow{Start: time.Now(), Metrics: make(map[string]float64)}
+		streamAlertWindows.set(key, window)
+	}
+	for metric, value := range batch {
+		window.Metrics[metric] += value
+	}
+	if time.Since(window.Start) >= types.AlertCfg.StreamWindow {
+		closed = &alertRun{Metrics: withErrorRate(window.Metrics), Previous: window.Previous}
+		window.Previous = closed.Metrics
+		window.Metrics = make(map[string]float64)
+		window.Start = time.Now()
+	}
+	streamAlertMu.Unlock()
+
+	rules, err := getAlertRules(key.UserId)
+	if err != nil || len(rules) == 0 {
+		return err
+	}
+
+	scope := "stream:" + key.Source
+	checkAlertRules(key.UserId, rules, &alertRun{
+		Origin:  ALERT_ORIGIN_STREAM,
+		FileId:  fileId,
+		Scope:   scope,
+		Metrics: withErrorRate(batch),
+		Entries: entries,
+	}, ALERT_KIND_KEYWORD)
+
+	if closed != nil {
+		closed.Origin = ALERT_ORIGIN_STREAM
+		closed.FileId = fileId
+		closed.Scope = scope
+		checkAlertRules(key.UserId, rules, closed, ALERT_KIND_THRESHOLD, ALERT_KIND_CHANGE)
+	}
+
+	return nil
+}
+
+/******************************************************************************
+* FUNCTION:        checkAlertRules
+*
+* DESCRIPTION:     Checks the rules of the given kinds against a run and
+*                  fires those that hold. A rule that fails to fire is
+*                  logged, the others are still checked
+* INPUT:           userId, rules, run, kinds
+* RETURNS:         void
+******************************************************************************/
+func checkAlertRules(userId string, rules []alertRule, run *alertRun, kinds ...string) {
+	for _, rule := range rules {
+		checked := false
+		for _, kind := range kinds {
+			checked = checked || rule.Parsed.Kind == kind
+		}
+		if !checked {
+			continue
+		}
+
+		holds, observed, previous := rule.Parsed.evaluate(run)
+		if !holds {
+			continue
+		}
+		if err := fireAlert(userId, rule, run, observed, previous); err != nil {
+			log.Errorf("failed to fire alert rule %d of user %s; err: %v", rule.RuleId, userId, err)
+		}
+	}
+}
+
+/******************************************************************************
+* FUNCTION:        fireAlert
+*
+* DESCRIPTION:     Records a rule that holds in alert_history with one
+*                  delivery per channel, enqueues the deliveries and lets
+*                  the user know through the websocket. A rule fires once
+*                  per cooldown, the claim is made in the same transaction
+*                  as the history so instances do not fire it twice
+* INPUT:           userId, rule, run, observed, previous
+* RETURNS:         error
+******************************************************************************/
+func fireAlert(userId string, rule alertRule, run *alertRun, observed, previous float64) (err error) {
+	ctx := context.Background()
+	tx, err := types.Db.DbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %v", err)
+	}
+	// a no-op once committed
+	defer tx.Rollback()
+
+	// the claim is committed with the history, an alert that fails to be
+	// recorded does not hold the cooldown
+	claimed, err := db.UpdateDataInDB(tx, `
+	UPDATE alert_rules SET last_fired_at = now()
+	WHERE rule_id = $1 AND enabled = true
+	AND (last_fired_at IS NULL OR last_fired_at <= now() - make_interval(secs => cooldown_sec))`, []interface{}{rule.RuleId})
+	if err != nil || claimed == 0 {
+		return err
+	}
+
+	message := fmt.Sprintf("Alert %q: %s (observed %s", rule.Name, rule.Condition, formatAlertValue(observed))
+	if rule.Parsed.Kind == ALERT_KIND_CHANGE {
+		message += ", last run " + formatAlertValue(previous)
+	}
+	message += ") on " + run.Scope
+
+	firedAt := time.Now()
+	payload := map[string]interface{}{
+		"rule_id":   rule.RuleId,
+		"rule":      rule.Name,
+		"condition": rule.Condition,
+		"observed":  observed,
+		"message":   message,
+		"origin":    run.Origin,
+		"scope":     run.Scope,
+		"file_id":   run.FileId,
+		"fired_at":  firedAt,
+	}
+	if rule.Parsed.Kind == ALERT_KIND_CHANGE {
+		payload["previous"] = previous
+	}
+	payloadJSON, _ := json.Marshal(payload)
+
+	alertId, err := db.InsertAndReturnColumn(tx, "alert_history", "alert_id", map[string]interface{}{
+		"user_id":    userId,
+		"rule_id":    rule.RuleId,
+		"rule_name":  rule.Name,
+		"condition":  rule.Condition,
+		"file_id":    run.FileId,
+		"origin":     run.Origin,
+		"observed":   observed,
+		"message":    message,
+		"payload":    string(payloadJSON),
+		"created_at": firedAt,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to insert into alert_history: %v", err)
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+	INSERT INTO alert_deliveries (alert_id, channel_id, channel_name)
+	SELECT $1, channel_id, name FROM alert_channels
+	WHERE user_id = $2 AND channel_id IN (SELECT jsonb_array_elements_text($3::jsonb)::bigint)
+	RETURNING delivery_id`, alertId, userId, rule.ChannelIds)
+	if err != nil {
+		return fmt.Errorf("failed to insert into alert_deliveries: %v", err)
+	}
+	var deliveryIds []int64
+	for rows.Next() {
+		var deliveryId int64
+		if err = rows.Scan(&deliveryId); err != nil {
+			rows.Close()
+			return err
+		}
+		deliveryIds = append(deliveryIds, deliveryId)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	for _, deliveryId := range deliveryIds {
+		enqueueAlertDelivery(deliveryId)
+	}
+
+	payload["alert_id"] = alertId
+	BroadcastMessage(payload, "alert-fired", userId)
+	return nil
+}
+
+/******************************************************************************
+* FUNCTION:        enqueueAlertDelivery
+*
+* DESCRIPTION:     Enqueues the task sending a delivery, a delivery that
+*                  cannot be enqueued is marked failed
+* INPUT:           deliveryId
+* RETURNS:         void
+******************************************************************************/
+func enqueueAlertDelivery(deliveryId int64) {
+	task, opts, err := tasks.NewAlertNotifyTask(deliveryId)
+	if err == nil {
+		_, err = types.AsynqClient.AsynqClient.EnqueueContext(context.Background(), task, opts...)
+	}
+	if err == nil {
+		return
+	}
+
+	log.Errorf("failed to enqueue alert delivery %d; err: %v", deliveryId, err)
+	_, dbErr := db.UpdateDataInDB(nil, "UPDATE alert_deliveries SET status = 'failed', last_error = $2 WHERE delivery_id = $1",
+		[]interface{}{deliveryId, "failed to enqueue: " + err.Error()})
+	if dbErr != nil {
+		log.Errorf("failed to update alert delivery %d; err: %v", deliveryId, dbErr)
+	}
+}
+
+/******************************************************************************
+* FUNCTION:        formatAlertValue
+*
+* DESCRIPTION:     Helper function formatting a metric for a message, with
+*                  at most two decimals
+* INPUT:           value
+* RETURNS:         string
+******************************************************************************/
+func formatAlertValue(value float64) string {
+	if value == math.Trunc(value) {
+		return strconv.FormatFloat(value, 'f', 0, 64)
+	}
+	return strconv.FormatFloat(value, 'f', 2, 64)
+}
@@ -0,0 +1,509 @@
+/**************************************************************************
+ * File       	   : serviceAnomalyDetection.go
+ * DESCRIPTION     : This file contains the error rate and keyword mix
+ *                   baselines of a user, learned from the completed jobs,
+ *                   and the detection of the spikes and the never seen
+ *                   keywords and message templates of a new job
+ * DATE            : 19-October-2026
+ **************************************************************************/
+
+package services
+
+import (
+	"LOGProcessor/shared/db"
+	"LOGProcessor/shared/types"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	ANOMALY_SCOPE_USER = "user"
+
+	ANOMALY_KIND_ERROR_SPIKE   = "error_spike"
+	ANOMALY_KIND_KEYWORD_SPIKE = "keyword_spike"
+	ANOMALY_KIND_NEW_KEYWORD   = "new_keyword"
+	ANOMALY_KIND_NEW_TEMPLATE  = "new_template"
+
+	// a value this many standard deviations above the baseline is a spike
+	ANOMALY_Z_THRESHOLD = 3.0
+	// a spike needs at least this many errors, a handful is never one
+	ANOMALY_MIN_SPIKE_COUNT = 10
+	// nothing is flagged against a baseline learned from fewer jobs or
+	// hours, every keyword is new to an empty one
+	ANOMALY_MIN_BASELINE_JOBS  = 3
+	ANOMALY_MIN_BASELINE_HOURS = 24
+	ANOMALY_MAX_PER_KIND       = 20
+
+	// templates are learned from warning and error lines only
+	ANOMALY_MAX_JOB_TEMPLATES   = 1000
+	ANOMALY_MAX_SCOPE_TEMPLATES = 10000
+	ANOMALY_TEMPLATE_MAX_LEN    = 256
+	ANOMALY_TEMPLATE_WILDCARD   = "<*>"
+)
+
+var (
+	templateQuotedRegex = regexp.MustCompile(`"[^"]*"|'[^']*'`)
+	// a file name with its dates, counters and ids left out,
+	// app-2026-10-19.1.log and app-2026-10-20.2.log are one pattern
+	fileNameDigitsRegex = regexp.MustCompile(`\d+`)
+	sourceFileNameRegex = regexp.MustCompile(`^sources/(\d+)/`)
+)
+
+// Anomaly is a finding of a job compared to a baseline
+type Anomaly struct {
+	Scope    string  `json:"scope"`
+	Kind     string  `json:"kind"`
+	Subject  string  `json:"subject"`
+	Score    float64 `json:"score"`
+	Observed float64 `json:"observed"`
+	Expected float64 `json:"expected"`
+}
+
+// anomalyBaseline is what was learned of a scope: the mean and variance
+// (Welford) of the errors per hour and the keyword counts of its jobs
+type anomalyBaseline struct {
+	Scope         string
+	Jobs          int64
+	Hours         int64
+	HourMean      float64
+	HourM2        float64
+	KeywordCounts map[string]int64
+	KeywordTotal  int64
+}
+
+// jobProfile is the same of a single job, templates keyed by their hash
+type jobProfile struct {
+	HourErrors   map[time.Time]int64
+	Keywords     map[string]int64
+	KeywordTotal int64
+	Templates    map[string]*jobTemplate
+}
+
+type jobTemplate struct {
+	Hash     string `json:"hash"`
+	Template string `json:"template"`
+	Count    int64  `json:"count"`
+}
+
+/******************************************************************************
+* FUNCTION:        anomalyScopeOf
+*
+* DESCRIPTION:     Returns the scope of a file next to the user's: the
+*                  ingest source it was fetched by, otherwise the pattern
+*                  of its name
+* INPUT:           file name
+* RETURNS:         scope
+******************************************************************************/
+func anomalyScopeOf(fileName string) string {
+	if match := sourceFileNameRegex.FindStringSubmatch(fileName); match != nil {
+		return "source:" + match[1]
+	}
+	return "file:" + fileNameDigitsRegex.ReplaceAllString(strings.ToLower(fileName), "#")
+}
+
+/******************************************************************************
+* FUNCTION:        messageTemplate
+*
+* DESCRIPTION:     Reduces a message to its template: quoted strings, tokens
+*                  with a digit (numbers, ids, addresses, durations) and the
+*                  values of key=value tokens become <*>
+* INPUT:           message
+* RETURNS:         template
+******************************************************************************/
+func messageTemplate(message string) string {
+	message = templateQuotedRegex.ReplaceAllString(message, ANOMALY_TEMPLATE_WILDCARD)
+
+	tokens := strings.Fields(message)
+	for i, token := range tokens {
+		if eq := strings.IndexByte(token, '='); eq > 0 {
+			tokens[i] = token[:eq+1] + ANOMALY_TEMPLATE_WILDCARD
+		} else if strings.ContainsAny(token, "0123456789") {
+			tokens[i] = ANOMALY_TEMPLATE_WILDCARD
+		}
+	}
+
+	template := strings.Join(tokens, " ")
+	if len(template) > ANOMALY_TEMPLATE_MAX_LEN {
+		cut := ANOMALY_TEMPLATE_MAX_LEN
+		for cut > 0 && !utf8.RuneStart(template[cut]) {
+			cut--
+		}
+		template = template[:cut]
+	}
+	return template
+}
+
+/******************************************************************************
+* FUNCTION:        buildJobProfile
+*
+* DESCRIPTION:     Counts the errors (lines with a keyword) per hour of the
+*                  log time, hours with lines but no error count as 0, the
+*                  keywords and the templates of the warning and error lines
+* INPUT:           entries
+* RETURNS:         jobProfile
+******************************************************************************/
+func buildJobProfile(entries []LogEntry) *jobProfile {
+	profile := &jobProfile{
+		HourErrors: make(map[time.Time]int64),
+		Keywords:   make(map[string]int64),
+		Templates:  make(map[string]*jobTemplate),
+	}
+	warnSeverity := levelSeverities["WARN"]
+
+	for _, entry := range entries {
+		hour := entry.Timestamp.UTC().Truncate(time.Hour)
+		profile.HourErrors[hour] += 0
+		if entry.KeywordDetected != "" {
+			profile.HourErrors[hour]++
+			profile.Keywords[entry.KeywordDetected]++
+			profile.KeywordTotal++
+		}
+
+		if entry.KeywordDetected == "" && entry.Severity < warnSeverity {
+			continue
+		}
+		template := messageTemplate(entry.Message)
+		if template == "" {
+			continue
+		}
+		hasher := fnv.New64a()
+		hasher.Write([]byte(template))
+		hash := fmt.Sprintf("%016x", hasher.Sum64())
+
+		if known, ok := profile.Templates[hash]; ok {
+			known.Count++
+		} else if len(profile.Templates) < ANOMALY_MAX_JOB_TEMPLATES {
+			profile.Templates[hash] = &jobTemplate{Hash: hash, Template: template, Count: 1}
+		}
+	}
+
+	return profile
+}
+
+/******************************************************************************
+* FUNCTION:        evaluate
+*
+* DESCRIPTION:     Compares a job to the baseline: hours whose errors are a
+*                  spike, keywords never seen and keywords whose share of
+*                  the errors is a spike
+* INPUT:           profile
+* RETURNS:         anomalies
+******************************************************************************/
+func (b *anomalyBaseline) evaluate(profile *jobProfile) []Anomaly {
+	var anomalies []Anomaly
+
+	if b.Hours >= ANOMALY_MIN_BASELINE_HOURS {
+		// errors are counts, the deviation is at least the poisson one
+		deviation := math.Max(math.Sqrt(b.HourM2/float64(b.Hours-1)), math.Max(math.Sqrt(b.HourMean), 1))
+		for hour, errors := range profile.HourErrors {
+			z := (float64(errors) - b.HourMean) / deviation
+			if errors >= ANOMALY_MIN_SPIKE_COUNT && z >= ANOMALY_Z_THRESHOLD {
+				anomalies = append(anomalies, Anomaly{
+					Scope: b.Scope, Kind: ANOMALY_KIND_ERROR_SPIKE, Subject: hour.Format(time.RFC3339),
+					Score: z, Observed: float64(errors), Expected: b.HourMean,
+				})
+			}
+		}
+	}
+
+	if b.Jobs >= ANOMALY_MIN_BASELINE_JOBS {
+		for keyword, count := range profile.Keywords {
+			seen := b.KeywordCounts[keyword]
+			if seen == 0 {
+				anomalies = append(anomalies, Anomaly{
+					Scope: b.Scope, Kind: ANOMALY_KIND_NEW_KEYWORD, Subject: keyword,
+					Score: float64(count), Observed: float64(count),
+				})
+				continue
+			}
+
+			share := float64(seen) / float64(b.KeywordTotal)
+			if share >= 1 {
+				continue
+			}
+			n := float64(profile.KeywordTotal)
+			expected := n * share
+			z := (float64(count) - expected) / math.Max(math.Sqrt(n*share*(1-share)), 1)
+			if count >= ANOMALY_MIN_SPIKE_COUNT && z >= ANOMALY_Z_THRESHOLD {
+				anomalies = append(anomalies, Anomaly{
+					Scope: b.Scope, Kind: ANOMALY_KIND_KEYWORD_SPIKE, Subject: keyword,
+					Score: z, Observed: float64(count), Expected: expected,
+				})
+			}
+		}
+	}
+
+	return anomalies
+}
+
+/******************************************************************************
+* FUNCTION:        learn
+*
+* DESCRIPTION:     Adds a job to the baseline
+* INPUT:           profile
+* RETURNS:         void
+******************************************************************************/
+func (b *anomalyBaseline) learn(profile *jobProfile) {
+	for _, errors := range profile.HourErrors {
+		b.Hours++
+		delta := float64(errors) - b.HourMean
+		b.HourMean += delta / float64(b.Hours)
+		b.HourM2 += delta * (float64(errors) - b.HourMean)
+	}
+	for keyword, count := range profile.Keywords {
+		b.KeywordCounts[keyword] += count
+	}
+	b.KeywordTotal += profile.KeywordTotal
+	b.Jobs++
+}
+
+/******************************************************************************
+* FUNCTION:        limitAnomalies
+*
+* DESCRIPTION:     Helper function keeping the ANOMALY_MAX_PER_KIND highest
+*                  scored anomalies of each kind, highest first
+* INPUT:           anomalies
+* RETURNS:         anomalies
+******************************************************************************/
+func limitAnomalies(anomalies []Anomaly) []Anomaly {
+	sort.Slice(anomalies, func(i, j int) bool {
+		if anomalies[i].Score != anomalies[j].Score {
+			return anomalies[i].Score > anomalies[j].Score
+		}
+		return anomalies[i].Subject < anomalies[j].Subject
+	})
+
+	perKind := make(map[string]int)
+	limited := anomalies[:0]
+	for _, anomaly := range anomalies {
+		if perKind[anomaly.Kind] < ANOMALY_MAX_PER_KIND {
+			perKind[anomaly.Kind]++
+			limited = append(limited, anomaly)
+		}
+	}
+	return limited
+}
+
+/******************************************************************************
+* FUNCTION:        lockAnomalyBaseline
+*
+* DESCRIPTION:     Reads the baseline of a scope for update, creating it on
+*                  first use, so concurrent jobs of a user learn in turn
+* INPUT:           ctx, tx, userId, scope
+* RETURNS:         baseline, error
+******************************************************************************/
+func lockAnomalyBaseline(ctx context.Context, tx *sql.Tx, userId, scope string) (*anomalyBaseline, error) {
+	var keywordJSON sql.NullString
+
+	_, err := db.UpdateDataInDB(tx, "INSERT INTO anomaly_baselines (user_id, scope) VALUES ($1, $2) ON CONFLICT (user_id, scope) DO NOTHING", []interface{}{userId, scope})
+	if err != nil {
+		return nil, err
+	}
+
+	b := &anomalyBaseline{Scope: scope, KeywordCounts: make(map[string]int64)}
+	err = tx.QueryRowContext(ctx, "SELECT jobs, hours, hour_mean, hour_m2, keyword_counts, keyword_total FROM anomaly_baselines WHERE user_id = $1 AND scope = $2 FOR UPDATE", userId, scope).
+		Scan(&b.Jobs, &b.Hours, &b.HourMean, &b.HourM2, &keywordJSON, &b.KeywordTotal)
+	if err != nil {
+		return nil, err
+	}
+	if keywordJSON.Valid && keywordJSON.String != "" {
+		json.Unmarshal([]byte(keywordJSON.String), &b.KeywordCounts)
+	}
+	return b, nil
+}
+
+/******************************************************************************
+* FUNCTION:        newTemplates
+*
+* DESCRIPTION:     Returns the templates of a job the scope has never seen,
+*                  read in the transaction holding the baseline lock
+* INPUT:           ctx, tx, userId, scope, profile
+* RETURNS:         templates, error
+******************************************************************************/
+func newTemplates(ctx context.Context, tx *sql.Tx, userId, scope string, profile *jobProfile) ([]*jobTemplate, error) {
+	if len(profile.Templates) == 0 {
+		return nil, nil
+	}
+
+	hashes := make([]string, 0, len(profile.Templates))
+	for hash := range profile.Templates {
+		hashes = append(hashes, hash)
+	}
+
+	rows, err := tx.QueryContext(ctx, "SELECT template_hash FROM anomaly_templates WHERE user_id = $1 AND scope = $2 AND template_hash = ANY($3::text[])", userId, scope, textArrayLiteral(hashes))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	seen := make(map[string]bool)
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			return nil, err
+		}
+		seen[hash] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var templates []*jobTemplate
+	for hash, template := range profile.Templates {
+		if !seen[hash] {
+			templates = append(templates, template)
+		}
+	}
+	return templates, nil
+}
+
+/******************************************************************************
+* FUNCTION:        storeAnomalyBaseline
+*
+* DESCRIPTION:     Writes a baseline back and adds the job's templates to
+*                  its known ones, new templates are no longer learned once
+*                  a scope knows ANOMALY_MAX_SCOPE_TEMPLATES
+* INPUT:           tx, userId, baseline, profile
+* RETURNS:         error
+******************************************************************************/
+func storeAnomalyBaseline(tx *sql.Tx, userId string, b *anomalyBaseline, profile *jobProfile) error {
+	keywordJSON, _ := json.Marshal(b.KeywordCounts)
+
+	update := `
+	UPDATE anomaly_baselines SET jobs = $3, hours = $4, hour_mean = $5, hour_m2 = $6,
+		keyword_counts = $7, keyword_total = $8, updated_at = $9
+	WHERE user_id = $1 AND scope = $2`
+
+	_, err := db.UpdateDataInDB(tx, update, []interface{}{userId, b.Scope, b.Jobs, b.Hours, b.HourMean, b.HourM2, string(keywordJSON), b.KeywordTotal, time.Now()})
+	if err != nil {
+		return err
+	}
+
+	if len(profile.Templates) == 0 {
+		return nil
+	}
+	templates := make([]*jobTemplate, 0, len(profile.Templates))
+	for _, template := range profile.Templates {
+		templates = append(templates, template)
+	}
+	templatesJSON, _ := json.Marshal(templates)
+
+	upsert := `
+	INSERT INTO anomaly_templates (user_id, scope, template_hash, template, seen_count, first_seen, last_seen)
+	SELECT $1, $2, t.hash, t.template, t.count, $4, $4
+	FROM jsonb_to_recordset($3::jsonb) AS t(hash TEXT, template TEXT, count BIGINT)
+	WHERE (SELECT COUNT(*) FROM anomaly_templates WHERE user_id = $1 AND scope = $2) < $5
+		OR EXISTS (SELECT 1 FROM anomaly_templates a WHERE a.user_id = $1 AND a.scope = $2 AND a.template_hash = t.hash)
+	ON CONFLICT (user_id, scope, template_hash) DO UPDATE
+	SET seen_count = anomaly_templates.seen_count + EXCLUDED.seen_count, last_seen = EXCLUDED.last_seen`
+
+	_, err = db.UpdateDataInDB(tx, upsert, []interface{}{userId, b.Scope, string(templatesJSON), time.Now(), ANOMALY_MAX_SCOPE_TEMPLATES})
+	return err
+}
+
+/******************************************************************************
+* FUNCTION:        detectJobAnomalies
+*
+* DESCRIPTION:     Compares a completed job to the baseline of its source or
+*                  file name pattern, or to the user's one while that is
+*                  still learning, stores the anomalies found and then
+*                  learns the job into both baselines
+* INPUT:           userId, fileId, entries of the job
+* RETURNS:         anomalies, error
+******************************************************************************/
+func detectJobAnomalies(userId string, fileId int64, entries []LogEntry) (anomalies []Anomaly, err error) {
+	defer PanicRecovery("detectJobAnomalies")
+
+	if len(entries) == 0 {
+		return nil, nil
+	}
+
+	file, err := db.GetDataFromDB("SELECT file_name FROM file_stats WHERE file_id = $1", []interface{}{fileId})
+	if err != nil {
+		return nil, err
+	}
+	if len(file) == 0 {
+		return nil, fmt.Errorf("file %d not found", fileId)
+	}
+	fileName, _ := file[0]["file_name"].(string)
+	profile := buildJobProfile(entries)
+
+	ctx := context.Background()
+	tx, err := types.Db.DbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %v", err)
+	}
+	// also runs when PanicRecovery swallows a panic, a no-op once committed
+	defer tx.Rollback()
+
+	// always the same lock order, user first
+	userBaseline, err := lockAnomalyBaseline(ctx, tx, userId, ANOMALY_SCOPE_USER)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock baseline: %v", err)
+	}
+	fileBaseline, err := lockAnomalyBaseline(ctx, tx, userId, anomalyScopeOf(fileName))
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock baseline: %v", err)
+	}
+
+	baseline := fileBaseline
+	if baseline.Jobs < ANOMALY_MIN_BASELINE_JOBS {
+		baseline = userBaseline
+	}
+
+	anomalies = baseline.evaluate(profile)
+	if baseline.Jobs >= ANOMALY_MIN_BASELINE_JOBS {
+		templates, err := newTemplates(ctx, tx, userId, baseline.Scope, profile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read known templates: %v", err)
+		}
+		for _, template := range templates {
+			anomalies = append(anomalies, Anomaly{
+				Scope: baseline.Scope, Kind: ANOMALY_KIND_NEW_TEMPLATE, Subject: template.Template,
+				Score: float64(template.Count), Observed: float64(template.Count),
+			})
+		}
+	}
+	anomalies = limitAnomalies(anomalies)
+
+	for _, b := range []*anomalyBaseline{userBaseline, fileBaseline} {
+		b.learn(profile)
+		if err = storeAnomalyBaseline(tx, userId, b, profile); err != nil {
+			return nil, fmt.Errorf("failed to store baseline: %v", err)
+		}
+	}
+
+	if len(anomalies) > 0 {
+		rows := make([]map[string]interface{}, 0, len(anomalies))
+		for _, anomaly := range anomalies {
+			rows = append(rows, map[string]interface{}{
+				"user_id":    userId,
+				"file_id":    fileId,
+				"scope":      anomaly.Scope,
+				"kind":       anomaly.Kind,
+				"subject":    anomaly.Subject,
+				"score":      anomaly.Score,
+				"observed":   anomaly.Observed,
+				"expected":   anomaly.Expected,
+				"created_at": time.Now(),
+			})
+		}
+		if err = db.AddMultipleRecordInDB(tx, "anomalies", rows); err != nil {
+			return nil, fmt.Errorf("failed to store anomalies: %v", err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return anomalies, nil
+}
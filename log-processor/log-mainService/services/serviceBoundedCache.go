@@ -0,0 +1,142 @@
+/**************************************************************************
+ * File       	   : serviceBoundedCache.go
+ * DESCRIPTION     : This file contains the in-memory cache bounded in age
+ *                   and in size, used for the per-user settings (level
+ *                   mappings, redaction rules, CIDR sets, alert rules), the
+ *                   compiled grok patterns, the file_stats rows and the
+ *                   alert windows of streams
+ * DATE            : 19-October-2026
+ **************************************************************************/
+
+package services
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// users whose settings are kept in memory per cache, the least
+	// recently used are dropped beyond this
+	CACHE_MAX_USERS = 10000
+)
+
+type boundedCacheEntry[V any] struct {
+	value    V
+	loadedAt time.Time
+	usedAt   time.Time
+}
+
+// boundedCache is safe for concurrent use. Entries expire ttl after they
+// were stored, a ttl of 0 keeps them until they are evicted
+type boundedCache[K comparable, V any] struct {
+	mu         sync.Mutex
+	ttl        time.Duration
+	maxEntries int
+	entries    map[K]*boundedCacheEntry[V]
+}
+
+/******************************************************************************
+* FUNCTION:        newBoundedCache
+*
+* DESCRIPTION:     Creates a cache holding up to maxEntries entries
+* INPUT:           ttl, maxEntries
+* RETURNS:         *boundedCache
+******************************************************************************/
+func newBoundedCache[K comparable, V any](ttl time.Duration, maxEntries int) *boundedCache[K, V] {
+	return &boundedCache[K, V]{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    make(map[K]*boundedCacheEntry[V]),
+	}
+}
+
+/******************************************************************************
+* FUNCTION:        get
+*
+* DESCRIPTION:     Returns the value of a key, ok is false when it is
+*                  missing or expired
+* INPUT:           key
+* RETURNS:         value, ok
+******************************************************************************/
+func (c *boundedCache[K, V]) get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok || c.expired(entry, time.Now()) {
+		var zero V
+		return zero, false
+	}
+	entry.usedAt = time.Now()
+	return entry.value, true
+}
+
+/******************************************************************************
+* FUNCTION:        set
+*
+* DESCRIPTION:     Stores the value of a key. A full cache first drops its
+*                  expired entries, then the least recently used one
+* INPUT:           key, value
+* RETURNS:         void
+******************************************************************************/
+func (c *boundedCache[K, V]) set(key K, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if _, exists := c.entries[key]; !exists && len(c.entries) >= c.maxEntries {
+		c.evict(now)
+	}
+	c.entries[key] = &boundedCacheEntry[V]{value: value, loadedAt: now, usedAt: now}
+}
+
+/******************************************************************************
+* FUNCTION:        remove
+*
+* DESCRIPTION:     Drops the value of a key, e.g. after a change
+* INPUT:           key
+* RETURNS:         void
+******************************************************************************/
+func (c *boundedCache[K, V]) remove(key K) {
+	c.mu.Lock()
+	delete(c.entries, key)
+	c.mu.Unlock()
+}
+
+/******************************************************************************
+* FUNCTION:        evict
+*
+* DESCRIPTION:     Helper function making room for one entry, called with
+*                  the lock held
+* INPUT:           now
+* RETURNS:         void
+******************************************************************************/
+func (c *boundedCache[K, V]) evict(now time.Time) {
+	var oldestKey K
+	var oldest *boundedCacheEntry[V]
+	for key, entry := range c.entries {
+		if c.expired(entry, now) {
+			delete(c.entries, key)
+			continue
+		}
+		if oldest == nil || entry.usedAt.Before(oldest.usedAt) {
+			oldestKey, oldest = key, entry
+		}
+	}
+
+	if oldest != nil && len(c.entries) >= c.maxEntries {
+		delete(c.entries, oldestKey)
+	}
+}
+
+/******************************************************************************
+* FUNCTION:        expired
+*
+* DESCRIPTION:     Helper function telling whether an entry outlived the ttl
+* INPUT:           entry, now
+* RETURNS:         bool
+******************************************************************************/
+func (c *boundedCache[K, V]) expired(entry *boundedCacheEntry[V], now time.Time) bool {
+	return c.ttl > 0 && now.Sub(entry.loadedAt) >= c.ttl
+}
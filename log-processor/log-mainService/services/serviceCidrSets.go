@@ -0,0 +1,205 @@
+/**************************************************************************
+ * File       	   : serviceCidrSets.go
+ * DESCRIPTION     : This file contains the named CIDR sets of a user
+ *                   (office, vpn, known scanners) and the tagging of the
+ *                   entries whose ip falls in one of them
+ * DATE            : 19-October-2026
+ **************************************************************************/
+
+package services
+
+import (
+	"LOGProcessor/shared/db"
+	"fmt"
+	"net/netip"
+	"strings"
+	"time"
+
+	"github.com/google/martian/log"
+)
+
+const (
+	// sets are reloaded after this long, so changes made through the api
+	// reach the task service as well
+	CIDR_SETS_CACHE_TTL = time.Minute
+)
+
+// cidrSet is a named set of networks, an entry whose ip is in any of them
+// is tagged with the name
+type cidrSet struct {
+	Name     string
+	Prefixes []netip.Prefix
+}
+
+var cidrSetsCache = newBoundedCache[string, []cidrSet](CIDR_SETS_CACHE_TTL, CACHE_MAX_USERS)
+
+/******************************************************************************
+* FUNCTION:        parseCidr
+*
+* DESCRIPTION:     Parses a network of a set. A single address is a /32 or
+*                  /128 network. Like the postgres cidr type, host bits set
+*                  to the right of the mask are refused
+* INPUT:           value
+* RETURNS:         prefix, error
+******************************************************************************/
+func parseCidr(value string) (netip.Prefix, error) {
+	value = strings.TrimSpace(value)
+
+	if !strings.Contains(value, "/") {
+		addr, err := netip.ParseAddr(value)
+		if err != nil {
+			return netip.Prefix{}, fmt.Errorf("invalid cidr %q", value)
+		}
+		addr = addr.Unmap()
+		return netip.PrefixFrom(addr, addr.BitLen()), nil
+	}
+
+	prefix, err := netip.ParsePrefix(value)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("invalid cidr %q", value)
+	}
+	if prefix.Masked() != prefix {
+		return netip.Prefix{}, fmt.Errorf("cidr %q has bits set to the right of the mask, use %s", value, prefix.Masked())
+	}
+	return prefix, nil
+}
+
+/******************************************************************************
+* FUNCTION:        getCidrSets
+*
+* DESCRIPTION:     Returns the CIDR sets of a user ordered by name, cached
+*                  for CIDR_SETS_CACHE_TTL
+* INPUT:           userId
+* RETURNS:         sets, error
+******************************************************************************/
+func getCidrSets(userId string) ([]cidrSet, error) {
+	if cached, ok := cidrSetsCache.get(userId); ok {
+		return cached, nil
+	}
+
+	rows, err := db.GetDataFromDB("SELECT name, array_to_string(cidrs, ',') AS cidrs FROM cidr_sets WHERE user_id = $1 ORDER BY name ASC", []interface{}{userId})
+	if err != nil {
+		return nil, err
+	}
+
+	sets := make([]cidrSet, 0, len(rows))
+	for _, row := range rows {
+		name, _ := row["name"].(string)
+		cidrs, _ := row["cidrs"].(string)
+
+		set := cidrSet{Name: name}
+		for _, value := range strings.Split(cidrs, ",") {
+			prefix, err := parseCidr(value)
+			if err != nil {
+				log.Errorf("skipping cidr of set %s of user %s; err: %v", name, userId, err)
+				continue
+			}
+			set.Prefixes = append(set.Prefixes, prefix)
+		}
+		sets = append(sets, set)
+	}
+
+	cidrSetsCache.set(userId, sets)
+
+	return sets, nil
+}
+
+/******************************************************************************
+* FUNCTION:        getCidrSetsOrEmpty
+*
+* DESCRIPTION:     Helper function for the push inputs, entries are stored
+*                  untagged rather than refused when the sets cannot be
+*                  loaded
+* INPUT:           userId
+* RETURNS:         sets
+******************************************************************************/
+func getCidrSetsOrEmpty(userId string) []cidrSet {
+	sets, err := getCidrSets(userId)
+	if err != nil {
+		log.Errorf("failed to load cidr sets of user %s; err: %v", userId, err)
+		return nil
+	}
+	return sets
+}
+
+/******************************************************************************
+* FUNCTION:        invalidateCidrSets
+*
+* DESCRIPTION:     Drops the cached sets of a user after a change
+* INPUT:           userId
+* RETURNS:         void
+******************************************************************************/
+func invalidateCidrSets(userId string) {
+	cidrSetsCache.remove(userId)
+}
+
+/******************************************************************************
+* FUNCTION:        cidrSetTags
+*
+* DESCRIPTION:     Returns the names of the sets containing an ip
+* INPUT:           sets, ip
+* RETURNS:         names, nil when none
+******************************************************************************/
+func cidrSetTags(sets []cidrSet, ip string) []string {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return nil
+	}
+	addr = addr.Unmap()
+
+	var tags []string
+	for _, set := range sets {
+		for _, prefix := range set.Prefixes {
+			if prefix.Contains(addr) {
+				tags = append(tags, set.Name)
+				break
+			}
+		}
+	}
+	return tags
+}
+
+/******************************************************************************
+* FUNCTION:        tagLogEntries
+*
+* DESCRIPTION:     Tags the entries whose ip is in one of the sets, each
+*                  distinct ip is matched once
+* INPUT:           entries, sets
+* RETURNS:         void
+******************************************************************************/
+func tagLogEntries(entries []LogEntry, sets []cidrSet) {
+	if len(sets) == 0 {
+		return
+	}
+
+	seen := make(map[string][]string)
+	for i := range entries {
+		ip := entries[i].IP
+		if ip == "" {
+			continue
+		}
+
+		tags, ok := seen[ip]
+		if !ok {
+			tags = cidrSetTags(sets, ip)
+			seen[ip] = tags
+		}
+		entries[i].IPTags = tags
+	}
+}
+
+/******************************************************************************
+* FUNCTION:        textArrayLiteral
+*
+* DESCRIPTION:     Helper function encoding values as a postgres array
+*                  literal, nil for none. The values are set names and
+*                  networks, they need no quoting
+* INPUT:           values
+* RETURNS:         literal or nil
+******************************************************************************/
+func textArrayLiteral(values []string) interface{} {
+	if len(values) == 0 {
+		return nil
+	}
+	return "{" + strings.Join(values, ",") + "}"
+}
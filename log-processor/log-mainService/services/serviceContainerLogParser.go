@@ -0,0 +1,332 @@
+/**************************************************************************
+ * File       	   : serviceContainerLogParser.go
+ * DESCRIPTION     : This file contains the parser of container log files,
+ *                   Docker's json-file format and the CRI format written
+ *                   by containerd/CRI-O, including the reassembly of
+ *                   partial lines and the kubernetes metadata derived
+ *                   from the file path
+ * DATE            : 19-October-2026
+ **************************************************************************/
+
+package services
+
+import (
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	CONTAINER_UNKNOWN_LEVEL = "UNKNOWN"
+	// reassembled lines are cut at this size, a runaway partial sequence
+	// must not grow without bound
+	CONTAINER_MAX_LINE_BYTES = 1 << 20
+
+	CRI_TAG_PARTIAL = "P"
+
+	// non-empty lines after which a file with no container line is read
+	// as plain lines only
+	CONTAINER_DETECT_LINES = 10
+)
+
+// formats of a container log file, locked by the first container line
+const (
+	containerFormatUnknown = iota
+	containerFormatDocker
+	containerFormatCri
+	containerFormatPlain
+)
+
+var (
+	// <time> <stream> <P|F> <message>
+	criLineRegex = regexp.MustCompile(`^(\S+) (stdout|stderr) ([PF])(?: (.*))?$`)
+
+	// /var/log/pods/<namespace>_<pod>_<pod uid>/<container>/<restart>.log
+	podLogPathRegex = regexp.MustCompile(`(?:^|/)([a-z0-9-]+)_([a-z0-9.-]+)_[a-f0-9-]+/([a-z0-9-]+)/\d+\.log(?:\.[0-9a-z.-]+)?$`)
+	// /var/log/containers/<pod>_<namespace>_<container>-<container id>.log
+	containerLogPathRegex = regexp.MustCompile(`(?:^|/)([a-z0-9.-]+)_([a-z0-9-]+)_([a-z0-9-]+)-[a-f0-9]{64}\.log$`)
+	// time prefix given to the names of fetched files
+	ingestStampRegex = regexp.MustCompile(`(^|/)\d{8}T\d{6}-`)
+)
+
+// ContainerMeta is the kubernetes metadata of a container log file
+type ContainerMeta struct {
+	Namespace string
+	Pod       string
+	Container string
+}
+
+type dockerJsonLine struct {
+	Log    *string `json:"log"`
+	Stream string  `json:"stream"`
+	Time   string  `json:"time"`
+}
+
+type partialLine struct {
+	timestamp time.Time
+	message   strings.Builder
+}
+
+// containerLogParser parses the lines of one file. Lines of the container
+// formats may be split by the runtime, the parts are kept per stream
+// until the closing part arrives
+type containerLogParser struct {
+	fileID  int64
+	meta    ContainerMeta
+	partial map[string]*partialLine
+	// parse handles plain lines and the messages of container lines
+	parse lineParseFunc
+	// the file's format once detected, and the lines read until then
+	format   int
+	detected int
+}
+
+/******************************************************************************
+* FUNCTION:        newContainerLogParser
+*
+* DESCRIPTION:     Creates the line parser of a file, the kubernetes
+*                  metadata is derived from its path
+* INPUT:           filePath, fileID, parser of plain lines
+* RETURNS:         *containerLogParser
+******************************************************************************/
+func newContainerLogParser(filePath string, fileID int64, parse lineParseFunc) *containerLogParser {
+	return &containerLogParser{
+		fileID:  fileID,
+		meta:    containerMetaFromPath(filePath),
+		partial: make(map[string]*partialLine),
+		parse:   parse,
+	}
+}
+
+/******************************************************************************
+* FUNCTION:        parseLine
+*
+* DESCRIPTION:     Parses a line in the Docker json-file or CRI format, any
+*                  other line with the file's line parser. The first
+*                  container line locks the format of the file, a file
+*                  whose first lines are not container lines is read as
+*                  plain lines. ok is false for partial lines, whose entry
+*                  is returned with the closing part, and for rejected
+*                  lines, which also return the parse error
+* INPUT:           line
+* RETURNS:         LogEntry, ok, error
+******************************************************************************/
+func (p *containerLogParser) parseLine(line string) (LogEntry, bool, error) {
+	if p.format == containerFormatUnknown || p.format == containerFormatDocker {
+		if entry, ok, matched := p.parseDockerLine(line); matched {
+			p.format = containerFormatDocker
+			return entry, ok, nil
+		}
+	}
+
+	if p.format == containerFormatUnknown || p.format == containerFormatCri {
+		if entry, ok, matched := p.parseCriLine(line); matched {
+			p.format = containerFormatCri
+			return entry, ok, nil
+		}
+	}
+
+	if p.format == containerFormatUnknown && strings.TrimSpace(line) != "" {
+		p.detected++
+		if p.detected >= CONTAINER_DETECT_LINES {
+			p.format = containerFormatPlain
+		}
+	}
+
+	entry, err := p.parse(line, p.fileID)
+	if err != nil {
+		return LogEntry{}, false, err
+	}
+	return p.withMeta(entry), true, nil
+}
+
+/******************************************************************************
+* FUNCTION:        parseDockerLine
+*
+* DESCRIPTION:     Parses a json-file record, which has a log, a stream of
+*                  stdout or stderr and an RFC3339 time. matched is false
+*                  for any other line, e.g. an application's own json log
+*                  that has a "log" field
+* INPUT:           line
+* RETURNS:         LogEntry, ok, matched
+******************************************************************************/
+func (p *containerLogParser) parseDockerLine(line string) (LogEntry, bool, bool) {
+	if !strings.HasPrefix(line, "{") {
+		return LogEntry{}, false, false
+	}
+
+	var record dockerJsonLine
+	if err := json.Unmarshal([]byte(line), &record); err != nil || record.Log == nil {
+		return LogEntry{}, false, false
+	}
+	if record.Stream != "stdout" && record.Stream != "stderr" {
+		return LogEntry{}, false, false
+	}
+	timestamp, err := time.Parse(time.RFC3339Nano, record.Time)
+	if err != nil {
+		return LogEntry{}, false, false
+	}
+
+	// json-file ends complete lines with a newline, a part of a split
+	// line has none
+	message, complete := strings.CutSuffix(*record.Log, "\n")
+	entry, ok := p.append(record.Stream, timestamp, message, complete)
+	return p.withMeta(entry), ok, true
+}
+
+/******************************************************************************
+* FUNCTION:        parseCriLine
+*
+* DESCRIPTION:     Parses a CRI line, matched is false for any other line
+* INPUT:           line
+* RETURNS:         LogEntry, ok, matched
+******************************************************************************/
+func (p *containerLogParser) parseCriLine(line string) (LogEntry, bool, bool) {
+	matches := criLineRegex.FindStringSubmatch(line)
+	if matches == nil {
+		return LogEntry{}, false, false
+	}
+	timestamp, err := time.Parse(time.RFC3339Nano, matches[1])
+	if err != nil {
+		return LogEntry{}, false, false
+	}
+
+	entry, ok := p.append(matches[2], timestamp, matches[4], matches[3] != CRI_TAG_PARTIAL)
+	return p.withMeta(entry), ok, true
+}
+
+/******************************************************************************
+* FUNCTION:        flush
+*
+* DESCRIPTION:     Returns the entries of partial lines still open at the
+*                  end of the file, ordered by stream
+* INPUT:           None
+* RETURNS:         []LogEntry
+******************************************************************************/
+func (p *containerLogParser) flush() []LogEntry {
+	streams := make([]string, 0, len(p.partial))
+	for stream := range p.partial {
+		streams = append(streams, stream)
+	}
+	sort.Strings(streams)
+
+	entries := make([]LogEntry, 0, len(streams))
+	for _, stream := range streams {
+		entry, _ := p.append(stream, time.Time{}, "", true)
+		entries = append(entries, p.withMeta(entry))
+	}
+	return entries
+}
+
+/******************************************************************************
+* FUNCTION:        append
+*
+* DESCRIPTION:     Adds a part to the open line of the stream and builds the
+*                  entry once the line is complete. The entry keeps the
+*                  time of the first part
+* INPUT:           stream, timestamp, message part, complete
+* RETURNS:         LogEntry, ok
+******************************************************************************/
+func (p *containerLogParser) append(stream string, timestamp time.Time, part string, complete bool) (LogEntry, bool) {
+	open, exists := p.partial[stream]
+	if !exists {
+		if complete {
+			return p.buildEntry(stream, timestamp, part), true
+		}
+		open = &partialLine{timestamp: timestamp}
+		p.partial[stream] = open
+	}
+
+	if remaining := CONTAINER_MAX_LINE_BYTES - open.message.Len(); len(part) > remaining {
+		part = part[:remaining]
+		complete = true
+	}
+	open.message.WriteString(part)
+
+	if !complete {
+		return LogEntry{}, false
+	}
+
+	delete(p.partial, stream)
+	return p.buildEntry(stream, open.timestamp, open.message.String()), true
+}
+
+/******************************************************************************
+* FUNCTION:        buildEntry
+*
+* DESCRIPTION:     Builds the entry of a complete container line. A message
+*                  in the application's own format is parsed as such,
+*                  otherwise the runtime's time is used with an unknown level
+* INPUT:           stream, timestamp, message
+* RETURNS:         LogEntry
+******************************************************************************/
+func (p *containerLogParser) buildEntry(stream string, timestamp time.Time, message string) LogEntry {
+	message = strings.TrimRight(message, "\r")
+
+	entry, err := p.parse(message, p.fileID)
+	if err != nil {
+		entry = buildLogEntry(timestamp, CONTAINER_UNKNOWN_LEVEL, message, p.fileID)
+	}
+	entry.Stream = stream
+
+	return entry
+}
+
+/******************************************************************************
+* FUNCTION:        withMeta
+*
+* DESCRIPTION:     Helper function setting the kubernetes metadata of the
+*                  file on an entry
+* INPUT:           entry
+* RETURNS:         LogEntry
+******************************************************************************/
+func (p *containerLogParser) withMeta(entry LogEntry) LogEntry {
+	entry.Namespace = p.meta.Namespace
+	entry.Pod = p.meta.Pod
+	entry.Container = p.meta.Container
+	return entry
+}
+
+/******************************************************************************
+* FUNCTION:        containerMetaFromPath
+*
+* DESCRIPTION:     Derives namespace, pod and container from the kubelet's
+*                  log path conventions, /var/log/pods/<namespace>_<pod>_<uid>
+*                  /<container>/<n>.log and the /var/log/containers/<pod>_
+*                  <namespace>_<container>-<id>.log symlinks. Other paths
+*                  give empty metadata
+* INPUT:           filePath
+* RETURNS:         ContainerMeta
+******************************************************************************/
+func containerMetaFromPath(filePath string) ContainerMeta {
+	filePath = ingestStampRegex.ReplaceAllString(filePath, "$1")
+
+	if matches := podLogPathRegex.FindStringSubmatch(filePath); matches != nil {
+		return ContainerMeta{Namespace: matches[1], Pod: matches[2], Container: matches[3]}
+	}
+
+	if matches := containerLogPathRegex.FindStringSubmatch(filePath); matches != nil {
+		return ContainerMeta{Pod: matches[1], Namespace: matches[2], Container: matches[3]}
+	}
+
+	return ContainerMeta{}
+}
+
+/******************************************************************************
+* FUNCTION:        containerObjectName
+*
+* DESCRIPTION:     Storage name of a fetched object. The directories of a
+*                  /var/log/pods path carry the kubernetes metadata and are
+*                  kept, other keys are reduced to their sanitized base name
+* INPUT:           key
+* RETURNS:         string
+******************************************************************************/
+func containerObjectName(key string) string {
+	if loc := podLogPathRegex.FindStringIndex(key); loc != nil {
+		return strings.TrimPrefix(key[loc[0]:], "/")
+	}
+
+	return sanitizeObjectName(key)
+}
@@ -0,0 +1,277 @@
+/**************************************************************************
+ * File       	   : serviceEncoding.go
+ * DESCRIPTION     : This file contains the character encoding detection of
+ *                   processed files and the reader transcoding them to
+ *                   UTF-8. Invalid sequences are replaced instead of
+ *                   failing the job
+ * DATE            : 19-October-2026
+ **************************************************************************/
+
+package services
+
+import (
+	"LOGProcessor/shared/db"
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+)
+
+const (
+	ENCODING_AUTO      = "auto"
+	ENCODING_UTF8      = "utf-8"
+	ENCODING_UTF16LE   = "utf-16le"
+	ENCODING_UTF16BE   = "utf-16be"
+	ENCODING_LATIN1    = "windows-1252"
+	ENCODING_SHIFT_JIS = "shift_jis"
+
+	// bytes of the start of a file the encoding is detected on
+	ENCODING_SNIFF_BYTES = 64 * 1024
+	// share of NUL bytes at odd (LE) or even (BE) offsets of UTF-16 text
+	// without BOM, ascii text in UTF-16 has one on every character
+	UTF16_NUL_RATIO = 0.4
+)
+
+var ErrUnsupportedEncoding = errors.New("unsupported encoding")
+
+var encodingBOMs = []struct {
+	bom  []byte
+	name string
+}{
+	{[]byte{0xEF, 0xBB, 0xBF}, ENCODING_UTF8},
+	{[]byte{0xFF, 0xFE}, ENCODING_UTF16LE},
+	{[]byte{0xFE, 0xFF}, ENCODING_UTF16BE},
+}
+
+// sourceEncoding is the encoding a file is read with, BOMLen the bytes of
+// its byte order mark
+type sourceEncoding struct {
+	Name   string
+	BOMLen int
+}
+
+/******************************************************************************
+* FUNCTION:        lookupEncoding
+*
+* DESCRIPTION:     Resolves an encoding label as used by browsers and
+*                  Content-Type headers (utf-16, latin1, iso-8859-1, sjis,
+*                  cp1252...) to its canonical name. Empty and "auto" mean
+*                  detect, returned as ""
+* INPUT:           label
+* RETURNS:         canonical name, error
+******************************************************************************/
+func lookupEncoding(label string) (string, error) {
+	label = strings.ToLower(strings.TrimSpace(label))
+	if label == "" || label == ENCODING_AUTO {
+		return "", nil
+	}
+
+	enc, err := htmlindex.Get(label)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedEncoding, label)
+	}
+	name, err := htmlindex.Name(enc)
+	if err != nil || name == "replacement" {
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedEncoding, label)
+	}
+	return name, nil
+}
+
+/******************************************************************************
+* FUNCTION:        detectSourceEncoding
+*
+* DESCRIPTION:     Picks the encoding of a file from its first bytes. A BOM
+*                  wins over the requested encoding, which wins over the
+*                  detection: UTF-16 by its NUL bytes, valid UTF-8, Shift-JIS
+*                  when it decodes to kana, Latin-1 (windows-1252) otherwise
+* INPUT:           start of the file, requested encoding ("" to detect)
+* RETURNS:         sourceEncoding
+******************************************************************************/
+func detectSourceEncoding(head []byte, requested string) sourceEncoding {
+	for _, mark := range encodingBOMs {
+		if bytes.HasPrefix(head, mark.bom) {
+			return sourceEncoding{Name: mark.name, BOMLen: len(mark.bom)}
+		}
+	}
+
+	if requested != "" {
+		return sourceEncoding{Name: requested}
+	}
+
+	if name, ok := detectUTF16(head); ok {
+		return sourceEncoding{Name: name}
+	}
+	if validUTF8Prefix(head) {
+		return sourceEncoding{Name: ENCODING_UTF8}
+	}
+	if looksLikeShiftJIS(head) {
+		return sourceEncoding{Name: ENCODING_SHIFT_JIS}
+	}
+	return sourceEncoding{Name: ENCODING_LATIN1}
+}
+
+/******************************************************************************
+* FUNCTION:        detectUTF16
+*
+* DESCRIPTION:     Helper function recognizing UTF-16 text without BOM by
+*                  the NUL high bytes of its ascii characters
+* INPUT:           start of the file
+* RETURNS:         encoding name, ok
+******************************************************************************/
+func detectUTF16(head []byte) (string, bool) {
+	pairs := len(head) / 2
+	if pairs == 0 {
+		return "", false
+	}
+
+	var evenNul, oddNul int
+	for i := 0; i+1 < len(head); i += 2 {
+		if head[i] == 0 {
+			evenNul++
+		}
+		if head[i+1] == 0 {
+			oddNul++
+		}
+	}
+
+	switch {
+	case float64(oddNul) >= UTF16_NUL_RATIO*float64(pairs) && evenNul < oddNul/10:
+		return ENCODING_UTF16LE, true
+	case float64(evenNul) >= UTF16_NUL_RATIO*float64(pairs) && oddNul < evenNul/10:
+		return ENCODING_UTF16BE, true
+	}
+	return "", false
+}
+
+/******************************************************************************
+* FUNCTION:        validUTF8Prefix
+*
+* DESCRIPTION:     Helper function telling whether the start of a file is
+*                  valid UTF-8, a character cut at the end is ignored
+* INPUT:           start of the file
+* RETURNS:         bool
+******************************************************************************/
+func validUTF8Prefix(head []byte) bool {
+	if utf8.Valid(head) {
+		return true
+	}
+
+	for i := len(head) - 1; i >= 0 && i >= len(head)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(head[i]) {
+			return !utf8.FullRune(head[i:]) && utf8.Valid(head[:i])
+		}
+	}
+	return false
+}
+
+/******************************************************************************
+* FUNCTION:        looksLikeShiftJIS
+*
+* DESCRIPTION:     Helper function telling whether the start of a file is
+*                  Japanese text in Shift-JIS: it decodes without invalid
+*                  sequences and contains hiragana or katakana, which
+*                  Latin-1 text read as Shift-JIS practically never gives
+* INPUT:           start of the file
+* RETURNS:         bool
+******************************************************************************/
+func looksLikeShiftJIS(head []byte) bool {
+	decoded, _, err := transform.Bytes(encodingByName(ENCODING_SHIFT_JIS).NewDecoder(), head)
+	if err != nil {
+		return false
+	}
+
+	text := string(decoded)
+	// the last character may be cut
+	if i := strings.LastIndexByte(text, '\n'); i >= 0 {
+		text = text[:i]
+	}
+	if strings.ContainsRune(text, utf8.RuneError) {
+		return false
+	}
+	return strings.IndexFunc(text, func(r rune) bool {
+		return unicode.In(r, unicode.Hiragana, unicode.Katakana) && r < 0xFF00
+	}) >= 0
+}
+
+/******************************************************************************
+* FUNCTION:        encodingByName
+*
+* DESCRIPTION:     Helper function returning the encoding of a canonical
+*                  name, UTF-8 for unknown names
+* INPUT:           name
+* RETURNS:         encoding.Encoding
+******************************************************************************/
+func encodingByName(name string) encoding.Encoding {
+	enc, err := htmlindex.Get(name)
+	if err != nil {
+		enc, _ = htmlindex.Get(ENCODING_UTF8)
+	}
+	return enc
+}
+
+/******************************************************************************
+* FUNCTION:        sniffFileEncoding
+*
+* DESCRIPTION:     Detects the encoding of a file from its first
+*                  ENCODING_SNIFF_BYTES, without moving its offset
+* INPUT:           file, requested encoding
+* RETURNS:         sourceEncoding, error
+******************************************************************************/
+func sniffFileEncoding(file *os.File, requested string) (sourceEncoding, error) {
+	head := make([]byte, ENCODING_SNIFF_BYTES)
+	n, err := file.ReadAt(head, 0)
+	if err != nil && err != io.EOF {
+		return sourceEncoding{}, fmt.Errorf("error reading file start: %v", err)
+	}
+	return detectSourceEncoding(head[:n], requested), nil
+}
+
+/******************************************************************************
+* FUNCTION:        decoder
+*
+* DESCRIPTION:     Wraps a reader positioned after the BOM so it yields
+*                  UTF-8. Invalid sequences become U+FFFD and NUL bytes are
+*                  dropped, postgres text holds neither
+* INPUT:           reader
+* RETURNS:         io.Reader
+******************************************************************************/
+func (e sourceEncoding) decoder(reader io.Reader) io.Reader {
+	dropNul := runes.Remove(runes.Predicate(func(r rune) bool { return r == 0 }))
+	return transform.NewReader(reader, transform.Chain(encodingByName(e.Name).NewDecoder(), dropNul))
+}
+
+/******************************************************************************
+* FUNCTION:        lineSplittable
+*
+* DESCRIPTION:     Tells whether a "\n" byte always ends a line, so the file
+*                  may be split into chunks at byte offsets. Not so in UTF-16
+* INPUT:           None
+* RETURNS:         bool
+******************************************************************************/
+func (e sourceEncoding) lineSplittable() bool {
+	return e.Name != ENCODING_UTF16LE && e.Name != ENCODING_UTF16BE
+}
+
+/******************************************************************************
+* FUNCTION:        storeFileEncoding
+*
+* DESCRIPTION:     Records the encoding a file was read with
+* INPUT:           tx, fileID, encoding name
+* RETURNS:         error
+******************************************************************************/
+func storeFileEncoding(tx *sql.Tx, fileID int64, name string) error {
+	if name == "" {
+		return nil
+	}
+	return db.UpdateSingleRecord(tx, "file_stats", "file_id", fileID, map[string]interface{}{"encoding": name})
+}
@@ -0,0 +1,766 @@
+/**************************************************************************
+ * File       	   : serviceFluentForward.go
+ * DESCRIPTION     : This file contains the optional Fluent Forward
+ *                   protocol listener, so that Fluent Bit/Fluentd can
+ *                   forward records directly. Message, Forward,
+ *                   PackedForward and CompressedPackedForward modes are
+ *                   supported, with acks and shared key authentication
+ * DATE            : 19-October-2026
+ **************************************************************************/
+
+package services
+
+import (
+	"LOGProcessor/shared/types"
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/martian/log"
+	"github.com/vmihailenco/msgpack/v5"
+	"github.com/vmihailenco/msgpack/v5/msgpcode"
+)
+
+const (
+	FORWARD_STREAM_PREFIX   = "forward-"
+	FORWARD_IDLE_TIMEOUT    = 5 * time.Minute
+	FORWARD_MAX_CONNECTIONS = 1000
+	FORWARD_EVENT_TIME_EXT  = 0
+	FORWARD_UNKNOWN_LEVEL   = "UNKNOWN"
+	// elements of an array or map and nesting of a message. The decoder
+	// of the library allocates whatever length a header announces
+	FORWARD_MAX_ELEMENTS = 100000
+	FORWARD_MAX_DEPTH    = 32
+)
+
+var (
+	ErrInvalidForwardMessage = errors.New("invalid forward message")
+	ErrForwardAuthFailed     = errors.New("forward shared key authentication failed")
+
+	// record keys used for the message and level, in order of preference
+	forwardMessageKeys = []string{"log", "message", "msg"}
+	forwardLevelKeys   = []string{"level", "severity", "log_level"}
+
+	forwardListener net.Listener
+	forwardConns    = make(map[net.Conn]struct{})
+	forwardMu       sync.Mutex
+	forwardWg       sync.WaitGroup
+	forwardSlots    = make(chan struct{}, FORWARD_MAX_CONNECTIONS)
+	forwardStopping bool
+)
+
+func init() {
+	msgpack.RegisterExt(FORWARD_EVENT_TIME_EXT, (*fluentEventTime)(nil))
+}
+
+// fluentEventTime is the EventTime ext type: seconds and nanoseconds as
+// two big-endian uint32
+type fluentEventTime struct {
+	time.Time
+}
+
+/******************************************************************************
+* FUNCTION:        MarshalMsgpack
+*
+* DESCRIPTION:     msgpack.Marshaler implementation of the EventTime ext
+* INPUT:           None
+* RETURNS:         []byte, error
+******************************************************************************/
+func (t *fluentEventTime) MarshalMsgpack() ([]byte, error) {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint32(b, uint32(t.Unix()))
+	binary.BigEndian.PutUint32(b[4:], uint32(t.Nanosecond()))
+	return b, nil
+}
+
+/******************************************************************************
+* FUNCTION:        UnmarshalMsgpack
+*
+* DESCRIPTION:     msgpack.Unmarshaler implementation of the EventTime ext
+* INPUT:           ext payload
+* RETURNS:         error
+******************************************************************************/
+func (t *fluentEventTime) UnmarshalMsgpack(b []byte) error {
+	if len(b) != 8 {
+		return ErrInvalidForwardMessage
+	}
+	t.Time = time.Unix(int64(binary.BigEndian.Uint32(b)), int64(binary.BigEndian.Uint32(b[4:])))
+	return nil
+}
+
+type forwardConn struct {
+	conn    net.Conn
+	budget  *budgetReader
+	decoder *msgpack.Decoder
+	encoder *msgpack.Encoder
+}
+
+// budgetReader fails once more than the remaining budget is read, bounding
+// the size of a single forward message
+type budgetReader struct {
+	reader    io.Reader
+	remaining int64
+}
+
+/******************************************************************************
+* FUNCTION:        Read
+*
+* DESCRIPTION:     io.Reader implementation enforcing the budget
+* INPUT:           buffer
+* RETURNS:         int, error
+******************************************************************************/
+func (b *budgetReader) Read(p []byte) (int, error) {
+	if b.remaining <= 0 {
+		return 0, fmt.Errorf("forward message exceeds %d bytes", types.ForwardCfg.MaxMessageBytes)
+	}
+	if int64(len(p)) > b.remaining {
+		p = p[:b.remaining]
+	}
+	n, err := b.reader.Read(p)
+	b.remaining -= int64(n)
+	return n, err
+}
+
+/******************************************************************************
+* FUNCTION:        StartForwardServer
+*
+* DESCRIPTION:     Starts the forward listener configured through
+*                  types.ForwardCfg. Nothing is started when no listen
+*                  address is set
+* INPUT:           None
+* RETURNS:         error
+******************************************************************************/
+func StartForwardServer() error {
+	cfg := types.ForwardCfg
+	if cfg.Addr == "" {
+		return nil
+	}
+	if cfg.UserId == "" {
+		return fmt.Errorf("FORWARD_USER_ID is required to run the forward listener")
+	}
+
+	listener, err := net.Listen("tcp", cfg.Addr)
+	if err != nil {
+		return fmt.Errorf("forward listen: %v", err)
+	}
+
+	forwardMu.Lock()
+	forwardListener = listener
+	forwardMu.Unlock()
+
+	forwardWg.Add(1)
+	go serveForward(listener)
+	log.Infof("fluent forward listening on %s", cfg.Addr)
+
+	return nil
+}
+
+/******************************************************************************
+* FUNCTION:        StopForwardServer
+*
+* DESCRIPTION:     Closes the listener and open connections and waits for
+*                  the handlers to return
+* INPUT:           None
+* RETURNS:         void
+******************************************************************************/
+func StopForwardServer() {
+	forwardMu.Lock()
+	forwardStopping = true
+	if forwardListener != nil {
+		forwardListener.Close()
+	}
+	for conn := range forwardConns {
+		conn.Close()
+	}
+	forwardMu.Unlock()
+
+	forwardWg.Wait()
+}
+
+/******************************************************************************
+* FUNCTION:        serveForward
+*
+* DESCRIPTION:     Accepts connections, bounded by FORWARD_MAX_CONNECTIONS
+* INPUT:           listener
+* RETURNS:         void
+******************************************************************************/
+func serveForward(listener net.Listener) {
+	defer forwardWg.Done()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				log.Errorf("forward accept; err: %v", err)
+			}
+			return
+		}
+
+		select {
+		case forwardSlots <- struct{}{}:
+		default:
+			log.Errorf("forward connection limit reached; closing %s", conn.RemoteAddr())
+			conn.Close()
+			continue
+		}
+
+		forwardMu.Lock()
+		if forwardStopping {
+			forwardMu.Unlock()
+			conn.Close()
+			<-forwardSlots
+			return
+		}
+		forwardConns[conn] = struct{}{}
+		forwardWg.Add(1)
+		forwardMu.Unlock()
+
+		go serveForwardConn(conn)
+	}
+}
+
+/******************************************************************************
+* FUNCTION:        serveForwardConn
+*
+* DESCRIPTION:     Runs the handshake when a shared key is configured, then
+*                  handles the messages of the connection until it closes
+* INPUT:           conn
+* RETURNS:         void
+******************************************************************************/
+func serveForwardConn(conn net.Conn) {
+	defer func() {
+		forwardMu.Lock()
+		delete(forwardConns, conn)
+		forwardMu.Unlock()
+		conn.Close()
+		<-forwardSlots
+		forwardWg.Done()
+	}()
+
+	budget := &budgetReader{reader: conn}
+	fc := &forwardConn{
+		conn:    conn,
+		budget:  budget,
+		decoder: msgpack.NewDecoder(budget),
+		encoder: msgpack.NewEncoder(conn),
+	}
+
+	if types.ForwardCfg.SharedKey != "" {
+		if err := fc.handshake(); err != nil {
+			log.Errorf("forward handshake with %s; err: %v", conn.RemoteAddr(), err)
+			return
+		}
+	}
+
+	for {
+		conn.SetReadDeadline(time.Now().Add(FORWARD_IDLE_TIMEOUT))
+		budget.remaining = int64(types.ForwardCfg.MaxMessageBytes)
+
+		message, err := decodeForwardArray(fc.decoder, budget)
+		if err != nil {
+			if err != io.EOF && !errors.Is(err, net.ErrClosed) {
+				log.Errorf("forward read from %s; err: %v", conn.RemoteAddr(), err)
+			}
+			return
+		}
+
+		if err := fc.handleMessage(message); err != nil {
+			log.Errorf("forward message from %s; err: %v", conn.RemoteAddr(), err)
+			if errors.Is(err, ErrInvalidForwardMessage) {
+				return
+			}
+		}
+	}
+}
+
+/******************************************************************************
+* FUNCTION:        handleMessage
+*
+* DESCRIPTION:     Decodes the entries of a message in any of the modes and
+*                  hands them to the stream of the tag. The ack is only
+*                  sent once the entries are buffered, so that the client
+*                  resends them otherwise
+* INPUT:           message
+* RETURNS:         error
+******************************************************************************/
+func (fc *forwardConn) handleMessage(message []interface{}) error {
+	if len(message) < 2 {
+		return ErrInvalidForwardMessage
+	}
+
+	tag, ok := message[0].(string)
+	if !ok {
+		return ErrInvalidForwardMessage
+	}
+
+	var (
+		entries []LogEntry
+		options map[string]interface{}
+		err     error
+	)
+
+	switch events := message[1].(type) {
+	case []interface{}:
+		// Forward mode: [tag, [[time, record], ...], option]
+		for _, event := range events {
+			pair, ok := event.([]interface{})
+			if !ok || len(pair) < 2 {
+				return ErrInvalidForwardMessage
+			}
+			if entry, ok := forwardEventToLogEntry(tag, pair[0], pair[1]); ok {
+				entries = append(entries, entry)
+			}
+		}
+		options = forwardOptions(message, 2)
+	case []byte, string:
+		// PackedForward mode: [tag, msgpack stream of [time, record], option]
+		options = forwardOptions(message, 2)
+		packed, _ := events.([]byte)
+		if s, isString := events.(string); isString {
+			packed = []byte(s)
+		}
+		entries, err = decodePackedForward(tag, packed, options)
+		if err != nil {
+			return err
+		}
+	default:
+		// Message mode: [tag, time, record, option]
+		if len(message) < 3 {
+			return ErrInvalidForwardMessage
+		}
+		if entry, ok := forwardEventToLogEntry(tag, message[1], message[2]); ok {
+			entries = append(entries, entry)
+		}
+		options = forwardOptions(message, 3)
+	}
+
+	if err = AppendStreamEntries(types.ForwardCfg.UserId, forwardStreamName(tag), entries); err != nil {
+		return err
+	}
+
+	if chunk, ok := options["chunk"].(string); ok && chunk != "" {
+		fc.conn.SetWriteDeadline(time.Now().Add(FORWARD_IDLE_TIMEOUT))
+		return fc.encoder.Encode(map[string]string{"ack": chunk})
+	}
+
+	return nil
+}
+
+/******************************************************************************
+* FUNCTION:        decodePackedForward
+*
+* DESCRIPTION:     Decodes the concatenated [time, record] entries of the
+*                  PackedForward and CompressedPackedForward modes
+* INPUT:           tag, packed entries, options
+* RETURNS:         []LogEntry, error
+******************************************************************************/
+func decodePackedForward(tag string, packed []byte, options map[string]interface{}) ([]LogEntry, error) {
+	var reader io.Reader = bytes.NewReader(packed)
+	var budget *budgetReader
+
+	if compressed, _ := options["compressed"].(string); compressed != "" {
+		if compressed != "gzip" {
+			return nil, fmt.Errorf("%w: unsupported compression %q", ErrInvalidForwardMessage, compressed)
+		}
+		gz, err := gzip.NewReader(reader)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidForwardMessage, err)
+		}
+		defer gz.Close()
+		// a decompression bomb is bounded like a plain message
+		budget = &budgetReader{reader: gz, remaining: int64(types.ForwardCfg.MaxMessageBytes)}
+		reader = budget
+	}
+
+	var entries []LogEntry
+	decoder := msgpack.NewDecoder(reader)
+	for {
+		pair, err := decodeForwardArray(decoder, budget)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidForwardMessage, err)
+		}
+		if len(pair) < 2 {
+			return nil, ErrInvalidForwardMessage
+		}
+		if entry, ok := forwardEventToLogEntry(tag, pair[0], pair[1]); ok {
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries, nil
+}
+
+/******************************************************************************
+* FUNCTION:        decodeForwardArray
+*
+* DESCRIPTION:     Decodes a message or an entry, which must be an array.
+*                  Lengths are checked before anything is allocated: a
+*                  header announcing more elements than FORWARD_MAX_ELEMENTS
+*                  or than bytes are left in the budget is rejected
+* INPUT:           decoder, budget of the reader (nil for a byte slice)
+* RETURNS:         []interface{}, error
+******************************************************************************/
+func decodeForwardArray(decoder *msgpack.Decoder, budget *budgetReader) ([]interface{}, error) {
+	code, err := decoder.PeekCode()
+	if err != nil {
+		return nil, err
+	}
+	if !msgpcode.IsFixedArray(code) && code != msgpcode.Array16 && code != msgpcode.Array32 {
+		return nil, fmt.Errorf("%w: not an array", ErrInvalidForwardMessage)
+	}
+
+	value, err := decodeForwardValue(decoder, budget, 0)
+	if err != nil {
+		return nil, err
+	}
+	return value.([]interface{}), nil
+}
+
+/******************************************************************************
+* FUNCTION:        decodeForwardValue
+*
+* DESCRIPTION:     Decodes a value, arrays and maps element by element with
+*                  their lengths checked. Scalars are left to the library,
+*                  whose string and binary allocations grow with the data
+*                  actually read
+* INPUT:           decoder, budget, nesting depth
+* RETURNS:         value, error
+******************************************************************************/
+func decodeForwardValue(decoder *msgpack.Decoder, budget *budgetReader, depth int) (interface{}, error) {
+	if depth > FORWARD_MAX_DEPTH {
+		return nil, fmt.Errorf("%w: nesting too deep", ErrInvalidForwardMessage)
+	}
+
+	code, err := decoder.PeekCode()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case msgpcode.IsFixedArray(code) || code == msgpcode.Array16 || code == msgpcode.Array32:
+		n, err := decoder.DecodeArrayLen()
+		if err != nil {
+			return nil, err
+		}
+		if err := checkForwardLength(decoder, budget, n); err != nil {
+			return nil, err
+		}
+		values := make([]interface{}, 0, n)
+		for i := 0; i < n; i++ {
+			value, err := decodeForwardValue(decoder, budget, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+		}
+		return values, nil
+
+	case msgpcode.IsFixedMap(code) || code == msgpcode.Map16 || code == msgpcode.Map32:
+		n, err := decoder.DecodeMapLen()
+		if err != nil {
+			return nil, err
+		}
+		// a key and a value take two bytes at least
+		if err := checkForwardLength(decoder, budget, 2*n); err != nil {
+			return nil, err
+		}
+		values := make(map[string]interface{}, n)
+		for i := 0; i < n; i++ {
+			key, err := decoder.DecodeString()
+			if err != nil {
+				return nil, fmt.Errorf("%w: map key: %v", ErrInvalidForwardMessage, err)
+			}
+			value, err := decodeForwardValue(decoder, budget, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			values[key] = value
+		}
+		return values, nil
+	}
+
+	return decoder.DecodeInterface()
+}
+
+/******************************************************************************
+* FUNCTION:        checkForwardLength
+*
+* DESCRIPTION:     Helper function rejecting a length that cannot be real.
+*                  Every element takes a byte at least, so there can be no
+*                  more than the bytes left: those still allowed by the
+*                  budget plus those the decoder already buffered
+* INPUT:           decoder, budget, length
+* RETURNS:         error
+******************************************************************************/
+func checkForwardLength(decoder *msgpack.Decoder, budget *budgetReader, n int) error {
+	if n > FORWARD_MAX_ELEMENTS {
+		return fmt.Errorf("%w: %d elements", ErrInvalidForwardMessage, n)
+	}
+
+	var available int64
+	if budget != nil {
+		available = budget.remaining
+	}
+	switch buffered := decoder.Buffered().(type) {
+	case interface{ Buffered() int }:
+		available += int64(buffered.Buffered())
+	case interface{ Len() int }:
+		available += int64(buffered.Len())
+	}
+
+	if int64(n) > available {
+		return fmt.Errorf("%w: %d elements in %d bytes", ErrInvalidForwardMessage, n, available)
+	}
+	return nil
+}
+
+/******************************************************************************
+* FUNCTION:        forwardEventToLogEntry
+*
+* DESCRIPTION:     Maps a forward event to a LogEntry. A "log" value that
+*                  is a full log line is parsed like a file line, otherwise
+*                  the message and level keys are used. Remaining record
+*                  keys and the tag are kept as attributes
+* INPUT:           tag, event time, record
+* RETURNS:         LogEntry, ok
+******************************************************************************/
+func forwardEventToLogEntry(tag string, eventTime interface{}, record interface{}) (LogEntry, bool) {
+	fields, ok := record.(map[string]interface{})
+	if !ok {
+		return LogEntry{}, false
+	}
+
+	timestamp := forwardTime(eventTime)
+
+	message, messageKey := forwardStringField(fields, forwardMessageKeys)
+	if message == "" {
+		return LogEntry{}, false
+	}
+	level, levelKey := forwardStringField(fields, forwardLevelKeys)
+
+	var entry LogEntry
+	if parsed, ok := parseLogLine(message, 0); ok && level == "" {
+		entry = parsed
+	} else {
+		if level == "" {
+			level = FORWARD_UNKNOWN_LEVEL
+		}
+		entry = buildLogEntry(timestamp, level, strings.TrimRight(message, "\n"), 0)
+	}
+
+	attributes := map[string]interface{}{"tag": tag}
+	for key, value := range fields {
+		if key == messageKey || key == levelKey {
+			continue
+		}
+		attributes[key] = forwardAttributeValue(value)
+	}
+	entry.Attributes = attributes
+
+	return entry, true
+}
+
+/******************************************************************************
+* FUNCTION:        forwardTime
+*
+* DESCRIPTION:     Converts the event time, an EventTime ext or integer
+*                  seconds, falling back to now
+* INPUT:           event time
+* RETURNS:         time.Time
+******************************************************************************/
+func forwardTime(eventTime interface{}) time.Time {
+	switch v := eventTime.(type) {
+	case *fluentEventTime:
+		return v.Time
+	case fluentEventTime:
+		return v.Time
+	case int64:
+		return time.Unix(v, 0)
+	case int32:
+		return time.Unix(int64(v), 0)
+	case uint64:
+		return time.Unix(int64(v), 0)
+	case uint32:
+		return time.Unix(int64(v), 0)
+	case float64:
+		return time.Unix(0, int64(v*float64(time.Second)))
+	default:
+		return time.Now()
+	}
+}
+
+/******************************************************************************
+* FUNCTION:        forwardStringField
+*
+* DESCRIPTION:     Helper function returning the first of the keys present
+*                  in the record as a string
+* INPUT:           record, keys
+* RETURNS:         value, key
+******************************************************************************/
+func forwardStringField(fields map[string]interface{}, keys []string) (string, string) {
+	for _, key := range keys {
+		switch v := fields[key].(type) {
+		case string:
+			return v, key
+		case []byte:
+			return string(v), key
+		case int8, int16, int32, int64, uint8, uint16, uint32, uint64:
+			// numeric levels, e.g. bunyan's 50
+			return fmt.Sprint(v), key
+		}
+	}
+	return "", ""
+}
+
+/******************************************************************************
+* FUNCTION:        forwardAttributeValue
+*
+* DESCRIPTION:     Helper function making a decoded value json friendly,
+*                  msgpack bin values come as []byte
+* INPUT:           value
+* RETURNS:         interface{}
+******************************************************************************/
+func forwardAttributeValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case []byte:
+		return string(v)
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = forwardAttributeValue(item)
+		}
+		return v
+	case []interface{}:
+		for i, item := range v {
+			v[i] = forwardAttributeValue(item)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+/******************************************************************************
+* FUNCTION:        forwardOptions
+*
+* DESCRIPTION:     Helper function returning the option map of a message
+* INPUT:           message, index of the option
+* RETURNS:         map[string]interface{}
+******************************************************************************/
+func forwardOptions(message []interface{}, index int) map[string]interface{} {
+	if len(message) <= index {
+		return nil
+	}
+	options, _ := message[index].(map[string]interface{})
+	return options
+}
+
+/******************************************************************************
+* FUNCTION:        forwardStreamName
+*
+* DESCRIPTION:     Builds a valid stream name out of the tag
+* INPUT:           tag
+* RETURNS:         string
+******************************************************************************/
+func forwardStreamName(tag string) string {
+	name := FORWARD_STREAM_PREFIX + unsafeStreamNameRegex.ReplaceAllString(tag, "_")
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	return name
+}
+
+/******************************************************************************
+* FUNCTION:        handshake
+*
+* DESCRIPTION:     Shared key handshake of the forward protocol: HELO with a
+*                  nonce, the client's PING carrying
+*                  sha512(salt + hostname + nonce + key) and our PONG
+* INPUT:           None
+* RETURNS:         error
+******************************************************************************/
+func (fc *forwardConn) handshake() error {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+
+	fc.conn.SetDeadline(time.Now().Add(FORWARD_IDLE_TIMEOUT))
+	defer fc.conn.SetDeadline(time.Time{})
+
+	helo := []interface{}{"HELO", map[string]interface{}{"nonce": nonce, "auth": []byte{}, "keepalive": true}}
+	if err := fc.encoder.Encode(helo); err != nil {
+		return err
+	}
+
+	fc.budget.remaining = int64(types.ForwardCfg.MaxMessageBytes)
+	ping, err := decodeForwardArray(fc.decoder, fc.budget)
+	if err != nil {
+		return err
+	}
+	if len(ping) < 4 || ping[0] != "PING" {
+		return ErrInvalidForwardMessage
+	}
+
+	clientHostname := forwardBytesOrString(ping[1])
+	salt := forwardBytesOrString(ping[2])
+	digest := forwardBytesOrString(ping[3])
+
+	expected := forwardSharedKeyDigest(salt, clientHostname, nonce)
+	if subtle.ConstantTimeCompare([]byte(digest), []byte(expected)) != 1 {
+		fc.encoder.Encode([]interface{}{"PONG", false, "shared key mismatch", "", ""})
+		return ErrForwardAuthFailed
+	}
+
+	hostname, _ := os.Hostname()
+	pong := []interface{}{"PONG", true, "", hostname, forwardSharedKeyDigest(salt, hostname, nonce)}
+	return fc.encoder.Encode(pong)
+}
+
+/******************************************************************************
+* FUNCTION:        forwardSharedKeyDigest
+*
+* DESCRIPTION:     Helper function computing the hex digest of the handshake
+* INPUT:           salt, hostname, nonce
+* RETURNS:         string
+******************************************************************************/
+func forwardSharedKeyDigest(salt, hostname string, nonce []byte) string {
+	h := sha512.New()
+	h.Write([]byte(salt))
+	h.Write([]byte(hostname))
+	h.Write(nonce)
+	h.Write([]byte(types.ForwardCfg.SharedKey))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+/******************************************************************************
+* FUNCTION:        forwardBytesOrString
+*
+* DESCRIPTION:     Helper function reading a str or bin value as a string
+* INPUT:           value
+* RETURNS:         string
+******************************************************************************/
+func forwardBytesOrString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,270 @@
+/**************************************************************************
+ * File       	   : serviceGeoIP.go
+ * DESCRIPTION     : This file contains the offline GeoIP and ASN enrichment
+ *                   of the ip of log entries from local MaxMind DB files.
+ *                   The files are reloaded when they change on disk
+ * DATE            : 19-October-2026
+ **************************************************************************/
+
+package services
+
+import (
+	"LOGProcessor/shared/types"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/martian/log"
+)
+
+// geoDatabase is a loaded database with the file state it was read at
+type geoDatabase struct {
+	reader  *mmdbReader
+	path    string
+	modTime time.Time
+	size    int64
+}
+
+// geoDatabases are swapped as a whole on reload, lookups never lock
+type geoDatabases struct {
+	city *geoDatabase
+	asn  *geoDatabase
+}
+
+// GeoInfo is what is known about an ip
+type GeoInfo struct {
+	Country string
+	City    string
+	ASN     int64
+	ASOrg   string
+}
+
+var (
+	geoDBs   atomic.Pointer[geoDatabases]
+	geoStop  chan struct{}
+	geoDone  chan struct{}
+	geoMu    sync.Mutex
+	noGeoDBs = &geoDatabases{}
+)
+
+/******************************************************************************
+* FUNCTION:        StartGeoIP
+*
+* DESCRIPTION:     Loads the configured databases and starts checking them
+*                  for changes every GEOIP_RELOAD_INTERVAL. A database that
+*                  cannot be loaded only disables its part of the
+*                  enrichment
+* INPUT:           None
+* RETURNS:         void
+******************************************************************************/
+func StartGeoIP() {
+	geoMu.Lock()
+	defer geoMu.Unlock()
+
+	cfg := types.GeoIPCfg
+	if cfg.CityDBPath == "" && cfg.ASNDBPath == "" {
+		return
+	}
+
+	reloadGeoDatabases()
+
+	geoStop = make(chan struct{})
+	geoDone = make(chan struct{})
+	go func() {
+		defer close(geoDone)
+
+		ticker := time.NewTicker(cfg.ReloadInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-geoStop:
+				return
+			case <-ticker.C:
+				reloadGeoDatabases()
+			}
+		}
+	}()
+}
+
+/******************************************************************************
+* FUNCTION:        StopGeoIP
+*
+* DESCRIPTION:     Stops the reload loop
+* INPUT:           None
+* RETURNS:         void
+******************************************************************************/
+func StopGeoIP() {
+	geoMu.Lock()
+	defer geoMu.Unlock()
+
+	if geoStop == nil {
+		return
+	}
+	close(geoStop)
+	<-geoDone
+	geoStop = nil
+}
+
+/******************************************************************************
+* FUNCTION:        reloadGeoDatabases
+*
+* DESCRIPTION:     Reloads the databases whose file changed since they were
+*                  loaded. A file that fails to load keeps the previous
+*                  version in use
+* INPUT:           None
+* RETURNS:         void
+******************************************************************************/
+func reloadGeoDatabases() {
+	defer PanicRecovery("reloadGeoDatabases")
+
+	current := geoDBs.Load()
+	if current == nil {
+		current = noGeoDBs
+	}
+
+	next := &geoDatabases{
+		city: reloadGeoDatabase(current.city, types.GeoIPCfg.CityDBPath),
+		asn:  reloadGeoDatabase(current.asn, types.GeoIPCfg.ASNDBPath),
+	}
+	if next.city != current.city || next.asn != current.asn {
+		geoDBs.Store(next)
+	}
+}
+
+/******************************************************************************
+* FUNCTION:        reloadGeoDatabase
+*
+* DESCRIPTION:     Helper function returning the database of a path, read
+*                  again when its modification time or size changed
+* INPUT:           loaded database (may be nil), path
+* RETURNS:         *geoDatabase
+******************************************************************************/
+func reloadGeoDatabase(loaded *geoDatabase, path string) *geoDatabase {
+	if path == "" {
+		return nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		log.Errorf("failed to stat geoip database %s; err: %v", path, err)
+		return loaded
+	}
+	if loaded != nil && loaded.modTime.Equal(info.ModTime()) && loaded.size == info.Size() {
+		return loaded
+	}
+
+	reader, err := openMMDB(path)
+	if err != nil {
+		log.Errorf("failed to load geoip database %s; err: %v", path, err)
+		return loaded
+	}
+
+	log.Infof("loaded geoip database %s (%s, built %s)", path, reader.DatabaseType, time.Unix(int64(reader.BuildEpoch), 0).UTC().Format(time.DateOnly))
+	return &geoDatabase{reader: reader, path: path, modTime: info.ModTime(), size: info.Size()}
+}
+
+/******************************************************************************
+* FUNCTION:        lookupGeo
+*
+* DESCRIPTION:     Looks an ip up in the loaded databases. Country and city
+*                  come from a City or Country database, the ASN from an ASN
+*                  or ISP database
+* INPUT:           ip
+* RETURNS:         GeoInfo, found
+******************************************************************************/
+func lookupGeo(ip string) (GeoInfo, bool) {
+	var info GeoInfo
+
+	dbs := geoDBs.Load()
+	if dbs == nil || ip == "" {
+		return info, false
+	}
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return info, false
+	}
+
+	if dbs.city != nil {
+		record, found, err := dbs.city.reader.lookup(parsed)
+		if err != nil {
+			log.Errorf("geoip lookup of %s in %s failed; err: %v", ip, dbs.city.path, err)
+		} else if found {
+			info.Country = geoRecordString(record, "country", "iso_code")
+			if info.Country == "" {
+				info.Country = geoRecordString(record, "registered_country", "iso_code")
+			}
+			info.City = geoRecordString(record, "city", "names", "en")
+		}
+	}
+
+	if dbs.asn != nil {
+		record, found, err := dbs.asn.reader.lookup(parsed)
+		if err != nil {
+			log.Errorf("geoip lookup of %s in %s failed; err: %v", ip, dbs.asn.path, err)
+		} else if found {
+			info.ASN = int64(mmdbUint(record["autonomous_system_number"]))
+			info.ASOrg, _ = record["autonomous_system_organization"].(string)
+			if info.ASOrg == "" {
+				info.ASOrg, _ = record["organization"].(string)
+			}
+		}
+	}
+
+	return info, info != GeoInfo{}
+}
+
+/******************************************************************************
+* FUNCTION:        geoRecordString
+*
+* DESCRIPTION:     Helper function reading a string at a path of nested
+*                  maps of a record
+* INPUT:           record, path
+* RETURNS:         string
+******************************************************************************/
+func geoRecordString(record map[string]interface{}, path ...string) string {
+	var value interface{} = record
+	for _, key := range path {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return ""
+		}
+		value = m[key]
+	}
+	s, _ := value.(string)
+	return s
+}
+
+/******************************************************************************
+* FUNCTION:        enrichLogEntries
+*
+* DESCRIPTION:     Attaches country, city, ASN and organisation to entries
+*                  with an ip, each distinct ip is looked up once
+* INPUT:           entries
+* RETURNS:         void
+******************************************************************************/
+func enrichLogEntries(entries []LogEntry) {
+	if geoDBs.Load() == nil {
+		return
+	}
+
+	seen := make(map[string]GeoInfo)
+	for i := range entries {
+		ip := entries[i].IP
+		if ip == "" {
+			continue
+		}
+
+		info, ok := seen[ip]
+		if !ok {
+			info, _ = lookupGeo(ip)
+			seen[ip] = info
+		}
+		entries[i].GeoCountry = info.Country
+		entries[i].GeoCity = info.City
+		entries[i].ASN = info.ASN
+		entries[i].ASOrg = info.ASOrg
+	}
+}
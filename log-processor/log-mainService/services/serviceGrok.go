@@ -0,0 +1,375 @@
+/**************************************************************************
+ * File       	   : serviceGrok.go
+ * DESCRIPTION     : This file contains the compiler of user defined
+ *                   Grok-style patterns (%{IP:client} %{NUMBER:status})
+ *                   along with the standard pattern library, and the
+ *                   line parser built from a compiled pattern
+ * DATE            : 19-October-2026
+ **************************************************************************/
+
+package services
+
+import (
+	"LOGProcessor/shared/db"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	GROK_MAX_PATTERN_LEN = 4096
+	GROK_MAX_DEPTH       = 16
+	// references fanning out at every level could expand exponentially
+	GROK_MAX_EXPANDED_LEN = 256 * 1024
+	// compiled patterns kept in memory, the least recently used are
+	// compiled again
+	GROK_CACHE_SIZE    = 256
+	GROK_UNKNOWN_LEVEL = "UNKNOWN"
+
+	GROK_TYPE_INT   = "int"
+	GROK_TYPE_FLOAT = "float"
+)
+
+var (
+	ErrGrokPatternNotFound = errors.New("grok pattern not found")
+
+	// %{NAME}, %{NAME:field} or %{NAME:field:type}
+	grokReferenceRegex = regexp.MustCompile(`%\{([A-Za-z0-9_]+)(?::([A-Za-z_][A-Za-z0-9_]*))?(?::([a-z]+))?\}`)
+	grokNameRegex      = regexp.MustCompile(`^[A-Z0-9_]+$`)
+
+	// field names mapped to the LogEntry, the other fields are attributes
+	grokTimestampFields = []string{"timestamp", "ts", "time"}
+	grokLevelFields     = []string{"level", "loglevel", "severity"}
+	grokMessageFields   = []string{"message", "msg"}
+	grokIpFields        = []string{"ip", "clientip", "client_ip", "client"}
+
+	grokCache = newBoundedCache[int64, *GrokPattern](0, GROK_CACHE_SIZE)
+)
+
+// grokLibrary is the standard pattern library, written for RE2
+var grokLibrary = map[string]string{
+	"USERNAME":          `[a-zA-Z0-9._-]+`,
+	"USER":              `%{USERNAME}`,
+	"EMAILLOCALPART":    `[a-zA-Z0-9!#$%&'*+/=?^_{|}~-]+(?:\.[a-zA-Z0-9!#$%&'*+/=?^_{|}~-]+)*`,
+	"EMAILADDRESS":      `%{EMAILLOCALPART}@%{HOSTNAME}`,
+	"INT":               `[+-]?[0-9]+`,
+	"BASE10NUM":         `[+-]?(?:[0-9]+(?:\.[0-9]+)?|\.[0-9]+)`,
+	"NUMBER":            `%{BASE10NUM}`,
+	"BASE16NUM":         `(?:0[xX])?[0-9A-Fa-f]+`,
+	"POSINT":            `\b[1-9][0-9]*\b`,
+	"NONNEGINT":         `\b[0-9]+\b`,
+	"WORD":              `\b\w+\b`,
+	"NOTSPACE":          `\S+`,
+	"SPACE":             `\s*`,
+	"DATA":              `.*?`,
+	"GREEDYDATA":        `.*`,
+	"QUOTEDSTRING":      `(?:"(?:[^"\\]|\\.)*"|'(?:[^'\\]|\\.)*')`,
+	"UUID":              `[A-Fa-f0-9]{8}-(?:[A-Fa-f0-9]{4}-){3}[A-Fa-f0-9]{12}`,
+	"MAC":               `(?:[A-Fa-f0-9]{2}[:-]){5}[A-Fa-f0-9]{2}`,
+	"IPV4":              `(?:(?:25[0-5]|2[0-4][0-9]|1[0-9]{2}|[1-9]?[0-9])\.){3}(?:25[0-5]|2[0-4][0-9]|1[0-9]{2}|[1-9]?[0-9])`,
+	"IPV6":              `(?:(?:[0-9A-Fa-f]{1,4}:){7}[0-9A-Fa-f]{1,4}|(?:[0-9A-Fa-f]{1,4}:){1,6}(?::[0-9A-Fa-f]{1,4}){1,6}|(?:[0-9A-Fa-f]{1,4}:){1,7}:|::(?:[0-9A-Fa-f]{1,4}:){0,6}[0-9A-Fa-f]{1,4}|::)`,
+	"IP":                `(?:%{IPV6}|%{IPV4})`,
+	"HOSTNAME":          `\b[0-9A-Za-z][0-9A-Za-z-]{0,62}(?:\.[0-9A-Za-z][0-9A-Za-z-]{0,62})*\.?\b`,
+	"IPORHOST":          `(?:%{IP}|%{HOSTNAME})`,
+	"HOSTPORT":          `%{IPORHOST}:%{POSINT}`,
+	"UNIXPATH":          `(?:/[\w%!$@:.,+~-]*)+`,
+	"WINPATH":           `(?:[A-Za-z]+:|\\)(?:\\[^\\?*]*)+`,
+	"PATH":              `(?:%{UNIXPATH}|%{WINPATH})`,
+	"URIPROTO":          `[A-Za-z][A-Za-z0-9+.-]+`,
+	"URIHOST":           `%{IPORHOST}(?::%{POSINT})?`,
+	"URIPATH":           `(?:/[A-Za-z0-9$.+!*'(){},~:;=@#%&_-]*)+`,
+	"URIPARAM":          `\?[A-Za-z0-9$.+!*'|(){},~@#%&/=:;_?\[\]<>-]*`,
+	"URIPATHPARAM":      `%{URIPATH}(?:%{URIPARAM})?`,
+	"URI":               `%{URIPROTO}://(?:%{USER}(?::[^@]*)?@)?(?:%{URIHOST})?(?:%{URIPATHPARAM})?`,
+	"MONTH":             `\b(?:[Jj]an(?:uary)?|[Ff]eb(?:ruary)?|[Mm]ar(?:ch)?|[Aa]pr(?:il)?|[Mm]ay|[Jj]un(?:e)?|[Jj]ul(?:y)?|[Aa]ug(?:ust)?|[Ss]ep(?:tember)?|[Oo]ct(?:ober)?|[Nn]ov(?:ember)?|[Dd]ec(?:ember)?)\b`,
+	"MONTHNUM":          `(?:0?[1-9]|1[0-2])`,
+	"MONTHDAY":          `(?:0[1-9]|[12][0-9]|3[01]|[1-9])`,
+	"DAY":               `(?:Mon(?:day)?|Tue(?:sday)?|Wed(?:nesday)?|Thu(?:rsday)?|Fri(?:day)?|Sat(?:urday)?|Sun(?:day)?)`,
+	"YEAR":              `(?:\d\d){1,2}`,
+	"HOUR":              `(?:2[0123]|[01]?[0-9])`,
+	"MINUTE":            `(?:[0-5][0-9])`,
+	"SECOND":            `(?:(?:[0-5]?[0-9]|60)(?:[:.,][0-9]+)?)`,
+	"TIME":              `%{HOUR}:%{MINUTE}(?::%{SECOND})?`,
+	"DATE_US":           `%{MONTHNUM}[/-]%{MONTHDAY}[/-]%{YEAR}`,
+	"DATE_EU":           `%{MONTHDAY}[./-]%{MONTHNUM}[./-]%{YEAR}`,
+	"ISO8601_TIMEZONE":  `(?:Z|[+-]%{HOUR}(?::?%{MINUTE}))`,
+	"TIMESTAMP_ISO8601": `%{YEAR}-%{MONTHNUM}-%{MONTHDAY}[T ]%{HOUR}:?%{MINUTE}(?::?%{SECOND})?%{ISO8601_TIMEZONE}?`,
+	"SYSLOGTIMESTAMP":   `%{MONTH} +%{MONTHDAY} %{TIME}`,
+	"HTTPDATE":          `%{MONTHDAY}/%{MONTH}/%{YEAR}:%{TIME} %{INT}`,
+	"LOGLEVEL":          `(?:[Aa]lert|ALERT|[Tt]race|TRACE|[Dd]ebug|DEBUG|[Nn]otice|NOTICE|[Ii]nfo|INFO|[Ww]arn(?:ing)?|WARN(?:ING)?|[Ee]rr(?:or)?|ERR(?:OR)?|[Cc]rit(?:ical)?|CRIT(?:ICAL)?|[Ff]atal|FATAL|[Ss]evere|SEVERE|[Ee]merg(?:ency)?|EMERG(?:ENCY)?)`,
+	"COMMONAPACHELOG":   `%{IPORHOST:clientip} %{USER:ident} %{USER:auth} \[%{HTTPDATE:timestamp}\] "(?:%{WORD:verb} %{NOTSPACE:request}(?: HTTP/%{NUMBER:httpversion})?|%{DATA:rawrequest})" %{NUMBER:response:int} (?:%{NUMBER:bytes:int}|-)`,
+	"COMBINEDAPACHELOG": `%{COMMONAPACHELOG} %{QUOTEDSTRING:referrer} %{QUOTEDSTRING:agent}`,
+}
+
+// GrokPattern is a compiled pattern
+type GrokPattern struct {
+	Regex *regexp.Regexp
+	// Types of the fields with a :int or :float suffix
+	Types map[string]string
+}
+
+/******************************************************************************
+* FUNCTION:        compileGrokPattern
+*
+* DESCRIPTION:     Expands the %{NAME:field:type} references of a pattern
+*                  with the standard library and the user's definitions
+*                  and compiles the result. Definitions may refer to each
+*                  other and to the library, and override library names
+* INPUT:           pattern, definitions
+* RETURNS:         *GrokPattern, error
+******************************************************************************/
+func compileGrokPattern(pattern string, definitions map[string]string) (*GrokPattern, error) {
+	if strings.TrimSpace(pattern) == "" {
+		return nil, fmt.Errorf("pattern is empty")
+	}
+	if len(pattern) > GROK_MAX_PATTERN_LEN {
+		return nil, fmt.Errorf("pattern is longer than %d characters", GROK_MAX_PATTERN_LEN)
+	}
+	for name, definition := range definitions {
+		if !grokNameRegex.MatchString(name) {
+			return nil, fmt.Errorf("invalid definition name %q, use upper case letters, digits and _", name)
+		}
+		if len(definition) > GROK_MAX_PATTERN_LEN {
+			return nil, fmt.Errorf("definition %s is longer than %d characters", name, GROK_MAX_PATTERN_LEN)
+		}
+	}
+
+	types := make(map[string]string)
+	expanded, err := expandGrok(pattern, definitions, types, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	regex, err := regexp.Compile(expanded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern: %v", err)
+	}
+
+	return &GrokPattern{Regex: regex, Types: types}, nil
+}
+
+/******************************************************************************
+* FUNCTION:        expandGrok
+*
+* DESCRIPTION:     Recursively replaces the references of a pattern, a
+*                  named reference becomes a named capture group
+* INPUT:           pattern, definitions, field types, depth
+* RETURNS:         expanded regex, error
+******************************************************************************/
+func expandGrok(pattern string, definitions map[string]string, types map[string]string, depth int) (string, error) {
+	if depth > GROK_MAX_DEPTH {
+		return "", fmt.Errorf("pattern references are nested deeper than %d, check for a cycle", GROK_MAX_DEPTH)
+	}
+
+	var expandErr error
+	expandedLen := len(pattern)
+	expanded := grokReferenceRegex.ReplaceAllStringFunc(pattern, func(reference string) string {
+		if expandErr != nil {
+			return ""
+		}
+		parts := grokReferenceRegex.FindStringSubmatch(reference)
+		name, field, fieldType := parts[1], parts[2], parts[3]
+
+		definition, ok := definitions[name]
+		if !ok {
+			definition, ok = grokLibrary[name]
+		}
+		if !ok {
+			expandErr = fmt.Errorf("unknown pattern %%{%s}", name)
+			return ""
+		}
+
+		inner, err := expandGrok(definition, definitions, types, depth+1)
+		if err != nil {
+			expandErr = err
+			return ""
+		}
+		if expandedLen += len(inner); expandedLen > GROK_MAX_EXPANDED_LEN {
+			expandErr = fmt.Errorf("pattern expands to more than %d characters", GROK_MAX_EXPANDED_LEN)
+			return ""
+		}
+
+		if field == "" {
+			return "(?:" + inner + ")"
+		}
+		switch fieldType {
+		case "":
+		case GROK_TYPE_INT, GROK_TYPE_FLOAT:
+			types[field] = fieldType
+		default:
+			expandErr = fmt.Errorf("unknown type %q of field %s, use int or float", fieldType, field)
+			return ""
+		}
+		return "(?P<" + field + ">" + inner + ")"
+	})
+
+	return expanded, expandErr
+}
+
+/******************************************************************************
+* FUNCTION:        match
+*
+* DESCRIPTION:     Matches a line and returns its typed fields. A field
+*                  captured more than once keeps its first non empty value
+* INPUT:           line
+* RETURNS:         fields, ok
+******************************************************************************/
+func (g *GrokPattern) match(line string) (map[string]interface{}, bool) {
+	matches := g.Regex.FindStringSubmatch(line)
+	if matches == nil {
+		return nil, false
+	}
+
+	fields := make(map[string]interface{})
+	for i, name := range g.Regex.SubexpNames() {
+		if name == "" || matches[i] == "" {
+			continue
+		}
+		if _, exists := fields[name]; exists {
+			continue
+		}
+
+		var value interface{} = matches[i]
+		switch g.Types[name] {
+		case GROK_TYPE_INT:
+			if n, err := strconv.ParseInt(matches[i], 10, 64); err == nil {
+				value = n
+			}
+		case GROK_TYPE_FLOAT:
+			if f, err := strconv.ParseFloat(matches[i], 64); err == nil {
+				value = f
+			}
+		}
+		fields[name] = value
+	}
+
+	return fields, true
+}
+
+/******************************************************************************
+* FUNCTION:        parseLine
+*
+* DESCRIPTION:     Parses a line into a LogEntry. The timestamp, level,
+*                  message and ip fields fill the entry (the timestamp is
+*                  required, the message defaults to the whole line), the
+*                  other fields are kept as attributes
+* INPUT:           line, fileID, timestamp recognizer of the file
+* RETURNS:         LogEntry, error
+******************************************************************************/
+func (g *GrokPattern) parseLine(line string, fileID int64, timestamps *timestampRecognizer) (LogEntry, error) {
+	fields, ok := g.match(line)
+	if !ok {
+		return LogEntry{}, ErrLineNoMatch
+	}
+
+	timestampField, timestampValue := grokField(fields, grokTimestampFields)
+	if timestampField == "" {
+		return LogEntry{}, ErrLineBadTimestamp
+	}
+	timestamp, ok := timestamps.parse(timestampValue)
+	if !ok {
+		return LogEntry{}, ErrLineBadTimestamp
+	}
+
+	levelField, level := grokField(fields, grokLevelFields)
+	if level == "" {
+		level = GROK_UNKNOWN_LEVEL
+	}
+	messageField, message := grokField(fields, grokMessageFields)
+	if messageField == "" {
+		message = line
+	}
+
+	entry := buildLogEntry(timestamp, level, message, fileID)
+	entry.RawTimestamp = timestampValue
+	if ipField, ip := grokField(fields, grokIpFields); ipField != "" {
+		entry.IP = normalizeIP(ip)
+	}
+
+	delete(fields, timestampField)
+	delete(fields, levelField)
+	delete(fields, messageField)
+	if len(fields) > 0 {
+		entry.Attributes = fields
+	}
+
+	return entry, nil
+}
+
+/******************************************************************************
+* FUNCTION:        hasTimestampField
+*
+* DESCRIPTION:     Tells whether the pattern captures one of the timestamp
+*                  fields, without which no line can be parsed
+* INPUT:           None
+* RETURNS:         bool
+******************************************************************************/
+func (g *GrokPattern) hasTimestampField() bool {
+	for _, name := range g.Regex.SubexpNames() {
+		for _, field := range grokTimestampFields {
+			if name == field {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+/******************************************************************************
+* FUNCTION:        grokField
+*
+* DESCRIPTION:     Helper function returning the first of the fields present
+* INPUT:           fields, names
+* RETURNS:         name, value
+******************************************************************************/
+func grokField(fields map[string]interface{}, names []string) (string, string) {
+	for _, name := range names {
+		if value, ok := fields[name]; ok {
+			return name, fmt.Sprint(value)
+		}
+	}
+	return "", ""
+}
+
+/******************************************************************************
+* FUNCTION:        getGrokPattern
+*
+* DESCRIPTION:     Returns the compiled pattern of the user. Patterns cannot
+*                  be edited, so the compiled form is cached by id
+* INPUT:           patternId, userId
+* RETURNS:         *GrokPattern, error
+******************************************************************************/
+func getGrokPattern(patternId int64, userId string) (*GrokPattern, error) {
+	query := `SELECT pattern, definitions FROM grok_patterns WHERE pattern_id = $1 AND user_id = $2`
+	result, err := db.GetDataFromDB(query, []interface{}{patternId, userId})
+	if err != nil {
+		return nil, err
+	}
+	if len(result) == 0 {
+		return nil, ErrGrokPatternNotFound
+	}
+
+	if cached, ok := grokCache.get(patternId); ok {
+		return cached, nil
+	}
+
+	pattern, _ := result[0]["pattern"].(string)
+	var definitions map[string]string
+	if raw, ok := result[0]["definitions"].(string); ok && raw != "" {
+		if err := json.Unmarshal([]byte(raw), &definitions); err != nil {
+			return nil, fmt.Errorf("invalid definitions of grok pattern %d: %v", patternId, err)
+		}
+	}
+
+	compiled, err := compileGrokPattern(pattern, definitions)
+	if err != nil {
+		return nil, err
+	}
+
+	grokCache.set(patternId, compiled)
+
+	return compiled, nil
+}
@@ -0,0 +1,249 @@
+/**************************************************************************
+ * File       	   : serviceIngestUtils.go
+ * DESCRIPTION     : This file contains the helper functions shared by
+ *                   every way a log file enters the service: storing the
+ *                   file in supabase, creating the file_stats row and
+ *                   enqueuing the processing task
+ * DATE            : 19-October-2026
+ **************************************************************************/
+
+package services
+
+import (
+	"LOGProcessor/log-mainService/tasks"
+	"LOGProcessor/shared/db"
+	"LOGProcessor/shared/types"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+var (
+	ErrCredentialsKeyMissing = errors.New("SOURCE_CREDENTIALS_KEY is not configured")
+	ErrIngestTooLarge        = errors.New("file exceeds the maximum ingest size")
+)
+
+type countingReader struct {
+	reader io.Reader
+	count  int64
+}
+
+/******************************************************************************
+* FUNCTION:        Read
+*
+* DESCRIPTION:     io.Reader implementation that counts the bytes read
+* INPUT:           buffer
+* RETURNS:         int, error
+******************************************************************************/
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.reader.Read(p)
+	c.count += int64(n)
+	return n, err
+}
+
+type maxBytesReader struct {
+	reader    io.Reader
+	remaining int64
+	exceeded  bool
+}
+
+/******************************************************************************
+* FUNCTION:        newMaxBytesReader
+*
+* DESCRIPTION:     Returns a reader that fails with ErrIngestTooLarge once
+*                  more than the configured INGEST_MAX_BYTES are read
+* INPUT:           reader
+* RETURNS:         io.Reader
+******************************************************************************/
+func newMaxBytesReader(reader io.Reader) io.Reader {
+	return &maxBytesReader{reader: reader, remaining: types.IngestCfg.MaxBytes}
+}
+
+/******************************************************************************
+* FUNCTION:        Read
+*
+* DESCRIPTION:     io.Reader implementation enforcing the size limit
+* INPUT:           buffer
+* RETURNS:         int, error
+******************************************************************************/
+func (m *maxBytesReader) Read(p []byte) (int, error) {
+	if m.remaining <= 0 {
+		// probe a single byte to tell an exact fit from an oversize file
+		var probe [1]byte
+		n, err := m.reader.Read(probe[:])
+		if n > 0 {
+			m.exceeded = true
+			return 0, ErrIngestTooLarge
+		}
+		return 0, err
+	}
+
+	if int64(len(p)) > m.remaining {
+		p = p[:m.remaining]
+	}
+	n, err := m.reader.Read(p)
+	m.remaining -= int64(n)
+	return n, err
+}
+
+/******************************************************************************
+* FUNCTION:        storeAndEnqueueLogFile
+*
+* DESCRIPTION:     Uploads the file to supabase, creates its file_stats row
+*                  and enqueues the log process task. The returned map is
+*                  the file_stats data including file_id and job_id, ready
+*                  to be broadcast
+* INPUT:           ctx, token, userId, fileName, file, parseOptions
+* RETURNS:         map[string]interface{}, error
+******************************************************************************/
+func storeAndEnqueueLogFile(ctx context.Context, token, userId, fileName string, file io.Reader, parseOptions tasks.LogParseOptions) (data map[string]interface{}, err error) {
+	var (
+		fileId int64
+		taskId string
+	)
+
+	counter := &countingReader{reader: file}
+	uploadRsp, err := uploadFileToSupeBaseStorage(token, fileName, counter)
+	if err != nil {
+		// storage may answer the aborted upload with an error of its own
+		if limited, ok := file.(*maxBytesReader); ok && limited.exceeded {
+			return nil, fmt.Errorf("%w: %w", ErrIngestTooLarge, err)
+		}
+		return nil, err
+	}
+
+	filePath := uploadRsp.Key
+	fileSize := counter.count
+	data = map[string]interface{}{
+		"file_name":    fileName,
+		"file_size_mb": float64(fileSize) / (1024 * 1024),
+		"status":       "pending",
+		"created_at":   time.Now(),
+		"file_path":    filePath,
+		"user_id":      userId,
+	}
+	if parseOptions.PatternId > 0 {
+		data["grok_pattern_id"] = parseOptions.PatternId
+	}
+	if parseOptions.Timezone != "" {
+		data["source_timezone"] = parseOptions.Timezone
+	}
+	if parseOptions.Encoding != "" {
+		data["source_encoding"] = parseOptions.Encoding
+	}
+
+	tx, err := types.Db.DbConn.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			err = fmt.Errorf("panic occurred: %v", p)
+		} else if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	fileId, err = db.InsertAndReturnID(tx, "file_stats", data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert into file_stats: %v", err)
+	}
+
+	task, err := tasks.NewLogProcessTask(filePath, userId, fileId, fileSize, parseOptions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create task: %v", err)
+	}
+	taskId, err = tasks.EnqueueFair(ctx, task)
+	if err != nil {
+		return nil, fmt.Errorf("failed to enqueue task: %v", err)
+	}
+
+	_, err = db.UpdateDataInDB(tx, "UPDATE file_stats SET job_id = $1 WHERE file_id = $2", []interface{}{taskId, fileId})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update job_id: %v", err)
+	}
+
+	data["file_id"] = fileId
+	data["job_id"] = taskId
+	return data, nil
+}
+
+/******************************************************************************
+* FUNCTION:        encryptCredentials
+*
+* DESCRIPTION:     Encrypts stored source credentials with AES-GCM using the
+*                  SOURCE_CREDENTIALS_KEY
+* INPUT:           plain text
+* RETURNS:         base64 cipher text, error
+******************************************************************************/
+func encryptCredentials(plain []byte) (string, error) {
+	gcm, err := credentialsCipher()
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := gcm.Seal(nonce, nonce, plain, nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+/******************************************************************************
+* FUNCTION:        decryptCredentials
+*
+* DESCRIPTION:     Decrypts credentials encrypted by encryptCredentials
+* INPUT:           base64 cipher text
+* RETURNS:         plain text, error
+******************************************************************************/
+func decryptCredentials(encoded string) ([]byte, error) {
+	gcm, err := credentialsCipher()
+	if err != nil {
+		return nil, err
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, fmt.Errorf("invalid encrypted credentials")
+	}
+
+	nonce, cipherText := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, cipherText, nil)
+}
+
+/******************************************************************************
+* FUNCTION:        credentialsCipher
+*
+* DESCRIPTION:     Helper function to build the AES-GCM cipher from the
+*                  configured key
+* INPUT:           None
+* RETURNS:         cipher.AEAD, error
+******************************************************************************/
+func credentialsCipher() (cipher.AEAD, error) {
+	if types.CmnGlblCfg.SOURCE_CREDENTIALS_KEY == "" {
+		return nil, ErrCredentialsKeyMissing
+	}
+
+	key := sha256.Sum256([]byte(types.CmnGlblCfg.SOURCE_CREDENTIALS_KEY))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,162 @@
+/**************************************************************************
+ * File       	   : serviceIpAddress.go
+ * DESCRIPTION     : This file contains the extraction of the ip of a log
+ *                   line: validated IPv4 and IPv6 addresses, bracketed and
+ *                   port-suffixed forms, normalized to their canonical text
+ * DATE            : 19-October-2026
+ **************************************************************************/
+
+package services
+
+import (
+	"net"
+	"net/netip"
+	"regexp"
+	"strings"
+)
+
+var (
+	// the character before an address is matched as well, go regexps have
+	// no lookbehind. "v1.2.3.4" and "1.2.3.4.5" are no ip
+	ipv4CandidateRegex = regexp.MustCompile(`(?:^|[^0-9A-Za-z_.])((?:\d{1,3}\.){3}\d{1,3})`)
+	// "std::map" and "12:30:45" are no ip, the first because of the word
+	// character before it, the second fails validation. The last groups
+	// may be a dotted IPv4 address (::ffff:10.0.0.1)
+	ipv6CandidateRegex = regexp.MustCompile(`(?i)(?:^|[^0-9a-z_:.])((?:[0-9a-f]{0,4}:){2,7}(?:(?:\d{1,3}\.){3}\d{1,3}|[0-9a-f]{0,4}))`)
+)
+
+/******************************************************************************
+* FUNCTION:        extractIP
+*
+* DESCRIPTION:     Returns the first valid ip address of a text in its
+*                  canonical form, "" when there is none. "[2001:db8::1]:443"
+*                  and "10.0.0.1:8080" give the address without the port.
+*                  An IPv6 address without brackets is taken as a whole, a
+*                  port cannot be told apart from its last group
+* INPUT:           text
+* RETURNS:         ip
+******************************************************************************/
+func extractIP(text string) string {
+	best, bestStart := "", len(text)
+
+	for _, match := range ipv4CandidateRegex.FindAllStringSubmatchIndex(text, -1) {
+		start, end := match[2], match[3]
+		if start >= bestStart {
+			break
+		}
+		if !ipv4Boundary(text, end) {
+			continue
+		}
+		if ip := normalizeIP(text[start:end]); ip != "" {
+			best, bestStart = ip, start
+			break
+		}
+	}
+
+	for _, match := range ipv6CandidateRegex.FindAllStringSubmatchIndex(text, -1) {
+		start, end := match[2], match[3]
+		if start >= bestStart {
+			break
+		}
+		// the groups are greedy, "fe80::1: reset" matches the colon after
+		// the address as well
+		if text[end-1] == ':' && text[end-2] != ':' {
+			end--
+		}
+		candidate := text[start:end]
+		// a lone "::" is punctuation far more often than an address
+		if !strings.ContainsAny(candidate, "0123456789abcdefABCDEF") || ipv6TrailingWord(text, end) {
+			continue
+		}
+		if ip := normalizeIP(candidate); ip != "" {
+			best, bestStart = ip, start
+			break
+		}
+	}
+
+	return best
+}
+
+/******************************************************************************
+* FUNCTION:        ipv4Boundary
+*
+* DESCRIPTION:     Helper function telling whether an IPv4 candidate ends
+*                  where an address would: not inside a word and not
+*                  followed by another dotted number as in a version
+* INPUT:           text, end of the candidate
+* RETURNS:         bool
+******************************************************************************/
+func ipv4Boundary(text string, end int) bool {
+	if end == len(text) {
+		return true
+	}
+
+	next := text[end]
+	if isWordByte(next) {
+		return false
+	}
+	if next == '.' && end+1 < len(text) && text[end+1] >= '0' && text[end+1] <= '9' {
+		return false
+	}
+	return true
+}
+
+/******************************************************************************
+* FUNCTION:        ipv6TrailingWord
+*
+* DESCRIPTION:     Helper function telling whether an IPv6 candidate is cut
+*                  out of something longer, e.g. a key fingerprint with
+*                  more than eight colon separated groups
+* INPUT:           text, end of the candidate
+* RETURNS:         bool
+******************************************************************************/
+func ipv6TrailingWord(text string, end int) bool {
+	if end == len(text) {
+		return false
+	}
+	if isWordByte(text[end]) {
+		return true
+	}
+	// "fe80::1: reset" ends the address, "aa:bb:...:hh:ii" does not
+	return (text[end] == ':' || text[end] == '.') && end+1 < len(text) && isWordByte(text[end+1])
+}
+
+/******************************************************************************
+* FUNCTION:        isWordByte
+*
+* DESCRIPTION:     Helper function matching the regexp \w class
+* INPUT:           byte
+* RETURNS:         bool
+******************************************************************************/
+func isWordByte(b byte) bool {
+	return b == '_' || (b >= '0' && b <= '9') || (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z')
+}
+
+/******************************************************************************
+* FUNCTION:        normalizeIP
+*
+* DESCRIPTION:     Validates an ip taken from a field (json "ip", grok,
+*                  logfmt, otlp client.address) and returns its canonical
+*                  text. Brackets, a port and an IPv6 zone are removed and an
+*                  IPv4-mapped IPv6 address becomes IPv4. "" when the value
+*                  is no address
+* INPUT:           value
+* RETURNS:         ip
+******************************************************************************/
+func normalizeIP(value string) string {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return ""
+	}
+
+	if host, _, err := net.SplitHostPort(value); err == nil {
+		value = host
+	}
+	value = strings.TrimSuffix(strings.TrimPrefix(value, "["), "]")
+
+	addr, err := netip.ParseAddr(value)
+	if err != nil {
+		return ""
+	}
+	return addr.WithZone("").Unmap().String()
+}
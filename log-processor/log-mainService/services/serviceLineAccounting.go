@@ -0,0 +1,208 @@
+/**************************************************************************
+ * File       	   : serviceLineAccounting.go
+ * DESCRIPTION     : This file contains the accounting of the lines of a
+ *                   processed file, the parsed and rejected counts with
+ *                   the rejection reasons and a bounded sample of the
+ *                   rejected lines per reason
+ * DATE            : 19-October-2026
+ **************************************************************************/
+
+package services
+
+import (
+	"LOGProcessor/shared/db"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	REJECT_REASON_NO_MATCH      = "no_match"
+	REJECT_REASON_BAD_TIMESTAMP = "bad_timestamp"
+	REJECT_REASON_OVERSIZE      = "oversize_line"
+
+	REJECT_SAMPLES_PER_REASON = 10
+	// sampled lines are cut at this size
+	REJECT_SAMPLE_MAX_BYTES = 1024
+)
+
+var (
+	// ErrLineNoMatch is returned by line parsers for lines not in their
+	// format
+	ErrLineNoMatch = errors.New("line does not match the format")
+	// ErrLineBadTimestamp is returned for lines in the format whose
+	// timestamp is missing or not recognized
+	ErrLineBadTimestamp = errors.New("timestamp missing or not recognized")
+	// ErrLineOversize wraps the parse error of a line that was cut at the
+	// maximum line length
+	ErrLineOversize = errors.New("line longer than the maximum")
+)
+
+type rejectedLineSample struct {
+	Reason     string
+	LineNumber int64
+	Line       string
+}
+
+// LineAccounting counts the lines of a file. Lines that are part of a
+// multi-line entry count as parsed
+type LineAccounting struct {
+	Total    int64
+	Parsed   int64
+	Rejected int64
+	Reasons  map[string]int64
+	Samples  []rejectedLineSample
+}
+
+/******************************************************************************
+* FUNCTION:        newLineAccounting
+*
+* DESCRIPTION:     Creates an empty accounting
+* INPUT:           None
+* RETURNS:         *LineAccounting
+******************************************************************************/
+func newLineAccounting() *LineAccounting {
+	return &LineAccounting{Reasons: make(map[string]int64)}
+}
+
+/******************************************************************************
+* FUNCTION:        count
+*
+* DESCRIPTION:     Counts a line with the result of its parsing, a nil err
+*                  is a parsed line
+* INPUT:           line number, line, parse error
+* RETURNS:         void
+******************************************************************************/
+func (a *LineAccounting) count(lineNumber int64, line string, err error) {
+	a.Total++
+	if err == nil {
+		a.Parsed++
+		return
+	}
+
+	reason := rejectionReason(err)
+	a.Rejected++
+	a.Reasons[reason]++
+	if a.Reasons[reason] <= REJECT_SAMPLES_PER_REASON {
+		a.Samples = append(a.Samples, rejectedLineSample{
+			Reason:     reason,
+			LineNumber: lineNumber,
+			Line:       truncateSample(line),
+		})
+	}
+}
+
+/******************************************************************************
+* FUNCTION:        merge
+*
+* DESCRIPTION:     Adds the accounting of a chunk, keeping the samples
+*                  bounded per reason
+* INPUT:           accounting of the chunk
+* RETURNS:         void
+******************************************************************************/
+func (a *LineAccounting) merge(other *LineAccounting) {
+	a.Total += other.Total
+	a.Parsed += other.Parsed
+	a.Rejected += other.Rejected
+
+	sampled := make(map[string]int)
+	for _, sample := range a.Samples {
+		sampled[sample.Reason]++
+	}
+	for _, sample := range other.Samples {
+		if sampled[sample.Reason] < REJECT_SAMPLES_PER_REASON {
+			a.Samples = append(a.Samples, sample)
+			sampled[sample.Reason]++
+		}
+	}
+	for reason, count := range other.Reasons {
+		a.Reasons[reason] += count
+	}
+}
+
+/******************************************************************************
+* FUNCTION:        rejectionReason
+*
+* DESCRIPTION:     Maps a parse error to its rejection reason
+* INPUT:           err
+* RETURNS:         string
+******************************************************************************/
+func rejectionReason(err error) string {
+	if errors.Is(err, ErrLineOversize) {
+		return REJECT_REASON_OVERSIZE
+	}
+	if errors.Is(err, ErrLineBadTimestamp) {
+		return REJECT_REASON_BAD_TIMESTAMP
+	}
+	return REJECT_REASON_NO_MATCH
+}
+
+/******************************************************************************
+* FUNCTION:        truncateSample
+*
+* DESCRIPTION:     Helper function cutting a sampled line at
+*                  REJECT_SAMPLE_MAX_BYTES without splitting a character
+* INPUT:           line
+* RETURNS:         string
+******************************************************************************/
+func truncateSample(line string) string {
+	if len(line) <= REJECT_SAMPLE_MAX_BYTES {
+		return line
+	}
+
+	cut := REJECT_SAMPLE_MAX_BYTES
+	for cut > 0 && !utf8.RuneStart(line[cut]) {
+		cut--
+	}
+	return line[:cut]
+}
+
+/******************************************************************************
+* FUNCTION:        storeLineAccounting
+*
+* DESCRIPTION:     Stores the line counts of a file in file_stats and
+*                  replaces its rejected line samples, a retried job keeps
+*                  only the samples of its last attempt
+* INPUT:           tx (nil outside a transaction), fileID, accounting
+* RETURNS:         error
+******************************************************************************/
+func storeLineAccounting(tx *sql.Tx, fileID int64, accounting *LineAccounting) error {
+	reasonsJSON, _ := json.Marshal(accounting.Reasons)
+	data := map[string]interface{}{
+		"line_count":       accounting.Total,
+		"parsed_lines":     accounting.Parsed,
+		"rejected_lines":   accounting.Rejected,
+		"rejected_reasons": string(reasonsJSON),
+	}
+	if err := db.UpdateSingleRecord(tx, "file_stats", "file_id", fileID, data); err != nil {
+		return fmt.Errorf("failed to update line counts: %v", err)
+	}
+
+	if _, err := db.UpdateDataInDB(tx, "DELETE FROM rejected_line_samples WHERE file_id = $1", []interface{}{fileID}); err != nil {
+		return fmt.Errorf("failed to clear rejected line samples: %v", err)
+	}
+	if len(accounting.Samples) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	samples := make([]map[string]interface{}, 0, len(accounting.Samples))
+	for _, sample := range accounting.Samples {
+		samples = append(samples, map[string]interface{}{
+			"file_id":     fileID,
+			"reason":      sample.Reason,
+			"line_number": sample.LineNumber,
+			// postgres text holds neither NUL nor invalid utf-8
+			"line":       sanitizeText(sample.Line),
+			"created_at": now,
+		})
+	}
+	if err := db.AddMultipleRecordInDB(tx, "rejected_line_samples", samples); err != nil {
+		return fmt.Errorf("failed to insert rejected line samples: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,202 @@
+/**************************************************************************
+ * File       	   : serviceLineReader.go
+ * DESCRIPTION     : This file contains the line reader of processed files.
+ *                   Lines longer than the configured maximum are cut
+ *                   instead of failing the job, in spill mode their full
+ *                   content is kept aside within a per file budget
+ * DATE            : 19-October-2026
+ **************************************************************************/
+
+package services
+
+import (
+	"LOGProcessor/shared/db"
+	"LOGProcessor/shared/types"
+	"bufio"
+	"database/sql"
+	"io"
+	"unicode/utf8"
+)
+
+const (
+	LINE_OVERSIZE_TRUNCATE = "truncate"
+	LINE_OVERSIZE_SPILL    = "spill"
+
+	DEFAULT_LINE_MAX_BYTES = 1 << 20
+	LINE_READ_BUFFER_BYTES = 64 * 1024
+)
+
+// fileLine is a line read from a file. Text holds at most the configured
+// maximum, Full the whole line of a spilled oversize line
+type fileLine struct {
+	Text     string
+	Full     string
+	Bytes    int64
+	Oversize bool
+}
+
+// spilledLine is the full content of an oversize line
+type spilledLine struct {
+	LineNumber int64
+	Bytes      int64
+	Content    string
+}
+
+// lineReader reads the lines of a file like bufio.ScanLines, without a
+// limit on their length
+type lineReader struct {
+	reader   *bufio.Reader
+	maxBytes int
+	// bytes of oversize lines that may still be kept whole, 0 unless
+	// spilling
+	spillBudget int64
+}
+
+/******************************************************************************
+* FUNCTION:        newLineReader
+*
+* DESCRIPTION:     Creates the line reader of a file with the configured
+*                  maximum line length and oversize mode
+* INPUT:           reader
+* RETURNS:         *lineReader
+******************************************************************************/
+func newLineReader(reader io.Reader) *lineReader {
+	maxBytes := types.ProcessingCfg.MaxLineBytes
+	if maxBytes <= 0 {
+		maxBytes = DEFAULT_LINE_MAX_BYTES
+	}
+
+	var spillBudget int64
+	if types.ProcessingCfg.OversizeMode == LINE_OVERSIZE_SPILL {
+		spillBudget = types.ProcessingCfg.SpillMaxBytes
+	}
+
+	return &lineReader{
+		reader:      bufio.NewReaderSize(reader, LINE_READ_BUFFER_BYTES),
+		maxBytes:    maxBytes,
+		spillBudget: spillBudget,
+	}
+}
+
+/******************************************************************************
+* FUNCTION:        next
+*
+* DESCRIPTION:     Reads the next line without its line ending. Bytes past
+*                  the maximum are discarded, or kept while the spill
+*                  budget lasts. io.EOF is returned after the last line
+* INPUT:           None
+* RETURNS:         fileLine, error
+******************************************************************************/
+func (r *lineReader) next() (fileLine, error) {
+	var (
+		buf   []byte
+		total int64
+		ended bool
+	)
+
+	limit := int64(r.maxBytes) + r.spillBudget
+	for !ended {
+		chunk, err := r.reader.ReadSlice('\n')
+		if err != nil && err != bufio.ErrBufferFull {
+			if err != io.EOF {
+				return fileLine{}, err
+			}
+			if total == 0 && len(chunk) == 0 {
+				return fileLine{}, io.EOF
+			}
+			ended = true
+		}
+		if err == nil {
+			ended = true
+			chunk = chunk[:len(chunk)-1]
+		}
+
+		total += int64(len(chunk))
+		if keep := limit - int64(len(buf)); keep > 0 {
+			if int64(len(chunk)) > keep {
+				chunk = chunk[:keep]
+			}
+			buf = append(buf, chunk...)
+		}
+	}
+
+	// a trailing \r is dropped like bufio.ScanLines does
+	if total == int64(len(buf)) && len(buf) > 0 && buf[len(buf)-1] == '\r' {
+		buf = buf[:len(buf)-1]
+		total--
+	}
+
+	if total <= int64(r.maxBytes) {
+		return fileLine{Text: string(buf), Bytes: total}, nil
+	}
+
+	line := fileLine{Text: cutAtRune(buf, r.maxBytes), Bytes: total, Oversize: true}
+	if total == int64(len(buf)) && total-int64(r.maxBytes) <= r.spillBudget {
+		line.Full = string(buf)
+		r.spillBudget -= total - int64(r.maxBytes)
+	}
+
+	return line, nil
+}
+
+/******************************************************************************
+* FUNCTION:        cutAtRune
+*
+* DESCRIPTION:     Helper function cutting bytes at n without splitting a
+*                  character
+* INPUT:           bytes, n
+* RETURNS:         string
+******************************************************************************/
+func cutAtRune(b []byte, n int) string {
+	if len(b) <= n {
+		return string(b)
+	}
+	for n > 0 && !utf8.RuneStart(b[n]) {
+		n--
+	}
+	return string(b[:n])
+}
+
+/******************************************************************************
+* FUNCTION:        markOversize
+*
+* DESCRIPTION:     Flags the entry of an oversize line and keeps its full
+*                  content when it was spilled
+* INPUT:           entry, line, line number, spilled lines
+* RETURNS:         spilled lines
+******************************************************************************/
+func markOversize(entry *LogEntry, line fileLine, lineNumber int64, spilled []spilledLine) []spilledLine {
+	entry.Truncated = true
+	entry.LineBytes = line.Bytes
+	entry.LineNumber = lineNumber
+
+	if line.Full != "" {
+		spilled = append(spilled, spilledLine{LineNumber: lineNumber, Bytes: line.Bytes, Content: line.Full})
+	}
+	return spilled
+}
+
+/******************************************************************************
+* FUNCTION:        insertSpilledLines
+*
+* DESCRIPTION:     Stores the full content of the spilled oversize lines
+*                  of a file
+* INPUT:           tx, fileID, spilled lines
+* RETURNS:         error
+******************************************************************************/
+func insertSpilledLines(tx *sql.Tx, fileID int64, spilled []spilledLine) error {
+	for _, line := range spilled {
+		data := []map[string]interface{}{{
+			"file_id":     fileID,
+			"line_number": line.LineNumber,
+			"line_bytes":  line.Bytes,
+			// postgres text holds neither NUL nor invalid utf-8
+			"content": sanitizeText(line.Content),
+		}}
+		// one row per insert, a spilled line may be large
+		if err := db.AddMultipleRecordInDB(tx, "oversize_lines", data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
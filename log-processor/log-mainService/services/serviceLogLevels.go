@@ -0,0 +1,239 @@
+/**************************************************************************
+ * File       	   : serviceLogLevels.go
+ * DESCRIPTION     : This file contains the canonical log level model, the
+ *                   built-in alias tables of the common formats and the
+ *                   user defined level mappings
+ * DATE            : 19-October-2026
+ **************************************************************************/
+
+package services
+
+import (
+	"LOGProcessor/shared/db"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/martian/log"
+)
+
+const (
+	// user mappings are reloaded after this long, so changes made through
+	// the api reach the task service as well
+	LEVEL_MAPPINGS_CACHE_TTL = time.Minute
+)
+
+// canonical levels in order of severity, UNKNOWN is 0
+var canonicalLevels = []string{UNKNOWN_LOG_LEVEL, "TRACE", "DEBUG", "INFO", "WARN", "ERROR", "FATAL"}
+
+var (
+	levelSeverities = func() map[string]int {
+		severities := make(map[string]int, len(canonicalLevels))
+		for severity, level := range canonicalLevels {
+			severities[level] = severity
+		}
+		return severities
+	}()
+
+	// level names of the text formats (log4j, logback, python, go, zap,
+	// java.util.logging, klog), upper-cased
+	levelAliases = map[string]string{
+		"T": "TRACE", "TRC": "TRACE", "TRACE": "TRACE", "FINEST": "TRACE", "FINER": "TRACE",
+		"D": "DEBUG", "DBG": "DEBUG", "DEBUG": "DEBUG", "FINE": "DEBUG", "VERBOSE": "DEBUG",
+		"I": "INFO", "INF": "INFO", "INFO": "INFO", "INFORMATION": "INFO", "NOTICE": "INFO", "CONFIG": "INFO",
+		"W": "WARN", "WRN": "WARN", "WARN": "WARN", "WARNING": "WARN",
+		"E": "ERROR", "ERR": "ERROR", "ERROR": "ERROR", "SEVERE": "ERROR",
+		"F": "FATAL", "FTL": "FATAL", "FATAL": "FATAL", "CRIT": "FATAL", "CRITICAL": "FATAL",
+		"ALERT": "FATAL", "EMERG": "FATAL", "EMERGENCY": "FATAL", "PANIC": "FATAL", "DPANIC": "FATAL",
+	}
+
+	// syslog severities 0-7
+	syslogNumericLevels = []string{"FATAL", "FATAL", "FATAL", "ERROR", "WARN", "INFO", "INFO", "DEBUG"}
+
+	// bunyan and pino numeric levels
+	bunyanNumericLevels = map[int]string{10: "TRACE", 20: "DEBUG", 30: "INFO", 40: "WARN", 50: "ERROR", 60: "FATAL"}
+
+	levelMappingsCache = newBoundedCache[string, map[string]string](LEVEL_MAPPINGS_CACHE_TTL, CACHE_MAX_USERS)
+)
+
+// LogLevelValue is a level given as a string or as a number in json
+type LogLevelValue string
+
+/******************************************************************************
+* FUNCTION:        UnmarshalJSON
+*
+* DESCRIPTION:     Accepts "warn" as well as 40
+* INPUT:           data
+* RETURNS:         error
+******************************************************************************/
+func (l *LogLevelValue) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		*l = LogLevelValue(text)
+		return nil
+	}
+
+	var number json.Number
+	if err := json.Unmarshal(data, &number); err != nil {
+		return fmt.Errorf("level must be a string or a number")
+	}
+	*l = LogLevelValue(number.String())
+	return nil
+}
+
+/******************************************************************************
+* FUNCTION:        normalizeLogLevel
+*
+* DESCRIPTION:     Maps a level as written to its canonical level with the
+*                  built-in alias tables. Numbers 0-7 are syslog severities,
+*                  10-60 bunyan/pino levels. Anything else is UNKNOWN
+* INPUT:           raw level
+* RETURNS:         canonical level
+******************************************************************************/
+func normalizeLogLevel(raw string) string {
+	raw = strings.ToUpper(strings.TrimSpace(raw))
+
+	if level, ok := levelAliases[raw]; ok {
+		return level
+	}
+
+	if n, err := strconv.Atoi(raw); err == nil {
+		if n >= 0 && n < len(syslogNumericLevels) {
+			return syslogNumericLevels[n]
+		}
+		if level, ok := bunyanNumericLevels[n]; ok {
+			return level
+		}
+	}
+
+	return UNKNOWN_LOG_LEVEL
+}
+
+/******************************************************************************
+* FUNCTION:        isCanonicalLevel
+*
+* DESCRIPTION:     Helper function telling whether a name is a canonical
+*                  level, UNKNOWN excluded
+* INPUT:           level
+* RETURNS:         bool
+******************************************************************************/
+func isCanonicalLevel(level string) bool {
+	severity, ok := levelSeverities[level]
+	return ok && severity > 0
+}
+
+/******************************************************************************
+* FUNCTION:        isLogLevelWord
+*
+* DESCRIPTION:     Tells whether a word following a timestamp is a level.
+*                  Single letter aliases are only accepted in level fields,
+*                  in free text they are too likely a word
+* INPUT:           word
+* RETURNS:         bool
+******************************************************************************/
+func isLogLevelWord(word string) bool {
+	_, ok := levelAliases[strings.ToUpper(word)]
+	return ok && len(word) > 1
+}
+
+/******************************************************************************
+* FUNCTION:        applyLevelMappings
+*
+* DESCRIPTION:     Overrides the canonical level of an entry with the user's
+*                  mapping of its raw level, if any
+* INPUT:           entry, mappings of upper-cased raw level to level
+* RETURNS:         void
+******************************************************************************/
+func applyLevelMappings(entry *LogEntry, mappings map[string]string) {
+	if len(mappings) == 0 || entry.RawLevel == "" {
+		return
+	}
+
+	if level, ok := mappings[strings.ToUpper(entry.RawLevel)]; ok {
+		entry.LogLevel = level
+		entry.Severity = levelSeverities[level]
+	}
+}
+
+/******************************************************************************
+* FUNCTION:        withLevelMappings
+*
+* DESCRIPTION:     Wraps a line parser so its entries get the user's level
+*                  mappings
+* INPUT:           line parser, mappings
+* RETURNS:         lineParseFunc
+******************************************************************************/
+func withLevelMappings(parse lineParseFunc, mappings map[string]string) lineParseFunc {
+	if len(mappings) == 0 {
+		return parse
+	}
+
+	return func(line string, fileID int64) (LogEntry, error) {
+		entry, err := parse(line, fileID)
+		if err == nil {
+			applyLevelMappings(&entry, mappings)
+		}
+		return entry, err
+	}
+}
+
+/******************************************************************************
+* FUNCTION:        getLevelMappings
+*
+* DESCRIPTION:     Returns the level mappings of a user, cached for
+*                  LEVEL_MAPPINGS_CACHE_TTL
+* INPUT:           userId
+* RETURNS:         mappings of upper-cased raw level to level, error
+******************************************************************************/
+func getLevelMappings(userId string) (map[string]string, error) {
+	if cached, ok := levelMappingsCache.get(userId); ok {
+		return cached, nil
+	}
+
+	result, err := db.GetDataFromDB("SELECT raw_level, level FROM level_mappings WHERE user_id = $1", []interface{}{userId})
+	if err != nil {
+		return nil, err
+	}
+
+	mappings := make(map[string]string, len(result))
+	for _, row := range result {
+		rawLevel, _ := row["raw_level"].(string)
+		level, _ := row["level"].(string)
+		mappings[rawLevel] = level
+	}
+
+	levelMappingsCache.set(userId, mappings)
+
+	return mappings, nil
+}
+
+/******************************************************************************
+* FUNCTION:        getLevelMappingsOrEmpty
+*
+* DESCRIPTION:     Helper function for the push inputs, which keep ingesting
+*                  with the built-in levels when the mappings cannot be
+*                  loaded
+* INPUT:           userId
+* RETURNS:         mappings
+******************************************************************************/
+func getLevelMappingsOrEmpty(userId string) map[string]string {
+	mappings, err := getLevelMappings(userId)
+	if err != nil {
+		log.Errorf("failed to load level mappings of user %s; err: %v", userId, err)
+		return nil
+	}
+	return mappings
+}
+
+/******************************************************************************
+* FUNCTION:        invalidateLevelMappings
+*
+* DESCRIPTION:     Drops the cached mappings of a user after a change
+* INPUT:           userId
+* RETURNS:         void
+******************************************************************************/
+func invalidateLevelMappings(userId string) {
+	levelMappingsCache.remove(userId)
+}
@@ -0,0 +1,222 @@
+/**************************************************************************
+ * File       	   : serviceLogfmtParser.go
+ * DESCRIPTION     : This file contains the parser of logfmt lines
+ *                   (key=value pairs, e.g. ts=... level=error msg="..."),
+ *                   with typed fields and durations kept for latency
+ *                   stats
+ * DATE            : 19-October-2026
+ **************************************************************************/
+
+package services
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	LOGFMT_UNKNOWN_LEVEL = "UNKNOWN"
+	// a line needs this many key=value pairs to be taken as logfmt
+	LOGFMT_MIN_PAIRS = 2
+)
+
+var (
+	// well-known keys, in order of preference
+	logfmtTimeKeys    = []string{"ts", "time", "timestamp", "t"}
+	logfmtLevelKeys   = []string{"level", "lvl", "severity", "log_level"}
+	logfmtMessageKeys = []string{"msg", "message"}
+	logfmtIpKeys      = []string{"ip", "client_ip", "remote_ip", "remote_addr"}
+)
+
+type logfmtPair struct {
+	Key    string
+	Value  string
+	Quoted bool
+}
+
+/******************************************************************************
+* FUNCTION:        parseLogfmtLine
+*
+* DESCRIPTION:     Parses a logfmt line. Timestamp, level, message and ip
+*                  come from the well-known keys, the remaining keys are
+*                  kept as typed attributes. Duration values (1.3s, 250ms)
+*                  are also collected in milliseconds for latency stats.
+*                  Lines without a readable timestamp are rejected
+* INPUT:           line, fileID, timestamp recognizer of the file
+* RETURNS:         LogEntry, error
+******************************************************************************/
+func parseLogfmtLine(line string, fileID int64, timestamps *timestampRecognizer) (LogEntry, error) {
+	pairs, ok := splitLogfmt(line)
+	if !ok {
+		return LogEntry{}, ErrLineNoMatch
+	}
+
+	fields := make(map[string]logfmtPair, len(pairs))
+	for _, pair := range pairs {
+		fields[pair.Key] = pair
+	}
+
+	timeKey, timeValue := logfmtField(fields, logfmtTimeKeys)
+	if timeKey == "" {
+		return LogEntry{}, ErrLineBadTimestamp
+	}
+	timestamp, ok := timestamps.parse(timeValue)
+	if !ok {
+		return LogEntry{}, ErrLineBadTimestamp
+	}
+
+	levelKey, level := logfmtField(fields, logfmtLevelKeys)
+	if level == "" {
+		level = LOGFMT_UNKNOWN_LEVEL
+	}
+	messageKey, message := logfmtField(fields, logfmtMessageKeys)
+	if messageKey == "" {
+		message = line
+	}
+
+	entry := buildLogEntry(timestamp, level, message, fileID)
+	entry.RawTimestamp = timeValue
+	if ipKey, ip := logfmtField(fields, logfmtIpKeys); ipKey != "" {
+		entry.IP = normalizeIP(ip)
+	}
+
+	attributes := make(map[string]interface{})
+	durations := make(map[string]float64)
+	for _, pair := range pairs {
+		if pair.Key == timeKey || pair.Key == levelKey || pair.Key == messageKey {
+			continue
+		}
+		value, isDuration := logfmtTypedValue(pair)
+		if isDuration {
+			durations[pair.Key] = value.(float64)
+			value = pair.Value
+		}
+		attributes[pair.Key] = value
+	}
+	if len(attributes) > 0 {
+		entry.Attributes = attributes
+	}
+	if len(durations) > 0 {
+		entry.Durations = durations
+	}
+
+	return entry, nil
+}
+
+/******************************************************************************
+* FUNCTION:        splitLogfmt
+*
+* DESCRIPTION:     Splits a line into its pairs. Values may be quoted with
+*                  Go escapes (\", \\, \n), a key without "=" is a true
+*                  flag. ok is false when the line is not logfmt
+* INPUT:           line
+* RETURNS:         []logfmtPair, ok
+******************************************************************************/
+func splitLogfmt(line string) ([]logfmtPair, bool) {
+	var pairs []logfmtPair
+	withValue := 0
+
+	i := 0
+	for i < len(line) {
+		if line[i] == ' ' || line[i] == '\t' {
+			i++
+			continue
+		}
+
+		start := i
+		for i < len(line) && line[i] != '=' && line[i] != ' ' && line[i] != '\t' {
+			if line[i] == '"' {
+				return nil, false
+			}
+			i++
+		}
+		key := line[start:i]
+		if key == "" {
+			return nil, false
+		}
+
+		if i >= len(line) || line[i] != '=' {
+			pairs = append(pairs, logfmtPair{Key: key, Value: "true"})
+			continue
+		}
+		i++
+		withValue++
+
+		if i < len(line) && line[i] == '"' {
+			end := i + 1
+			for end < len(line) && line[end] != '"' {
+				if line[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(line) {
+				return nil, false
+			}
+			value, err := strconv.Unquote(line[i : end+1])
+			if err != nil {
+				value = line[i+1 : end]
+			}
+			pairs = append(pairs, logfmtPair{Key: key, Value: value, Quoted: true})
+			i = end + 1
+			continue
+		}
+
+		start = i
+		for i < len(line) && line[i] != ' ' && line[i] != '\t' {
+			i++
+		}
+		pairs = append(pairs, logfmtPair{Key: key, Value: line[start:i]})
+	}
+
+	return pairs, withValue >= LOGFMT_MIN_PAIRS
+}
+
+/******************************************************************************
+* FUNCTION:        logfmtTypedValue
+*
+* DESCRIPTION:     Infers the type of an unquoted value: bool, integer,
+*                  float or duration, returned in milliseconds. Quoted
+*                  values and anything else stay strings
+* INPUT:           pair
+* RETURNS:         value, isDuration
+******************************************************************************/
+func logfmtTypedValue(pair logfmtPair) (interface{}, bool) {
+	if pair.Quoted {
+		return pair.Value, false
+	}
+
+	if pair.Value == "true" || pair.Value == "false" {
+		return pair.Value == "true", false
+	}
+	if n, err := strconv.ParseInt(pair.Value, 10, 64); err == nil {
+		return n, false
+	}
+	// NaN and Inf would not encode as json
+	if f, err := strconv.ParseFloat(pair.Value, 64); err == nil && !math.IsNaN(f) && !math.IsInf(f, 0) {
+		return f, false
+	}
+	if d, err := time.ParseDuration(pair.Value); err == nil {
+		return float64(d) / float64(time.Millisecond), true
+	}
+
+	return pair.Value, false
+}
+
+/******************************************************************************
+* FUNCTION:        logfmtField
+*
+* DESCRIPTION:     Helper function returning the first of the keys present
+* INPUT:           fields, keys
+* RETURNS:         key, value
+******************************************************************************/
+func logfmtField(fields map[string]logfmtPair, keys []string) (string, string) {
+	for _, key := range keys {
+		if pair, ok := fields[key]; ok {
+			return key, strings.TrimSpace(pair.Value)
+		}
+	}
+	return "", ""
+}
@@ -0,0 +1,407 @@
+/**************************************************************************
+ * File       	   : serviceMaxMindReader.go
+ * DESCRIPTION     : This file contains a reader of MaxMind DB (mmdb) files
+ *                   as used by GeoLite2/GeoIP2 and compatible databases.
+ *                   The file is read into memory, lookups walk the binary
+ *                   search tree and decode the data section
+ * DATE            : 19-October-2026
+ **************************************************************************/
+
+package services
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"net"
+	"os"
+)
+
+const (
+	MMDB_TYPE_EXTENDED = 0
+	MMDB_TYPE_POINTER  = 1
+	MMDB_TYPE_STRING   = 2
+	MMDB_TYPE_DOUBLE   = 3
+	MMDB_TYPE_BYTES    = 4
+	MMDB_TYPE_UINT16   = 5
+	MMDB_TYPE_UINT32   = 6
+	MMDB_TYPE_MAP      = 7
+	MMDB_TYPE_INT32    = 8
+	MMDB_TYPE_UINT64   = 9
+	MMDB_TYPE_UINT128  = 10
+	MMDB_TYPE_ARRAY    = 11
+	MMDB_TYPE_BOOLEAN  = 14
+	MMDB_TYPE_FLOAT    = 15
+
+	// bytes of zeros between the search tree and the data section
+	MMDB_DATA_SEPARATOR = 16
+	// nesting of maps and arrays a record may have
+	MMDB_MAX_DEPTH = 32
+)
+
+var (
+	ErrInvalidMMDB = errors.New("invalid MaxMind DB file")
+
+	mmdbMetadataMarker = []byte("\xAB\xCD\xEFMaxMind.com")
+)
+
+// mmdbReader looks up ip addresses in a MaxMind DB file
+type mmdbReader struct {
+	tree         []byte
+	data         mmdbDecoder
+	nodeCount    uint
+	recordSize   uint
+	ipVersion    uint
+	ipv4Start    uint
+	DatabaseType string
+	BuildEpoch   uint64
+}
+
+// mmdbDecoder decodes values of a data section or of the metadata
+type mmdbDecoder struct {
+	buf []byte
+}
+
+/******************************************************************************
+* FUNCTION:        openMMDB
+*
+* DESCRIPTION:     Reads a MaxMind DB file and its metadata
+* INPUT:           path
+* RETURNS:         *mmdbReader, error
+******************************************************************************/
+func openMMDB(path string) (*mmdbReader, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseMMDB(buf)
+}
+
+/******************************************************************************
+* FUNCTION:        parseMMDB
+*
+* DESCRIPTION:     Reads the metadata and the layout of a MaxMind DB held
+*                  in memory
+* INPUT:           buf
+* RETURNS:         *mmdbReader, error
+******************************************************************************/
+func parseMMDB(buf []byte) (*mmdbReader, error) {
+	markerAt := bytes.LastIndex(buf, mmdbMetadataMarker)
+	if markerAt < 0 {
+		return nil, fmt.Errorf("%w: metadata not found", ErrInvalidMMDB)
+	}
+
+	metaValue, _, err := mmdbDecoder{buf: buf[markerAt+len(mmdbMetadataMarker):]}.decode(0, 0)
+	if err != nil {
+		return nil, fmt.Errorf("%w: metadata: %v", ErrInvalidMMDB, err)
+	}
+	meta, ok := metaValue.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%w: metadata is not a map", ErrInvalidMMDB)
+	}
+
+	r := &mmdbReader{
+		nodeCount:  uint(mmdbUint(meta["node_count"])),
+		recordSize: uint(mmdbUint(meta["record_size"])),
+		ipVersion:  uint(mmdbUint(meta["ip_version"])),
+		BuildEpoch: mmdbUint(meta["build_epoch"]),
+	}
+	r.DatabaseType, _ = meta["database_type"].(string)
+
+	if r.recordSize != 24 && r.recordSize != 28 && r.recordSize != 32 {
+		return nil, fmt.Errorf("%w: record size %d", ErrInvalidMMDB, r.recordSize)
+	}
+	if r.ipVersion != 4 && r.ipVersion != 6 {
+		return nil, fmt.Errorf("%w: ip version %d", ErrInvalidMMDB, r.ipVersion)
+	}
+
+	// a node takes 6 bytes at least, checked first so that a corrupted
+	// count cannot overflow the tree size
+	if r.nodeCount > uint(markerAt)/6 {
+		return nil, fmt.Errorf("%w: search tree larger than the file", ErrInvalidMMDB)
+	}
+	treeSize := r.nodeCount * r.recordSize / 4
+	if treeSize+MMDB_DATA_SEPARATOR > uint(markerAt) {
+		return nil, fmt.Errorf("%w: search tree larger than the file", ErrInvalidMMDB)
+	}
+	r.tree = buf[:treeSize]
+	r.data = mmdbDecoder{buf: buf[treeSize+MMDB_DATA_SEPARATOR : markerAt]}
+
+	// ipv4 addresses of an ipv6 tree are under ::/96
+	if r.ipVersion == 6 {
+		for i := 0; i < 96 && r.ipv4Start < r.nodeCount; i++ {
+			r.ipv4Start = r.readRecord(r.ipv4Start, 0)
+		}
+	}
+
+	return r, nil
+}
+
+/******************************************************************************
+* FUNCTION:        readRecord
+*
+* DESCRIPTION:     Helper function reading the left (0) or right (1) record
+*                  of a node of the search tree
+* INPUT:           node, bit
+* RETURNS:         record value
+******************************************************************************/
+func (r *mmdbReader) readRecord(node, bit uint) uint {
+	switch r.recordSize {
+	case 24:
+		b := r.tree[node*6+bit*3:]
+		return uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+	case 28:
+		b := r.tree[node*7:]
+		if bit == 0 {
+			return uint(b[3]&0xF0)<<20 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+		}
+		return uint(b[3]&0x0F)<<24 | uint(b[4])<<16 | uint(b[5])<<8 | uint(b[6])
+	default:
+		return uint(binary.BigEndian.Uint32(r.tree[node*8+bit*4:]))
+	}
+}
+
+/******************************************************************************
+* FUNCTION:        lookup
+*
+* DESCRIPTION:     Returns the record of an ip address, false when the
+*                  database has none
+* INPUT:           ip
+* RETURNS:         record, found, error
+******************************************************************************/
+func (r *mmdbReader) lookup(ip net.IP) (map[string]interface{}, bool, error) {
+	address := ip.To4()
+	node := uint(0)
+	if address != nil && r.ipVersion == 6 {
+		node = r.ipv4Start
+	}
+	if address == nil {
+		if r.ipVersion == 4 {
+			return nil, false, nil
+		}
+		address = ip.To16()
+	}
+	if address == nil {
+		return nil, false, nil
+	}
+
+	for i := 0; i < len(address)*8 && node < r.nodeCount; i++ {
+		bit := uint(address[i/8]>>(7-uint(i%8))) & 1
+		node = r.readRecord(node, bit)
+	}
+	if node <= r.nodeCount {
+		return nil, false, nil
+	}
+
+	value, _, err := r.data.decode(node-r.nodeCount-MMDB_DATA_SEPARATOR, 0)
+	if err != nil {
+		return nil, false, err
+	}
+	record, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, false, fmt.Errorf("%w: record is not a map", ErrInvalidMMDB)
+	}
+	return record, true, nil
+}
+
+/******************************************************************************
+* FUNCTION:        decode
+*
+* DESCRIPTION:     Decodes the value at an offset. Maps become
+*                  map[string]interface{}, arrays []interface{}, unsigned
+*                  integers uint64 (uint128 *big.Int), int32 int64 and
+*                  floating point values float64
+* INPUT:           offset, nesting depth
+* RETURNS:         value, offset after the value, error
+******************************************************************************/
+func (d mmdbDecoder) decode(offset uint, depth int) (interface{}, uint, error) {
+	if depth > MMDB_MAX_DEPTH {
+		return nil, 0, fmt.Errorf("%w: nesting too deep", ErrInvalidMMDB)
+	}
+	if offset >= uint(len(d.buf)) {
+		return nil, 0, fmt.Errorf("%w: offset %d out of range", ErrInvalidMMDB, offset)
+	}
+
+	ctrl := d.buf[offset]
+	offset++
+	kind := uint(ctrl >> 5)
+
+	if kind == MMDB_TYPE_POINTER {
+		target, next, err := d.pointer(ctrl, offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		value, _, err := d.decode(target, depth+1)
+		return value, next, err
+	}
+
+	if kind == MMDB_TYPE_EXTENDED {
+		if offset >= uint(len(d.buf)) {
+			return nil, 0, fmt.Errorf("%w: truncated type", ErrInvalidMMDB)
+		}
+		kind = 7 + uint(d.buf[offset])
+		offset++
+	}
+
+	size := uint(ctrl & 0x1f)
+	if size >= 29 {
+		n := size - 28
+		extra, err := d.bytes(offset, n)
+		if err != nil {
+			return nil, 0, err
+		}
+		offset += n
+		size = []uint{29, 285, 65821}[n-1] + uint(mmdbBigEndian(extra))
+	}
+
+	// every entry takes a byte at least, a corrupted size must not
+	// allocate more than the data could hold
+	if (kind == MMDB_TYPE_MAP || kind == MMDB_TYPE_ARRAY) && size > uint(len(d.buf))-offset {
+		return nil, 0, fmt.Errorf("%w: %d entries past the end", ErrInvalidMMDB, size)
+	}
+
+	switch kind {
+	case MMDB_TYPE_MAP:
+		m := make(map[string]interface{}, size)
+		for i := uint(0); i < size; i++ {
+			key, next, err := d.decode(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			name, ok := key.(string)
+			if !ok {
+				return nil, 0, fmt.Errorf("%w: map key is not a string", ErrInvalidMMDB)
+			}
+			value, after, err := d.decode(next, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			m[name] = value
+			offset = after
+		}
+		return m, offset, nil
+	case MMDB_TYPE_ARRAY:
+		a := make([]interface{}, 0, size)
+		for i := uint(0); i < size; i++ {
+			value, next, err := d.decode(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			a = append(a, value)
+			offset = next
+		}
+		return a, offset, nil
+	case MMDB_TYPE_BOOLEAN:
+		return size != 0, offset, nil
+	}
+
+	b, err := d.bytes(offset, size)
+	if err != nil {
+		return nil, 0, err
+	}
+	offset += size
+
+	switch kind {
+	case MMDB_TYPE_STRING:
+		return string(b), offset, nil
+	case MMDB_TYPE_BYTES:
+		return append([]byte(nil), b...), offset, nil
+	case MMDB_TYPE_DOUBLE:
+		if size != 8 {
+			return nil, 0, fmt.Errorf("%w: double of %d bytes", ErrInvalidMMDB, size)
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), offset, nil
+	case MMDB_TYPE_FLOAT:
+		if size != 4 {
+			return nil, 0, fmt.Errorf("%w: float of %d bytes", ErrInvalidMMDB, size)
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), offset, nil
+	case MMDB_TYPE_UINT16, MMDB_TYPE_UINT32, MMDB_TYPE_UINT64:
+		if size > 8 {
+			return nil, 0, fmt.Errorf("%w: integer of %d bytes", ErrInvalidMMDB, size)
+		}
+		return mmdbBigEndian(b), offset, nil
+	case MMDB_TYPE_INT32:
+		if size > 4 {
+			return nil, 0, fmt.Errorf("%w: int32 of %d bytes", ErrInvalidMMDB, size)
+		}
+		return int64(int32(uint32(mmdbBigEndian(b)))), offset, nil
+	case MMDB_TYPE_UINT128:
+		return new(big.Int).SetBytes(b), offset, nil
+	}
+
+	return nil, 0, fmt.Errorf("%w: unknown type %d", ErrInvalidMMDB, kind)
+}
+
+/******************************************************************************
+* FUNCTION:        pointer
+*
+* DESCRIPTION:     Helper function reading the target of a pointer, the
+*                  size bits of the control byte give its length
+* INPUT:           control byte, offset after it
+* RETURNS:         target offset, offset after the pointer, error
+******************************************************************************/
+func (d mmdbDecoder) pointer(ctrl byte, offset uint) (uint, uint, error) {
+	length := uint((ctrl>>3)&0x3) + 1
+	b, err := d.bytes(offset, length)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	high := uint(ctrl & 0x7)
+	value := uint(mmdbBigEndian(b))
+	switch length {
+	case 1:
+		value |= high << 8
+	case 2:
+		value = (value | high<<16) + 2048
+	case 3:
+		value = (value | high<<24) + 526336
+	}
+	return value, offset + length, nil
+}
+
+/******************************************************************************
+* FUNCTION:        bytes
+*
+* DESCRIPTION:     Helper function returning n bytes at an offset
+* INPUT:           offset, n
+* RETURNS:         []byte, error
+******************************************************************************/
+func (d mmdbDecoder) bytes(offset, n uint) ([]byte, error) {
+	if offset+n > uint(len(d.buf)) {
+		return nil, fmt.Errorf("%w: value past the end", ErrInvalidMMDB)
+	}
+	return d.buf[offset : offset+n], nil
+}
+
+/******************************************************************************
+* FUNCTION:        mmdbBigEndian
+*
+* DESCRIPTION:     Helper function reading up to 8 big endian bytes
+* INPUT:           bytes
+* RETURNS:         uint64
+******************************************************************************/
+func mmdbBigEndian(b []byte) uint64 {
+	var value uint64
+	for _, c := range b {
+		value = value<<8 | uint64(c)
+	}
+	return value
+}
+
+/******************************************************************************
+* FUNCTION:        mmdbUint
+*
+* DESCRIPTION:     Helper function reading an unsigned value of a record, 0
+*                  when absent
+* INPUT:           value
+* RETURNS:         uint64
+******************************************************************************/
+func mmdbUint(value interface{}) uint64 {
+	n, _ := value.(uint64)
+	return n
+}
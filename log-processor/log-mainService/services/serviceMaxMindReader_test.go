@@ -0,0 +1,326 @@
+package services
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// mmdbFixture writes values in the MaxMind DB data format
+type mmdbFixture struct {
+	bytes.Buffer
+}
+
+func (f *mmdbFixture) ctrl(kind, size int) {
+	if kind > 7 {
+		f.WriteByte(byte(size))
+		f.WriteByte(byte(kind - 7))
+		return
+	}
+	f.WriteByte(byte(kind<<5 | size))
+}
+
+func (f *mmdbFixture) value(v interface{}) {
+	switch v := v.(type) {
+	case string:
+		f.ctrl(MMDB_TYPE_STRING, len(v))
+		f.WriteString(v)
+	case uint32:
+		b := make([]byte, 4)
+		binary.BigEndian.PutUint32(b, v)
+		f.ctrl(MMDB_TYPE_UINT32, 4)
+		f.Write(b)
+	case uint64:
+		b := make([]byte, 8)
+		binary.BigEndian.PutUint64(b, v)
+		f.ctrl(MMDB_TYPE_UINT64, 8)
+		f.Write(b)
+	case int32:
+		b := make([]byte, 4)
+		binary.BigEndian.PutUint32(b, uint32(v))
+		f.ctrl(MMDB_TYPE_INT32, 4)
+		f.Write(b)
+	case float64:
+		b := make([]byte, 8)
+		binary.BigEndian.PutUint64(b, math.Float64bits(v))
+		f.ctrl(MMDB_TYPE_DOUBLE, 8)
+		f.Write(b)
+	case bool:
+		size := 0
+		if v {
+			size = 1
+		}
+		f.ctrl(MMDB_TYPE_BOOLEAN, size)
+	case []interface{}:
+		f.ctrl(MMDB_TYPE_ARRAY, len(v))
+		for _, item := range v {
+			f.value(item)
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		f.ctrl(MMDB_TYPE_MAP, len(keys))
+		for _, key := range keys {
+			f.value(key)
+			f.value(v[key])
+		}
+	default:
+		panic("unsupported fixture value")
+	}
+}
+
+// buildMMDB returns a database with record size 24 holding one record
+// under the network whose leading bits are path
+func buildMMDB(ipVersion int, path []int, record map[string]interface{}) []byte {
+	nodeCount := len(path)
+	var file bytes.Buffer
+	for node, bit := range path {
+		next := node + 1
+		if node == len(path)-1 {
+			// the record is the first value of the data section
+			next = nodeCount + MMDB_DATA_SEPARATOR
+		}
+		records := [2]int{nodeCount, nodeCount}
+		records[bit] = next
+		for _, r := range records {
+			file.Write([]byte{byte(r >> 16), byte(r >> 8), byte(r)})
+		}
+	}
+	file.Write(make([]byte, MMDB_DATA_SEPARATOR))
+
+	data := &mmdbFixture{}
+	data.value(record)
+	file.Write(data.Bytes())
+
+	meta := &mmdbFixture{}
+	meta.value(map[string]interface{}{
+		"node_count":    uint32(nodeCount),
+		"record_size":   uint32(24),
+		"ip_version":    uint32(ipVersion),
+		"database_type": "Test-City",
+		"build_epoch":   uint64(1700000000),
+	})
+	file.Write(mmdbMetadataMarker)
+	file.Write(meta.Bytes())
+
+	return file.Bytes()
+}
+
+func prefixPath(ip net.IP, bits int) []int {
+	path := make([]int, bits)
+	for i := range path {
+		path[i] = int(ip[i/8]>>(7-uint(i%8))) & 1
+	}
+	return path
+}
+
+func writeMMDB(t *testing.T, buf []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "test.mmdb")
+	if err := os.WriteFile(path, buf, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+var mmdbTestRecord = map[string]interface{}{
+	"country":  map[string]interface{}{"iso_code": "DE"},
+	"city":     map[string]interface{}{"names": map[string]interface{}{"en": "Berlin"}},
+	"location": map[string]interface{}{"latitude": 52.52, "longitude": 13.405},
+	"asn":      uint32(64500),
+	"offset":   int32(-7),
+	"subnets":  []interface{}{"a", "b"},
+	"is_proxy": true,
+}
+
+func mmdbTestDatabases() map[string][]byte {
+	ipv4 := net.ParseIP("1.2.3.0").To4()
+	return map[string][]byte{
+		"ipv4": buildMMDB(4, prefixPath(ipv4, 24), mmdbTestRecord),
+		// ipv4 addresses of an ipv6 database are under ::/96
+		"ipv6": buildMMDB(6, append(make([]int, 96), prefixPath(ipv4, 24)...), mmdbTestRecord),
+	}
+}
+
+func TestMMDBLookup(t *testing.T) {
+	want := map[string]interface{}{
+		"country":  map[string]interface{}{"iso_code": "DE"},
+		"city":     map[string]interface{}{"names": map[string]interface{}{"en": "Berlin"}},
+		"location": map[string]interface{}{"latitude": 52.52, "longitude": 13.405},
+		"asn":      uint64(64500),
+		"offset":   int64(-7),
+		"subnets":  []interface{}{"a", "b"},
+		"is_proxy": true,
+	}
+
+	for name, buf := range mmdbTestDatabases() {
+		t.Run(name, func(t *testing.T) {
+			r, err := openMMDB(writeMMDB(t, buf))
+			if err != nil {
+				t.Fatalf("openMMDB: %v", err)
+			}
+			if r.DatabaseType != "Test-City" || r.BuildEpoch != 1700000000 {
+				t.Errorf("metadata = %q %d", r.DatabaseType, r.BuildEpoch)
+			}
+
+			tests := []struct {
+				ip    string
+				found bool
+			}{
+				{"1.2.3.4", true},
+				{"1.2.3.255", true},
+				{"1.2.4.1", false},
+				{"10.0.0.1", false},
+				{"2001:db8::1", false},
+			}
+			for _, tt := range tests {
+				record, found, err := r.lookup(net.ParseIP(tt.ip))
+				if err != nil {
+					t.Fatalf("lookup(%s): %v", tt.ip, err)
+				}
+				if found != tt.found {
+					t.Fatalf("lookup(%s) found = %v, want %v", tt.ip, found, tt.found)
+				}
+				if found && !reflect.DeepEqual(record, want) {
+					t.Errorf("lookup(%s) = %v, want %v", tt.ip, record, want)
+				}
+			}
+		})
+	}
+}
+
+func TestMMDBTruncated(t *testing.T) {
+	for name, buf := range mmdbTestDatabases() {
+		t.Run(name, func(t *testing.T) {
+			for n := 0; n < len(buf); n++ {
+				r, err := parseMMDB(buf[:n])
+				if err != nil {
+					if !errors.Is(err, ErrInvalidMMDB) {
+						t.Fatalf("parseMMDB of %d bytes: %v, want ErrInvalidMMDB", n, err)
+					}
+					continue
+				}
+				if _, _, err := r.lookup(net.ParseIP("1.2.3.4")); err != nil && !errors.Is(err, ErrInvalidMMDB) {
+					t.Fatalf("lookup in %d bytes: %v, want ErrInvalidMMDB", n, err)
+				}
+			}
+		})
+	}
+}
+
+func TestMMDBCorrupted(t *testing.T) {
+	for name, buf := range mmdbTestDatabases() {
+		t.Run(name, func(t *testing.T) {
+			for i := range buf {
+				for _, value := range []byte{0x00, 0xFF, buf[i] ^ 0x80, buf[i] ^ 0x1F} {
+					corrupted := append([]byte(nil), buf...)
+					corrupted[i] = value
+
+					r, err := parseMMDB(corrupted)
+					if err != nil {
+						continue
+					}
+					for _, ip := range []string{"1.2.3.4", "0.0.0.0", "255.255.255.255", "::1"} {
+						r.lookup(net.ParseIP(ip))
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestMMDBDecodeInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		buf  []byte
+	}{
+		{"empty", nil},
+		{"pointer to itself", []byte{MMDB_TYPE_POINTER << 5, 0x00}},
+		{"truncated pointer", []byte{MMDB_TYPE_POINTER<<5 | 0x18}},
+		{"string past the end", []byte{MMDB_TYPE_STRING<<5 | 10, 'a'}},
+		{"truncated size", []byte{MMDB_TYPE_STRING<<5 | 30, 0x01}},
+		{"huge map", []byte{MMDB_TYPE_MAP<<5 | 31, 0xFF, 0xFF, 0xFF}},
+		{"huge array", []byte{MMDB_TYPE_EXTENDED<<5 | 31, MMDB_TYPE_ARRAY - 7, 0xFF, 0xFF, 0xFF}},
+		{"map key not a string", []byte{MMDB_TYPE_MAP<<5 | 1, MMDB_TYPE_EXTENDED<<5 | 0, MMDB_TYPE_BOOLEAN - 7, MMDB_TYPE_STRING << 5}},
+		{"double of 4 bytes", []byte{MMDB_TYPE_DOUBLE<<5 | 4, 0, 0, 0, 0}},
+		{"int32 of 5 bytes", []byte{MMDB_TYPE_EXTENDED<<5 | 5, MMDB_TYPE_INT32 - 7, 0, 0, 0, 0, 0}},
+		{"truncated extended type", []byte{MMDB_TYPE_EXTENDED << 5}},
+		{"unknown type", []byte{MMDB_TYPE_EXTENDED << 5, 0x20}},
+	}
+
+	nested := &mmdbFixture{}
+	for i := 0; i <= MMDB_MAX_DEPTH+1; i++ {
+		nested.ctrl(MMDB_TYPE_ARRAY, 1)
+	}
+	nested.value("x")
+	tests = append(tests, struct {
+		name string
+		buf  []byte
+	}{"nesting too deep", nested.Bytes()})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := (mmdbDecoder{buf: tt.buf}).decode(0, 0); !errors.Is(err, ErrInvalidMMDB) {
+				t.Errorf("decode = %v, want ErrInvalidMMDB", err)
+			}
+		})
+	}
+}
+
+func TestMMDBDecodePointer(t *testing.T) {
+	data := &mmdbFixture{}
+	data.value("shared")
+	// an array of two pointers to offset 0
+	data.ctrl(MMDB_TYPE_ARRAY, 2)
+	data.Write([]byte{MMDB_TYPE_POINTER << 5, 0x00, MMDB_TYPE_POINTER << 5, 0x00})
+
+	value, next, err := (mmdbDecoder{buf: data.Bytes()}).decode(7, 0)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if want := []interface{}{"shared", "shared"}; !reflect.DeepEqual(value, want) {
+		t.Errorf("decode = %v, want %v", value, want)
+	}
+	if next != uint(data.Len()) {
+		t.Errorf("next = %d, want %d", next, data.Len())
+	}
+}
+
+func TestMMDBInvalidMetadata(t *testing.T) {
+	tests := []struct {
+		name string
+		meta map[string]interface{}
+	}{
+		{"record size", map[string]interface{}{"node_count": uint32(1), "record_size": uint32(20), "ip_version": uint32(4)}},
+		{"ip version", map[string]interface{}{"node_count": uint32(1), "record_size": uint32(24), "ip_version": uint32(5)}},
+		{"tree larger than the file", map[string]interface{}{"node_count": uint32(1000), "record_size": uint32(24), "ip_version": uint32(4)}},
+		{"overflowing node count", map[string]interface{}{"node_count": uint64(1 << 62), "record_size": uint32(24), "ip_version": uint32(6)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			meta := &mmdbFixture{}
+			meta.value(tt.meta)
+			buf := append(make([]byte, 64), mmdbMetadataMarker...)
+			buf = append(buf, meta.Bytes()...)
+
+			if _, err := parseMMDB(buf); !errors.Is(err, ErrInvalidMMDB) {
+				t.Errorf("parseMMDB = %v, want ErrInvalidMMDB", err)
+			}
+		})
+	}
+
+	if _, err := parseMMDB([]byte("not a database")); !errors.Is(err, ErrInvalidMMDB) {
+		t.Errorf("parseMMDB without metadata = %v, want ErrInvalidMMDB", err)
+	}
+}
@@ -0,0 +1,498 @@
+/**************************************************************************
+ * File       	   : serviceRedaction.go
+ * DESCRIPTION     : This file contains the PII redaction applied between
+ *                   parsing and insert: the built-in detectors, the user
+ *                   defined redaction rules and the mask, hash and drop
+ *                   actions
+ * DATE            : 19-October-2026
+ **************************************************************************/
+
+package services
+
+import (
+	"LOGProcessor/shared/db"
+	"LOGProcessor/shared/types"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/netip"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/martian/log"
+)
+
+const (
+	REDACT_ACTION_MASK = "mask"
+	REDACT_ACTION_HASH = "hash"
+	REDACT_ACTION_DROP = "drop"
+	// off disables a built-in detector for a user
+	REDACT_ACTION_OFF = "off"
+
+	// user rules are reloaded after this long, so changes made through the
+	// api reach the task service as well
+	REDACTION_RULES_CACHE_TTL = time.Minute
+	// hex characters of the keyed hash kept in the replacement
+	REDACT_HASH_HEX_LEN = 16
+)
+
+// piiDetector finds one kind of value. Group is the submatch replaced, 0
+// for the whole match, Validate drops false positives of the regex
+type piiDetector struct {
+	Name     string
+	Regex    *regexp.Regexp
+	Group    int
+	Validate func(value string) bool
+}
+
+// built-in detectors in the order they run, jwt before bearer_token so a
+// bearer jwt counts as a jwt
+var builtinDetectors = []piiDetector{
+	{Name: "email", Regex: regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,}`)},
+	{Name: "jwt", Regex: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)},
+	{Name: "bearer_token", Regex: regexp.MustCompile(`(?i)\bbearer\s+([A-Za-z0-9._~+/-]{8,}=*)`), Group: 1},
+	{Name: "aws_access_key", Regex: regexp.MustCompile(`\b(?:AKIA|ASIA|AIDA|AROA|AGPA|ANPA|ANVA|AIPA)[A-Z0-9]{16}\b`)},
+	{Name: "aws_secret_key", Regex: regexp.MustCompile(`(?i)aws_?secret_?(?:access_?)?key["']?\s*[:=]\s*["']?([A-Za-z0-9/+=]{40})\b`), Group: 1},
+	// visa, mastercard, amex, discover, jcb and diners prefixes, so epoch
+	// times and ids that pass the luhn check are left alone
+	{Name: "credit_card", Regex: regexp.MustCompile(`\b(?:4|5[1-5]|2[2-7]|3[47]|3[0568]|6[05])(?:[ -]?\d){11,17}\b`), Validate: luhnValid},
+	{Name: "phone", Regex: regexp.MustCompile(`(?:\+\d{1,3}[ .-]?)?(?:\(\d{2,4}\)|\b\d{2,4})[ .-]?\d{3,4}[ .-]?\d{4}\b`)},
+	{Name: "ipv4", Regex: regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b`), Validate: func(value string) bool { return net.ParseIP(value) != nil }},
+	// not preceded by a word character, so "std::vector" is no "d::"
+	{Name: "ipv6", Regex: regexp.MustCompile(`(?i)(?:^|[^0-9a-z:.])((?:[0-9a-f]{0,4}:){2,7}[0-9a-f]{0,4})`), Group: 1, Validate: func(value string) bool {
+		ip := net.ParseIP(value)
+		return ip != nil && ip.To4() == nil
+	}},
+}
+
+var redactorCache = newBoundedCache[string, *redactor](REDACTION_RULES_CACHE_TTL, CACHE_MAX_USERS)
+
+// redactionRule is a detector with the action it takes
+type redactionRule struct {
+	piiDetector
+	Action string
+}
+
+// redactor applies the redaction rules of a user
+type redactor struct {
+	rules   []redactionRule
+	hashKey []byte
+}
+
+// RedactionCounts counts the redacted values per rule
+type RedactionCounts map[string]int64
+
+/******************************************************************************
+* FUNCTION:        IsRedactionDetector
+*
+* DESCRIPTION:     Tells whether a name is a built-in detector
+* INPUT:           name
+* RETURNS:         bool
+******************************************************************************/
+func IsRedactionDetector(name string) bool {
+	_, ok := builtinDetector(name)
+	return ok
+}
+
+/******************************************************************************
+* FUNCTION:        builtinDetector
+*
+* DESCRIPTION:     Helper function returning a built-in detector by name
+* INPUT:           name
+* RETURNS:         piiDetector, ok
+******************************************************************************/
+func builtinDetector(name string) (piiDetector, bool) {
+	for _, detector := range builtinDetectors {
+		if detector.Name == name {
+			return detector, true
+		}
+	}
+	return piiDetector{}, false
+}
+
+/******************************************************************************
+* FUNCTION:        isRedactAction
+*
+* DESCRIPTION:     Helper function validating an action of a rule
+* INPUT:           action
+* RETURNS:         bool
+******************************************************************************/
+func isRedactAction(action string) bool {
+	switch action {
+	case REDACT_ACTION_MASK, REDACT_ACTION_DROP:
+		return true
+	case REDACT_ACTION_HASH:
+		return types.RedactionCfg.HashKey != ""
+	}
+	return false
+}
+
+/******************************************************************************
+* FUNCTION:        luhnValid
+*
+* DESCRIPTION:     Helper function checking the luhn checksum of a card
+*                  number, separators ignored
+* INPUT:           value
+* RETURNS:         bool
+******************************************************************************/
+func luhnValid(value string) bool {
+	var sum, digits int
+	double := false
+	for i := len(value) - 1; i >= 0; i-- {
+		c := value[i]
+		if c == ' ' || c == '-' {
+			continue
+		}
+		d := int(c - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		digits++
+		double = !double
+	}
+	return digits >= 13 && digits <= 19 && sum%10 == 0
+}
+
+/******************************************************************************
+* FUNCTION:        newRedactor
+*
+* DESCRIPTION:     Builds the rules of a user: the built-in detectors
+*                  enabled by REDACTION_DETECTORS with REDACTION_ACTION,
+*                  overridden or enabled by the user's rules of the same
+*                  name, followed by the user's regex rules
+* INPUT:           user rules (name, pattern, action)
+* RETURNS:         *redactor
+******************************************************************************/
+func newRedactor(userRules []map[string]interface{}) *redactor {
+	actions := make(map[string]string)
+	for _, name := range types.RedactionCfg.Detectors {
+		actions[name] = types.RedactionCfg.Action
+	}
+
+	var custom []redactionRule
+	for _, row := range userRules {
+		name, _ := row["name"].(string)
+		pattern, _ := row["pattern"].(string)
+		action, _ := row["action"].(string)
+		if action == REDACT_ACTION_HASH && types.RedactionCfg.HashKey == "" {
+			action = REDACT_ACTION_MASK
+		}
+
+		if pattern == "" {
+			actions[name] = action
+			continue
+		}
+		regex, err := regexp.Compile(pattern)
+		if err != nil || regex.MatchString("") {
+			continue
+		}
+		custom = append(custom, redactionRule{piiDetector: piiDetector{Name: name, Regex: regex}, Action: action})
+	}
+
+	r := &redactor{hashKey: []byte(types.RedactionCfg.HashKey)}
+	for _, detector := range builtinDetectors {
+		if action, ok := actions[detector.Name]; ok && action != REDACT_ACTION_OFF {
+			r.rules = append(r.rules, redactionRule{piiDetector: detector, Action: action})
+		}
+	}
+	r.rules = append(r.rules, custom...)
+
+	return r
+}
+
+/******************************************************************************
+* FUNCTION:        redact
+*
+* DESCRIPTION:     Applies the rules to a text. Masked values become
+*                  [REDACTED:<rule>], hashed ones [<rule>:<keyed hash>] so
+*                  equal values stay joinable, dropped ones are removed
+* INPUT:           text, counts to add to
+* RETURNS:         redacted text
+******************************************************************************/
+func (r *redactor) redact(text string, counts RedactionCounts) string {
+	for _, rule := range r.rules {
+		matches := rule.Regex.FindAllStringSubmatchIndex(text, -1)
+		if len(matches) == 0 {
+			continue
+		}
+
+		var b strings.Builder
+		last := 0
+		for _, match := range matches {
+			start, end := match[2*rule.Group], match[2*rule.Group+1]
+			// an empty match has nothing to redact
+			if start < 0 || start < last || start == end {
+				continue
+			}
+			value := text[start:end]
+			if rule.Validate != nil && !rule.Validate(value) {
+				continue
+			}
+			b.WriteString(text[last:start])
+			b.WriteString(r.replacement(rule, value))
+			last = end
+			counts[rule.Name]++
+		}
+		if last > 0 {
+			b.WriteString(text[last:])
+			text = b.String()
+		}
+	}
+	return text
+}
+
+/******************************************************************************
+* FUNCTION:        replacement
+*
+* DESCRIPTION:     Helper function returning what a value is replaced with
+* INPUT:           rule, value
+* RETURNS:         string
+******************************************************************************/
+func (r *redactor) replacement(rule redactionRule, value string) string {
+	switch rule.Action {
+	case REDACT_ACTION_HASH:
+		mac := hmac.New(sha256.New, r.hashKey)
+		mac.Write([]byte(value))
+		return fmt.Sprintf("[%s:%s]", rule.Name, hex.EncodeToString(mac.Sum(nil))[:REDACT_HASH_HEX_LEN])
+	case REDACT_ACTION_DROP:
+		return ""
+	}
+	return fmt.Sprintf("[REDACTED:%s]", rule.Name)
+}
+
+/******************************************************************************
+* FUNCTION:        redactValue
+*
+* DESCRIPTION:     Helper function redacting the strings of an attribute
+*                  value, nested objects and arrays included
+* INPUT:           value, counts
+* RETURNS:         redacted value
+******************************************************************************/
+func (r *redactor) redactValue(value interface{}, counts RedactionCounts) interface{} {
+	switch v := value.(type) {
+	case string:
+		return r.redact(v, counts)
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = r.redactValue(item, counts)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = r.redactValue(item, counts)
+		}
+	}
+	return value
+}
+
+/******************************************************************************
+* FUNCTION:        redactEntries
+*
+* DESCRIPTION:     Redacts the message, attributes and ip of entries in
+*                  place
+* INPUT:           entries, counts
+* RETURNS:         void
+******************************************************************************/
+func (r *redactor) redactEntries(entries []LogEntry, counts RedactionCounts) {
+	if len(r.rules) == 0 {
+		return
+	}
+
+	for i := range entries {
+		entries[i].Message = r.redact(entries[i].Message, counts)
+		for key, value := range entries[i].Attributes {
+			entries[i].Attributes[key] = r.redactValue(value, counts)
+		}
+		if entries[i].IP != "" {
+			r.redactIP(&entries[i])
+		}
+	}
+}
+
+/******************************************************************************
+* FUNCTION:        redactIP
+*
+* DESCRIPTION:     Helper function redacting the ip of an entry when a rule
+*                  matches it. The ip column is an inet, so it is cleared
+*                  and a hash is kept in the "ip" attribute instead. Mask
+*                  and drop clear the GeoIP and ASN fields as well, and a
+*                  threat indicator that was the ip or its network is
+*                  replaced so the entry stays flagged. The value is
+*                  counted with the message or attribute it came from
+* INPUT:           entry
+* RETURNS:         void
+******************************************************************************/
+func (r *redactor) redactIP(entry *LogEntry) {
+	rule, ok := r.matchingRule(entry.IP)
+	if !ok {
+		return
+	}
+
+	replacement := r.replacement(rule, entry.IP)
+	if rule.Action == REDACT_ACTION_HASH {
+		if entry.Attributes == nil {
+			entry.Attributes = make(map[string]interface{})
+		}
+		entry.Attributes["ip"] = replacement
+	} else {
+		entry.GeoCountry = ""
+		entry.GeoCity = ""
+		entry.ASN = 0
+		entry.ASOrg = ""
+		replacement = fmt.Sprintf("[REDACTED:%s]", rule.Name)
+	}
+
+	if entry.ThreatIndicator != "" {
+		_, addrErr := netip.ParseAddr(entry.ThreatIndicator)
+		_, prefixErr := netip.ParsePrefix(entry.ThreatIndicator)
+		if addrErr == nil || prefixErr == nil {
+			entry.ThreatIndicator = replacement
+		}
+	}
+	entry.IP = ""
+}
+
+/******************************************************************************
+* FUNCTION:        matchingRule
+*
+* DESCRIPTION:     Helper function returning the first rule finding a value
+*                  in the text
+* INPUT:           text
+* RETURNS:         rule, found
+******************************************************************************/
+func (r *redactor) matchingRule(text string) (redactionRule, bool) {
+	for _, rule := range r.rules {
+		for _, match := range rule.Regex.FindAllStringSubmatchIndex(text, -1) {
+			start, end := match[2*rule.Group], match[2*rule.Group+1]
+			if start < 0 {
+				continue
+			}
+			if rule.Validate == nil || rule.Validate(text[start:end]) {
+				return rule, true
+			}
+		}
+	}
+	return redactionRule{}, false
+}
+
+/******************************************************************************
+* FUNCTION:        redactLogStats
+*
+* DESCRIPTION:     Redacts everything of a processed file that is stored:
+*                  the entries, the spilled oversize lines and the rejected
+*                  line samples
+* INPUT:           stats, redactor
+* RETURNS:         RedactionCounts
+******************************************************************************/
+func redactLogStats(stats *LogStats, r *redactor) RedactionCounts {
+	counts := make(RedactionCounts)
+	if len(r.rules) == 0 {
+		return counts
+	}
+
+	r.redactEntries(stats.LogEntries, counts)
+	for i := range stats.Spilled {
+		stats.Spilled[i].Content = r.redact(stats.Spilled[i].Content, counts)
+	}
+	if stats.Lines != nil {
+		for i := range stats.Lines.Samples {
+			stats.Lines.Samples[i].Line = r.redact(stats.Lines.Samples[i].Line, counts)
+		}
+	}
+
+	return counts
+}
+
+/******************************************************************************
+* FUNCTION:        getRedactor
+*
+* DESCRIPTION:     Returns the redactor of a user, cached for
+*                  REDACTION_RULES_CACHE_TTL
+* INPUT:           userId
+* RETURNS:         *redactor, error
+******************************************************************************/
+func getRedactor(userId string) (*redactor, error) {
+	if cached, ok := redactorCache.get(userId); ok {
+		return cached, nil
+	}
+
+	rules, err := db.GetDataFromDB("SELECT name, pattern, action FROM redaction_rules WHERE user_id = $1 ORDER BY rule_id ASC", []interface{}{userId})
+	if err != nil {
+		return nil, err
+	}
+
+	r := newRedactor(rules)
+
+	redactorCache.set(userId, r)
+
+	return r, nil
+}
+
+/******************************************************************************
+* FUNCTION:        getRedactorOrDefault
+*
+* DESCRIPTION:     Helper function for the push inputs. When the user's
+*                  rules cannot be loaded the built-in defaults still apply,
+*                  values are never stored unredacted for a db hiccup
+* INPUT:           userId
+* RETURNS:         *redactor
+******************************************************************************/
+func getRedactorOrDefault(userId string) *redactor {
+	r, err := getRedactor(userId)
+	if err != nil {
+		log.Errorf("failed to load redaction rules of user %s; err: %v", userId, err)
+		return newRedactor(nil)
+	}
+	return r
+}
+
+/******************************************************************************
+* FUNCTION:        invalidateRedactor
+*
+* DESCRIPTION:     Drops the cached redactor of a user after a change
+* INPUT:           userId
+* RETURNS:         void
+******************************************************************************/
+func invalidateRedactor(userId string) {
+	redactorCache.remove(userId)
+}
+
+/******************************************************************************
+* FUNCTION:        mergeRedactionCounts
+*
+* DESCRIPTION:     Helper function adding counts to the counts stored as
+*                  json
+* INPUT:           stored json (may be empty), counts
+* RETURNS:         merged json
+******************************************************************************/
+func mergeRedactionCounts(stored string, counts RedactionCounts) string {
+	merged := make(RedactionCounts)
+	if stored != "" {
+		json.Unmarshal([]byte(stored), &merged)
+	}
+	for name, count := range counts {
+		merged[name] += count
+	}
+	mergedJSON, _ := json.Marshal(merged)
+	return string(mergedJSON)
+}
+
+/******************************************************************************
+* FUNCTION:        storeRedactionCounts
+*
+* DESCRIPTION:     Records the redaction counts of a job in file_stats
+* INPUT:           tx, fileID, counts
+* RETURNS:         error
+******************************************************************************/
+func storeRedactionCounts(tx *sql.Tx, fileID int64, counts RedactionCounts) error {
+	countsJSON, _ := json.Marshal(counts)
+	return db.UpdateSingleRecord(tx, "file_stats", "file_id", fileID, map[string]interface{}{"redaction_counts": string(countsJSON)})
+}
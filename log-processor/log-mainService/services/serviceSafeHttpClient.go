@@ -0,0 +1,188 @@
+/**************************************************************************
+ * File       	   : serviceSafeHttpClient.go
+ * DESCRIPTION     : This file contains the http client and dialer used to
+ *                   reach user supplied destinations. They refuse to connect
+ *                   to loopback, private, link-local and other internal
+ *                   ranges unless they are allow-listed, the client bounds the
+ *                   number of redirects
+ * DATE            : 19-October-2026
+ **************************************************************************/
+
+package services
+
+import (
+	"LOGProcessor/shared/types"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+)
+
+var (
+	ErrDisallowedDestination = errors.New("destination address is not allowed")
+	ErrTooManyRedirects      = errors.New("too many redirects")
+	ErrRemoteStatus          = errors.New("unexpected status from remote")
+
+	// ranges not covered by the net.IP helpers
+	blockedNets = mustParseCIDRs(
+		"0.0.0.0/8",      // this network
+		"100.64.0.0/10",  // carrier-grade NAT
+		"192.0.0.0/24",   // IETF protocol assignments
+		"198.18.0.0/15",  // benchmarking
+		"240.0.0.0/4",    // reserved, includes broadcast
+		"64:ff9b::/96",   // NAT64, embeds IPv4 addresses
+		"64:ff9b:1::/48", // local-use NAT64
+		"2002::/16",      // 6to4, embeds IPv4 addresses
+	)
+)
+
+/******************************************************************************
+* FUNCTION:        newIngestHttpClient
+*
+* DESCRIPTION:     Returns the http client used to fetch remote log files.
+*                  Redirects are bounded and must stay on http(s). The
+*                  user's headers and credentials are dropped on a redirect
+*                  to another scheme or host
+* INPUT:           None
+* RETURNS:         *http.Client
+******************************************************************************/
+func newIngestHttpClient() *http.Client {
+	return &http.Client{
+		Transport: newSafeTransport(),
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > types.IngestCfg.MaxRedirects {
+				return ErrTooManyRedirects
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return fmt.Errorf("%w: redirect to %s scheme", ErrDisallowedDestination, req.URL.Scheme)
+			}
+			// net/http only strips Authorization and Cookie, custom
+			// headers such as api keys would follow to the new host
+			origin := via[0].URL
+			if req.URL.Scheme != origin.Scheme || !strings.EqualFold(req.URL.Host, origin.Host) {
+				req.Header = make(http.Header)
+			}
+			return nil
+		},
+	}
+}
+
+/******************************************************************************
+* FUNCTION:        newSafeTransport
+*
+* DESCRIPTION:     Returns the http transport of the user supplied
+*                  destinations, dialing through newSafeDialer. Proxies
+*                  from the environment are ignored as they would dial on
+*                  our behalf
+* INPUT:           None
+* RETURNS:         *http.Transport
+******************************************************************************/
+func newSafeTransport() *http.Transport {
+	return &http.Transport{
+		Proxy:                 nil,
+		DialContext:           newSafeDialer().DialContext,
+		TLSHandshakeTimeout:   SOURCE_DIAL_TIMEOUT,
+		ResponseHeaderTimeout: SOURCE_DIAL_TIMEOUT,
+	}
+}
+
+/******************************************************************************
+* FUNCTION:        newSafeDialer
+*
+* DESCRIPTION:     Returns the dialer of every user supplied destination:
+*                  http urls, s3 endpoints, sftp hosts and webhooks. The
+*                  destination is checked on the resolved address at dial
+*                  time, so DNS rebinding and redirects to internal hosts
+*                  are refused as well
+* INPUT:           None
+* RETURNS:         *net.Dialer
+******************************************************************************/
+func newSafeDialer() *net.Dialer {
+	return &net.Dialer{
+		Timeout: SOURCE_DIAL_TIMEOUT,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			return checkDialAddress(address)
+		},
+	}
+}
+
+/******************************************************************************
+* FUNCTION:        checkDialAddress
+*
+* DESCRIPTION:     Helper function run before every connection, with the
+*                  already resolved ip:port
+* INPUT:           address
+* RETURNS:         error
+******************************************************************************/
+func checkDialAddress(address string) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return fmt.Errorf("%w: %s", ErrDisallowedDestination, host)
+	}
+	if !isAllowedIP(ip) {
+		return fmt.Errorf("%w: %s", ErrDisallowedDestination, ip)
+	}
+
+	return nil
+}
+
+/******************************************************************************
+* FUNCTION:        isAllowedIP
+*
+* DESCRIPTION:     Tells whether the ip is a public address or part of the
+*                  INGEST_ALLOWED_CIDRS allow-list
+* INPUT:           ip
+* RETURNS:         bool
+******************************************************************************/
+func isAllowedIP(ip net.IP) bool {
+	if v4 := ip.To4(); v4 != nil {
+		ip = v4
+	}
+
+	for _, allowed := range types.IngestCfg.AllowedNets {
+		if allowed.Contains(ip) {
+			return true
+		}
+	}
+
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+
+	for _, blocked := range blockedNets {
+		if blocked.Contains(ip) {
+			return false
+		}
+	}
+
+	return true
+}
+
+/******************************************************************************
+* FUNCTION:        mustParseCIDRs
+*
+* DESCRIPTION:     Helper function to parse the built-in blocked ranges
+* INPUT:           cidrs
+* RETURNS:         []*net.IPNet
+******************************************************************************/
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		nets = append(nets, ipNet)
+	}
+
+	return nets
+}
@@ -0,0 +1,472 @@
+/**************************************************************************
+ * File       	   : serviceSourceFetchers.go
+ * DESCRIPTION     : This file contains the fetchers that list and read
+ *                   objects from the remote ingest sources: HTTP(S) URLs,
+ *                   S3 compatible buckets and SFTP directories
+ * DATE            : 19-October-2026
+ **************************************************************************/
+
+package services
+
+import (
+	"LOGProcessor/shared/types"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	SOURCE_TYPE_HTTP = "http"
+	SOURCE_TYPE_S3   = "s3"
+	SOURCE_TYPE_SFTP = "sftp"
+
+	SOURCE_DIAL_TIMEOUT = 30 * time.Second
+)
+
+type IngestSourceConfig struct {
+	// http
+	URL     string            `json:"url,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	// s3
+	Endpoint string `json:"endpoint,omitempty"`
+	Bucket   string `json:"bucket,omitempty"`
+	Prefix   string `json:"prefix,omitempty"`
+	Region   string `json:"region,omitempty"`
+	UseSSL   bool   `json:"useSSL,omitempty"`
+	// sftp
+	Host    string `json:"host,omitempty"`
+	Path    string `json:"path,omitempty"`
+	Pattern string `json:"pattern,omitempty"`
+	HostKey string `json:"hostKey,omitempty"`
+}
+
+type IngestSourceCredentials struct {
+	Username    string `json:"username,omitempty"`
+	Password    string `json:"password,omitempty"`
+	PrivateKey  string `json:"privateKey,omitempty"`
+	AccessKey   string `json:"accessKey,omitempty"`
+	SecretKey   string `json:"secretKey,omitempty"`
+	BearerToken string `json:"bearerToken,omitempty"`
+}
+
+type IngestSource struct {
+	SourceId    int64
+	UserId      string
+	Name        string
+	SourceType  string
+	CronSpec    string
+	Enabled     bool
+	Config      IngestSourceConfig
+	Credentials IngestSourceCredentials
+}
+
+type RemoteObject struct {
+	Key     string
+	Version string
+	Size    int64
+}
+
+type sourceFetcher interface {
+	List(ctx context.Context) ([]RemoteObject, error)
+	Open(ctx context.Context, obj RemoteObject) (io.ReadCloser, error)
+	Close() error
+}
+
+/******************************************************************************
+* FUNCTION:        newSourceFetcher
+*
+* DESCRIPTION:     Returns the fetcher matching the source type
+* INPUT:           ctx, source
+* RETURNS:         sourceFetcher, error
+******************************************************************************/
+func newSourceFetcher(ctx context.Context, source *IngestSource) (sourceFetcher, error) {
+	switch source.SourceType {
+	case SOURCE_TYPE_HTTP:
+		return &httpSourceFetcher{source: source, client: newIngestHttpClient()}, nil
+	case SOURCE_TYPE_S3:
+		return newS3SourceFetcher(source)
+	case SOURCE_TYPE_SFTP:
+		return newSftpSourceFetcher(ctx, source)
+	default:
+		return nil, fmt.Errorf("unsupported source type %q", source.SourceType)
+	}
+}
+
+/******************************************************************************
+* FUNCTION:        validateIngestSource
+*
+* DESCRIPTION:     Checks that the source has the settings its type needs
+* INPUT:           source
+* RETURNS:         error
+******************************************************************************/
+func validateIngestSource(source *IngestSource) error {
+	cfg := source.Config
+	switch source.SourceType {
+	case SOURCE_TYPE_HTTP:
+		if !strings.HasPrefix(cfg.URL, "http://") && !strings.HasPrefix(cfg.URL, "https://") {
+			return fmt.Errorf("url must be an http(s) url")
+		}
+	case SOURCE_TYPE_S3:
+		if cfg.Endpoint == "" || cfg.Bucket == "" {
+			return fmt.Errorf("endpoint and bucket are required")
+		}
+	case SOURCE_TYPE_SFTP:
+		if cfg.Host == "" || cfg.Path == "" {
+			return fmt.Errorf("host and path are required")
+		}
+		if cfg.HostKey == "" {
+			return fmt.Errorf("hostKey is required to verify the sftp server")
+		}
+		if source.Credentials.Username == "" || (source.Credentials.Password == "" && source.Credentials.PrivateKey == "") {
+			return fmt.Errorf("username and password or privateKey are required")
+		}
+		if cfg.Pattern != "" {
+			if _, err := path.Match(cfg.Pattern, ""); err != nil {
+				return fmt.Errorf("invalid pattern: %v", err)
+			}
+		}
+	default:
+		return fmt.Errorf("type must be one of %s, %s, %s", SOURCE_TYPE_HTTP, SOURCE_TYPE_S3, SOURCE_TYPE_SFTP)
+	}
+
+	return nil
+}
+
+/******************************************************************************
+* FUNCTION:        expandSourceTemplate
+*
+* DESCRIPTION:     Replaces the {today} and {yesterday} placeholders of a
+*                  url, prefix or path with UTC dates as YYYY-MM-DD, so that
+*                  a nightly run can pick up the previous day's logs
+* INPUT:           value, now
+* RETURNS:         string
+******************************************************************************/
+func expandSourceTemplate(value string, now time.Time) string {
+	now = now.UTC()
+	replacer := strings.NewReplacer(
+		"{today}", now.Format("2006-01-02"),
+		"{yesterday}", now.AddDate(0, 0, -1).Format("2006-01-02"),
+	)
+	return replacer.Replace(value)
+}
+
+type httpSourceFetcher struct {
+	source *IngestSource
+	client *http.Client
+}
+
+/******************************************************************************
+* FUNCTION:        List
+*
+* DESCRIPTION:     A http source is a single object. Its version is taken
+*                  from the ETag or Last-Modified header when the server
+*                  answers HEAD requests, otherwise it is left empty and
+*                  the content is hashed when the object is fetched
+* INPUT:           ctx
+* RETURNS:         []RemoteObject, error
+******************************************************************************/
+func (f *httpSourceFetcher) List(ctx context.Context) ([]RemoteObject, error) {
+	url := expandSourceTemplate(f.source.Config.URL, time.Now())
+	obj := RemoteObject{Key: url, Size: -1}
+
+	resp, err := f.do(ctx, http.MethodHead, url)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		obj.Version = resp.Header.Get("ETag")
+		if obj.Version == "" {
+			obj.Version = resp.Header.Get("Last-Modified")
+		}
+		obj.Size = resp.ContentLength
+	case resp.StatusCode == http.StatusNotFound:
+		return []RemoteObject{}, nil
+	case resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented:
+		// no HEAD support, the url itself identifies the object
+	default:
+		return nil, fmt.Errorf("%w: status %d from %s", ErrRemoteStatus, resp.StatusCode, url)
+	}
+
+	return []RemoteObject{obj}, nil
+}
+
+/******************************************************************************
+* FUNCTION:        Open
+*
+* DESCRIPTION:     Opens the http object for reading
+* INPUT:           ctx, obj
+* RETURNS:         io.ReadCloser, error
+******************************************************************************/
+func (f *httpSourceFetcher) Open(ctx context.Context, obj RemoteObject) (io.ReadCloser, error) {
+	resp, err := f.do(ctx, http.MethodGet, obj.Key)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%w: status %d from %s", ErrRemoteStatus, resp.StatusCode, obj.Key)
+	}
+	if resp.ContentLength > types.IngestCfg.MaxBytes {
+		resp.Body.Close()
+		return nil, ErrIngestTooLarge
+	}
+
+	return resp.Body, nil
+}
+
+/******************************************************************************
+* FUNCTION:        do
+*
+* DESCRIPTION:     Helper function to send a request with the configured
+*                  headers and credentials
+* INPUT:           ctx, method, url
+* RETURNS:         *http.Response, error
+******************************************************************************/
+func (f *httpSourceFetcher) do(ctx context.Context, method, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for key, value := range f.source.Config.Headers {
+		req.Header.Set(key, value)
+	}
+
+	creds := f.source.Credentials
+	if creds.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+creds.BearerToken)
+	} else if creds.Username != "" {
+		req.SetBasicAuth(creds.Username, creds.Password)
+	}
+
+	return f.client.Do(req)
+}
+
+/******************************************************************************
+* FUNCTION:        Close
+*
+* DESCRIPTION:     Nothing to release for http sources
+* INPUT:           None
+* RETURNS:         error
+******************************************************************************/
+func (f *httpSourceFetcher) Close() error {
+	return nil
+}
+
+type s3SourceFetcher struct {
+	source *IngestSource
+	client *minio.Client
+}
+
+/******************************************************************************
+* FUNCTION:        newS3SourceFetcher
+*
+* DESCRIPTION:     Creates the client of an S3 compatible source. The
+*                  endpoint is dialed through the safe transport, as it is
+*                  user supplied
+* INPUT:           source
+* RETURNS:         *s3SourceFetcher, error
+******************************************************************************/
+func newS3SourceFetcher(source *IngestSource) (*s3SourceFetcher, error) {
+	client, err := minio.New(source.Config.Endpoint, &minio.Options{
+		Creds:     credentials.NewStaticV4(source.Credentials.AccessKey, source.Credentials.SecretKey, ""),
+		Secure:    source.Config.UseSSL,
+		Region:    source.Config.Region,
+		Transport: newSafeTransport(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create s3 client: %v", err)
+	}
+
+	return &s3SourceFetcher{source: source, client: client}, nil
+}
+
+/******************************************************************************
+* FUNCTION:        List
+*
+* DESCRIPTION:     Lists every object under the prefix, the ETag is used as
+*                  the object version
+* INPUT:           ctx
+* RETURNS:         []RemoteObject, error
+******************************************************************************/
+func (f *s3SourceFetcher) List(ctx context.Context) ([]RemoteObject, error) {
+	prefix := expandSourceTemplate(f.source.Config.Prefix, time.Now())
+	objects := []RemoteObject{}
+
+	for info := range f.client.ListObjects(ctx, f.source.Config.Bucket, minio.ListObjectsOptions{
+		Prefix:    prefix,
+		Recursive: true,
+	}) {
+		if info.Err != nil {
+			return nil, fmt.Errorf("failed to list objects: %v", info.Err)
+		}
+		if strings.HasSuffix(info.Key, "/") {
+			continue
+		}
+		objects = append(objects, RemoteObject{
+			Key:     info.Key,
+			Version: info.ETag,
+			Size:    info.Size,
+		})
+	}
+
+	return objects, nil
+}
+
+/******************************************************************************
+* FUNCTION:        Open
+*
+* DESCRIPTION:     Opens the s3 object for reading
+* INPUT:           ctx, obj
+* RETURNS:         io.ReadCloser, error
+******************************************************************************/
+func (f *s3SourceFetcher) Open(ctx context.Context, obj RemoteObject) (io.ReadCloser, error) {
+	return f.client.GetObject(ctx, f.source.Config.Bucket, obj.Key, minio.GetObjectOptions{})
+}
+
+/******************************************************************************
+* FUNCTION:        Close
+*
+* DESCRIPTION:     Nothing to release for s3 sources
+* INPUT:           None
+* RETURNS:         error
+******************************************************************************/
+func (f *s3SourceFetcher) Close() error {
+	return nil
+}
+
+type sftpSourceFetcher struct {
+	source    *IngestSource
+	sshClient *ssh.Client
+	client    *sftp.Client
+}
+
+/******************************************************************************
+* FUNCTION:        newSftpSourceFetcher
+*
+* DESCRIPTION:     Connects to the sftp server through the safe dialer.
+*                  The server is verified against the configured host key
+* INPUT:           ctx, source
+* RETURNS:         *sftpSourceFetcher, error
+******************************************************************************/
+func newSftpSourceFetcher(ctx context.Context, source *IngestSource) (*sftpSourceFetcher, error) {
+	hostKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(source.Config.HostKey))
+	if err != nil {
+		return nil, fmt.Errorf("invalid host key: %v", err)
+	}
+
+	auth := []ssh.AuthMethod{}
+	if source.Credentials.PrivateKey != "" {
+		signer, err := ssh.ParsePrivateKey([]byte(source.Credentials.PrivateKey))
+		if err != nil {
+			return nil, fmt.Errorf("invalid private key: %v", err)
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+	if source.Credentials.Password != "" {
+		auth = append(auth, ssh.Password(source.Credentials.Password))
+	}
+
+	addr := source.Config.Host
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "22")
+	}
+
+	conn, err := newSafeDialer().DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %v", addr, err)
+	}
+
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, addr, &ssh.ClientConfig{
+		User:            source.Credentials.Username,
+		Auth:            auth,
+		HostKeyCallback: ssh.FixedHostKey(hostKey),
+		Timeout:         SOURCE_DIAL_TIMEOUT,
+	})
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("ssh handshake with %s failed: %v", addr, err)
+	}
+	sshClient := ssh.NewClient(sshConn, chans, reqs)
+
+	client, err := sftp.NewClient(sshClient)
+	if err != nil {
+		sshClient.Close()
+		return nil, fmt.Errorf("failed to start sftp session: %v", err)
+	}
+
+	return &sftpSourceFetcher{source: source, sshClient: sshClient, client: client}, nil
+}
+
+/******************************************************************************
+* FUNCTION:        List
+*
+* DESCRIPTION:     Lists the regular files of the directory matching the
+*                  pattern. Modification time and size make up the version
+* INPUT:           ctx
+* RETURNS:         []RemoteObject, error
+******************************************************************************/
+func (f *sftpSourceFetcher) List(ctx context.Context) ([]RemoteObject, error) {
+	dir := expandSourceTemplate(f.source.Config.Path, time.Now())
+
+	entries, err := f.client.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", dir, err)
+	}
+
+	objects := []RemoteObject{}
+	for _, entry := range entries {
+		if !entry.Mode().IsRegular() {
+			continue
+		}
+		if f.source.Config.Pattern != "" {
+			if ok, _ := path.Match(f.source.Config.Pattern, entry.Name()); !ok {
+				continue
+			}
+		}
+		objects = append(objects, RemoteObject{
+			Key:     path.Join(dir, entry.Name()),
+			Version: strconv.FormatInt(entry.ModTime().Unix(), 10) + "-" + strconv.FormatInt(entry.Size(), 10),
+			Size:    entry.Size(),
+		})
+	}
+
+	return objects, nil
+}
+
+/******************************************************************************
+* FUNCTION:        Open
+*
+* DESCRIPTION:     Opens the remote file for reading
+* INPUT:           ctx, obj
+* RETURNS:         io.ReadCloser, error
+******************************************************************************/
+func (f *sftpSourceFetcher) Open(ctx context.Context, obj RemoteObject) (io.ReadCloser, error) {
+	return f.client.Open(obj.Key)
+}
+
+/******************************************************************************
+* FUNCTION:        Close
+*
+* DESCRIPTION:     Closes the sftp session and the ssh connection
+* INPUT:           None
+* RETURNS:         error
+******************************************************************************/
+func (f *sftpSourceFetcher) Close() error {
+	f.client.Close()
+	return f.sshClient.Close()
+}
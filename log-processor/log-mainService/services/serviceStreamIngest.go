@@ -0,0 +1,485 @@
+/**************************************************************************
+ * File       	   : serviceStreamIngest.go
+ * DESCRIPTION     : This file contains the batcher shared by the push
+ *                   inputs. Entries are buffered per user and source and
+ *                   flushed into log_stats under a long-lived "stream"
+ *                   file_stats row whose error/keyword stats are kept up
+ *                   to date on every flush
+ * DATE            : 19-October-2026
+ **************************************************************************/
+
+package services
+
+import (
+	"LOGProcessor/shared/db"
+	"LOGProcessor/shared/types"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/martian/log"
+	"github.com/lib/pq"
+)
+
+const (
+	STREAM_STATUS         = "streaming"
+	STREAM_FLUSH_INTERVAL = time.Second
+	STREAM_BATCH_SIZE     = 500
+	// entries buffered per stream before pushes are refused, bounds the
+	// memory held while the db is slow or down
+	STREAM_MAX_BUFFERED = 50000
+	// file_stats rows of streams kept in memory, the least recently used
+	// are read again from the db
+	STREAM_MAX_FILE_IDS = 10000
+)
+
+var (
+	ErrStreamBackpressure = errors.New("stream buffer is full, retry later")
+	ErrInvalidStreamName  = errors.New("source must be 1-64 characters of letters, digits, '.', '_' or '-'")
+
+	streamNameRegex = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+	streamBuffers = make(map[streamKey]*streamBuffer)
+	streamFileIds = newBoundedCache[streamKey, int64](0, STREAM_MAX_FILE_IDS)
+	streamMu      sync.Mutex
+	streamFlushCh = make(chan struct{}, 1)
+	streamStop    context.CancelFunc
+	streamDone    chan struct{}
+)
+
+type streamKey struct {
+	UserId string
+	Source string
+}
+
+type streamBuffer struct {
+	FileId  int64
+	Entries []LogEntry
+	// Redactions counts the values redacted from the buffered entries
+	Redactions RedactionCounts
+}
+
+/******************************************************************************
+* FUNCTION:        AppendStreamEntries
+*
+* DESCRIPTION:     Buffers parsed entries of a user's stream. The entries
+*                  are written on the next flush, or right away once a full
+*                  batch is buffered. Text is made valid for postgres, the
+*                  entries are enriched with GeoIP, then the user's level
+*                  mappings and redaction rules are applied
+* INPUT:           userId, source, entries
+* RETURNS:         error
+******************************************************************************/
+func AppendStreamEntries(userId, source string, entries []LogEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	if !streamNameRegex.MatchString(source) {
+		return ErrInvalidStreamName
+	}
+
+	key := streamKey{UserId: userId, Source: source}
+	fileId, err := getStreamFileId(key)
+	if err != nil {
+		return err
+	}
+
+	for i := range entries {
+		sanitizeLogEntry(&entries[i])
+	}
+	enrichLogEntries(entries)
+	tagLogEntries(entries, getCidrSetsOrEmpty(userId))
+	matchThreatEntries(entries)
+	redactions := make(RedactionCounts)
+	getRedactorOrDefault(userId).redactEntries(entries, redactions)
+	// loaded before streamMu, a cache miss reads the db
+	mappings := getLevelMappingsOrEmpty(userId)
+	for i := range entries {
+		entries[i].FileID = fileId
+		applyLevelMappings(&entries[i], mappings)
+	}
+
+	streamMu.Lock()
+	buf, ok := streamBuffers[key]
+	if !ok {
+		buf = &streamBuffer{FileId: fileId, Redactions: make(RedactionCounts)}
+		streamBuffers[key] = buf
+	}
+	if len(buf.Entries)+len(entries) > STREAM_MAX_BUFFERED {
+		streamMu.Unlock()
+		return ErrStreamBackpressure
+	}
+	buf.Entries = append(buf.Entries, entries...)
+	for name, count := range redactions {
+		buf.Redactions[name] += count
+	}
+	full := len(buf.Entries) >= STREAM_BATCH_SIZE
+	streamMu.Unlock()
+
+	if full {
+		select {
+		case streamFlushCh <- struct{}{}:
+		default:
+		}
+	}
+
+	return nil
+}
+
+/******************************************************************************
+* FUNCTION:        checkStreamCapacity
+*
+* DESCRIPTION:     Checks that every stream of a request has room for its
+*                  entries, so a push spanning several streams is refused
+*                  before any of them is buffered
+* INPUT:           userId, entry count per source
+* RETURNS:         error
+******************************************************************************/
+func checkStreamCapacity(userId string, counts map[string]int) error {
+	streamMu.Lock()
+	defer streamMu.Unlock()
+
+	for source, count := range counts {
+		buffered := 0
+		if buf, ok := streamBuffers[streamKey{UserId: userId, Source: source}]; ok {
+			buffered = len(buf.Entries)
+		}
+		if buffered+count > STREAM_MAX_BUFFERED {
+			return ErrStreamBackpressure
+		}
+	}
+	return nil
+}
+
+/******************************************************************************
+* FUNCTION:        StartStreamBatcher
+*
+* DESCRIPTION:     Starts the background flush loop of the stream buffers
+* INPUT:           None
+* RETURNS:         void
+******************************************************************************/
+func StartStreamBatcher() {
+	var ctx context.Context
+	ctx, streamStop = context.WithCancel(context.Background())
+	streamDone = make(chan struct{})
+
+	go func() {
+		defer close(streamDone)
+
+		ticker := time.NewTicker(STREAM_FLUSH_INTERVAL)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				flushStreams()
+				return
+			case <-ticker.C:
+				flushStreams()
+			case <-streamFlushCh:
+				flushStreams()
+			}
+		}
+	}()
+}
+
+/******************************************************************************
+* FUNCTION:        StopStreamBatcher
+*
+* DESCRIPTION:     Stops the flush loop and waits for the final flush of
+*                  what is still buffered
+* INPUT:           None
+* RETURNS:         void
+******************************************************************************/
+func StopStreamBatcher() {
+	if streamStop == nil {
+		return
+	}
+
+	streamStop()
+	<-streamDone
+}
+
+/******************************************************************************
+* FUNCTION:        flushStreams
+*
+* DESCRIPTION:     Writes out every buffered stream. A stream whose write
+*                  fails on a connection or transient error keeps its
+*                  entries for the next flush, a batch the database
+*                  rejects is dropped as it would fail again
+* INPUT:           None
+* RETURNS:         void
+******************************************************************************/
+func flushStreams() {
+	defer PanicRecovery("flushStreams")
+
+	streamMu.Lock()
+	pending := streamBuffers
+	streamBuffers = make(map[streamKey]*streamBuffer)
+	streamMu.Unlock()
+
+	for key, buf := range pending {
+		if len(buf.Entries) == 0 {
+			continue
+		}
+
+		data, err := writeStreamBatch(buf.FileId, buf.Entries, buf.Redactions)
+		if err != nil {
+			if !isTransientDBError(err) {
+				log.Errorf("dropping %d entries of stream %s/%s rejected by the db; err: %v", len(buf.Entries), key.UserId, key.Source, err)
+				continue
+			}
+			log.Errorf("failed to flush %d entries of stream %s/%s; err: %v", len(buf.Entries), key.UserId, key.Source, err)
+			requeueStreamEntries(key, buf)
+			continue
+		}
+
+		data["file_id"] = buf.FileId
+		data["stream_source"] = key.Source
+		BroadcastMessage(data, "log-table-update", key.UserId)
+		broadcastThreatHits(key.UserId, buf.FileId, buf.Entries)
+		if err = checkStreamAlerts(key, buf.FileId, buf.Entries); err != nil {
+			log.Errorf("failed to check alert rules of stream %s/%s; err: %v", key.UserId, key.Source, err)
+		}
+	}
+}
+
+/******************************************************************************
+* FUNCTION:        requeueStreamEntries
+*
+* DESCRIPTION:     Puts back entries of a failed flush in front of the ones
+*                  buffered meanwhile, dropping the oldest beyond the limit
+* INPUT:           key, buf
+* RETURNS:         void
+******************************************************************************/
+func requeueStreamEntries(key streamKey, buf *streamBuffer) {
+	streamMu.Lock()
+	defer streamMu.Unlock()
+
+	if current, ok := streamBuffers[key]; ok {
+		buf.Entries = append(buf.Entries, current.Entries...)
+		for name, count := range current.Redactions {
+			buf.Redactions[name] += count
+		}
+	}
+	if dropped := len(buf.Entries) - STREAM_MAX_BUFFERED; dropped > 0 {
+		log.Errorf("dropping %d entries of stream %s/%s", dropped, key.UserId, key.Source)
+		buf.Entries = buf.Entries[dropped:]
+	}
+	streamBuffers[key] = buf
+}
+
+/******************************************************************************
+* FUNCTION:        writeStreamBatch
+*
+* DESCRIPTION:     Inserts a batch into log_stats and adds its counts to the
+*                  stream's file_stats row in the same transaction
+* INPUT:           fileId, entries, redaction counts of the entries
+* RETURNS:         updated file_stats data, error
+******************************************************************************/
+func writeStreamBatch(fileId int64, entries []LogEntry, redactions RedactionCounts) (data map[string]interface{}, err error) {
+	var (
+		errorCount     sql.NullInt64
+		keywordJSON    sql.NullString
+		lineCount      sql.NullInt64
+		redactionsJSON sql.NullString
+		threatHits     sql.NullInt64
+		keywordStats   = make(KeywordStats)
+	)
+
+	ctx := context.Background()
+	tx, err := types.Db.DbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err = insertLogEntries(tx, ctx, entries); err != nil {
+		return nil, err
+	}
+
+	err = tx.QueryRowContext(ctx, "SELECT error_count, keyword_stats, line_count, redaction_counts, threat_hits FROM file_stats WHERE file_id = $1 FOR UPDATE", fileId).
+		Scan(&errorCount, &keywordJSON, &lineCount, &redactionsJSON, &threatHits)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock stream stats: %w", err)
+	}
+	if keywordJSON.Valid && keywordJSON.String != "" {
+		json.Unmarshal([]byte(keywordJSON.String), &keywordStats)
+	}
+
+	for _, entry := range entries {
+		if entry.KeywordDetected != "" {
+			keywordStats[entry.KeywordDetected]++
+			errorCount.Int64++
+		}
+		if entry.ThreatIndicator != "" {
+			threatHits.Int64++
+		}
+	}
+	keywordBytes, _ := json.Marshal(keywordStats)
+
+	data = map[string]interface{}{
+		"error_count":   errorCount.Int64,
+		"keyword_stats": string(keywordBytes),
+		"line_count":    lineCount.Int64 + int64(len(entries)),
+		"threat_hits":   threatHits.Int64,
+		"completed_at":  time.Now(),
+	}
+	if len(redactions) > 0 {
+		data["redaction_counts"] = mergeRedactionCounts(redactionsJSON.String, redactions)
+	}
+	if err = db.UpdateSingleRecord(tx, "file_stats", "file_id", fileId, data); err != nil {
+		return nil, fmt.Errorf("failed to update stream stats: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return data, nil
+}
+
+/******************************************************************************
+* FUNCTION:        isTransientDBError
+*
+* DESCRIPTION:     Tells whether a failed write may succeed when retried.
+*                  Postgres errors are transient in the classes of lost
+*                  connections, rolled back transactions, exhausted
+*                  resources and server shutdown; other errors of the
+*                  driver, e.g. a broken connection, are transient as well
+* INPUT:           err
+* RETURNS:         bool
+******************************************************************************/
+func isTransientDBError(err error) bool {
+	if errors.Is(err, sql.ErrNoRows) {
+		return false
+	}
+
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return true
+	}
+
+	switch pqErr.Code.Class() {
+	case "08", "40", "53", "57", "58":
+		return true
+	}
+	return false
+}
+
+/******************************************************************************
+* FUNCTION:        sanitizeLogEntry
+*
+* DESCRIPTION:     Makes the text of a pushed entry storable. Postgres text
+*                  and jsonb hold neither NUL bytes nor invalid utf-8, one
+*                  such value would fail the whole batch
+* INPUT:           entry
+* RETURNS:         void
+******************************************************************************/
+func sanitizeLogEntry(entry *LogEntry) {
+	entry.Message = sanitizeText(entry.Message)
+	entry.RawLevel = sanitizeText(entry.RawLevel)
+	entry.RawTimestamp = sanitizeText(entry.RawTimestamp)
+	entry.KeywordDetected = sanitizeText(entry.KeywordDetected)
+	entry.IP = sanitizeText(entry.IP)
+	entry.TraceID = sanitizeText(entry.TraceID)
+	entry.SpanID = sanitizeText(entry.SpanID)
+	entry.Stream = sanitizeText(entry.Stream)
+	entry.Namespace = sanitizeText(entry.Namespace)
+	entry.Pod = sanitizeText(entry.Pod)
+	entry.Container = sanitizeText(entry.Container)
+	if entry.Attributes != nil {
+		entry.Attributes = sanitizeValue(entry.Attributes).(map[string]interface{})
+	}
+}
+
+/******************************************************************************
+* FUNCTION:        sanitizeValue
+*
+* DESCRIPTION:     Helper function sanitizing the strings of an attribute
+*                  value, keys of nested maps included
+* INPUT:           value
+* RETURNS:         sanitized value
+******************************************************************************/
+func sanitizeValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		return sanitizeText(v)
+	case map[string]interface{}:
+		sanitized := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			sanitized[sanitizeText(key)] = sanitizeValue(item)
+		}
+		return sanitized
+	case []interface{}:
+		for i, item := range v {
+			v[i] = sanitizeValue(item)
+		}
+		return v
+	}
+	return value
+}
+
+/******************************************************************************
+* FUNCTION:        sanitizeText
+*
+* DESCRIPTION:     Helper function dropping NUL bytes and replacing invalid
+*                  utf-8 sequences with U+FFFD
+* INPUT:           text
+* RETURNS:         string
+******************************************************************************/
+func sanitizeText(text string) string {
+	return strings.ToValidUTF8(strings.ReplaceAll(text, "\x00", ""), "\uFFFD")
+}
+
+/******************************************************************************
+* FUNCTION:        getStreamFileId
+*
+* DESCRIPTION:     Returns the file_stats row of a stream, creating it on
+*                  first use. The unique index on (user_id, stream_source)
+*                  keeps concurrent api instances on the same row
+* INPUT:           key
+* RETURNS:         fileId, error
+******************************************************************************/
+func getStreamFileId(key streamKey) (int64, error) {
+	fileId, ok := streamFileIds.get(key)
+	if ok {
+		return fileId, nil
+	}
+
+	insert := `
+	INSERT INTO file_stats (file_name, file_path, file_size_mb, status, created_at, user_id, stream_source)
+	VALUES ($1, '', 0, $2, $3, $4, $5)
+	ON CONFLICT (user_id, stream_source) WHERE stream_source IS NOT NULL DO NOTHING
+	RETURNING file_id`
+
+	result, err := db.GetDataFromDB(insert, []interface{}{"stream:" + key.Source, STREAM_STATUS, time.Now(), key.UserId, key.Source})
+	if err != nil {
+		return 0, fmt.Errorf("failed to create stream: %v", err)
+	}
+	if len(result) == 0 {
+		result, err = db.GetDataFromDB("SELECT file_id FROM file_stats WHERE user_id = $1 AND stream_source = $2", []interface{}{key.UserId, key.Source})
+		if err != nil {
+			return 0, fmt.Errorf("failed to get stream: %v", err)
+		}
+		if len(result) == 0 {
+			return 0, fmt.Errorf("stream %s disappeared while being created", key.Source)
+		}
+	}
+
+	fileId, _ = result[0]["file_id"].(int64)
+
+	streamFileIds.set(key, fileId)
+
+	return fileId, nil
+}
@@ -0,0 +1,261 @@
+/**************************************************************************
+ * File       	   : serviceSyslogParser.go
+ * DESCRIPTION     : This file contains the parser of RFC 5424 and
+ *                   RFC 3164 (BSD) syslog messages
+ * DATE            : 19-October-2026
+ **************************************************************************/
+
+package services
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	SYSLOG_NIL_VALUE = "-"
+)
+
+var (
+	ErrInvalidSyslogMessage = errors.New("invalid syslog message")
+
+	// syslog severities 0-7 as log levels
+	syslogSeverityLevels = []string{"EMERGENCY", "ALERT", "CRITICAL", "ERROR", "WARNING", "NOTICE", "INFO", "DEBUG"}
+)
+
+type SyslogMessage struct {
+	Facility  int
+	Severity  int
+	Timestamp time.Time
+	Hostname  string
+	AppName   string
+	ProcId    string
+	MsgId     string
+	Message   string
+}
+
+/******************************************************************************
+* FUNCTION:        parseSyslogMessage
+*
+* DESCRIPTION:     Parses a syslog message, RFC 5424 when the priority is
+*                  followed by a version, RFC 3164 otherwise
+* INPUT:           raw message, time of receipt
+* RETURNS:         *SyslogMessage, error
+******************************************************************************/
+func parseSyslogMessage(raw string, received time.Time) (*SyslogMessage, error) {
+	raw = strings.TrimRight(raw, "\r\n\x00")
+
+	pri, rest, err := parseSyslogPriority(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := &SyslogMessage{Facility: pri / 8, Severity: pri % 8}
+	if strings.HasPrefix(rest, "1 ") {
+		err = parseRfc5424(msg, rest[2:], received)
+	} else {
+		parseRfc3164(msg, rest, received)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return msg, nil
+}
+
+/******************************************************************************
+* FUNCTION:        parseSyslogPriority
+*
+* DESCRIPTION:     Parses the <PRI> header
+* INPUT:           raw message
+* RETURNS:         priority, rest of the message, error
+******************************************************************************/
+func parseSyslogPriority(raw string) (int, string, error) {
+	if !strings.HasPrefix(raw, "<") {
+		return 0, "", ErrInvalidSyslogMessage
+	}
+
+	end := strings.IndexByte(raw, '>')
+	if end < 2 || end > 4 {
+		return 0, "", ErrInvalidSyslogMessage
+	}
+
+	pri, err := strconv.Atoi(raw[1:end])
+	if err != nil || pri < 0 || pri > 191 {
+		return 0, "", ErrInvalidSyslogMessage
+	}
+
+	return pri, raw[end+1:], nil
+}
+
+/******************************************************************************
+* FUNCTION:        parseRfc5424
+*
+* DESCRIPTION:     Parses TIMESTAMP HOSTNAME APP-NAME PROCID MSGID SD [MSG]
+* INPUT:           msg, header after the version, time of receipt
+* RETURNS:         error
+******************************************************************************/
+func parseRfc5424(msg *SyslogMessage, rest string, received time.Time) error {
+	fields := make([]string, 5)
+	for i := range fields {
+		var field string
+		field, rest, _ = strings.Cut(rest, " ")
+		if field == "" {
+			return ErrInvalidSyslogMessage
+		}
+		fields[i] = field
+	}
+
+	msg.Timestamp = received
+	if fields[0] != SYSLOG_NIL_VALUE {
+		timestamp, err := time.Parse(time.RFC3339Nano, fields[0])
+		if err != nil {
+			return ErrInvalidSyslogMessage
+		}
+		msg.Timestamp = timestamp
+	}
+	msg.Hostname = syslogNilToEmpty(fields[1])
+	msg.AppName = syslogNilToEmpty(fields[2])
+	msg.ProcId = syslogNilToEmpty(fields[3])
+	msg.MsgId = syslogNilToEmpty(fields[4])
+
+	rest, err := skipStructuredData(rest)
+	if err != nil {
+		return err
+	}
+	msg.Message = strings.TrimPrefix(strings.TrimPrefix(rest, " "), "\ufeff")
+
+	return nil
+}
+
+/******************************************************************************
+* FUNCTION:        skipStructuredData
+*
+* DESCRIPTION:     Skips the structured data of a RFC 5424 message, which
+*                  is either "-" or a list of [id param="value" ...]
+*                  elements where values may contain escaped ] and "
+* INPUT:           rest of the message
+* RETURNS:         rest after the structured data, error
+******************************************************************************/
+func skipStructuredData(rest string) (string, error) {
+	if strings.HasPrefix(rest, SYSLOG_NIL_VALUE) {
+		return rest[1:], nil
+	}
+
+	i := 0
+	for i < len(rest) && rest[i] == '[' {
+		inQuotes := false
+		for i++; i < len(rest); i++ {
+			c := rest[i]
+			if c == '\\' && inQuotes {
+				i++
+				continue
+			}
+			if c == '"' {
+				inQuotes = !inQuotes
+				continue
+			}
+			if c == ']' && !inQuotes {
+				break
+			}
+		}
+		if i >= len(rest) {
+			return "", ErrInvalidSyslogMessage
+		}
+		i++
+	}
+	if i == 0 {
+		return "", ErrInvalidSyslogMessage
+	}
+
+	return rest[i:], nil
+}
+
+/******************************************************************************
+* FUNCTION:        parseRfc3164
+*
+* DESCRIPTION:     Parses "Mmm dd hh:mm:ss HOSTNAME TAG: MSG". BSD syslog is
+*                  loosely followed by devices, so anything that does not
+*                  fit is kept as the message with the time of receipt.
+*                  The timestamp has no year, the current one is assumed
+*                  unless that puts it in the future
+* INPUT:           msg, message after the priority, time of receipt
+* RETURNS:         void
+******************************************************************************/
+func parseRfc3164(msg *SyslogMessage, rest string, received time.Time) {
+	msg.Timestamp = received
+	msg.Message = rest
+
+	const stampLen = len(time.Stamp)
+	if len(rest) < stampLen+1 {
+		return
+	}
+
+	timestamp, err := time.ParseInLocation(time.Stamp, rest[:stampLen], received.Location())
+	if err != nil {
+		return
+	}
+	msg.Timestamp = inferYear(timestamp, received)
+	parseRfc3164Header(msg, strings.TrimPrefix(rest[stampLen:], " "))
+}
+
+/******************************************************************************
+* FUNCTION:        parseRfc3164Header
+*
+* DESCRIPTION:     Parses the "HOSTNAME TAG: MSG" part following the
+*                  timestamp, hostname and tag are optional
+* INPUT:           msg, message after the timestamp
+* RETURNS:         void
+******************************************************************************/
+func parseRfc3164Header(msg *SyslogMessage, rest string) {
+	// hostname is absent when the next word already is the tag
+	if host, after, ok := strings.Cut(rest, " "); ok && !strings.HasSuffix(host, ":") && !strings.Contains(host, "[") {
+		msg.Hostname = host
+		rest = after
+	}
+
+	if tag, after, ok := strings.Cut(rest, ": "); ok && !strings.Contains(tag, " ") {
+		if name, pid, found := strings.Cut(tag, "["); found {
+			msg.AppName = name
+			msg.ProcId = strings.TrimSuffix(pid, "]")
+		} else {
+			msg.AppName = tag
+		}
+		rest = after
+	}
+	msg.Message = rest
+}
+
+/******************************************************************************
+* FUNCTION:        toLogEntry
+*
+* DESCRIPTION:     Maps the syslog message to a LogEntry, the severity
+*                  becomes the log level and the app name prefixes the
+*                  message
+* INPUT:           None
+* RETURNS:         LogEntry
+******************************************************************************/
+func (m *SyslogMessage) toLogEntry() LogEntry {
+	message := m.Message
+	if m.AppName != "" {
+		message = m.AppName + ": " + message
+	}
+
+	return buildLogEntry(m.Timestamp, syslogSeverityLevels[m.Severity], message, 0)
+}
+
+/******************************************************************************
+* FUNCTION:        syslogNilToEmpty
+*
+* DESCRIPTION:     Helper function mapping the "-" nil value to ""
+* INPUT:           value
+* RETURNS:         string
+******************************************************************************/
+func syslogNilToEmpty(value string) string {
+	if value == SYSLOG_NIL_VALUE {
+		return ""
+	}
+	return value
+}
@@ -0,0 +1,346 @@
+/**************************************************************************
+ * File       	   : serviceSyslogServer.go
+ * DESCRIPTION     : This file contains the optional syslog listeners
+ *                   (UDP, TCP and TLS). Messages are written through the
+ *                   stream batcher under one stream per sending address
+ * DATE            : 19-October-2026
+ **************************************************************************/
+
+package services
+
+import (
+	"LOGProcessor/shared/types"
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/martian/log"
+)
+
+const (
+	SYSLOG_STREAM_PREFIX   = "syslog-"
+	SYSLOG_IDLE_TIMEOUT    = 5 * time.Minute
+	SYSLOG_MAX_CONNECTIONS = 1000
+)
+
+var (
+	unsafeStreamNameRegex = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+	syslogListeners []io.Closer
+	syslogConns     = make(map[net.Conn]struct{})
+	syslogMu        sync.Mutex
+	syslogWg        sync.WaitGroup
+	syslogSlots     = make(chan struct{}, SYSLOG_MAX_CONNECTIONS)
+	syslogStopping  bool
+)
+
+/******************************************************************************
+* FUNCTION:        StartSyslogServer
+*
+* DESCRIPTION:     Starts the syslog listeners configured through
+*                  types.SyslogCfg. Nothing is started when no listen
+*                  address is set
+* INPUT:           None
+* RETURNS:         error
+******************************************************************************/
+func StartSyslogServer() error {
+	cfg := types.SyslogCfg
+	if cfg.UDPAddr == "" && cfg.TCPAddr == "" && cfg.TLSAddr == "" {
+		return nil
+	}
+	if cfg.UserId == "" {
+		return fmt.Errorf("SYSLOG_USER_ID is required to run the syslog listeners")
+	}
+
+	if cfg.UDPAddr != "" {
+		conn, err := net.ListenPacket("udp", cfg.UDPAddr)
+		if err != nil {
+			return fmt.Errorf("syslog udp listen: %v", err)
+		}
+		trackSyslogListener(conn)
+		syslogWg.Add(1)
+		go serveSyslogUDP(conn)
+		log.Infof("syslog udp listening on %s", cfg.UDPAddr)
+	}
+
+	if cfg.TCPAddr != "" {
+		listener, err := net.Listen("tcp", cfg.TCPAddr)
+		if err != nil {
+			return fmt.Errorf("syslog tcp listen: %v", err)
+		}
+		trackSyslogListener(listener)
+		syslogWg.Add(1)
+		go serveSyslogStream(listener)
+		log.Infof("syslog tcp listening on %s", cfg.TCPAddr)
+	}
+
+	if cfg.TLSAddr != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.TLSCertFile, cfg.TLSKeyFile)
+		if err != nil {
+			return fmt.Errorf("syslog tls certificate: %v", err)
+		}
+		listener, err := tls.Listen("tcp", cfg.TLSAddr, &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			MinVersion:   tls.VersionTLS12,
+		})
+		if err != nil {
+			return fmt.Errorf("syslog tls listen: %v", err)
+		}
+		trackSyslogListener(listener)
+		syslogWg.Add(1)
+		go serveSyslogStream(listener)
+		log.Infof("syslog tls listening on %s", cfg.TLSAddr)
+	}
+
+	return nil
+}
+
+/******************************************************************************
+* FUNCTION:        StopSyslogServer
+*
+* DESCRIPTION:     Closes the listeners and open connections and waits for
+*                  the handlers, so that every received message has been
+*                  handed to the stream batcher
+* INPUT:           None
+* RETURNS:         void
+******************************************************************************/
+func StopSyslogServer() {
+	syslogMu.Lock()
+	syslogStopping = true
+	for _, listener := range syslogListeners {
+		listener.Close()
+	}
+	for conn := range syslogConns {
+		conn.Close()
+	}
+	syslogMu.Unlock()
+
+	syslogWg.Wait()
+}
+
+/******************************************************************************
+* FUNCTION:        serveSyslogUDP
+*
+* DESCRIPTION:     Reads one message per datagram
+* INPUT:           conn
+* RETURNS:         void
+******************************************************************************/
+func serveSyslogUDP(conn net.PacketConn) {
+	defer syslogWg.Done()
+
+	buf := make([]byte, types.SyslogCfg.MaxMessageBytes)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				log.Errorf("syslog udp read; err: %v", err)
+			}
+			return
+		}
+		handleSyslogMessage(string(buf[:n]), addr)
+	}
+}
+
+/******************************************************************************
+* FUNCTION:        serveSyslogStream
+*
+* DESCRIPTION:     Accepts TCP/TLS connections, bounded by
+*                  SYSLOG_MAX_CONNECTIONS
+* INPUT:           listener
+* RETURNS:         void
+******************************************************************************/
+func serveSyslogStream(listener net.Listener) {
+	defer syslogWg.Done()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				log.Errorf("syslog accept; err: %v", err)
+			}
+			return
+		}
+
+		select {
+		case syslogSlots <- struct{}{}:
+		default:
+			log.Errorf("syslog connection limit reached; closing %s", conn.RemoteAddr())
+			conn.Close()
+			continue
+		}
+
+		syslogMu.Lock()
+		if syslogStopping {
+			syslogMu.Unlock()
+			conn.Close()
+			<-syslogSlots
+			return
+		}
+		syslogConns[conn] = struct{}{}
+		syslogWg.Add(1)
+		syslogMu.Unlock()
+
+		go serveSyslogConn(conn)
+	}
+}
+
+/******************************************************************************
+* FUNCTION:        serveSyslogConn
+*
+* DESCRIPTION:     Reads the messages of a connection. Octet-counting
+*                  framing (RFC 6587) is used when a frame starts with a
+*                  digit, newline framing otherwise
+* INPUT:           conn
+* RETURNS:         void
+******************************************************************************/
+func serveSyslogConn(conn net.Conn) {
+	defer func() {
+		syslogMu.Lock()
+		delete(syslogConns, conn)
+		syslogMu.Unlock()
+		conn.Close()
+		<-syslogSlots
+		syslogWg.Done()
+	}()
+
+	maxBytes := types.SyslogCfg.MaxMessageBytes
+	reader := bufio.NewReaderSize(conn, maxBytes)
+	for {
+		conn.SetReadDeadline(time.Now().Add(SYSLOG_IDLE_TIMEOUT))
+
+		message, err := readSyslogFrame(reader, maxBytes)
+		if message != "" {
+			handleSyslogMessage(message, conn.RemoteAddr())
+		}
+		if err != nil {
+			if err != io.EOF && !errors.Is(err, net.ErrClosed) {
+				log.Errorf("syslog read from %s; err: %v", conn.RemoteAddr(), err)
+			}
+			return
+		}
+	}
+}
+
+/******************************************************************************
+* FUNCTION:        readSyslogFrame
+*
+* DESCRIPTION:     Reads the next framed message of a stream
+* INPUT:           reader, max message size
+* RETURNS:         message, error
+******************************************************************************/
+func readSyslogFrame(reader *bufio.Reader, maxBytes int) (string, error) {
+	first, err := reader.Peek(1)
+	if err != nil {
+		return "", err
+	}
+
+	if first[0] >= '0' && first[0] <= '9' {
+		lengthField, err := reader.ReadSlice(' ')
+		if err != nil {
+			return "", err
+		}
+		lengthStr := strings.TrimSpace(string(lengthField))
+		length, err := strconv.Atoi(lengthStr)
+		if err != nil || length <= 0 || length > maxBytes {
+			return "", fmt.Errorf("invalid octet count %q", lengthStr)
+		}
+
+		frame := make([]byte, length)
+		if _, err := io.ReadFull(reader, frame); err != nil {
+			return "", err
+		}
+		return string(frame), nil
+	}
+
+	line, err := reader.ReadSlice('\n')
+	if errors.Is(err, bufio.ErrBufferFull) {
+		// keep the head of an oversize message and skip the rest
+		message := string(line)
+		for errors.Is(err, bufio.ErrBufferFull) {
+			_, err = reader.ReadSlice('\n')
+		}
+		return message, err
+	}
+
+	return strings.TrimRight(string(line), "\r\n"), err
+}
+
+/******************************************************************************
+* FUNCTION:        handleSyslogMessage
+*
+* DESCRIPTION:     Parses a message and hands it to the stream of the
+*                  sending address. The host name of a message is chosen
+*                  by the sender, it is kept as the hostname attribute
+*                  but does not create streams
+* INPUT:           raw message, remote address
+* RETURNS:         void
+******************************************************************************/
+func handleSyslogMessage(raw string, addr net.Addr) {
+	raw = sanitizeText(raw)
+	if strings.TrimSpace(raw) == "" {
+		return
+	}
+
+	msg, err := parseSyslogMessage(raw, time.Now())
+	if err != nil {
+		log.Errorf("dropping syslog message from %s; err: %v", addr, err)
+		return
+	}
+
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		host = addr.String()
+	}
+
+	entry := msg.toLogEntry()
+	if msg.Hostname != "" {
+		entry.Attributes = map[string]interface{}{"hostname": msg.Hostname}
+	}
+
+	err = AppendStreamEntries(types.SyslogCfg.UserId, syslogStreamName(host), []LogEntry{entry})
+	if err != nil {
+		log.Errorf("dropping syslog message from %s; err: %v", addr, err)
+	}
+}
+
+/******************************************************************************
+* FUNCTION:        syslogStreamName
+*
+* DESCRIPTION:     Builds a valid stream name out of the sending address
+* INPUT:           host
+* RETURNS:         string
+******************************************************************************/
+func syslogStreamName(host string) string {
+	name := unsafeStreamNameRegex.ReplaceAllString(host, "_")
+	if name == "" {
+		name = "unknown"
+	}
+
+	name = SYSLOG_STREAM_PREFIX + name
+	if len(name) > 64 {
+		name = name[:64]
+	}
+
+	return name
+}
+
+/******************************************************************************
+* FUNCTION:        trackSyslogListener
+*
+* DESCRIPTION:     Helper function to remember a listener for shutdown
+* INPUT:           listener
+* RETURNS:         void
+******************************************************************************/
+func trackSyslogListener(listener io.Closer) {
+	syslogMu.Lock()
+	syslogListeners = append(syslogListeners, listener)
+	syslogMu.Unlock()
+}
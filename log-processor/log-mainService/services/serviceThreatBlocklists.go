@@ -0,0 +1,389 @@
+/**************************************************************************
+ * File       	   : serviceThreatBlocklists.go
+ * DESCRIPTION     : This file contains the parsing of the threat intel
+ *                   blocklists uploaded by admins (plain text, CSV and
+ *                   STIX-lite JSON) and their storage
+ * DATE            : 19-October-2026
+ **************************************************************************/
+
+package services
+
+import (
+	"LOGProcessor/shared/db"
+	"LOGProcessor/shared/types"
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	BLOCKLIST_FORMAT_TEXT = "text"
+	BLOCKLIST_FORMAT_CSV  = "csv"
+	BLOCKLIST_FORMAT_STIX = "stix"
+
+	BLOCKLIST_MAX_BYTES      = 64 << 20
+	BLOCKLIST_MAX_INDICATORS = 1000000
+	BLOCKLIST_INSERT_BATCH   = 1000
+)
+
+var (
+	ErrBlocklistTooLarge = errors.New("blocklist exceeds 64 MiB or 1000000 indicators")
+
+	blocklistDomainRegex = regexp.MustCompile(`^(?:[a-z0-9](?:[a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z][a-z0-9-]{0,61}[a-z0-9]$`)
+	// the values of a STIX pattern, [ipv4-addr:value = '203.0.113.7' OR
+	// domain-name:value = 'evil.example']
+	stixPatternValueRegex = regexp.MustCompile(`(ipv4-addr|ipv6-addr|domain-name):value\s*=\s*'((?:[^'\\]|\\.)*)'`)
+
+	// header names of the column holding the indicator in a CSV list
+	blocklistCsvColumns = map[string]bool{
+		"indicator": true, "ioc": true, "value": true, "ip": true, "ip_address": true,
+		"domain": true, "hostname": true, "host": true, "cidr": true, "network": true,
+	}
+
+	// addresses of hosts-file lists, "0.0.0.0 evil.example"
+	hostsFileSinks = map[string]bool{"0.0.0.0": true, "127.0.0.1": true, "::": true, "::1": true}
+)
+
+// threatIndicator is a network or a domain of a blocklist
+type threatIndicator struct {
+	Kind  string
+	Value string
+}
+
+// parsedBlocklist holds the distinct indicators of a list and the number
+// of values that were neither a network nor a domain
+type parsedBlocklist struct {
+	Indicators []threatIndicator
+	Skipped    int
+	seen       map[threatIndicator]bool
+}
+
+/******************************************************************************
+* FUNCTION:        blocklistFormatOf
+*
+* DESCRIPTION:     Helper function picking the format of an upload without
+*                  an explicit one from the file name
+* INPUT:           file name
+* RETURNS:         format
+******************************************************************************/
+func blocklistFormatOf(fileName string) string {
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".csv":
+		return BLOCKLIST_FORMAT_CSV
+	case ".json":
+		return BLOCKLIST_FORMAT_STIX
+	default:
+		return BLOCKLIST_FORMAT_TEXT
+	}
+}
+
+/******************************************************************************
+* FUNCTION:        isBlocklistFormat
+*
+* DESCRIPTION:     Helper function validating a format
+* INPUT:           format
+* RETURNS:         bool
+******************************************************************************/
+func isBlocklistFormat(format string) bool {
+	return format == BLOCKLIST_FORMAT_TEXT || format == BLOCKLIST_FORMAT_CSV || format == BLOCKLIST_FORMAT_STIX
+}
+
+/******************************************************************************
+* FUNCTION:        classifyIndicator
+*
+* DESCRIPTION:     Turns a value of a list into an indicator: an address or
+*                  network, or a domain. Defanged values (evil[.]example,
+*                  hxxp://) and urls are accepted, the host is kept
+* INPUT:           value
+* RETURNS:         indicator, ok
+******************************************************************************/
+func classifyIndicator(value string) (threatIndicator, bool) {
+	value = strings.Trim(strings.TrimSpace(value), `"'`)
+	value = strings.NewReplacer("[.]", ".", "(.)", ".", "[:]", ":", "hxxp", "http").Replace(value)
+	if value == "" {
+		return threatIndicator{}, false
+	}
+
+	if strings.Contains(value, "://") {
+		parsed, err := url.Parse(value)
+		if err != nil || parsed.Hostname() == "" {
+			return threatIndicator{}, false
+		}
+		value = parsed.Hostname()
+	}
+
+	if prefix, err := parseCidr(value); err == nil {
+		return threatIndicator{Kind: THREAT_KIND_CIDR, Value: prefix.String()}, true
+	}
+
+	domain := strings.TrimPrefix(strings.TrimSuffix(strings.ToLower(value), "."), "*.")
+	if blocklistDomainRegex.MatchString(domain) && len(domain) <= 253 {
+		return threatIndicator{Kind: THREAT_KIND_DOMAIN, Value: domain}, true
+	}
+	return threatIndicator{}, false
+}
+
+/******************************************************************************
+* FUNCTION:        add
+*
+* DESCRIPTION:     Adds a value of the list, counting it as skipped when it
+*                  is no indicator
+* INPUT:           value
+* RETURNS:         error once the list has too many indicators
+******************************************************************************/
+func (p *parsedBlocklist) add(value string) error {
+	indicator, ok := classifyIndicator(value)
+	if !ok {
+		p.Skipped++
+		return nil
+	}
+	if p.seen[indicator] {
+		return nil
+	}
+	if len(p.Indicators) >= BLOCKLIST_MAX_INDICATORS {
+		return ErrBlocklistTooLarge
+	}
+	p.seen[indicator] = true
+	p.Indicators = append(p.Indicators, indicator)
+	return nil
+}
+
+/******************************************************************************
+* FUNCTION:        parseBlocklist
+*
+* DESCRIPTION:     Reads the indicators of a list in one of the formats
+*                  text: one value per line, # comments, hosts-file lines
+*                  csv:  the column named indicator, ip, domain... or the
+*                        first one
+*                  stix: a bundle or an array of objects, indicators with
+*                        an ipv4-addr, ipv6-addr or domain-name pattern and
+*                        observables of those types
+* INPUT:           reader, format
+* RETURNS:         parsedBlocklist, error
+******************************************************************************/
+func parseBlocklist(reader io.Reader, format string) (*parsedBlocklist, error) {
+	limited := &io.LimitedReader{R: reader, N: BLOCKLIST_MAX_BYTES + 1}
+	parsed := &parsedBlocklist{seen: make(map[threatIndicator]bool)}
+
+	var err error
+	switch format {
+	case BLOCKLIST_FORMAT_CSV:
+		err = parseCsvBlocklist(limited, parsed)
+	case BLOCKLIST_FORMAT_STIX:
+		err = parseStixBlocklist(limited, parsed)
+	default:
+		err = parseTextBlocklist(limited, parsed)
+	}
+	if limited.N <= 0 {
+		return nil, ErrBlocklistTooLarge
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parsed, nil
+}
+
+/******************************************************************************
+* FUNCTION:        parseTextBlocklist
+*
+* DESCRIPTION:     Helper function reading a plain text list
+* INPUT:           reader, list
+* RETURNS:         error
+******************************************************************************/
+func parseTextBlocklist(reader io.Reader, parsed *parsedBlocklist) error {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		if hash := strings.IndexByte(line, '#'); hash >= 0 {
+			line = line[:hash]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		value := fields[0]
+		if len(fields) > 1 && hostsFileSinks[fields[0]] {
+			value = fields[1]
+		}
+		if err := parsed.add(value); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+/******************************************************************************
+* FUNCTION:        parseCsvBlocklist
+*
+* DESCRIPTION:     Helper function reading a CSV list. A first row naming a
+*                  known column is a header, otherwise the first column is
+*                  read from the first row on
+* INPUT:           reader, list
+* RETURNS:         error
+******************************************************************************/
+func parseCsvBlocklist(reader io.Reader, parsed *parsedBlocklist) error {
+	csvReader := csv.NewReader(reader)
+	csvReader.FieldsPerRecord = -1
+	csvReader.Comment = '#'
+	csvReader.LazyQuotes = true
+	csvReader.ReuseRecord = true
+
+	column, first := 0, true
+	for {
+		record, err := csvReader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("invalid csv: %v", err)
+		}
+
+		if first {
+			first = false
+			header := false
+			for i, name := range record {
+				if blocklistCsvColumns[strings.ToLower(strings.TrimSpace(name))] {
+					column, header = i, true
+					break
+				}
+			}
+			if header {
+				continue
+			}
+		}
+
+		if column >= len(record) {
+			parsed.Skipped++
+			continue
+		}
+		if err = parsed.add(record[column]); err != nil {
+			return err
+		}
+	}
+}
+
+/******************************************************************************
+* FUNCTION:        parseStixBlocklist
+*
+* DESCRIPTION:     Helper function reading a STIX-lite list. Only what is
+*                  needed to find addresses and domains is decoded
+* INPUT:           reader, list
+* RETURNS:         error
+******************************************************************************/
+func parseStixBlocklist(reader io.Reader, parsed *parsedBlocklist) error {
+	type stixObject struct {
+		Type    string `json:"type"`
+		Pattern string `json:"pattern"`
+		Value   string `json:"value"`
+	}
+
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+
+	var objects []stixObject
+	if trimmed := strings.TrimSpace(string(body)); strings.HasPrefix(trimmed, "[") {
+		err = json.Unmarshal(body, &objects)
+	} else {
+		var bundle struct {
+			Objects []stixObject `json:"objects"`
+		}
+		err = json.Unmarshal(body, &bundle)
+		objects = bundle.Objects
+	}
+	if err != nil {
+		return fmt.Errorf("invalid stix json: %v", err)
+	}
+
+	for _, object := range objects {
+		var values []string
+		switch object.Type {
+		case "indicator":
+			for _, match := range stixPatternValueRegex.FindAllStringSubmatch(object.Pattern, -1) {
+				values = append(values, strings.ReplaceAll(match[2], `\'`, `'`))
+			}
+			if len(values) == 0 {
+				parsed.Skipped++
+			}
+		case "ipv4-addr", "ipv6-addr", "domain-name":
+			values = append(values, object.Value)
+		}
+
+		for _, value := range values {
+			if err = parsed.add(value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+/******************************************************************************
+* FUNCTION:        storeBlocklist
+*
+* DESCRIPTION:     Stores a list, replacing the indicators of the list of
+*                  the same name in one transaction
+* INPUT:           name, format, parsed list
+* RETURNS:         blocklist id, error
+******************************************************************************/
+func storeBlocklist(name, format string, parsed *parsedBlocklist) (blocklistId int64, err error) {
+	ctx := context.Background()
+	tx, err := types.Db.DbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	upsert := `
+	INSERT INTO threat_blocklists (name, format, indicator_count, skipped_count, updated_at)
+	VALUES ($1, $2, $3, $4, $5)
+	ON CONFLICT (name) DO UPDATE SET format = EXCLUDED.format, indicator_count = EXCLUDED.indicator_count,
+		skipped_count = EXCLUDED.skipped_count, updated_at = EXCLUDED.updated_at
+	RETURNING blocklist_id`
+
+	err = tx.QueryRowContext(ctx, upsert, name, format, len(parsed.Indicators), parsed.Skipped, time.Now()).Scan(&blocklistId)
+	if err != nil {
+		return 0, fmt.Errorf("failed to store blocklist: %v", err)
+	}
+
+	if _, err = db.UpdateDataInDB(tx, "DELETE FROM threat_indicators WHERE blocklist_id = $1", []interface{}{blocklistId}); err != nil {
+		return 0, fmt.Errorf("failed to replace indicators: %v", err)
+	}
+
+	for start := 0; start < len(parsed.Indicators); start += BLOCKLIST_INSERT_BATCH {
+		end := min(start+BLOCKLIST_INSERT_BATCH, len(parsed.Indicators))
+		batch := make([]map[string]interface{}, 0, end-start)
+		for _, indicator := range parsed.Indicators[start:end] {
+			batch = append(batch, map[string]interface{}{
+				"blocklist_id": blocklistId,
+				"kind":         indicator.Kind,
+				"value":        indicator.Value,
+			})
+		}
+		if err = db.AddMultipleRecordInDB(tx, "threat_indicators", batch); err != nil {
+			return 0, fmt.Errorf("failed to insert indicators: %v", err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return blocklistId, nil
+}
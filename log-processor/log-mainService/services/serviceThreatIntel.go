@@ -0,0 +1,475 @@
+/**************************************************************************
+ * File       	   : serviceThreatIntel.go
+ * DESCRIPTION     : This file contains the in-memory matcher of the threat
+ *                   intel blocklists: a radix tree of the networks and a
+ *                   set of the domains, reloaded when the lists change, and
+ *                   the flagging of the entries touching an indicator
+ * DATE            : 19-October-2026
+ **************************************************************************/
+
+package services
+
+import (
+	"LOGProcessor/shared/db"
+	"LOGProcessor/shared/types"
+	"database/sql"
+	"fmt"
+	"math/bits"
+	"net/netip"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/martian/log"
+)
+
+const (
+	THREAT_KIND_CIDR   = "cidr"
+	THREAT_KIND_DOMAIN = "domain"
+	// distinct indicators listed in a threat-hit message
+	THREAT_HIT_MAX_INDICATORS = 20
+)
+
+// host names of a message: labels separated by dots, the last one starting
+// with a letter so that addresses and version numbers are left out
+var domainCandidateRegex = regexp.MustCompile(`(?i)\b(?:[a-z0-9](?:[a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z][a-z0-9-]{0,61}[a-z0-9]\b`)
+
+// threatMatch is the indicator an entry touched and the list it is from
+type threatMatch struct {
+	Indicator string
+	List      string
+}
+
+// cidrTrieNode is a node of a path compressed binary radix tree. A node
+// without a match only joins the subtrees below it
+type cidrTrieNode struct {
+	prefix   netip.Prefix
+	match    *threatMatch
+	children [2]*cidrTrieNode
+}
+
+// threatMatcher is built once per reload and never modified afterwards,
+// lookups need no lock
+type threatMatcher struct {
+	root4   *cidrTrieNode
+	root6   *cidrTrieNode
+	cidrs   int
+	domains map[string]*threatMatch
+	version string
+}
+
+// ThreatIndicatorHit is an indicator with the number of entries touching it
+type ThreatIndicatorHit struct {
+	Indicator string `json:"indicator"`
+	List      string `json:"list"`
+	Count     int64  `json:"count"`
+}
+
+var (
+	threatMatcherPtr atomic.Pointer[threatMatcher]
+	threatStop       chan struct{}
+	threatDone       chan struct{}
+	threatMu         sync.Mutex
+	// reloads of the ticker and of the admin api are not run concurrently
+	threatReloadMu sync.Mutex
+)
+
+/******************************************************************************
+* FUNCTION:        insert
+*
+* DESCRIPTION:     Adds a network to the tree below a root. When a network
+*                  is listed twice the first list keeps it
+* INPUT:           root, prefix (masked), match
+* RETURNS:         void
+******************************************************************************/
+func (m *threatMatcher) insert(root **cidrTrieNode, prefix netip.Prefix, match *threatMatch) {
+	node := root
+	for {
+		n := *node
+		if n == nil {
+			*node = &cidrTrieNode{prefix: prefix, match: match}
+			return
+		}
+
+		common := commonPrefixBits(n.prefix, prefix)
+		if common == n.prefix.Bits() {
+			if common == prefix.Bits() {
+				if n.match == nil {
+					n.match = match
+				}
+				return
+			}
+			node = &n.children[addrBit(prefix.Addr(), common)]
+			continue
+		}
+
+		split := &cidrTrieNode{prefix: netip.PrefixFrom(prefix.Addr(), common).Masked()}
+		split.children[addrBit(n.prefix.Addr(), common)] = n
+		if common == prefix.Bits() {
+			split.match = match
+		} else {
+			split.children[addrBit(prefix.Addr(), common)] = &cidrTrieNode{prefix: prefix, match: match}
+		}
+		*node = split
+		return
+	}
+}
+
+/******************************************************************************
+* FUNCTION:        lookupAddr
+*
+* DESCRIPTION:     Returns the most specific listed network containing an
+*                  address
+* INPUT:           addr
+* RETURNS:         match or nil
+******************************************************************************/
+func (m *threatMatcher) lookupAddr(addr netip.Addr) *threatMatch {
+	node := m.root6
+	if addr.Is4() {
+		node = m.root4
+	}
+
+	var found *threatMatch
+	for node != nil && node.prefix.Contains(addr) {
+		if node.match != nil {
+			found = node.match
+		}
+		if node.prefix.Bits() == addr.BitLen() {
+			break
+		}
+		node = node.children[addrBit(addr, node.prefix.Bits())]
+	}
+	return found
+}
+
+/******************************************************************************
+* FUNCTION:        lookupDomain
+*
+* DESCRIPTION:     Returns the listed domain a host name is or is below,
+*                  "a.evil.example" matches a listed "evil.example"
+* INPUT:           host name
+* RETURNS:         match or nil
+******************************************************************************/
+func (m *threatMatcher) lookupDomain(host string) *threatMatch {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	for {
+		if match, ok := m.domains[host]; ok {
+			return match
+		}
+		dot := strings.IndexByte(host, '.')
+		if dot < 0 || strings.IndexByte(host[dot+1:], '.') < 0 {
+			// a bare top level domain is never matched
+			return nil
+		}
+		host = host[dot+1:]
+	}
+}
+
+/******************************************************************************
+* FUNCTION:        commonPrefixBits
+*
+* DESCRIPTION:     Helper function returning the number of leading bits two
+*                  prefixes of the same family share, at most the shorter
+*                  prefix length
+* INPUT:           a, b
+* RETURNS:         bits
+******************************************************************************/
+func commonPrefixBits(a, b netip.Prefix) int {
+	limit := min(a.Bits(), b.Bits())
+	aBytes, bBytes := a.Addr().AsSlice(), b.Addr().AsSlice()
+
+	common := 0
+	for i := range aBytes {
+		diff := aBytes[i] ^ bBytes[i]
+		if diff != 0 {
+			common += bits.LeadingZeros8(diff)
+			break
+		}
+		common += 8
+		if common >= limit {
+			break
+		}
+	}
+	return min(common, limit)
+}
+
+/******************************************************************************
+* FUNCTION:        addrBit
+*
+* DESCRIPTION:     Helper function returning bit i of an address, 0 being
+*                  the most significant
+* INPUT:           addr, i
+* RETURNS:         0 or 1
+******************************************************************************/
+func addrBit(addr netip.Addr, i int) int {
+	b := addr.AsSlice()
+	return int(b[i/8]>>(7-uint(i%8))) & 1
+}
+
+/******************************************************************************
+* FUNCTION:        StartThreatIntel
+*
+* DESCRIPTION:     Loads the blocklists and starts checking them for changes
+*                  every THREAT_RELOAD_INTERVAL
+* INPUT:           None
+* RETURNS:         void
+******************************************************************************/
+func StartThreatIntel() {
+	threatMu.Lock()
+	defer threatMu.Unlock()
+
+	if err := reloadThreatMatcher(); err != nil {
+		log.Errorf("failed to load threat blocklists; err: %v", err)
+	}
+
+	threatStop = make(chan struct{})
+	threatDone = make(chan struct{})
+	go func() {
+		defer close(threatDone)
+
+		ticker := time.NewTicker(types.ThreatIntelCfg.ReloadInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-threatStop:
+				return
+			case <-ticker.C:
+				if err := reloadThreatMatcher(); err != nil {
+					log.Errorf("failed to reload threat blocklists; err: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+/******************************************************************************
+* FUNCTION:        StopThreatIntel
+*
+* DESCRIPTION:     Stops the reload loop
+* INPUT:           None
+* RETURNS:         void
+******************************************************************************/
+func StopThreatIntel() {
+	threatMu.Lock()
+	defer threatMu.Unlock()
+
+	if threatStop == nil {
+		return
+	}
+	close(threatStop)
+	<-threatDone
+	threatStop = nil
+}
+
+/******************************************************************************
+* FUNCTION:        reloadThreatMatcher
+*
+* DESCRIPTION:     Rebuilds the matcher when the blocklists changed since it
+*                  was built. On error the previous matcher stays in use
+* INPUT:           None
+* RETURNS:         error
+******************************************************************************/
+func reloadThreatMatcher() error {
+	defer PanicRecovery("reloadThreatMatcher")
+
+	threatReloadMu.Lock()
+	defer threatReloadMu.Unlock()
+
+	result, err := db.GetDataFromDB("SELECT COUNT(*) AS lists, COALESCE(SUM(indicator_count), 0) AS indicators, MAX(updated_at) AS updated_at FROM threat_blocklists", nil)
+	if err != nil {
+		return err
+	}
+	version := fmt.Sprint(result[0]["lists"], "/", result[0]["indicators"], "/", result[0]["updated_at"])
+
+	if current := threatMatcherPtr.Load(); current != nil && current.version == version {
+		return nil
+	}
+
+	query := `
+	SELECT b.name, i.kind, i.value FROM threat_indicators i
+	JOIN threat_blocklists b ON b.blocklist_id = i.blocklist_id
+	ORDER BY b.blocklist_id ASC`
+
+	rows, err := db.GetDataFromDB(query, nil)
+	if err != nil {
+		return err
+	}
+
+	matcher := &threatMatcher{domains: make(map[string]*threatMatch), version: version}
+	for _, row := range rows {
+		list, _ := row["name"].(string)
+		kind, _ := row["kind"].(string)
+		value, _ := row["value"].(string)
+		match := &threatMatch{Indicator: value, List: list}
+
+		switch kind {
+		case THREAT_KIND_CIDR:
+			prefix, err := netip.ParsePrefix(value)
+			if err != nil {
+				continue
+			}
+			if prefix.Addr().Is4() {
+				matcher.insert(&matcher.root4, prefix, match)
+			} else {
+				matcher.insert(&matcher.root6, prefix, match)
+			}
+			matcher.cidrs++
+		case THREAT_KIND_DOMAIN:
+			if _, ok := matcher.domains[value]; !ok {
+				matcher.domains[value] = match
+			}
+		}
+	}
+
+	threatMatcherPtr.Store(matcher)
+	log.Infof("loaded threat blocklists: %d networks, %d domains", matcher.cidrs, len(matcher.domains))
+	return nil
+}
+
+/******************************************************************************
+* FUNCTION:        matchEntry
+*
+* DESCRIPTION:     Returns the first indicator an entry touches: its ip,
+*                  then the host names in its message and string attributes
+* INPUT:           entry
+* RETURNS:         match or nil
+******************************************************************************/
+func (m *threatMatcher) matchEntry(entry *LogEntry) *threatMatch {
+	if entry.IP != "" && (m.root4 != nil || m.root6 != nil) {
+		if addr, err := netip.ParseAddr(entry.IP); err == nil {
+			if match := m.lookupAddr(addr); match != nil {
+				return match
+			}
+		}
+	}
+
+	if len(m.domains) == 0 {
+		return nil
+	}
+	if match := m.matchText(entry.Message); match != nil {
+		return match
+	}
+	for _, value := range entry.Attributes {
+		if text, ok := value.(string); ok {
+			if match := m.matchText(text); match != nil {
+				return match
+			}
+		}
+	}
+	return nil
+}
+
+/******************************************************************************
+* FUNCTION:        matchText
+*
+* DESCRIPTION:     Helper function returning the first listed domain among
+*                  the host names of a text
+* INPUT:           text
+* RETURNS:         match or nil
+******************************************************************************/
+func (m *threatMatcher) matchText(text string) *threatMatch {
+	for _, host := range domainCandidateRegex.FindAllString(text, -1) {
+		if match := m.lookupDomain(host); match != nil {
+			return match
+		}
+	}
+	return nil
+}
+
+/******************************************************************************
+* FUNCTION:        matchThreatEntries
+*
+* DESCRIPTION:     Flags the entries touching an indicator of the loaded
+*                  blocklists. Runs before redaction, which may mask the
+*                  values matched
+* INPUT:           entries
+* RETURNS:         number of entries flagged
+******************************************************************************/
+func matchThreatEntries(entries []LogEntry) int64 {
+	matcher := threatMatcherPtr.Load()
+	if matcher == nil || (matcher.cidrs == 0 && len(matcher.domains) == 0) {
+		return 0
+	}
+
+	var hits int64
+	for i := range entries {
+		if match := matcher.matchEntry(&entries[i]); match != nil {
+			entries[i].ThreatIndicator = match.Indicator
+			entries[i].ThreatList = match.List
+			hits++
+		}
+	}
+	return hits
+}
+
+/******************************************************************************
+* FUNCTION:        summarizeThreatHits
+*
+* DESCRIPTION:     Counts the flagged entries per indicator, most hit first
+* INPUT:           entries
+* RETURNS:         hits, indicators (at most THREAT_HIT_MAX_INDICATORS)
+******************************************************************************/
+func summarizeThreatHits(entries []LogEntry) (int64, []ThreatIndicatorHit) {
+	var hits int64
+	counts := make(map[threatMatch]int64)
+	for _, entry := range entries {
+		if entry.ThreatIndicator != "" {
+			counts[threatMatch{Indicator: entry.ThreatIndicator, List: entry.ThreatList}]++
+			hits++
+		}
+	}
+
+	indicators := make([]ThreatIndicatorHit, 0, len(counts))
+	for match, count := range counts {
+		indicators = append(indicators, ThreatIndicatorHit{Indicator: match.Indicator, List: match.List, Count: count})
+	}
+	sort.Slice(indicators, func(i, j int) bool {
+		if indicators[i].Count != indicators[j].Count {
+			return indicators[i].Count > indicators[j].Count
+		}
+		return indicators[i].Indicator < indicators[j].Indicator
+	})
+	if len(indicators) > THREAT_HIT_MAX_INDICATORS {
+		indicators = indicators[:THREAT_HIT_MAX_INDICATORS]
+	}
+
+	return hits, indicators
+}
+
+/******************************************************************************
+* FUNCTION:        storeThreatHits
+*
+* DESCRIPTION:     Records the number of entries of a file that touched an
+*                  indicator
+* INPUT:           tx, fileID, hits
+* RETURNS:         error
+******************************************************************************/
+func storeThreatHits(tx *sql.Tx, fileID int64, hits int64) error {
+	return db.UpdateSingleRecord(tx, "file_stats", "file_id", fileID, map[string]interface{}{"threat_hits": hits})
+}
+
+/******************************************************************************
+* FUNCTION:        broadcastThreatHits
+*
+* DESCRIPTION:     Sends a threat-hit message to the user when entries of a
+*                  file touched an indicator
+* INPUT:           userId, fileID, entries
+* RETURNS:         void
+******************************************************************************/
+func broadcastThreatHits(userId string, fileID int64, entries []LogEntry) {
+	hits, indicators := summarizeThreatHits(entries)
+	if hits == 0 {
+		return
+	}
+
+	data := map[string]interface{}{
+		"file_id":     fileID,
+		"threat_hits": hits,
+		"indicators":  indicators,
+	}
+	BroadcastMessage(data, "threat-hit", userId)
+}
@@ -0,0 +1,410 @@
+/**************************************************************************
+ * File       	   : serviceTimestampRecognizer.go
+ * DESCRIPTION     : This file contains the recognizer of the timestamp
+ *                   formats found in log lines. The format of a file is
+ *                   detected on its first lines and then locked, times
+ *                   without zone are read in the source timezone and
+ *                   year-less times get their year inferred
+ * DATE            : 19-October-2026
+ **************************************************************************/
+
+package services
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// number of timestamps after which the format of a file is locked
+	TIMESTAMP_DETECT_SAMPLES = 100
+	// year-less times more than this far in the future belong to the
+	// previous year
+	TIMESTAMP_FUTURE_TOLERANCE = 24 * time.Hour
+)
+
+var ErrInvalidTimezone = errors.New("invalid timezone")
+
+// timestampLayout is a family of timestamp formats. Pattern finds the
+// timestamp at the start of a value, Layouts parse it
+type timestampLayout struct {
+	Name     string
+	Pattern  *regexp.Regexp
+	Layouts  []string
+	YearLess bool
+	Epoch    bool
+}
+
+var timestampLayouts = []timestampLayout{
+	{
+		// 2006-01-02T15:04:05Z07:00, with a space instead of T, a
+		// fraction after a point or comma, with or without zone
+		Name:    "iso8601",
+		Pattern: regexp.MustCompile(`^\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}(?:[.,]\d{1,9})?(?:Z|[+-]\d{2}:?\d{2})?`),
+		Layouts: []string{"2006-01-02T15:04:05Z07:00", "2006-01-02T15:04:05Z0700", "2006-01-02T15:04:05"},
+	},
+	{
+		Name:    "slashed",
+		Pattern: regexp.MustCompile(`^\d{4}/\d{2}/\d{2}[T ]\d{2}:\d{2}:\d{2}(?:[.,]\d{1,9})?`),
+		Layouts: []string{"2006/01/02T15:04:05"},
+	},
+	{
+		// access log time
+		Name:    "httpdate",
+		Pattern: regexp.MustCompile(`^\d{2}/[A-Z][a-z]{2}/\d{4}:\d{2}:\d{2}:\d{2}(?:[.,]\d{1,9})? [+-]\d{4}`),
+		Layouts: []string{"02/Jan/2006:15:04:05 -0700"},
+	},
+	{
+		Name:    "rfc1123",
+		Pattern: regexp.MustCompile(`^[A-Z][a-z]{2}, \d{2} [A-Z][a-z]{2} \d{4} \d{2}:\d{2}:\d{2} (?:[+-]\d{4}|[A-Z]{3,4})`),
+		Layouts: []string{time.RFC1123Z, time.RFC1123},
+	},
+	{
+		Name:    "ansic",
+		Pattern: regexp.MustCompile(`^[A-Z][a-z]{2} [A-Z][a-z]{2} [ \d]\d \d{2}:\d{2}:\d{2}(?:[.,]\d{1,9})?(?: [A-Z]{3,4})? \d{4}`),
+		Layouts: []string{time.ANSIC, time.UnixDate},
+	},
+	{
+		// syslog style, the year is inferred
+		Name:     "stamp",
+		Pattern:  regexp.MustCompile(`^[A-Z][a-z]{2} [ \d]?\d \d{2}:\d{2}:\d{2}(?:[.,]\d{1,9})?`),
+		Layouts:  []string{time.Stamp, "Jan 2 15:04:05"},
+		YearLess: true,
+	},
+	{
+		Name:    "dd-mmm-yyyy",
+		Pattern: regexp.MustCompile(`^\d{2}-[A-Z][a-z]{2}-\d{4} \d{2}:\d{2}:\d{2}(?:[.,]\d{1,9})?`),
+		Layouts: []string{"02-Jan-2006 15:04:05"},
+	},
+	{
+		Name:    "us",
+		Pattern: regexp.MustCompile(`^\d{2}/\d{2}/\d{4} \d{2}:\d{2}:\d{2}(?:[.,]\d{1,9})?`),
+		Layouts: []string{"01/02/2006 15:04:05"},
+	},
+	{
+		Name:    "eu",
+		Pattern: regexp.MustCompile(`^\d{2}\.\d{2}\.\d{4} \d{2}:\d{2}:\d{2}(?:[.,]\d{1,9})?`),
+		Layouts: []string{"02.01.2006 15:04:05"},
+	},
+	{
+		// seconds with an optional fraction, millis, micros or nanos
+		Name:    "epoch",
+		Pattern: regexp.MustCompile(`^(?:\d{19}|\d{16}|\d{13}|\d{10}(?:\.\d{1,9})?)`),
+		Epoch:   true,
+	},
+}
+
+// defaultTimestamps reads zone-less times as UTC and does not lock, so it
+// can be shared by concurrent requests
+var defaultTimestamps = &timestampRecognizer{location: time.UTC, locked: -1}
+
+// timestampRecognizer parses the timestamps of one source
+type timestampRecognizer struct {
+	location  *time.Location
+	reference time.Time
+	detect    bool
+
+	mu      sync.Mutex
+	locked  int
+	counts  []int
+	samples int
+}
+
+/******************************************************************************
+* FUNCTION:        newTimestampRecognizer
+*
+* DESCRIPTION:     Creates the recognizer of a file. Times without zone are
+*                  read in location, year-less times are placed relative
+*                  to reference
+* INPUT:           location, reference time
+* RETURNS:         *timestampRecognizer
+******************************************************************************/
+func newTimestampRecognizer(location *time.Location, reference time.Time) *timestampRecognizer {
+	if location == nil {
+		location = time.UTC
+	}
+
+	return &timestampRecognizer{
+		location:  location,
+		reference: reference,
+		detect:    true,
+		locked:    -1,
+		counts:    make([]int, len(timestampLayouts)),
+	}
+}
+
+/******************************************************************************
+* FUNCTION:        parseLogTimestamp
+*
+* DESCRIPTION:     Parses a timestamp value with any of the known formats,
+*                  zone-less times are UTC
+* INPUT:           value
+* RETURNS:         time.Time, ok
+******************************************************************************/
+func parseLogTimestamp(value string) (time.Time, bool) {
+	return defaultTimestamps.parse(value)
+}
+
+/******************************************************************************
+* FUNCTION:        parse
+*
+* DESCRIPTION:     Parses a value that is a timestamp as a whole, once the
+*                  format is locked only that format is accepted
+* INPUT:           value
+* RETURNS:         time.Time, ok
+******************************************************************************/
+func (r *timestampRecognizer) parse(value string) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+
+	for _, index := range r.order() {
+		layout := &timestampLayouts[index]
+		if raw := layout.Pattern.FindString(value); raw == "" || len(raw) != len(value) {
+			continue
+		}
+		if timestamp, ok := r.parseWith(layout, value); ok {
+			r.record(index)
+			return timestamp, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+/******************************************************************************
+* FUNCTION:        parsePrefix
+*
+* DESCRIPTION:     Parses the timestamp a line starts with. The timestamp
+*                  must end at a word boundary, once the format is locked
+*                  only that format is accepted
+* INPUT:           line
+* RETURNS:         timestamp, raw timestamp, rest of the line, layout, ok
+******************************************************************************/
+func (r *timestampRecognizer) parsePrefix(line string) (time.Time, string, string, *timestampLayout, bool) {
+	for _, index := range r.order() {
+		layout := &timestampLayouts[index]
+		raw := layout.Pattern.FindString(line)
+		if raw == "" {
+			continue
+		}
+		if len(line) > len(raw) && isTimestampChar(line[len(raw)]) {
+			continue
+		}
+		if timestamp, ok := r.parseWith(layout, raw); ok {
+			r.record(index)
+			return timestamp, raw, line[len(raw):], layout, true
+		}
+	}
+
+	return time.Time{}, "", "", nil, false
+}
+
+/******************************************************************************
+* FUNCTION:        looksLikeTimestamp
+*
+* DESCRIPTION:     Tells whether a line starts with something shaped like a
+*                  timestamp of any format, used to tell a bad timestamp
+*                  from a line in another format
+* INPUT:           line
+* RETURNS:         bool
+******************************************************************************/
+func (r *timestampRecognizer) looksLikeTimestamp(line string) bool {
+	for i := range timestampLayouts {
+		if timestampLayouts[i].Epoch {
+			continue
+		}
+		if timestampLayouts[i].Pattern.MatchString(line) {
+			return true
+		}
+	}
+	return false
+}
+
+/******************************************************************************
+* FUNCTION:        parseWith
+*
+* DESCRIPTION:     Parses a raw timestamp with a layout and returns it in
+*                  UTC
+* INPUT:           layout, raw timestamp
+* RETURNS:         time.Time, ok
+******************************************************************************/
+func (r *timestampRecognizer) parseWith(layout *timestampLayout, raw string) (time.Time, bool) {
+	if layout.Epoch {
+		return parseEpoch(raw)
+	}
+
+	// the layouts use T and a single space, fractions are accepted by
+	// time.Parse after a point or comma
+	value := raw
+	if layout.Name == "iso8601" || layout.Name == "slashed" {
+		value = strings.Replace(value, " ", "T", 1)
+	}
+	if layout.YearLess {
+		value = strings.Join(strings.Fields(value), " ")
+	}
+
+	for _, goLayout := range layout.Layouts {
+		timestamp, err := time.ParseInLocation(goLayout, value, r.location)
+		if err != nil {
+			continue
+		}
+		if layout.YearLess {
+			reference := r.reference
+			if reference.IsZero() {
+				reference = time.Now()
+			}
+			timestamp = inferYear(timestamp, reference.In(r.location))
+		}
+		return timestamp.UTC(), true
+	}
+
+	return time.Time{}, false
+}
+
+/******************************************************************************
+* FUNCTION:        order
+*
+* DESCRIPTION:     Returns the layouts to try, only the locked one once the
+*                  format of the file is known
+* INPUT:           None
+* RETURNS:         []int
+******************************************************************************/
+func (r *timestampRecognizer) order() []int {
+	r.mu.Lock()
+	locked := r.locked
+	r.mu.Unlock()
+
+	if locked >= 0 {
+		return []int{locked}
+	}
+
+	order := make([]int, len(timestampLayouts))
+	for i := range order {
+		order[i] = i
+	}
+	return order
+}
+
+/******************************************************************************
+* FUNCTION:        record
+*
+* DESCRIPTION:     Counts the layout of a parsed timestamp and locks the
+*                  most frequent one after TIMESTAMP_DETECT_SAMPLES
+* INPUT:           layout index
+* RETURNS:         void
+******************************************************************************/
+func (r *timestampRecognizer) record(index int) {
+	if !r.detect {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.locked >= 0 {
+		return
+	}
+	r.counts[index]++
+	r.samples++
+	if r.samples < TIMESTAMP_DETECT_SAMPLES {
+		return
+	}
+
+	best := 0
+	for i, count := range r.counts {
+		if count > r.counts[best] {
+			best = i
+		}
+	}
+	r.locked = best
+}
+
+/******************************************************************************
+* FUNCTION:        parseEpoch
+*
+* DESCRIPTION:     Parses epoch seconds (with an optional fraction),
+*                  millis, micros or nanos told apart by their length
+* INPUT:           raw timestamp
+* RETURNS:         time.Time, ok
+******************************************************************************/
+func parseEpoch(raw string) (time.Time, bool) {
+	seconds, fraction, _ := strings.Cut(raw, ".")
+
+	value, err := strconv.ParseInt(seconds, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	switch len(seconds) {
+	case 10:
+		nanos := int64(0)
+		if fraction != "" {
+			nanos, _ = strconv.ParseInt((fraction + "000000000")[:9], 10, 64)
+		}
+		return time.Unix(value, nanos).UTC(), true
+	case 13:
+		return time.UnixMilli(value).UTC(), true
+	case 16:
+		return time.UnixMicro(value).UTC(), true
+	case 19:
+		return time.Unix(0, value).UTC(), true
+	}
+
+	return time.Time{}, false
+}
+
+/******************************************************************************
+* FUNCTION:        inferYear
+*
+* DESCRIPTION:     Gives a year-less time the year of the reference, or the
+*                  previous one when that would put it in the future
+* INPUT:           timestamp, reference
+* RETURNS:         time.Time
+******************************************************************************/
+func inferYear(timestamp time.Time, reference time.Time) time.Time {
+	timestamp = time.Date(reference.Year(), timestamp.Month(), timestamp.Day(),
+		timestamp.Hour(), timestamp.Minute(), timestamp.Second(), timestamp.Nanosecond(), timestamp.Location())
+	if timestamp.After(reference.Add(TIMESTAMP_FUTURE_TOLERANCE)) {
+		timestamp = timestamp.AddDate(-1, 0, 0)
+	}
+	return timestamp
+}
+
+/******************************************************************************
+* FUNCTION:        isTimestampChar
+*
+* DESCRIPTION:     Helper function telling whether a timestamp would go on
+*                  with this character
+* INPUT:           character
+* RETURNS:         bool
+******************************************************************************/
+func isTimestampChar(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c == ':' || c == '.'
+}
+
+/******************************************************************************
+* FUNCTION:        loadSourceTimezone
+*
+* DESCRIPTION:     Loads the IANA timezone given with an upload, UTC when
+*                  empty. The server's local zone is not accepted
+* INPUT:           timezone
+* RETURNS:         *time.Location, error
+******************************************************************************/
+func loadSourceTimezone(timezone string) (*time.Location, error) {
+	if timezone == "" {
+		return time.UTC, nil
+	}
+	if timezone == "Local" {
+		return nil, ErrInvalidTimezone
+	}
+
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidTimezone, err)
+	}
+	return location, nil
+}
@@ -44,6 +44,34 @@ func JwtTokenCreatorForSupebaseStorage(filePath string) (token string, err error
 	return token, nil
 }
 
+/******************************************************************************
+* FUNCTION:        JwtTokenCreatorForUser
+*
+* DESCRIPTION:     This function is used to create a short lived JWT acting
+* 								 as the given user. Used by background ingestion which has
+* 								 no request token to upload with
+* INPUT:           userId
+* RETURNS:         string, err
+******************************************************************************/
+func JwtTokenCreatorForUser(userId string) (token string, err error) {
+	claims := jwt.MapClaims{
+		"sub":  userId,
+		"aud":  "authenticated",
+		"role": "authenticated",
+		"exp":  time.Now().Add(time.Hour).Unix(),
+		"iat":  time.Now().Unix(),
+	}
+
+	unsignedToken := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token, err = unsignedToken.SignedString([]byte(types.CmnGlblCfg.JWT_SECRET))
+
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
 /******************************************************************************
 * FUNCTION:        ConfigSupeBaseStorageClient
 * DESCRIPTION:     Function to set supebase storage client
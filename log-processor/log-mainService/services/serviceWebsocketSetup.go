@@ -106,3 +106,26 @@ func BroadcastMessage(message interface{}, mssgType, userId string) {
 	}
 
 }
+
+/******************************************************************************
+* FUNCTION:        CloseAllWebSockets
+*
+* DESCRIPTION:     Sends a close frame to every connected client and closes
+*                  the connection. Called during shutdown
+* INPUT:					 void
+* RETURNS:         void
+******************************************************************************/
+func CloseAllWebSockets() {
+	mu.Lock()
+	defer mu.Unlock()
+
+	closeMssg := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
+	for userId, conn := range clients {
+		err := conn.WriteControl(websocket.CloseMessage, closeMssg, time.Now().Add(time.Second))
+		if err != nil {
+			fmt.Printf("Failed to send close frame to user %s: %v\n", userId, err)
+		}
+		conn.Close()
+		delete(clients, userId)
+	}
+}
@@ -0,0 +1,81 @@
+/**************************************************************************
+ * File       	   : serviceWorkerShutdown.go
+ * DESCRIPTION     : This file contains the tracking of running task
+ *                   handlers, so that the shutdown waits for them before
+ *                   closing the db, and tells handlers cancelled by the
+ *                   shutdown apart from failed ones
+ * DATE            : 19-October-2026
+ **************************************************************************/
+
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+
+	"github.com/hibiken/asynq"
+)
+
+var (
+	taskHandlers   sync.WaitGroup
+	workerStopping atomic.Bool
+)
+
+/******************************************************************************
+* FUNCTION:        TrackTaskHandlers
+*
+* DESCRIPTION:     asynq middleware counting the running handlers. asynq
+*                  stops waiting for a handler once ShutdownTimeout is up
+*                  and puts its task back in the queue, the handler itself
+*                  keeps running until it sees its context cancelled
+* INPUT:           next handler
+* RETURNS:         asynq.Handler
+******************************************************************************/
+func TrackTaskHandlers(next asynq.Handler) asynq.Handler {
+	return asynq.HandlerFunc(func(ctx context.Context, t *asynq.Task) error {
+		taskHandlers.Add(1)
+		defer taskHandlers.Done()
+		return next.ProcessTask(ctx, t)
+	})
+}
+
+/******************************************************************************
+* FUNCTION:        StopTaskHandlers
+*
+* DESCRIPTION:     Marks the worker as shutting down, called before the
+*                  asynq server is shut down
+* INPUT:           None
+* RETURNS:         void
+******************************************************************************/
+func StopTaskHandlers() {
+	workerStopping.Store(true)
+}
+
+/******************************************************************************
+* FUNCTION:        WaitTaskHandlers
+*
+* DESCRIPTION:     Waits for the handlers still running once the asynq
+*                  server is shut down
+* INPUT:           None
+* RETURNS:         void
+******************************************************************************/
+func WaitTaskHandlers() {
+	taskHandlers.Wait()
+}
+
+/******************************************************************************
+* FUNCTION:        cancelledByShutdown
+*
+* DESCRIPTION:     Tells whether a handler was cancelled by the shutdown.
+*                  Its task is back in the queue, so it is neither failed
+*                  nor does it give up its fair scheduling slot. A task
+*                  running past its deadline fails with DeadlineExceeded
+*                  instead
+* INPUT:           ctx
+* RETURNS:         bool
+******************************************************************************/
+func cancelledByShutdown(ctx context.Context) bool {
+	return workerStopping.Load() && errors.Is(ctx.Err(), context.Canceled)
+}
@@ -0,0 +1,398 @@
+/**************************************************************************
+ * File       	   : fairScheduler.go
+ * DESCRIPTION     : This file contains functions that schedule tasks
+ *                   fairly between users. Tasks are first parked in a
+ *                   per-user pending list in redis and a dispatcher moves
+ *                   them to the asynq queues in weighted round-robin
+ *                   order while honouring the per-user concurrency caps
+ * DATE            : 19-October-2026
+ **************************************************************************/
+
+package tasks
+
+import (
+	"LOGProcessor/shared/types"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/martian/log"
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	fairKeyPrefix   = "logproc:fair:"
+	fairUsersKey    = fairKeyPrefix + "users"
+	fairLimitsKey   = fairKeyPrefix + "limits"
+	fairPendingKey  = fairKeyPrefix + "pending:"
+	fairInFlightKey = fairKeyPrefix + "inflight:"
+)
+
+type FairTask struct {
+	TaskID   string
+	UserId   string
+	Type     string
+	Payload  []byte
+	Queue    string
+	MaxRetry int
+	Timeout  time.Duration
+	// the deadline starts when the task is handed to asynq, not while it
+	// waits in the fair queue
+	DeadlineSec int
+	EnqueuedAt  time.Time
+}
+
+type TenantLimits struct {
+	MaxConcurrency int `json:"maxConcurrency"`
+	Weight         int `json:"weight"`
+}
+
+type TenantStatus struct {
+	UserId         string `json:"userId"`
+	Pending        int64  `json:"pending"`
+	InFlight       int64  `json:"inFlight"`
+	MaxConcurrency int    `json:"maxConcurrency"`
+	Weight         int    `json:"weight"`
+}
+
+// dispatchScript pops the next task of a user only when the user is below
+// its concurrency cap, so that several dispatchers can run side by side.
+// KEYS: pending list, inflight zset, users set
+// ARGV: cap, now (unix), stale before (unix), user id
+var dispatchScript = redis.NewScript(`
+redis.call('ZREMRANGEBYSCORE', KEYS[2], '-inf', ARGV[3])
+if redis.call('ZCARD', KEYS[2]) >= tonumber(ARGV[1]) then
+	return false
+end
+local item = redis.call('LPOP', KEYS[1])
+if not item then
+	redis.call('SREM', KEYS[3], ARGV[4])
+	return false
+end
+if redis.call('LLEN', KEYS[1]) == 0 then
+	redis.call('SREM', KEYS[3], ARGV[4])
+end
+redis.call('ZADD', KEYS[2], ARGV[2], cjson.decode(item)['TaskID'])
+return item
+`)
+
+/******************************************************************************
+* FUNCTION:        EnqueueFair
+*
+* DESCRIPTION:     This function parks a task in the pending list of its user.
+*                  The task id is assigned up front so that callers can
+*                  store it before the task reaches asynq
+* INPUT:					 ctx, fairTask
+* RETURNS:         taskId, error
+******************************************************************************/
+func EnqueueFair(ctx context.Context, fairTask *FairTask) (string, error) {
+	if fairTask.TaskID == "" {
+		fairTask.TaskID = uuid.NewString()
+	}
+	fairTask.EnqueuedAt = time.Now()
+
+	item, err := json.Marshal(fairTask)
+	if err != nil {
+		return "", err
+	}
+
+	pipe := types.Redis.RedisConn.TxPipeline()
+	pipe.RPush(ctx, fairPendingKey+fairTask.UserId, item)
+	pipe.SAdd(ctx, fairUsersKey, fairTask.UserId)
+	_, err = pipe.Exec(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to park task for user %s: %v", fairTask.UserId, err)
+	}
+
+	return fairTask.TaskID, nil
+}
+
+/******************************************************************************
+* FUNCTION:        RunFairDispatcher
+*
+* DESCRIPTION:     This function moves parked tasks to asynq until the
+*                  context is cancelled. Each round visits every user with
+*                  pending tasks and dispatches up to weight tasks for it
+* INPUT:					 ctx
+* RETURNS:         void
+******************************************************************************/
+func RunFairDispatcher(ctx context.Context) {
+	cfg := types.FairSchedCfg
+	ticker := time.NewTicker(cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		dispatched, err := dispatchRound(ctx)
+		if err != nil {
+			log.Errorf("fair dispatch round failed; err: %v", err)
+		}
+
+		// keep going while there is work, otherwise wait for the next tick
+		if dispatched > 0 && err == nil {
+			if ctx.Err() != nil {
+				return
+			}
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+/******************************************************************************
+* FUNCTION:        dispatchRound
+*
+* DESCRIPTION:     Helper function that does a single weighted round-robin
+*                  pass over the users with pending tasks
+* INPUT:           ctx
+* RETURNS:         dispatched count, error
+******************************************************************************/
+func dispatchRound(ctx context.Context) (int, error) {
+	rdb := types.Redis.RedisConn
+
+	users, err := rdb.SMembers(ctx, fairUsersKey).Result()
+	if err != nil {
+		return 0, err
+	}
+	sort.Strings(users)
+
+	dispatched := 0
+	for _, userId := range users {
+		limits := GetTenantLimits(ctx, userId)
+
+		for i := 0; i < limits.Weight; i++ {
+			fairTask, err := popTenantTask(ctx, userId, limits)
+			if err != nil {
+				return dispatched, err
+			}
+			if fairTask == nil {
+				break
+			}
+
+			err = enqueueToAsynq(ctx, fairTask)
+			if err != nil {
+				return dispatched, err
+			}
+			dispatched++
+		}
+	}
+
+	return dispatched, nil
+}
+
+/******************************************************************************
+* FUNCTION:        popTenantTask
+*
+* DESCRIPTION:     Helper function to pop the next task of a user if the
+*                  user is below its concurrency cap
+* INPUT:           ctx, userId, limits
+* RETURNS:         *FairTask (nil when nothing can be dispatched), error
+******************************************************************************/
+func popTenantTask(ctx context.Context, userId string, limits TenantLimits) (*FairTask, error) {
+	now := time.Now()
+	keys := []string{fairPendingKey + userId, fairInFlightKey + userId, fairUsersKey}
+
+	item, err := dispatchScript.Run(ctx, types.Redis.RedisConn, keys,
+		limits.MaxConcurrency, now.Unix(), now.Add(-types.FairSchedCfg.InFlightTTL).Unix(), userId).Text()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var fairTask FairTask
+	err = json.Unmarshal([]byte(item), &fairTask)
+	if err != nil {
+		return nil, fmt.Errorf("invalid parked task for user %s: %v", userId, err)
+	}
+
+	return &fairTask, nil
+}
+
+/******************************************************************************
+* FUNCTION:        enqueueToAsynq
+*
+* DESCRIPTION:     Helper function to enqueue a dispatched task to asynq. On
+*                  failure the task is put back at the head of the user list
+* INPUT:           ctx, fairTask
+* RETURNS:         error
+******************************************************************************/
+func enqueueToAsynq(ctx context.Context, fairTask *FairTask) error {
+	options := []asynq.Option{
+		asynq.TaskID(fairTask.TaskID),
+		asynq.Queue(fairTask.Queue),
+		asynq.MaxRetry(fairTask.MaxRetry),
+	}
+	if fairTask.Timeout > 0 {
+		options = append(options, asynq.Timeout(fairTask.Timeout))
+	}
+	if fairTask.DeadlineSec > 0 {
+		options = append(options, asynq.Deadline(time.Now().Add(time.Duration(fairTask.DeadlineSec)*time.Second)))
+	}
+
+	_, err := types.AsynqClient.AsynqClient.EnqueueContext(ctx, asynq.NewTask(fairTask.Type, fairTask.Payload), options...)
+	if err == nil || errors.Is(err, asynq.ErrTaskIDConflict) {
+		return nil
+	}
+
+	item, _ := json.Marshal(fairTask)
+	pipe := types.Redis.RedisConn.TxPipeline()
+	pipe.LPush(ctx, fairPendingKey+fairTask.UserId, item)
+	pipe.SAdd(ctx, fairUsersKey, fairTask.UserId)
+	pipe.ZRem(ctx, fairInFlightKey+fairTask.UserId, fairTask.TaskID)
+	if _, pErr := pipe.Exec(ctx); pErr != nil {
+		log.Errorf("failed to put back task %s; err: %v", fairTask.TaskID, pErr)
+	}
+
+	return fmt.Errorf("failed to enqueue task %s: %v", fairTask.TaskID, err)
+}
+
+/******************************************************************************
+* FUNCTION:        ReleaseTenantSlot
+*
+* DESCRIPTION:     This function frees the concurrency slot held by a task.
+*                  Must be called once the task will not be retried anymore
+* INPUT:					 userId, taskId
+* RETURNS:         void
+******************************************************************************/
+func ReleaseTenantSlot(userId, taskId string) {
+	err := types.Redis.RedisConn.ZRem(context.Background(), fairInFlightKey+userId, taskId).Err()
+	if err != nil {
+		log.Errorf("failed to release slot of task %s for user %s; err: %v", taskId, userId, err)
+	}
+}
+
+/******************************************************************************
+* FUNCTION:        GetTenantLimits
+*
+* DESCRIPTION:     This function returns the limits of a user, falling back
+*                  to the configured defaults
+* INPUT:					 ctx, userId
+* RETURNS:         TenantLimits
+******************************************************************************/
+func GetTenantLimits(ctx context.Context, userId string) TenantLimits {
+	limits := TenantLimits{
+		MaxConcurrency: types.FairSchedCfg.DefaultMaxConcurrency,
+		Weight:         types.FairSchedCfg.DefaultWeight,
+	}
+
+	raw, err := types.Redis.RedisConn.HGet(ctx, fairLimitsKey, userId).Result()
+	if err != nil {
+		return limits
+	}
+
+	var custom TenantLimits
+	if err := json.Unmarshal([]byte(raw), &custom); err != nil {
+		log.Errorf("invalid limits stored for user %s; err: %v", userId, err)
+		return limits
+	}
+	if custom.MaxConcurrency > 0 {
+		limits.MaxConcurrency = custom.MaxConcurrency
+	}
+	if custom.Weight > 0 {
+		limits.Weight = custom.Weight
+	}
+
+	return limits
+}
+
+/******************************************************************************
+* FUNCTION:        SetTenantLimits
+*
+* DESCRIPTION:     This function stores custom limits for a user
+* INPUT:					 ctx, userId, limits
+* RETURNS:         error
+******************************************************************************/
+func SetTenantLimits(ctx context.Context, userId string, limits TenantLimits) error {
+	if limits.MaxConcurrency <= 0 || limits.Weight <= 0 {
+		return fmt.Errorf("maxConcurrency and weight must be positive")
+	}
+
+	raw, err := json.Marshal(limits)
+	if err != nil {
+		return err
+	}
+
+	return types.Redis.RedisConn.HSet(ctx, fairLimitsKey, userId, raw).Err()
+}
+
+/******************************************************************************
+* FUNCTION:        DeleteTenantLimits
+*
+* DESCRIPTION:     This function removes the custom limits of a user so that
+*                  the defaults apply again
+* INPUT:					 ctx, userId
+* RETURNS:         error
+******************************************************************************/
+func DeleteTenantLimits(ctx context.Context, userId string) error {
+	return types.Redis.RedisConn.HDel(ctx, fairLimitsKey, userId).Err()
+}
+
+/******************************************************************************
+* FUNCTION:        GetTenantStatuses
+*
+* DESCRIPTION:     This function returns pending/in-flight counts and limits
+*                  of every user that has parked or running tasks
+* INPUT:					 ctx
+* RETURNS:         []TenantStatus, error
+******************************************************************************/
+func GetTenantStatuses(ctx context.Context) ([]TenantStatus, error) {
+	rdb := types.Redis.RedisConn
+
+	userSet := make(map[string]bool)
+	users, err := rdb.SMembers(ctx, fairUsersKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	for _, userId := range users {
+		userSet[userId] = true
+	}
+
+	iter := rdb.Scan(ctx, 0, fairInFlightKey+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		userSet[strings.TrimPrefix(iter.Val(), fairInFlightKey)] = true
+	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+
+	result := make([]TenantStatus, 0, len(userSet))
+	for userId := range userSet {
+		result = append(result, GetTenantStatus(ctx, userId))
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].UserId < result[j].UserId })
+
+	return result, nil
+}
+
+/******************************************************************************
+* FUNCTION:        GetTenantStatus
+*
+* DESCRIPTION:     This function returns pending/in-flight counts and limits
+*                  of one user
+* INPUT:					 ctx, userId
+* RETURNS:         TenantStatus
+******************************************************************************/
+func GetTenantStatus(ctx context.Context, userId string) TenantStatus {
+	rdb := types.Redis.RedisConn
+	limits := GetTenantLimits(ctx, userId)
+
+	return TenantStatus{
+		UserId:         userId,
+		Pending:        rdb.LLen(ctx, fairPendingKey+userId).Val(),
+		InFlight:       rdb.ZCard(ctx, fairInFlightKey+userId).Val(),
+		MaxConcurrency: limits.MaxConcurrency,
+		Weight:         limits.Weight,
+	}
+}
@@ -0,0 +1,166 @@
+/**************************************************************************
+ * File       	   : taskTiers.go
+ * DESCRIPTION     : This file contains functions that pick the queue,
+ *                   timeout, deadline and retry policy of a task based
+ *                   on the size of the file it processes
+ * DATE            : 19-October-2026
+ **************************************************************************/
+
+package tasks
+
+import (
+	"LOGProcessor/shared/types"
+	"encoding/json"
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/hibiken/asynq"
+)
+
+// DefaultTaskTiers are used when TASK_TIERS is not configured. The last
+// tier has no size bound and catches everything larger
+var DefaultTaskTiers = []types.TaskTier{
+	{
+		Name:         "small",
+		MaxSizeBytes: 100 * 1024 * 1024,
+		Queue:        QueueHigh,
+		TimeoutSec:   15 * 60,
+		DeadlineSec:  6 * 60 * 60,
+		MaxRetry:     3,
+		RetryBaseSec: 10,
+		RetryMaxSec:  10 * 60,
+	},
+	{
+		Name:         "medium",
+		MaxSizeBytes: 1073741824,
+		Queue:        QueueHigh,
+		TimeoutSec:   60 * 60,
+		DeadlineSec:  12 * 60 * 60,
+		MaxRetry:     3,
+		RetryBaseSec: 30,
+		RetryMaxSec:  30 * 60,
+	},
+	{
+		Name:         "large",
+		MaxSizeBytes: 0,
+		Queue:        QueueLow,
+		TimeoutSec:   4 * 60 * 60,
+		DeadlineSec:  24 * 60 * 60,
+		MaxRetry:     2,
+		RetryBaseSec: 60,
+		RetryMaxSec:  60 * 60,
+	},
+}
+
+/******************************************************************************
+* FUNCTION:        GetTaskTier
+*
+* DESCRIPTION:     This function returns the tier matching the file size.
+*                  Tiers are checked from the smallest bound upwards and a
+*                  bound of 0 means unbounded
+* INPUT:					 fileSizeBytes
+* RETURNS:         types.TaskTier
+******************************************************************************/
+func GetTaskTier(fileSizeBytes int64) types.TaskTier {
+	tiers := types.TaskTiers
+	if len(tiers) == 0 {
+		tiers = DefaultTaskTiers
+	}
+
+	sorted := make([]types.TaskTier, len(tiers))
+	copy(sorted, tiers)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].MaxSizeBytes == 0 {
+			return false
+		}
+		if sorted[j].MaxSizeBytes == 0 {
+			return true
+		}
+		return sorted[i].MaxSizeBytes < sorted[j].MaxSizeBytes
+	})
+
+	for _, tier := range sorted {
+		if tier.MaxSizeBytes == 0 || fileSizeBytes <= tier.MaxSizeBytes {
+			return tier
+		}
+	}
+
+	return sorted[len(sorted)-1]
+}
+
+/******************************************************************************
+* FUNCTION:        QueuesInUse
+*
+* DESCRIPTION:     Returns the queues tasks are enqueued to, the queue of
+*                  every tier and those of the source and alert tasks,
+*                  with what uses them
+* INPUT:           tiers
+* RETURNS:         map of queue name to its users
+******************************************************************************/
+func QueuesInUse(tiers []types.TaskTier) map[string][]string {
+	queues := map[string][]string{
+		QueueLow:  {TypeSourceIngest},
+		QueueHigh: {TypeAlertNotify},
+	}
+	for _, tier := range tiers {
+		queues[tier.Queue] = append(queues[tier.Queue], "tier "+tier.Name)
+	}
+	return queues
+}
+
+/******************************************************************************
+* FUNCTION:        RetryDelay
+*
+* DESCRIPTION:     This function is the asynq RetryDelayFunc. Log process
+*                  tasks back off exponentially from the base delay of
+*                  their tier up to its max delay, alert notifications from
+*                  ALERT_RETRY_BASE up to ALERT_RETRY_MAX, with up to 20%
+*                  jitter
+* INPUT:					 retry count, error, task
+* RETURNS:         time.Duration
+******************************************************************************/
+func RetryDelay(n int, e error, t *asynq.Task) time.Duration {
+	if t.Type() == TypeAlertNotify {
+		return backoffDelay(n, ALERT_RETRY_BASE, ALERT_RETRY_MAX)
+	}
+	if t.Type() != TypeLogProcess {
+		return asynq.DefaultRetryDelayFunc(n, e, t)
+	}
+
+	var payload LogProcessPayload
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		return asynq.DefaultRetryDelayFunc(n, e, t)
+	}
+
+	tier := GetTaskTier(payload.FileSizeBytes)
+	base := time.Duration(tier.RetryBaseSec) * time.Second
+	maxDelay := time.Duration(tier.RetryMaxSec) * time.Second
+	if base <= 0 {
+		return asynq.DefaultRetryDelayFunc(n, e, t)
+	}
+
+	return backoffDelay(n, base, maxDelay)
+}
+
+/******************************************************************************
+* FUNCTION:        backoffDelay
+*
+* DESCRIPTION:     Helper function doubling the base delay per retry up to
+*                  the max delay, no bound when it is 0, with up to 20%
+*                  jitter
+* INPUT:					 retry count, base, maxDelay
+* RETURNS:         time.Duration
+******************************************************************************/
+func backoffDelay(n int, base, maxDelay time.Duration) time.Duration {
+	delay := base
+	for i := 0; i < n && (maxDelay <= 0 || delay < maxDelay); i++ {
+		delay *= 2
+	}
+	if maxDelay > 0 && delay > maxDelay {
+		delay = maxDelay
+	}
+
+	jitter := time.Duration(rand.Int63n(int64(delay)/5 + 1))
+	return delay + jitter
+}
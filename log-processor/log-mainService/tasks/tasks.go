@@ -9,56 +9,148 @@ package tasks
 
 import (
 	"encoding/json"
+	"time"
 
 	"github.com/hibiken/asynq"
 )
 
 const (
-	TypeLogProcess = "log:process"
+	TypeLogProcess   = "log:process"
+	TypeSourceIngest = "source:ingest"
+	TypeAlertNotify  = "alert:notify"
+
+	// queues of the tasks not tied to a tier
+	QueueHigh = "high"
+	QueueLow  = "low"
+
+	// notifications are retried from ALERT_RETRY_BASE, doubling up to
+	// ALERT_RETRY_MAX
+	ALERT_MAX_RETRY  = 8
+	ALERT_RETRY_BASE = 10 * time.Second
+	ALERT_RETRY_MAX  = 10 * time.Minute
 )
 
+type AlertNotifyPayload struct {
+	DeliveryId int64
+}
+
+type SourceIngestPayload struct {
+	SourceId int64
+}
+
 type LogProcessPayload struct {
 	FileId        int64
 	FilePath      string
 	FileSizeBytes int64
 	UserId        string
+	ParseOptions  LogParseOptions
+}
+
+// LogParseOptions are the per upload parser settings
+type LogParseOptions struct {
+	// PatternId selects a user defined grok pattern instead of the
+	// built-in line formats
+	PatternId int64
+	// Timezone is the IANA zone of timestamps written without an offset,
+	// UTC when empty
+	Timezone string
+	// Encoding is the character encoding of the file, detected when empty
+	Encoding string
 }
 
 /******************************************************************************
 * FUNCTION:        NewLogProcessTask
 *
-* DESCRIPTION:     This function is used to create new asynq task
-* INPUT:					 fileId, filePath
-* RETURNS:         *asynq.Task, error
+* DESCRIPTION:     This function is used to create new log process task with
+*                  the queue, timeout, deadline and retries of its size
+*                  tier. The task is not enqueued to asynq directly, it is
+*                  handed to the fair scheduler via EnqueueFair
+* INPUT:					 filePath, userId, fileId, fileSizeBytes, parseOptions
+* RETURNS:         *FairTask, error
 ******************************************************************************/
-func NewLogProcessTask(filePath, userId string, fileId int64, fileSizeBytes int64) (*asynq.Task, error) {
+func NewLogProcessTask(filePath, userId string, fileId int64, fileSizeBytes int64, parseOptions LogParseOptions) (*FairTask, error) {
 	var (
-		err       error
-		queueName string
-		options   []asynq.Option
+		err error
 	)
 
-	if fileSizeBytes > 1073741824 {
-		queueName = "low"
-	} else {
-		queueName = "high"
-	}
+	tier := GetTaskTier(fileSizeBytes)
 
 	payload, err := json.Marshal(LogProcessPayload{
 		FileId:        fileId,
 		FilePath:      filePath,
 		FileSizeBytes: fileSizeBytes,
 		UserId:        userId,
+		ParseOptions:  parseOptions,
 	})
 
 	if err != nil {
 		return nil, err
 	}
 
-	options = []asynq.Option{
-		asynq.Queue(queueName),
-		asynq.MaxRetry(3),
+	fairTask := &FairTask{
+		UserId:      userId,
+		Type:        TypeLogProcess,
+		Payload:     payload,
+		Queue:       tier.Queue,
+		MaxRetry:    tier.MaxRetry,
+		Timeout:     time.Duration(tier.TimeoutSec) * time.Second,
+		DeadlineSec: tier.DeadlineSec,
+	}
+
+	return fairTask, nil
+}
+
+/******************************************************************************
+* FUNCTION:        NewSourceIngestTask
+*
+* DESCRIPTION:     This function is used to create the task that fetches new
+*                  objects of a registered ingest source. It is enqueued by
+*                  the periodic task manager or by a manual run
+* INPUT:					 sourceId
+* RETURNS:         *asynq.Task, []asynq.Option, error
+******************************************************************************/
+func NewSourceIngestTask(sourceId int64) (*asynq.Task, []asynq.Option, error) {
+	payload, err := json.Marshal(SourceIngestPayload{
+		SourceId: sourceId,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// the unique lock keeps two scheduler instances, or a manual run, from
+	// fetching the same source concurrently
+	options := []asynq.Option{
+		asynq.Queue(QueueLow),
+		asynq.MaxRetry(2),
+		asynq.Timeout(2 * time.Hour),
+		asynq.Unique(time.Hour),
+	}
+
+	return asynq.NewTask(TypeSourceIngest, payload), options, nil
+}
+
+/******************************************************************************
+* FUNCTION:        NewAlertNotifyTask
+*
+* DESCRIPTION:     This function is used to create the task that sends one
+*                  notification of a fired alert to its channel. Failed
+*                  sends are retried with backoff, see RetryDelay
+* INPUT:					 deliveryId
+* RETURNS:         *asynq.Task, []asynq.Option, error
+******************************************************************************/
+func NewAlertNotifyTask(deliveryId int64) (*asynq.Task, []asynq.Option, error) {
+	payload, err := json.Marshal(AlertNotifyPayload{
+		DeliveryId: deliveryId,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	options := []asynq.Option{
+		asynq.Queue(QueueHigh),
+		asynq.MaxRetry(ALERT_MAX_RETRY),
+		asynq.Timeout(time.Minute),
 	}
 
-	return asynq.NewTask(TypeLogProcess, payload, options...), nil
+	return asynq.NewTask(TypeAlertNotify, payload), options, nil
 }
@@ -248,6 +248,17 @@ func GetDataFromDB(query string, queryParams []interface{}) (results []map[strin
  * RETURNS: insertedID, err
  *****************************************************************************/
 func InsertAndReturnID(tx *sql.Tx, tableName string, data map[string]interface{}) (int64, error) {
+	return InsertAndReturnColumn(tx, tableName, "file_id", data)
+}
+
+/****************************************************************************
+ * FUNCTION: InsertAndReturnColumn
+ * DESCRIPTION: Inserts a record into the specified table and returns the
+ *              generated value of the given id column
+ * INPUT: tableName, idColumn, data
+ * RETURNS: insertedID, err
+ *****************************************************************************/
+func InsertAndReturnColumn(tx *sql.Tx, tableName, idColumn string, data map[string]interface{}) (int64, error) {
 	if len(data) == 0 {
 		return 0, errors.New("empty data received")
 	}
@@ -264,7 +275,7 @@ func InsertAndReturnID(tx *sql.Tx, tableName string, data map[string]interface{}
 		placeholders = append(placeholders, fmt.Sprintf("$%d", len(placeholders)+1))
 	}
 
-	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s) RETURNING file_id", tableName, strings.Join(keys, ", "), strings.Join(placeholders, ", "))
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s) RETURNING %s", tableName, strings.Join(keys, ", "), strings.Join(placeholders, ", "), idColumn)
 
 	var insertedID int64
 
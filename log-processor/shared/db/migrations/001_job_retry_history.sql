@@ -0,0 +1,12 @@
+-- One row per failed attempt of a log:process job
+CREATE TABLE IF NOT EXISTS job_retry_history (
+    id          BIGSERIAL PRIMARY KEY,
+    file_id     BIGINT      NOT NULL REFERENCES file_stats (file_id) ON DELETE CASCADE,
+    job_id      TEXT        NOT NULL,
+    attempt     INT         NOT NULL,
+    reason      TEXT        NOT NULL,
+    will_retry  BOOLEAN     NOT NULL,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_job_retry_history_job_id ON job_retry_history (job_id);
@@ -0,0 +1,31 @@
+-- Remote sources fetched on a cron schedule
+CREATE TABLE IF NOT EXISTS ingest_sources (
+    source_id          BIGSERIAL PRIMARY KEY,
+    user_id            TEXT        NOT NULL,
+    name               TEXT        NOT NULL,
+    source_type        TEXT        NOT NULL,
+    cron_spec          TEXT        NOT NULL,
+    config             JSONB       NOT NULL DEFAULT '{}',
+    -- AES-GCM encrypted with SOURCE_CREDENTIALS_KEY
+    credentials        TEXT,
+    enabled            BOOLEAN     NOT NULL DEFAULT true,
+    last_run_at        TIMESTAMPTZ,
+    last_run_status    TEXT,
+    last_run_ingested  INT,
+    last_error         TEXT,
+    created_at         TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_ingest_sources_user_id ON ingest_sources (user_id);
+
+-- Objects already pulled from a source, keyed by their version so that a
+-- changed object is ingested again
+CREATE TABLE IF NOT EXISTS ingest_source_objects (
+    id              BIGSERIAL PRIMARY KEY,
+    source_id       BIGINT      NOT NULL REFERENCES ingest_sources (source_id) ON DELETE CASCADE,
+    object_key      TEXT        NOT NULL,
+    object_version  TEXT        NOT NULL,
+    file_id         BIGINT      REFERENCES file_stats (file_id) ON DELETE SET NULL,
+    ingested_at     TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (source_id, object_key, object_version)
+);
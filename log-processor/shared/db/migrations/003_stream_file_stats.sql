@@ -0,0 +1,7 @@
+-- Push inputs write under one long-lived file_stats row per user and
+-- source, marked by stream_source
+ALTER TABLE file_stats ADD COLUMN IF NOT EXISTS stream_source TEXT;
+ALTER TABLE file_stats ADD COLUMN IF NOT EXISTS line_count BIGINT;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_file_stats_stream
+    ON file_stats (user_id, stream_source) WHERE stream_source IS NOT NULL;
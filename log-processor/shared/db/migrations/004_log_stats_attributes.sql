@@ -0,0 +1,6 @@
+-- Structured fields and trace context of log lines, e.g. from OTLP
+ALTER TABLE log_stats ADD COLUMN IF NOT EXISTS attributes JSONB;
+ALTER TABLE log_stats ADD COLUMN IF NOT EXISTS trace_id TEXT;
+ALTER TABLE log_stats ADD COLUMN IF NOT EXISTS span_id TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_log_stats_trace_id ON log_stats (trace_id) WHERE trace_id IS NOT NULL;
@@ -0,0 +1,7 @@
+-- Stream and kubernetes metadata of container log lines
+ALTER TABLE log_stats ADD COLUMN IF NOT EXISTS stream TEXT;
+ALTER TABLE log_stats ADD COLUMN IF NOT EXISTS namespace TEXT;
+ALTER TABLE log_stats ADD COLUMN IF NOT EXISTS pod TEXT;
+ALTER TABLE log_stats ADD COLUMN IF NOT EXISTS container TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_log_stats_pod ON log_stats (namespace, pod, container) WHERE pod IS NOT NULL;
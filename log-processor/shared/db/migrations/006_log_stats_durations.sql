@@ -0,0 +1,2 @@
+-- Duration fields of structured lines in milliseconds, e.g. {"dur": 1300}
+ALTER TABLE log_stats ADD COLUMN IF NOT EXISTS durations JSONB;
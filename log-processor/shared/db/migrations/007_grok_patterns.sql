@@ -0,0 +1,13 @@
+-- User defined grok patterns, selectable as the parser of an upload
+CREATE TABLE IF NOT EXISTS grok_patterns (
+    pattern_id   BIGSERIAL PRIMARY KEY,
+    user_id      TEXT        NOT NULL,
+    name         TEXT        NOT NULL,
+    pattern      TEXT        NOT NULL,
+    -- custom sub-patterns, {"NAME": "regex"}
+    definitions  JSONB,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (user_id, name)
+);
+
+ALTER TABLE file_stats ADD COLUMN IF NOT EXISTS grok_pattern_id BIGINT;
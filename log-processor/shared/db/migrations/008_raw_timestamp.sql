@@ -0,0 +1,5 @@
+-- Timestamp as written in the log line, err_timestamp holds it in UTC
+ALTER TABLE log_stats ADD COLUMN IF NOT EXISTS raw_timestamp TEXT;
+
+-- IANA timezone of zone-less timestamps of the upload
+ALTER TABLE file_stats ADD COLUMN IF NOT EXISTS source_timezone TEXT;
@@ -0,0 +1,35 @@
+-- Level as written in the line and the severity of the canonical level
+-- in log_level (0 UNKNOWN, 1 TRACE .. 6 FATAL)
+ALTER TABLE log_stats ADD COLUMN IF NOT EXISTS raw_level TEXT;
+ALTER TABLE log_stats ADD COLUMN IF NOT EXISTS severity SMALLINT NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS idx_log_stats_file_severity ON log_stats (file_id, severity);
+
+-- User defined mappings of raw levels to canonical levels
+CREATE TABLE IF NOT EXISTS level_mappings (
+    user_id     TEXT        NOT NULL,
+    -- upper-cased
+    raw_level   TEXT        NOT NULL,
+    level       TEXT        NOT NULL,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (user_id, raw_level)
+);
+
+-- Normalize the levels stored before, with the built-in text aliases
+UPDATE log_stats SET raw_level = log_level, log_level = CASE
+        WHEN upper(log_level) IN ('TRACE', 'TRC', 'FINEST', 'FINER') THEN 'TRACE'
+        WHEN upper(log_level) IN ('DEBUG', 'DBG', 'FINE', 'VERBOSE') THEN 'DEBUG'
+        WHEN upper(log_level) IN ('INFO', 'INF', 'INFORMATION', 'NOTICE', 'CONFIG') THEN 'INFO'
+        WHEN upper(log_level) IN ('WARN', 'WRN', 'WARNING') THEN 'WARN'
+        WHEN upper(log_level) IN ('ERROR', 'ERR', 'SEVERE') THEN 'ERROR'
+        WHEN upper(log_level) IN ('FATAL', 'FTL', 'CRIT', 'CRITICAL', 'ALERT', 'EMERG', 'EMERGENCY', 'PANIC') THEN 'FATAL'
+        ELSE 'UNKNOWN'
+    END
+WHERE raw_level IS NULL AND log_level IS NOT NULL;
+
+UPDATE log_stats SET severity = CASE log_level
+        WHEN 'TRACE' THEN 1 WHEN 'DEBUG' THEN 2 WHEN 'INFO' THEN 3
+        WHEN 'WARN' THEN 4 WHEN 'ERROR' THEN 5 WHEN 'FATAL' THEN 6
+        ELSE 0
+    END
+WHERE severity = 0;
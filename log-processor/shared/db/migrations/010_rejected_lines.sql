@@ -0,0 +1,17 @@
+-- Line counts of processed files, line_count (003) holds the total
+ALTER TABLE file_stats ADD COLUMN IF NOT EXISTS parsed_lines BIGINT;
+ALTER TABLE file_stats ADD COLUMN IF NOT EXISTS rejected_lines BIGINT;
+-- rejected lines per reason, {"no_match": 12, "bad_timestamp": 3}
+ALTER TABLE file_stats ADD COLUMN IF NOT EXISTS rejected_reasons JSONB;
+
+-- Bounded sample of the rejected lines of a file per reason
+CREATE TABLE IF NOT EXISTS rejected_line_samples (
+    sample_id    BIGSERIAL PRIMARY KEY,
+    file_id      BIGINT      NOT NULL,
+    reason       TEXT        NOT NULL,
+    line_number  BIGINT      NOT NULL,
+    line         TEXT        NOT NULL,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_rejected_line_samples_file ON rejected_line_samples (file_id, reason);
@@ -0,0 +1,16 @@
+-- Entries of lines cut at LOG_MAX_LINE_BYTES
+ALTER TABLE log_stats ADD COLUMN IF NOT EXISTS truncated BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE log_stats ADD COLUMN IF NOT EXISTS line_bytes BIGINT;
+ALTER TABLE log_stats ADD COLUMN IF NOT EXISTS line_number BIGINT;
+
+-- Full content of the oversize lines of a file in spill mode
+CREATE TABLE IF NOT EXISTS oversize_lines (
+    oversize_line_id BIGSERIAL PRIMARY KEY,
+    file_id          BIGINT      NOT NULL,
+    line_number      BIGINT      NOT NULL,
+    line_bytes       BIGINT      NOT NULL,
+    content          TEXT        NOT NULL,
+    created_at       TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_oversize_lines_file ON oversize_lines (file_id, line_number);
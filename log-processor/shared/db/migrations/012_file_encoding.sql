@@ -0,0 +1,4 @@
+-- Encoding given on upload (NULL when detected) and the encoding the file
+-- was read with
+ALTER TABLE file_stats ADD COLUMN IF NOT EXISTS source_encoding TEXT;
+ALTER TABLE file_stats ADD COLUMN IF NOT EXISTS encoding TEXT;
@@ -0,0 +1,14 @@
+-- Redaction rules of a user: a rule named after a built-in detector (no
+-- pattern) sets its action, other rules redact their regex
+CREATE TABLE IF NOT EXISTS redaction_rules (
+    rule_id     BIGSERIAL PRIMARY KEY,
+    user_id     TEXT        NOT NULL,
+    name        TEXT        NOT NULL,
+    pattern     TEXT,
+    action      TEXT        NOT NULL,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (user_id, name)
+);
+
+-- redacted values per rule, {"email": 12, "credit_card": 1}
+ALTER TABLE file_stats ADD COLUMN IF NOT EXISTS redaction_counts JSONB;
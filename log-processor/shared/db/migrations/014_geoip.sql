@@ -0,0 +1,8 @@
+-- GeoIP and ASN of the ip of an entry, from the local mmdb databases
+ALTER TABLE log_stats ADD COLUMN IF NOT EXISTS geo_country TEXT;
+ALTER TABLE log_stats ADD COLUMN IF NOT EXISTS geo_city TEXT;
+ALTER TABLE log_stats ADD COLUMN IF NOT EXISTS asn BIGINT;
+ALTER TABLE log_stats ADD COLUMN IF NOT EXISTS as_org TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_log_stats_file_geo_country ON log_stats (file_id, geo_country);
+CREATE INDEX IF NOT EXISTS idx_log_stats_file_asn ON log_stats (file_id, asn);
@@ -0,0 +1,28 @@
+-- The ip of an entry as inet, so searches can use network operators
+-- (ip <<= '10.0.0.0/8'). Values that are no address, which the old
+-- extraction stored ("999.1.1.1"), become NULL instead of failing the cast
+CREATE OR REPLACE FUNCTION pg_temp.ip_or_null(value TEXT) RETURNS INET AS $$
+BEGIN
+    RETURN host(NULLIF(value, '')::inet)::inet;
+EXCEPTION WHEN others THEN
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql IMMUTABLE;
+
+ALTER TABLE log_stats ALTER COLUMN ip TYPE INET USING pg_temp.ip_or_null(ip::text);
+
+CREATE INDEX IF NOT EXISTS idx_log_stats_ip ON log_stats USING GIST (ip inet_ops);
+
+-- names of the user's cidr sets containing the ip when it was stored
+ALTER TABLE log_stats ADD COLUMN IF NOT EXISTS ip_tags TEXT[];
+
+CREATE INDEX IF NOT EXISTS idx_log_stats_ip_tags ON log_stats USING GIN (ip_tags);
+
+-- Named sets of networks of a user (office, vpn, known scanners)
+CREATE TABLE IF NOT EXISTS cidr_sets (
+    user_id     TEXT        NOT NULL,
+    name        TEXT        NOT NULL,
+    cidrs       CIDR[]      NOT NULL,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (user_id, name)
+);
@@ -0,0 +1,28 @@
+-- Threat intel blocklists uploaded by admins, shared by every user
+CREATE TABLE IF NOT EXISTS threat_blocklists (
+    blocklist_id    BIGSERIAL PRIMARY KEY,
+    name            TEXT        NOT NULL UNIQUE,
+    -- text, csv or stix
+    format          TEXT        NOT NULL,
+    indicator_count INTEGER     NOT NULL DEFAULT 0,
+    -- values that were neither an address, a network nor a domain
+    skipped_count   INTEGER     NOT NULL DEFAULT 0,
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- kind is cidr (a single address is a /32 or /128) or domain, a domain
+-- matches its subdomains as well
+CREATE TABLE IF NOT EXISTS threat_indicators (
+    blocklist_id    BIGINT NOT NULL REFERENCES threat_blocklists (blocklist_id) ON DELETE CASCADE,
+    kind            TEXT   NOT NULL,
+    value           TEXT   NOT NULL,
+    PRIMARY KEY (blocklist_id, kind, value)
+);
+
+-- The indicator an entry touched and its list
+ALTER TABLE log_stats ADD COLUMN IF NOT EXISTS threat_indicator TEXT;
+ALTER TABLE log_stats ADD COLUMN IF NOT EXISTS threat_list TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_log_stats_file_threat ON log_stats (file_id) WHERE threat_indicator IS NOT NULL;
+
+ALTER TABLE file_stats ADD COLUMN IF NOT EXISTS threat_hits INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,47 @@
+-- What was learned of the completed jobs of a user, per scope: "user" for
+-- all of them, "source:<id>" per ingest source and "file:<pattern>" per
+-- file name with its digits left out
+CREATE TABLE IF NOT EXISTS anomaly_baselines (
+    user_id         TEXT             NOT NULL,
+    scope           TEXT             NOT NULL,
+    jobs            BIGINT           NOT NULL DEFAULT 0,
+    -- errors per hour of log time, mean and sum of squared deviations
+    hours           BIGINT           NOT NULL DEFAULT 0,
+    hour_mean       DOUBLE PRECISION NOT NULL DEFAULT 0,
+    hour_m2         DOUBLE PRECISION NOT NULL DEFAULT 0,
+    -- {"panic": 3, "timeout": 120}
+    keyword_counts  JSONB,
+    keyword_total   BIGINT           NOT NULL DEFAULT 0,
+    updated_at      TIMESTAMPTZ      NOT NULL DEFAULT now(),
+    PRIMARY KEY (user_id, scope)
+);
+
+-- Message templates of the warning and error lines seen in a scope
+CREATE TABLE IF NOT EXISTS anomaly_templates (
+    user_id         TEXT        NOT NULL,
+    scope           TEXT        NOT NULL,
+    template_hash   TEXT        NOT NULL,
+    template        TEXT        NOT NULL,
+    seen_count      BIGINT      NOT NULL DEFAULT 0,
+    first_seen      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    last_seen       TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (user_id, scope, template_hash)
+);
+
+-- error_spike, keyword_spike, new_keyword or new_template
+CREATE TABLE IF NOT EXISTS anomalies (
+    anomaly_id  BIGSERIAL PRIMARY KEY,
+    user_id     TEXT             NOT NULL,
+    file_id     BIGINT           NOT NULL REFERENCES file_stats (file_id) ON DELETE CASCADE,
+    scope       TEXT             NOT NULL,
+    kind        TEXT             NOT NULL,
+    -- the hour, keyword or template
+    subject     TEXT             NOT NULL,
+    score       DOUBLE PRECISION NOT NULL,
+    observed    DOUBLE PRECISION NOT NULL,
+    expected    DOUBLE PRECISION NOT NULL,
+    created_at  TIMESTAMPTZ      NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_anomalies_user_id ON anomalies (user_id, anomaly_id);
+CREATE INDEX IF NOT EXISTS idx_anomalies_file_id ON anomalies (file_id);
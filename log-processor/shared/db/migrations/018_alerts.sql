@@ -0,0 +1,65 @@
+-- Where the alerts of a user are sent: webhook, slack or email
+CREATE TABLE IF NOT EXISTS alert_channels (
+    channel_id    BIGSERIAL PRIMARY KEY,
+    user_id       TEXT        NOT NULL,
+    name          TEXT        NOT NULL,
+    channel_type  TEXT        NOT NULL,
+    -- {"url": ...} of a webhook, {"to": [...]} of an email channel
+    config        JSONB       NOT NULL DEFAULT '{}',
+    -- the webhook signing secret or the slack url, AES-GCM encrypted with
+    -- SOURCE_CREDENTIALS_KEY
+    credentials   TEXT,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (user_id, name)
+);
+
+-- Conditions checked at job completion and on stream ingestion, e.g.
+-- "error_count > 100", "keyword 'panic' seen", "error rate up 3x vs last run"
+CREATE TABLE IF NOT EXISTS alert_rules (
+    rule_id        BIGSERIAL PRIMARY KEY,
+    user_id        TEXT        NOT NULL,
+    name           TEXT        NOT NULL,
+    condition      TEXT        NOT NULL,
+    -- [1, 2]
+    channel_ids    JSONB       NOT NULL DEFAULT '[]',
+    cooldown_sec   INT         NOT NULL DEFAULT 300,
+    enabled        BOOLEAN     NOT NULL DEFAULT true,
+    last_fired_at  TIMESTAMPTZ,
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (user_id, name)
+);
+
+-- Every time a rule fired
+CREATE TABLE IF NOT EXISTS alert_history (
+    alert_id    BIGSERIAL PRIMARY KEY,
+    user_id     TEXT             NOT NULL,
+    rule_id     BIGINT           REFERENCES alert_rules (rule_id) ON DELETE SET NULL,
+    rule_name   TEXT             NOT NULL,
+    condition   TEXT             NOT NULL,
+    file_id     BIGINT           REFERENCES file_stats (file_id) ON DELETE SET NULL,
+    -- "job" or "stream"
+    origin      TEXT             NOT NULL,
+    observed    DOUBLE PRECISION NOT NULL,
+    message     TEXT             NOT NULL,
+    -- the body posted to webhooks
+    payload     JSONB            NOT NULL,
+    created_at  TIMESTAMPTZ      NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_alert_history_user_id ON alert_history (user_id, alert_id);
+
+-- One notification of an alert per channel, sent by the alert:notify task.
+-- status is pending, retrying, sent or failed
+CREATE TABLE IF NOT EXISTS alert_deliveries (
+    delivery_id  BIGSERIAL PRIMARY KEY,
+    alert_id     BIGINT      NOT NULL REFERENCES alert_history (alert_id) ON DELETE CASCADE,
+    channel_id   BIGINT      REFERENCES alert_channels (channel_id) ON DELETE SET NULL,
+    channel_name TEXT        NOT NULL,
+    status       TEXT        NOT NULL DEFAULT 'pending',
+    attempts     INT         NOT NULL DEFAULT 0,
+    last_error   TEXT,
+    sent_at      TIMESTAMPTZ,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_alert_deliveries_alert_id ON alert_deliveries (alert_id);
@@ -3,19 +3,57 @@ package models
 import "database/sql"
 
 type SvcConfig struct {
-	DbConn                *sql.DB
-	RUNNING_PORT          string
-	JWT_SECRET            string
-	SUPEBASE_API          string
-	SUPEBASE_API_KEY      string
-	SUPEBASE_BUCKET       string
-	SUPEBASE_STORAGE_BASE string
-	SUPEBASE_REST_BASE    string
-	DB_USER               string
-	DB_PASSWORD           string
-	DB_DATABASE           string
-	DB_PORT               string
-	DB_HOST               string
-	REDIS_ADDR            string
-	KEYWORD_CONFIG        string
+	DbConn                        *sql.DB
+	RUNNING_PORT                  string
+	JWT_SECRET                    string
+	SUPEBASE_API                  string
+	SUPEBASE_API_KEY              string
+	SUPEBASE_BUCKET               string
+	SUPEBASE_STORAGE_BASE         string
+	SUPEBASE_REST_BASE            string
+	DB_USER                       string
+	DB_PASSWORD                   string
+	DB_DATABASE                   string
+	DB_PORT                       string
+	DB_HOST                       string
+	REDIS_ADDR                    string
+	KEYWORD_CONFIG                string
+	WORKER_CONCURRENCY            string
+	WORKER_QUEUES                 string
+	WORKER_STRICT_PRIORITY        string
+	SHUTDOWN_GRACE_PERIOD_SEC     string
+	FAIR_DEFAULT_USER_CONCURRENCY string
+	FAIR_DEFAULT_USER_WEIGHT      string
+	TASK_TIERS                    string
+	SOURCE_CREDENTIALS_KEY        string
+	INGEST_MAX_BYTES              string
+	INGEST_MAX_REDIRECTS          string
+	INGEST_ALLOWED_CIDRS          string
+	SYSLOG_USER_ID                string
+	SYSLOG_UDP_ADDR               string
+	SYSLOG_TCP_ADDR               string
+	SYSLOG_TLS_ADDR               string
+	SYSLOG_TLS_CERT_FILE          string
+	SYSLOG_TLS_KEY_FILE           string
+	SYSLOG_MAX_MESSAGE_BYTES      string
+	FORWARD_ADDR                  string
+	FORWARD_USER_ID               string
+	FORWARD_SHARED_KEY            string
+	FORWARD_MAX_MESSAGE_BYTES     string
+	LOG_MAX_LINE_BYTES            string
+	LOG_OVERSIZE_MODE             string
+	LOG_SPILL_MAX_BYTES           string
+	REDACTION_DETECTORS           string
+	REDACTION_ACTION              string
+	REDACTION_HASH_KEY            string
+	GEOIP_CITY_DB                 string
+	GEOIP_ASN_DB                  string
+	GEOIP_RELOAD_INTERVAL_SEC     string
+	THREAT_RELOAD_INTERVAL_SEC    string
+	ALERT_STREAM_WINDOW_SEC       string
+	ALERT_DELIVERY_TIMEOUT_SEC    string
+	SMTP_ADDR                     string
+	SMTP_USERNAME                 string
+	SMTP_PASSWORD                 string
+	SMTP_FROM                     string
 }
@@ -3,17 +3,20 @@ package types
 import (
 	"LOGProcessor/shared/models"
 	"database/sql"
+	"net"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/hibiken/asynq"
+	"github.com/redis/go-redis/v9"
 )
 
 type ServiceApiRoute struct {
-	Method    string
-	Pattern   string
-	Handler   gin.HandlerFunc
-	IsAuthReq bool
+	Method     string
+	Pattern    string
+	Handler    gin.HandlerFunc
+	IsAuthReq  bool
+	IsAdminReq bool
 }
 
 type Data interface {
@@ -29,12 +32,17 @@ type ApiResponse struct {
 var (
 	Db          DbHandler
 	AsynqClient AsynqHdlr
+	Redis       RedisHandler
 )
 
 type DbHandler struct {
 	DbConn *sql.DB
 }
 
+type RedisHandler struct {
+	RedisConn *redis.Client
+}
+
 type AsynqHdlr struct {
 	AsynqClient    *asynq.Client
 	AsynqInspector *asynq.Inspector
@@ -43,9 +51,20 @@ type AsynqHdlr struct {
 type ApiRoutes []ServiceApiRoute
 
 var (
-	ExitChan   chan error
-	CmnGlblCfg models.SvcConfig
-	RateLimit  RateLimitConfig
+	ExitChan       chan error
+	CmnGlblCfg     models.SvcConfig
+	RateLimit      RateLimitConfig
+	WorkerCfg      WorkerConfig
+	FairSchedCfg   FairSchedulingConfig
+	TaskTiers      []TaskTier
+	IngestCfg      IngestConfig
+	SyslogCfg      SyslogConfig
+	ForwardCfg     ForwardConfig
+	ProcessingCfg  ProcessingConfig
+	RedactionCfg   RedactionConfig
+	GeoIPCfg       GeoIPConfig
+	ThreatIntelCfg ThreatIntelConfig
+	AlertCfg       AlertConfig
 )
 
 type PerRouteLimit struct {
@@ -65,3 +84,110 @@ type RateLimitConfig struct {
 type IPRateLimitOptions struct {
 	ClientTimeout time.Duration
 }
+
+type WorkerConfig struct {
+	Concurrency    int            `json:"concurrency"`
+	Queues         map[string]int `json:"queues"`
+	StrictPriority bool           `json:"strictPriority"`
+	// ShutdownGracePeriod is how long in-flight jobs get to finish before
+	// their context is cancelled and the task is put back in the queue
+	ShutdownGracePeriod time.Duration `json:"shutdownGracePeriod"`
+}
+
+type FairSchedulingConfig struct {
+	DefaultMaxConcurrency int           `json:"defaultMaxConcurrency"`
+	DefaultWeight         int           `json:"defaultWeight"`
+	PollInterval          time.Duration `json:"pollInterval"`
+	// InFlightTTL bounds how long a slot is held when a worker dies
+	// without releasing it
+	InFlightTTL time.Duration `json:"inFlightTTL"`
+}
+
+type TaskTier struct {
+	Name string `json:"name"`
+	// MaxSizeBytes is the inclusive upper bound of the tier, 0 is unbounded
+	MaxSizeBytes int64  `json:"maxSizeBytes"`
+	Queue        string `json:"queue"`
+	TimeoutSec   int    `json:"timeoutSec"`
+	DeadlineSec  int    `json:"deadlineSec"`
+	MaxRetry     int    `json:"maxRetry"`
+	RetryBaseSec int    `json:"retryBaseSec"`
+	RetryMaxSec  int    `json:"retryMaxSec"`
+}
+
+type IngestConfig struct {
+	// MaxBytes caps the size of a single file pulled from a remote source
+	MaxBytes int64 `json:"maxBytes"`
+	// MaxRedirects bounds the redirects followed when fetching a url
+	MaxRedirects int `json:"maxRedirects"`
+	// AllowedNets are internal ranges that urls may still point to
+	AllowedNets []*net.IPNet `json:"-"`
+}
+
+type SyslogConfig struct {
+	// UserId owns the streams of every syslog message received
+	UserId          string `json:"userId"`
+	UDPAddr         string `json:"udpAddr"`
+	TCPAddr         string `json:"tcpAddr"`
+	TLSAddr         string `json:"tlsAddr"`
+	TLSCertFile     string `json:"tlsCertFile"`
+	TLSKeyFile      string `json:"tlsKeyFile"`
+	MaxMessageBytes int    `json:"maxMessageBytes"`
+}
+
+type ForwardConfig struct {
+	// UserId owns the streams of every forwarded record
+	UserId string `json:"userId"`
+	Addr   string `json:"addr"`
+	// SharedKey enables the handshake of the forward protocol when set
+	SharedKey       string `json:"-"`
+	MaxMessageBytes int    `json:"maxMessageBytes"`
+}
+
+type ProcessingConfig struct {
+	// MaxLineBytes is the longest line parsed as is, longer lines are cut
+	MaxLineBytes int `json:"maxLineBytes"`
+	// OversizeMode is "truncate" or "spill", spill keeps the full content
+	// of cut lines aside
+	OversizeMode string `json:"oversizeMode"`
+	// SpillMaxBytes bounds the bytes spilled per file (per chunk of large
+	// files), later oversize lines are only truncated
+	SpillMaxBytes int64 `json:"spillMaxBytes"`
+}
+
+type RedactionConfig struct {
+	// Detectors are the built-in detectors enabled for every user
+	Detectors []string `json:"detectors"`
+	// Action of the enabled detectors, "mask", "hash" or "drop"
+	Action string `json:"action"`
+	// HashKey keys the hash action, which is unavailable without it
+	HashKey string `json:"-"`
+}
+
+type GeoIPConfig struct {
+	// CityDBPath is a GeoLite2/GeoIP2 City or Country mmdb file
+	CityDBPath string `json:"cityDbPath"`
+	// ASNDBPath is a GeoLite2 ASN or GeoIP2 ISP mmdb file
+	ASNDBPath string `json:"asnDbPath"`
+	// the files are checked for changes this often
+	ReloadInterval time.Duration `json:"reloadInterval"`
+}
+
+type ThreatIntelConfig struct {
+	// the blocklists are checked for changes this often
+	ReloadInterval time.Duration `json:"reloadInterval"`
+}
+
+type AlertConfig struct {
+	// StreamWindow is the window the threshold and "vs last run" rules of
+	// a stream are checked over
+	StreamWindow time.Duration `json:"streamWindow"`
+	// DeliveryTimeout bounds one webhook post or email
+	DeliveryTimeout time.Duration `json:"deliveryTimeout"`
+	// SMTPAddr is the host:port of the mail server, email channels are
+	// unavailable without it
+	SMTPAddr     string `json:"smtpAddr"`
+	SMTPUsername string `json:"smtpUsername"`
+	SMTPPassword string `json:"-"`
+	SMTPFrom     string `json:"smtpFrom"`
+}